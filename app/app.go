@@ -3,6 +3,7 @@ package app
 import (
 	"BookVault-API/database"
 	"BookVault-API/handler"
+	"BookVault-API/jwt"
 	"BookVault-API/middleware"
 	"BookVault-API/service"
 	
@@ -10,6 +11,11 @@ import (
 	"gorm.io/gorm"
 )
 
+// Routes documents the endpoints. A "[{userID}/]" segment is optional: without it the
+// request acts on the authenticated user, and only admins may name another user.
+// Lists marked "{page}" are paginated: they accept limit (at most 100), cursor,
+// sort (prefixed with "-" for descending; rankings such as helpful are always descending),
+// created_after and created_before, and respond with {"items", "next_cursor", "total"}.
 var Routes =  map[string]string {
 	"NotFound": 		"/",
 	"Home":				"/user",
@@ -17,33 +23,84 @@ var Routes =  map[string]string {
 
 	"Register":			"/user/register",
 	"Login":			"/user/login",
-	"CreateDetails":	"/user/createDetails/{userID}",
-	"GetUserByID":		"/user/getById/{userID}",
+	"Refresh":			"/user/refresh",
+	"Logout":			"/user/logout",
+	"CreateDetails":	"/user/createDetails[/{userID}]",
+	"GetUserByID":		"/user/getById[/{userID}]",
+	"DeleteUser":		"/user/delete/{userID}",
 
 	"CreateBook":		"/book/create",
-	"GetByTitle":		"/book?title={bookTitle}",
-	"GetBooks":			"/book/all",
-	"GetBooksByAuthor":	"/book/author?author={bookAuthor}",
-	"UpdateStock":		"/book/updateStock/{bookID}",
-
-	"AddToCart":		"/cart/add/{userID}/{bookID}",
-	"ClearCart":		"/cart/clear/{userID}",
-	"RemoveFromCart":	"/cart/remove/{userID}/{bookID}",
-	"UpdateQuantity":	"/cart/update/{userID}/{bookID}?quantity={quantity}",
-	"GetCart":			"/cart/{cartID}",
-
-	"CreateOrder":		"/order/create/{userID}?address={address}",
+	"GetByTitle":		"/book?title={bookTitle}&currency={currency}",
+	"GetByISBN":		"/book/isbn/{isbn}?currency={currency}",
+	"GetBooks":			"/book/all?author={author}&min_price={price}&max_price={price}&in_stock={bool}&currency={currency}&{page}",
+	"GetBooksByAuthor":	"/book/author?author={bookAuthor}&currency={currency}",
+	"SearchBooks":		"/book/search?q={query}&currency={currency}&{page}",
+	"GetBooksByCategory":"/book/category/{categoryID}?includeDescendants={bool}&currency={currency}&{page}",
+	"SetBookCategories":"/book/categories/{bookID}",
+	"SetBookAuthors":	"/book/authors/{bookID}",
+	"UpdateStock":		"/book/updateStock/{bookID}?stock={stock}|delta={delta}",
+	"UpdateBook":		"/book/update/{bookID}",
+	"DeleteBook":		"/book/delete/{bookID}",
+	"RestoreBook":		"/book/restore/{bookID}",
+	"GetDeletedBooks":	"/book/deleted",
+	"ImportBooks":		"/book/import?format={csv|jsonl}&dryRun={bool}",
+	"ExportBooks":		"/book/export?format={csv|jsonl|onix}&author={author}&min_price={price}&max_price={price}&in_stock={bool}",
+
+	"CreateAuthor":		"/author/create",
+	"GetAuthor":		"/author/{authorID}",
+	"UpdateAuthor":		"/author/update/{authorID}",
+	"DeleteAuthor":		"/author/delete/{authorID}",
+	"MergeAuthors":		"/author/merge/{authorID}/{intoAuthorID}",
+
+	"CreateCategory":	"/category/create",
+	"GetCategoryTree":	"/category/all",
+	"UpdateCategory":	"/category/update/{categoryID}",
+	"DeleteCategory":	"/category/delete/{categoryID}",
+
+	"AddToCart":		"/cart/add/[{userID}/]{bookID}",
+	"ClearCart":		"/cart/clear[/{userID}]",
+	"RemoveFromCart":	"/cart/remove/[{userID}/]{bookID}",
+	"UpdateQuantity":	"/cart/update/[{userID}/]{bookID}?quantity={quantity}",
+	"SetCartLines":		"/cart/lines[/{userID}]",
+	"GetCart":			"/cart/{cartID}?currency={currency}",
+	"ApplyCoupon":		"/cart/coupon/apply[/{userID}]?code={code}",
+	"RemoveCoupon":		"/cart/coupon/remove[/{userID}]?code={code}",
+
+	"CreateCoupon":		"/coupon/create",
+	"GetCoupons":		"/coupon/all",
+	"GetCoupon":		"/coupon/{couponID}",
+	"UpdateCoupon":		"/coupon/update/{couponID}",
+	"DeleteCoupon":		"/coupon/delete/{couponID}",
+
+	"CreateOrder":		"/order/create[/{userID}]?address={address}&currency={currency}&expected_total={total}",
 	"CancelOrder":		"/order/cancel/{orderID}",
-	"GetOrder":			"/order/{orderID}",
-	"GetUserOrders":	"/order/user/{userID}",
-	"GetOrdersByStatus":"/order/status/?status={status}",
+	"GetOrder":			"/order/{orderID}?currency={currency}",
+	"GetUserOrders":	"/order/user[/{userID}]?currency={currency}&{page}",
+	"GetOrdersByStatus":"/order/status/?status={status}&currency={currency}&{page}",
 	"UpdateStatus":		"/order/update/{orderID}?status={status}",
 
-	"AddReview":		"/review/add/{userID}/{bookID}",
-	"GetReviewsByBook": "/review/get/{bookID}",
+	"AddReview":		"/review/add/[{userID}/]{bookID}",
+	"GetReviewsByBook": "/review/get/{bookID}?verified={bool}&{page}",
 	"GetReviewsByUser": "/review/getByUser/{userID}",
-	"UpdateReview":		"/review/update/{userID}/{bookID}",
+	"UpdateReview":		"/review/update/[{userID}/]{bookID}",
+	"SetReview":		"/review/set/[{userID}/]{bookID}",
 	"DeleteReviewByID":	"/review/delete/{reviewID}",
+	"ReportReview":		"/review/report/{reviewID}",
+	"VoteReview":		"/review/vote/{reviewID}?helpful={bool}",
+	"RetractVote":		"/review/unvote/{reviewID}",
+	"GetModerationQueue":"/review/queue?{page}",
+	"ApproveReview":	"/review/approve/{reviewID}",
+	"RejectReview":		"/review/reject/{reviewID}",
+
+	"GetReviewSettings":	"/settings/reviews",
+	"UpdateReviewSettings":	"/settings/reviews/update",
+	"GetRateSettings":		"/settings/rates",
+	"UpdateRateSettings":	"/settings/rates/update",
+
+	"SetRate":			"/rates/set",
+	"GetRates":			"/rates",
+	"DeleteRate":		"/rates/delete/{base}/{quote}",
+	"ImportRates":		"/rates/import",
 }
 
 type App struct {
@@ -51,16 +108,26 @@ type App struct {
 
 	UserService 	service.UserService
 	BookService 	service.BookService
+	CategoryService	service.CategoryService
+	AuthorService	service.AuthorService
 	CartService		service.CartService
+	CouponService	service.CouponService
 	OrderService	service.OrderService
 	ReviewService	service.ReviewService
+	SettingsService	service.SettingsService
+	ExchangeRateService	service.ExchangeRateService
 
 	HomeHandler 	*handler.HomeHandler
 	UserHandler		*handler.UserHandler
 	BookHandler 	*handler.BookHandler
+	CategoryHandler	*handler.CategoryHandler
+	AuthorHandler	*handler.AuthorHandler
 	CartHandler		*handler.CartHandler
+	CouponHandler	*handler.CouponHandler
 	OrderHandler	*handler.OrderHandler
 	ReviewHandler	*handler.ReviewHandler
+	SettingsHandler	*handler.SettingsHandler
+	ExchangeRateHandler	*handler.ExchangeRateHandler
 }
 
 
@@ -69,31 +136,53 @@ func NewApp() *App {
 
 	userService 	:= service.NewUserService(db)
 	bookService 	:= service.NewBookService(db)
+	categoryService	:= service.NewCategoryService(db)
+	authorService	:= service.NewAuthorService(db)
 	cartService 	:= service.NewCartService(db)
+	couponService	:= service.NewCouponService(db)
 	orderService 	:= service.NewOrderService(db)
 	reviewService 	:= service.NewReviewService(db)
+	settingsService	:= service.NewSettingsService(db)
+	rateService		:= service.NewExchangeRateService(db)
+
+	jwt.SetRevocationChecker(userService.IsTokenRevoked)
 
 	homeHandler 	:= handler.NewHomeHandler()
 	userHandler 	:= handler.NewUserHandler(userService)
-	bookHandler 	:= handler.NewBookHandler(bookService)
-	cartHandler 	:= handler.NewCartHandler(cartService)
-	orderHandler 	:= handler.NewOrderHandler(orderService)
+	bookHandler 	:= handler.NewBookHandler(bookService, rateService)
+	categoryHandler	:= handler.NewCategoryHandler(categoryService)
+	authorHandler	:= handler.NewAuthorHandler(authorService)
+	cartHandler 	:= handler.NewCartHandler(cartService, rateService)
+	couponHandler	:= handler.NewCouponHandler(couponService)
+	orderHandler 	:= handler.NewOrderHandler(orderService, rateService)
 	reviewHandler	:= handler.NewReviewHandler(reviewService)
+	settingsHandler	:= handler.NewSettingsHandler(settingsService)
+	rateHandler		:= handler.NewExchangeRateHandler(rateService)
 
 	return &App{
 		DB: db,
 		UserService: userService,
 		BookService: bookService,
+		CategoryService: categoryService,
+		AuthorService: authorService,
 		CartService: cartService,
+		CouponService: couponService,
 		OrderService: orderService,
 		ReviewService: reviewService,
+		SettingsService: settingsService,
+		ExchangeRateService: rateService,
 
 		HomeHandler: homeHandler,
 		UserHandler: userHandler,
 		BookHandler: bookHandler,
+		CategoryHandler: categoryHandler,
+		AuthorHandler: authorHandler,
 		CartHandler: cartHandler,
+		CouponHandler: couponHandler,
 		OrderHandler: orderHandler,
 		ReviewHandler: reviewHandler,
+		SettingsHandler: settingsHandler,
+		ExchangeRateHandler: rateHandler,
 	}
 }
 
@@ -109,27 +198,72 @@ func (a *App) Init() {
 	//userHandlers
 	mux.HandleFunc("/user/register", 		a.UserHandler.Register)
 	mux.HandleFunc("/user/login", 			a.UserHandler.Login)
+	mux.HandleFunc("/user/refresh", 		a.UserHandler.Refresh)
+	mux.HandleFunc("/user/logout", 			middleware.AuthMiddleware("admin", "user")(a.UserHandler.Logout))
+	mux.HandleFunc("/user/createDetails",	middleware.AuthMiddleware("admin", "user")(a.UserHandler.CreateDetails))
 	mux.HandleFunc("/user/createDetails/",	middleware.AuthMiddleware("admin", "user")(a.UserHandler.CreateDetails))
+	mux.HandleFunc("/user/getById", 		middleware.AuthMiddleware("admin", "user")(a.UserHandler.GetUserByID))
 	mux.HandleFunc("/user/getById/", 		middleware.AuthMiddleware("admin", "user")(a.UserHandler.GetUserByID))
+	mux.HandleFunc("/user/delete/", 		middleware.AuthMiddleware("admin")(a.UserHandler.DeleteUser))
 
 	//bookHandlers
 	mux.HandleFunc("/book/create", 			middleware.AuthMiddleware("admin")(a.BookHandler.CreateBook))
 	mux.HandleFunc("/book", 				middleware.AuthMiddleware("admin", "user")(a.BookHandler.GetByTitle))
+	mux.HandleFunc("/book/isbn/", 			middleware.AuthMiddleware("admin", "user")(a.BookHandler.GetByISBN))
 	mux.HandleFunc("/book/all", 			middleware.AuthMiddleware("admin", "user")(a.BookHandler.GetBooks))
 	mux.HandleFunc("/book/author", 			middleware.AuthMiddleware("admin", "user")(a.BookHandler.GetBooksByAuthor))
+	mux.HandleFunc("/book/search", 			middleware.AuthMiddleware("admin", "user")(a.BookHandler.SearchBooks))
+	mux.HandleFunc("/book/category/", 		middleware.AuthMiddleware("admin", "user")(a.BookHandler.GetBooksByCategory))
+	mux.HandleFunc("/book/categories/", 	middleware.AuthMiddleware("admin")(a.BookHandler.SetBookCategories))
+	mux.HandleFunc("/book/authors/", 		middleware.AuthMiddleware("admin")(a.BookHandler.SetBookAuthors))
 	mux.HandleFunc("/book/updateStock/", 	middleware.AuthMiddleware("admin")(a.BookHandler.UpdateStock))
+	mux.HandleFunc("/book/update/", 		middleware.AuthMiddleware("admin")(a.BookHandler.UpdateBook))
+	mux.HandleFunc("/book/delete/", 		middleware.AuthMiddleware("admin")(a.BookHandler.DeleteBook))
+	mux.HandleFunc("/book/restore/", 		middleware.AuthMiddleware("admin")(a.BookHandler.RestoreBook))
+	mux.HandleFunc("/book/deleted", 		middleware.AuthMiddleware("admin")(a.BookHandler.GetDeletedBooks))
+	mux.HandleFunc("/book/import", 			middleware.AuthMiddleware("admin")(a.BookHandler.ImportBooks))
+	mux.HandleFunc("/book/export", 			middleware.AuthMiddleware("admin")(a.BookHandler.ExportBooks))
+
+	//authorHandlers
+	mux.HandleFunc("/author/create", 		middleware.AuthMiddleware("admin")(a.AuthorHandler.CreateAuthor))
+	mux.HandleFunc("/author/", 				middleware.AuthMiddleware("admin", "user")(a.AuthorHandler.GetAuthor))
+	mux.HandleFunc("/author/update/", 		middleware.AuthMiddleware("admin")(a.AuthorHandler.UpdateAuthor))
+	mux.HandleFunc("/author/delete/", 		middleware.AuthMiddleware("admin")(a.AuthorHandler.DeleteAuthor))
+	mux.HandleFunc("/author/merge/", 		middleware.AuthMiddleware("admin")(a.AuthorHandler.MergeAuthors))
+
+	//categoryHandlers
+	mux.HandleFunc("/category/create", 		middleware.AuthMiddleware("admin")(a.CategoryHandler.CreateCategory))
+	mux.HandleFunc("/category/all", 		middleware.AuthMiddleware("admin", "user")(a.CategoryHandler.GetCategoryTree))
+	mux.HandleFunc("/category/update/", 	middleware.AuthMiddleware("admin")(a.CategoryHandler.UpdateCategory))
+	mux.HandleFunc("/category/delete/", 	middleware.AuthMiddleware("admin")(a.CategoryHandler.DeleteCategory))
 
 	//cartHandlers
 	mux.HandleFunc("/cart/add/", 		middleware.AuthMiddleware("admin", "user")(a.CartHandler.AddToCart))
+	mux.HandleFunc("/cart/clear", 		middleware.AuthMiddleware("admin", "user")(a.CartHandler.ClearCart))
 	mux.HandleFunc("/cart/clear/", 		middleware.AuthMiddleware("admin", "user")(a.CartHandler.ClearCart))
 	mux.HandleFunc("/cart/remove/", 	middleware.AuthMiddleware("admin", "user")(a.CartHandler.RemoveFromCart))
 	mux.HandleFunc("/cart/update/", 	middleware.AuthMiddleware("admin", "user")(a.CartHandler.UpdateQuantity))
+	mux.HandleFunc("/cart/lines", 		middleware.AuthMiddleware("admin", "user")(a.CartHandler.SetCartLines))
+	mux.HandleFunc("/cart/lines/", 		middleware.AuthMiddleware("admin", "user")(a.CartHandler.SetCartLines))
+	mux.HandleFunc("/cart/coupon/apply", 	middleware.AuthMiddleware("admin", "user")(a.CartHandler.ApplyCoupon))
+	mux.HandleFunc("/cart/coupon/apply/", 	middleware.AuthMiddleware("admin", "user")(a.CartHandler.ApplyCoupon))
+	mux.HandleFunc("/cart/coupon/remove", 	middleware.AuthMiddleware("admin", "user")(a.CartHandler.RemoveCoupon))
+	mux.HandleFunc("/cart/coupon/remove/", 	middleware.AuthMiddleware("admin", "user")(a.CartHandler.RemoveCoupon))
 	mux.HandleFunc("/cart/", 			middleware.AuthMiddleware("admin", "user")(a.CartHandler.GetCart))
 
+	//couponHandlers
+	mux.HandleFunc("/coupon/create", 		middleware.AuthMiddleware("admin")(a.CouponHandler.CreateCoupon))
+	mux.HandleFunc("/coupon/all", 			middleware.AuthMiddleware("admin")(a.CouponHandler.GetCoupons))
+	mux.HandleFunc("/coupon/", 				middleware.AuthMiddleware("admin")(a.CouponHandler.GetCoupon))
+	mux.HandleFunc("/coupon/update/", 		middleware.AuthMiddleware("admin")(a.CouponHandler.UpdateCoupon))
+	mux.HandleFunc("/coupon/delete/", 		middleware.AuthMiddleware("admin")(a.CouponHandler.DeleteCoupon))
+
 	//orderHandlers
+	mux.HandleFunc("/order/create", 	middleware.AuthMiddleware("admin", "user")(a.OrderHandler.CreateOrder))
 	mux.HandleFunc("/order/create/", 	middleware.AuthMiddleware("admin", "user")(a.OrderHandler.CreateOrder))
 	mux.HandleFunc("/order/cancel/", 	middleware.AuthMiddleware("admin", "user")(a.OrderHandler.CancelOrder))
 	mux.HandleFunc("/order/", 			middleware.AuthMiddleware("admin", "user")(a.OrderHandler.GetOrder))
+	mux.HandleFunc("/order/user", 		middleware.AuthMiddleware("admin", "user")(a.OrderHandler.GetUserOrders))
 	mux.HandleFunc("/order/user/", 		middleware.AuthMiddleware("admin", "user")(a.OrderHandler.GetUserOrders))
 	mux.HandleFunc("/order/status/", 	middleware.AuthMiddleware("admin", "user")(a.OrderHandler.GetOrdersByStatus))
 	mux.HandleFunc("/order/update/", 	middleware.AuthMiddleware("admin")(a.OrderHandler.UpdateStatus))
@@ -139,7 +273,26 @@ func (a *App) Init() {
 	mux.HandleFunc("/review/get/", 			middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.GetReviewsByBook))
 	mux.HandleFunc("/review/getByUser/", 	middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.GetReviewsByUser))
 	mux.HandleFunc("/review/update/", 		middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.UpdateReview))
-	mux.HandleFunc("/review/delete/", 		middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.DeleteReviewByID))	
+	mux.HandleFunc("/review/set/", 			middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.SetReview))
+	mux.HandleFunc("/review/delete/", 		middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.DeleteReviewByID))
+	mux.HandleFunc("/review/report/", 		middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.ReportReview))
+	mux.HandleFunc("/review/vote/", 		middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.VoteReview))
+	mux.HandleFunc("/review/unvote/", 		middleware.AuthMiddleware("admin", "user")(a.ReviewHandler.RetractVote))
+	mux.HandleFunc("/review/queue", 		middleware.AuthMiddleware("admin")(a.ReviewHandler.GetModerationQueue))
+	mux.HandleFunc("/review/approve/", 		middleware.AuthMiddleware("admin")(a.ReviewHandler.ApproveReview))
+	mux.HandleFunc("/review/reject/", 		middleware.AuthMiddleware("admin")(a.ReviewHandler.RejectReview))
+
+	//settingsHandlers
+	mux.HandleFunc("/settings/reviews", 		middleware.AuthMiddleware("admin")(a.SettingsHandler.GetReviewSettings))
+	mux.HandleFunc("/settings/reviews/update", 	middleware.AuthMiddleware("admin")(a.SettingsHandler.UpdateReviewSettings))
+	mux.HandleFunc("/settings/rates", 			middleware.AuthMiddleware("admin")(a.SettingsHandler.GetRateSettings))
+	mux.HandleFunc("/settings/rates/update", 	middleware.AuthMiddleware("admin")(a.SettingsHandler.UpdateRateSettings))
+
+	//exchangeRateHandlers
+	mux.HandleFunc("/rates", 				middleware.AuthMiddleware("admin", "user")(a.ExchangeRateHandler.GetRates))
+	mux.HandleFunc("/rates/set", 			middleware.AuthMiddleware("admin")(a.ExchangeRateHandler.SetRate))
+	mux.HandleFunc("/rates/delete/", 		middleware.AuthMiddleware("admin")(a.ExchangeRateHandler.DeleteRate))
+	mux.HandleFunc("/rates/import", 		middleware.AuthMiddleware("admin")(a.ExchangeRateHandler.ImportRates))
 
 	http.ListenAndServe(":8080", mux)
 }
\ No newline at end of file
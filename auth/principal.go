@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+const RoleAdmin = "admin"
+
+// Principal is the authenticated caller of a request, taken from its access token.
+type Principal struct {
+	UserID		uint
+	Username	string
+	Role		string
+	TokenID		string
+	ExpiresAt	time.Time
+}
+
+type contextKey struct{}
+
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+
+// CanActAs reports whether the principal may read or change data owned by userID.
+func (p Principal) CanActAs(userID uint) bool {
+	return p.IsAdmin() || p.UserID == userID
+}
+
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(Principal)
+	return principal, ok
+}
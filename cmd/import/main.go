@@ -0,0 +1,78 @@
+package main
+
+import (
+	"BookVault-API/database"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage: go run ./cmd/import [-format csv|jsonl] [-dry-run] <file>
+
+imports books from a CSV or JSON Lines file, creating new books and updating
+books with the same ISBN, or the same title and author. The format defaults
+to the extension of the file.`
+
+
+func main() {
+	format := flag.String("format", "", "file format, csv or jsonl")
+	dryRun := flag.Bool("dry-run", false, "report what would be imported without saving anything")
+	flag.Usage = func() { fmt.Println(usage) }
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if *format == "ndjson" {
+			*format = string(model.ImportJSONL)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	bookService := service.NewBookService(database.Open())
+
+	report, err := bookService.ImportBooks(file, model.ImportFormat(*format), *dryRun)
+	if err != nil {
+		log.Fatalf("import %s: %v", path, err)
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case model.ImportFailed:
+			fmt.Printf("line %-6d %-8s %s\n", row.Line, row.Status, row.Error)
+		case model.ImportCreated:
+			if report.DryRun {
+				fmt.Printf("line %-6d %-8s %q\n", row.Line, row.Status, row.Title)
+				continue
+			}
+			fallthrough
+		default:
+			fmt.Printf("line %-6d %-8s %q (book %d)\n", row.Line, row.Status, row.Title, row.BookID)
+		}
+	}
+
+	summary := fmt.Sprintf("%d created, %d updated, %d failed", report.Created, report.Updated, report.Failed)
+	if report.DryRun {
+		summary += " (dry run, nothing was saved)"
+	}
+	fmt.Println(summary)
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"BookVault-API/database"
+	"BookVault-API/database/migrations"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const usage = `usage: go run ./cmd/migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [n]    revert the last n migrations (default 1)
+  status      list migrations and whether they are applied`
+
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	db := database.Open()
+
+	switch os.Args[1] {
+	case "up":
+		if err := migrations.Up(db); err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			n, err := strconv.Atoi(os.Args[2])
+			if err != nil || n < 1 {
+				log.Fatalf("invalid number of steps: %s", os.Args[2])
+			}
+			steps = n
+		}
+
+		if err := migrations.Down(db, steps); err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+
+	case "status":
+		statuses, err := migrations.Status(db)
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -1,7 +1,7 @@
 package database
 
 import (
-	"BookVault-API/model"
+	"BookVault-API/database/migrations"
 	"fmt"
 	"log"
 	"os"
@@ -10,28 +10,45 @@ import (
 	"gorm.io/gorm"
 )
 
-func InitDB() *gorm.DB {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("failed to load .env file: %v", err)
-	}
-
+// Connect opens the named database using the connection settings from the environment.
+func Connect(dbName string) (*gorm.DB, error) {
 	host 		:= 	os.Getenv("DB_HOST")
 	port 		:= 	os.Getenv("DB_PORT")
 	user 		:= 	os.Getenv("DB_USER")
 	password 	:= 	os.Getenv("DB_PASSWORD")
-	dbName 		:= 	os.Getenv("DB_NAME")
 	sslMode 	:= 	os.Getenv("DB_SSLMODE")
 
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",host, port, user, password, dbName, sslMode)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps constraint violations to gorm errors such as gorm.ErrDuplicatedKey.
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+}
+
+
+// Open loads the .env file and connects to the application database without migrating it.
+func Open() *gorm.DB {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatalf("failed to load .env file: %v", err)
+	}
+
+	db, err := Connect(os.Getenv("DB_NAME"))
 	if err != nil {
 		log.Fatalf("Failed to connect database: %v", err)
 	}
 
-	db.Migrator().DropTable(&model.User{}, &model.UserDetails{}, &model.Book{}, &model.Cart{}, &model.CartBook{}, &model.Order{}, &model.OrderBook{}, &model.Review{})
-	db.AutoMigrate(&model.User{}, &model.UserDetails{}, &model.Book{}, &model.Cart{}, &model.CartBook{}, &model.Order{}, &model.OrderBook{}, &model.Review{})
+	return db
+}
+
+
+func InitDB() *gorm.DB {
+	db := Open()
+
+	if os.Getenv("DB_AUTO_MIGRATE") != "false" {
+		if err := migrations.Up(db); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	}
 
 	return db
-}
\ No newline at end of file
+}
@@ -0,0 +1,101 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// The structs below are a frozen copy of the models as they were when the schema was
+// first created. Later migrations must not change them; they alter the tables instead.
+
+type initialUser struct {
+	gorm.Model
+	Username 	string
+	Password	string
+	Email		string
+	Role		string
+	Details		initialUserDetails	`gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Reviews		[]initialReview		`gorm:"foreignKey:UserID"`
+}
+
+type initialUserDetails struct {
+	gorm.Model
+	FullName	string
+	PhoneNumber	string
+	UserID		uint	`gorm:"uniqueIndex"`
+}
+
+type initialBook struct {
+	gorm.Model
+	Title       string
+	Author      string
+	Description string
+	Price       float32
+	InStock     bool
+	Reviews		[]initialReview	`gorm:"foreignKey:BookID"`
+}
+
+type initialCart struct {
+	gorm.Model
+	UserID		uint				`gorm:"uniqueIndex"`
+	User		initialUser			`gorm:"constraint:OnDelete:CASCADE;"`
+	Books		[]initialCartBook	`gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE;"`
+}
+
+type initialCartBook struct {
+	gorm.Model
+	CartID		uint		`gorm:"index"`
+	Cart		initialCart	`gorm:"constraint:OnDelete:CASCADE;"`
+	BookID		uint		`gorm:"index"`
+	Book		initialBook	`gorm:"constraint:OnDelete:CASCADE;"`
+	Quantity	int
+}
+
+type initialOrder struct {
+	gorm.Model
+	UserID		uint
+	User		initialUser
+	Status		string
+	Address		string
+	Total		float32
+	Books		[]initialOrderBook	`gorm:"foreignKey:OrderID"`
+}
+
+type initialOrderBook struct {
+	gorm.Model
+	OrderID		uint
+	Order		initialOrder
+	BookID		uint
+	Book		initialBook
+	Quantity	uint
+	Price		float32
+}
+
+type initialReview struct {
+	gorm.Model
+	Text		string
+	UserID		uint
+	User		initialUser
+	BookID		uint
+	Book		initialBook
+}
+
+func (initialUser) TableName() string 			{ return "users" }
+func (initialUserDetails) TableName() string 	{ return "user_details" }
+func (initialBook) TableName() string 			{ return "books" }
+func (initialCart) TableName() string 			{ return "carts" }
+func (initialCartBook) TableName() string 		{ return "cart_books" }
+func (initialOrder) TableName() string 			{ return "orders" }
+func (initialOrderBook) TableName() string 		{ return "order_books" }
+func (initialReview) TableName() string 		{ return "reviews" }
+
+
+// initialSchema creates the tables the API used to build with AutoMigrate on every boot.
+// AutoMigrate keeps it safe to run against databases created before migrations existed.
+var initialSchema = Migration{
+	Version: 1,
+	Name:	 "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&initialUser{}, &initialUserDetails{}, &initialBook{}, &initialCart{}, &initialCartBook{}, &initialOrder{}, &initialOrderBook{}, &initialReview{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable("reviews", "order_books", "orders", "cart_books", "carts", "books", "user_details", "users")
+	},
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// refreshTokens adds the rotating refresh token store and the access token revocation list.
+var refreshTokens = Migration{
+	Version: 2,
+	Name:	 "refresh_tokens",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE refresh_tokens (
+				id bigserial PRIMARY KEY,
+				created_at timestamptz,
+				updated_at timestamptz,
+				deleted_at timestamptz,
+				user_id bigint NOT NULL,
+				token_hash text NOT NULL,
+				family_id text NOT NULL,
+				expires_at timestamptz NOT NULL,
+				used_at timestamptz,
+				revoked_at timestamptz,
+				CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at)`,
+			`CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id)`,
+			`CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash)`,
+			`CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id)`,
+			`CREATE TABLE revoked_tokens (
+				id bigserial PRIMARY KEY,
+				created_at timestamptz,
+				updated_at timestamptz,
+				deleted_at timestamptz,
+				token_id text NOT NULL,
+				expires_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_revoked_tokens_deleted_at ON revoked_tokens (deleted_at)`,
+			`CREATE UNIQUE INDEX idx_revoked_tokens_token_id ON revoked_tokens (token_id)`,
+			`CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS revoked_tokens`,
+			`DROP TABLE IF EXISTS refresh_tokens`,
+		)
+	},
+}
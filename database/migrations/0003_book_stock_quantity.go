@@ -0,0 +1,25 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// bookStockQuantity replaces the in_stock flag with a stock count. Books that were in stock
+// get a single copy, since the real count was never recorded; admins set it afterwards.
+var bookStockQuantity = Migration{
+	Version: 3,
+	Name:	 "book_stock_quantity",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN stock bigint NOT NULL DEFAULT 0`,
+			`UPDATE books SET stock = 1 WHERE in_stock`,
+			`ALTER TABLE books ADD CONSTRAINT chk_books_stock CHECK (stock >= 0)`,
+			`ALTER TABLE books DROP COLUMN in_stock`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN in_stock boolean`,
+			`UPDATE books SET in_stock = stock > 0`,
+			`ALTER TABLE books DROP COLUMN stock`,
+		)
+	},
+}
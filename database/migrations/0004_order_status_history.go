@@ -0,0 +1,37 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// orderStatusHistory adds the per-order status audit trail and restricts orders.status to
+// the known lifecycle. The check is NOT VALID so that orders already carrying a mistyped
+// status do not block the migration; they are still rejected on their next update.
+// Existing orders get a single history entry holding their current status.
+var orderStatusHistory = Migration{
+	Version: 4,
+	Name:	 "order_status_history",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`UPDATE orders SET status = LOWER(TRIM(status))`,
+			`ALTER TABLE orders ADD CONSTRAINT chk_orders_status
+				CHECK (status IN ('pending', 'approved', 'shipped', 'delivered', 'cancelled', 'refunded')) NOT VALID`,
+			`CREATE TABLE order_status_histories (
+				id bigserial PRIMARY KEY,
+				order_id bigint NOT NULL,
+				from_status text NOT NULL DEFAULT '',
+				to_status text NOT NULL,
+				changed_by_id bigint NOT NULL,
+				changed_at timestamptz NOT NULL,
+				CONSTRAINT fk_orders_history FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX idx_order_status_histories_order_id ON order_status_histories (order_id)`,
+			`INSERT INTO order_status_histories (order_id, to_status, changed_by_id, changed_at)
+				SELECT id, status, user_id, created_at FROM orders`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS order_status_histories`,
+			`ALTER TABLE orders DROP CONSTRAINT IF EXISTS chk_orders_status`,
+		)
+	},
+}
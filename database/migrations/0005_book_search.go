@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// bookSearch adds the full-text search vector of books. Being a generated column, Postgres
+// recomputes it whenever a book is inserted or updated. Title matches weigh the most,
+// then author, then description.
+var bookSearch = Migration{
+	Version: 5,
+	Name:	 "book_search",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(author, '')), 'B') ||
+				setweight(to_tsvector('english', coalesce(description, '')), 'C')
+			) STORED`,
+			`CREATE INDEX idx_books_search_vector ON books USING GIN (search_vector)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP INDEX IF EXISTS idx_books_search_vector`,
+			`ALTER TABLE books DROP COLUMN IF EXISTS search_vector`,
+		)
+	},
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// bookRatings adds a 1-5 star rating to reviews and the per-book rating aggregates.
+// Reviews written before ratings existed keep a rating of 0 and are left out of the aggregates.
+var bookRatings = Migration{
+	Version: 6,
+	Name:	 "book_ratings",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE reviews ADD COLUMN rating smallint NOT NULL DEFAULT 0`,
+			`ALTER TABLE reviews ADD CONSTRAINT chk_reviews_rating CHECK (rating BETWEEN 0 AND 5)`,
+			`CREATE TABLE book_ratings (
+				book_id bigint PRIMARY KEY,
+				count bigint NOT NULL DEFAULT 0,
+				average double precision NOT NULL DEFAULT 0,
+				stars1 bigint NOT NULL DEFAULT 0,
+				stars2 bigint NOT NULL DEFAULT 0,
+				stars3 bigint NOT NULL DEFAULT 0,
+				stars4 bigint NOT NULL DEFAULT 0,
+				stars5 bigint NOT NULL DEFAULT 0,
+				updated_at timestamptz,
+				CONSTRAINT fk_books_rating FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+			)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS book_ratings`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS rating`,
+		)
+	},
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// uniqueUserReview allows a single live review per user and book. Existing duplicates are
+// collapsed by soft-deleting all but the newest one, and the rating aggregates are rebuilt.
+var uniqueUserReview = Migration{
+	Version: 7,
+	Name:	 "unique_user_review",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`UPDATE reviews SET deleted_at = NOW()
+			WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, book_id ORDER BY created_at DESC, id DESC) AS position
+					FROM reviews
+					WHERE deleted_at IS NULL
+				) AS ranked
+				WHERE position > 1
+			)`,
+			`CREATE UNIQUE INDEX idx_reviews_user_book ON reviews (user_id, book_id) WHERE deleted_at IS NULL`,
+			`DELETE FROM book_ratings`,
+			`INSERT INTO book_ratings (book_id, count, average, stars1, stars2, stars3, stars4, stars5, updated_at)
+			SELECT book_id, COUNT(*), AVG(rating),
+				COUNT(*) FILTER (WHERE rating = 1),
+				COUNT(*) FILTER (WHERE rating = 2),
+				COUNT(*) FILTER (WHERE rating = 3),
+				COUNT(*) FILTER (WHERE rating = 4),
+				COUNT(*) FILTER (WHERE rating = 5),
+				NOW()
+			FROM reviews
+			WHERE rating > 0 AND deleted_at IS NULL
+			GROUP BY book_id`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP INDEX IF EXISTS idx_reviews_user_book`,
+		)
+	},
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// verifiedReviews marks reviews written by users who received the book in a delivered order,
+// and adds the settings table that holds the admin-configurable review rules.
+var verifiedReviews = Migration{
+	Version: 8,
+	Name:	 "verified_reviews",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE reviews ADD COLUMN verified boolean NOT NULL DEFAULT false`,
+			`UPDATE reviews SET verified = true
+			WHERE EXISTS (
+				SELECT 1 FROM order_books
+				JOIN orders ON orders.id = order_books.order_id AND orders.deleted_at IS NULL
+				WHERE orders.user_id = reviews.user_id
+					AND order_books.book_id = reviews.book_id
+					AND orders.status = 'delivered'
+					AND order_books.deleted_at IS NULL
+			)`,
+			`CREATE TABLE settings (
+				key text PRIMARY KEY,
+				value text NOT NULL,
+				updated_at timestamptz
+			)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS settings`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS verified`,
+		)
+	},
+}
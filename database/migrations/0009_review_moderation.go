@@ -0,0 +1,48 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// reviewModeration adds the moderation status of reviews, their moderation history and user reports.
+// Reviews written before moderation existed are approved.
+var reviewModeration = Migration{
+	Version: 9,
+	Name:	 "review_moderation",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE reviews ADD COLUMN status text NOT NULL DEFAULT 'approved'`,
+			`ALTER TABLE reviews ALTER COLUMN status SET DEFAULT 'pending'`,
+			`ALTER TABLE reviews ADD CONSTRAINT chk_reviews_status CHECK (status IN ('pending', 'approved', 'rejected'))`,
+			`ALTER TABLE reviews ADD COLUMN moderated_at timestamptz`,
+			`CREATE INDEX idx_reviews_status ON reviews (status)`,
+			`CREATE TABLE review_moderations (
+				id bigserial PRIMARY KEY,
+				review_id bigint NOT NULL,
+				from_status text NOT NULL,
+				to_status text NOT NULL,
+				reason text NOT NULL DEFAULT '',
+				moderated_by_id bigint,
+				created_at timestamptz,
+				CONSTRAINT fk_reviews_moderations FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX idx_review_moderations_review_id ON review_moderations (review_id)`,
+			`CREATE TABLE review_reports (
+				id bigserial PRIMARY KEY,
+				review_id bigint NOT NULL,
+				user_id bigint NOT NULL,
+				reason text NOT NULL DEFAULT '',
+				created_at timestamptz,
+				CONSTRAINT fk_reviews_reports FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE,
+				CONSTRAINT fk_users_review_reports FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE UNIQUE INDEX idx_review_reports_review_user ON review_reports (review_id, user_id)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS review_reports`,
+			`DROP TABLE IF EXISTS review_moderations`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS moderated_at`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS status`,
+		)
+	},
+}
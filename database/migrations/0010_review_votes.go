@@ -0,0 +1,37 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// reviewVotes adds helpful/unhelpful votes on reviews. The vote counts and the helpfulness
+// score are kept on the review, so that reviews can be sorted and paginated by them.
+var reviewVotes = Migration{
+	Version: 10,
+	Name:	 "review_votes",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE reviews ADD COLUMN helpful_votes bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE reviews ADD COLUMN unhelpful_votes bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE reviews ADD COLUMN helpful_score double precision NOT NULL DEFAULT 0`,
+			`CREATE INDEX idx_reviews_book_helpful_score ON reviews (book_id, helpful_score, id)`,
+			`CREATE TABLE review_votes (
+				review_id bigint NOT NULL,
+				user_id bigint NOT NULL,
+				helpful boolean NOT NULL,
+				created_at timestamptz,
+				updated_at timestamptz,
+				PRIMARY KEY (review_id, user_id),
+				CONSTRAINT fk_reviews_votes FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE,
+				CONSTRAINT fk_users_review_votes FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS review_votes`,
+			`DROP INDEX IF EXISTS idx_reviews_book_helpful_score`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS helpful_score`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS unhelpful_votes`,
+			`ALTER TABLE reviews DROP COLUMN IF EXISTS helpful_votes`,
+		)
+	},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// categories adds the category tree and the many-to-many assignment of books to categories.
+// Sibling categories must have distinct names, compared case-insensitively.
+var categories = Migration{
+	Version: 11,
+	Name:	 "categories",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE categories (
+				id bigserial PRIMARY KEY,
+				name text NOT NULL,
+				parent_id bigint,
+				created_at timestamptz,
+				updated_at timestamptz,
+				CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories(id)
+			)`,
+			`CREATE UNIQUE INDEX idx_categories_parent_name ON categories (COALESCE(parent_id, 0), LOWER(name))`,
+			`CREATE INDEX idx_categories_parent_id ON categories (parent_id)`,
+			`CREATE TABLE book_categories (
+				book_id bigint NOT NULL,
+				category_id bigint NOT NULL,
+				PRIMARY KEY (book_id, category_id),
+				CONSTRAINT fk_book_categories_book FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+				CONSTRAINT fk_book_categories_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX idx_book_categories_category_id ON book_categories (category_id)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS book_categories`,
+			`DROP TABLE IF EXISTS categories`,
+		)
+	},
+}
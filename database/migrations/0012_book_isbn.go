@@ -0,0 +1,25 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// bookISBN adds the ISBN of a book, stored as a bare ISBN-13 together with its ISBN-10 form
+// when it has one. ISBNs are unique across all books, including soft-deleted ones, so that
+// restoring a book can never create a duplicate.
+var bookISBN = Migration{
+	Version: 12,
+	Name:	 "book_isbn",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN isbn13 varchar(13)`,
+			`ALTER TABLE books ADD COLUMN isbn10 varchar(10)`,
+			`CREATE UNIQUE INDEX idx_books_isbn13 ON books (isbn13)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP INDEX IF EXISTS idx_books_isbn13`,
+			`ALTER TABLE books DROP COLUMN IF EXISTS isbn10`,
+			`ALTER TABLE books DROP COLUMN IF EXISTS isbn13`,
+		)
+	},
+}
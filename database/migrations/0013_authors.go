@@ -0,0 +1,60 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// authors adds authors with their aliases and the authorship of books, where an author can
+// write, translate or edit a book. Every existing author string becomes one author, matched
+// case-insensitively, and is linked to its books as their author. books.author is kept as
+// the display name of a book's authors.
+var authors = Migration{
+	Version: 13,
+	Name:	 "authors",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE authors (
+				id bigserial PRIMARY KEY,
+				name text NOT NULL,
+				bio text NOT NULL DEFAULT '',
+				born_on date,
+				died_on date,
+				created_at timestamptz,
+				updated_at timestamptz
+			)`,
+			`CREATE UNIQUE INDEX idx_authors_name ON authors (LOWER(name))`,
+			`CREATE TABLE author_aliases (
+				id bigserial PRIMARY KEY,
+				author_id bigint NOT NULL,
+				alias text NOT NULL,
+				CONSTRAINT fk_author_aliases_author FOREIGN KEY (author_id) REFERENCES authors(id) ON DELETE CASCADE
+			)`,
+			`CREATE UNIQUE INDEX idx_author_aliases_alias ON author_aliases (LOWER(alias))`,
+			`CREATE INDEX idx_author_aliases_author_id ON author_aliases (author_id)`,
+			`CREATE TABLE book_authors (
+				book_id bigint NOT NULL,
+				author_id bigint NOT NULL,
+				role varchar(20) NOT NULL DEFAULT 'author',
+				position integer NOT NULL DEFAULT 0,
+				PRIMARY KEY (book_id, author_id, role),
+				CONSTRAINT chk_book_authors_role CHECK (role IN ('author', 'translator', 'editor')),
+				CONSTRAINT fk_book_authors_book FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+				CONSTRAINT fk_book_authors_author FOREIGN KEY (author_id) REFERENCES authors(id)
+			)`,
+			`CREATE INDEX idx_book_authors_author_id ON book_authors (author_id)`,
+			`INSERT INTO authors (name, created_at, updated_at)
+				SELECT DISTINCT ON (LOWER(TRIM(author))) TRIM(author), now(), now()
+				FROM books
+				WHERE TRIM(author) <> ''
+				ORDER BY LOWER(TRIM(author)), id`,
+			`INSERT INTO book_authors (book_id, author_id, role, position)
+				SELECT books.id, authors.id, 'author', 0
+				FROM books JOIN authors ON LOWER(authors.name) = LOWER(TRIM(books.author))`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS book_authors`,
+			`DROP TABLE IF EXISTS author_aliases`,
+			`DROP TABLE IF EXISTS authors`,
+		)
+	},
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"BookVault-API/money"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// moneyColumns are the float amounts moneyAmounts converts, by table. The initial schema
+// model and field are what reverting recreates the column from.
+var moneyColumns = []struct {
+	table	string
+	column	string
+	model	any
+	field	string
+}{
+	{"books", "price", &initialBook{}, "Price"},
+	{"order_books", "price", &initialOrderBook{}, "Price"},
+	{"orders", "total", &initialOrder{}, "Total"},
+}
+
+// moneyAmounts replaces the float prices and totals with an integer amount in minor units and
+// a currency. Existing amounts carry no currency, so they are taken to be in the default
+// currency (CATALOG_CURRENCY, or EUR) and rounded to its minor unit, halves away from zero.
+// Reverting drops the currencies and restores the columns with the type the initial schema
+// gave them, rounded to the minor unit of each currency.
+var moneyAmounts = Migration{
+	Version: 14,
+	Name:	 "money_amounts",
+	Up: func(tx *gorm.DB) error {
+		currency := money.DefaultCurrency()
+		scale := pow10(money.Exponent(currency))
+
+		for _, c := range moneyColumns {
+			err := execAll(tx,
+				fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s_amount bigint, ADD COLUMN %s_currency varchar(3)`, c.table, c.column, c.column),
+				fmt.Sprintf(`UPDATE %s SET %s_amount = ROUND(COALESCE(%s, 0)::numeric * %d), %s_currency = '%s'`, c.table, c.column, c.column, scale, c.column, currency),
+				fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s_amount SET NOT NULL, ALTER COLUMN %s_currency SET NOT NULL`, c.table, c.column, c.column),
+				fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT chk_%s_%s_amount CHECK (%s_amount >= 0)`, c.table, c.table, c.column, c.column),
+				fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, c.table, c.column),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, c := range moneyColumns {
+			if err := tx.Migrator().AddColumn(c.model, c.field); err != nil {
+				return err
+			}
+
+			var currencies []string
+			if err := tx.Table(c.table).Distinct(c.column + "_currency").Pluck(c.column+"_currency", &currencies).Error; err != nil {
+				return err
+			}
+
+			for _, currency := range currencies {
+				exponent := money.Exponent(currency)
+				statement := fmt.Sprintf(`UPDATE %s SET %s = ROUND(%s_amount::numeric / %d, %d) WHERE %s_currency = ?`, c.table, c.column, c.column, pow10(exponent), exponent, c.column)
+				if err := tx.Exec(statement, currency).Error; err != nil {
+					return err
+				}
+			}
+
+			err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s_amount, DROP COLUMN %s_currency`, c.table, c.column, c.column)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+
+func pow10(exponent int) int64 {
+	scale := int64(1)
+	for i := 0; i < exponent; i++ {
+		scale *= 10
+	}
+	return scale
+}
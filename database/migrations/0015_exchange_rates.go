@@ -0,0 +1,32 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// exchangeRates adds the exchange rates prices are shown in other currencies with, and the
+// rate an order was shown in at checkout, kept with the order so its prices never change.
+var exchangeRates = Migration{
+	Version: 15,
+	Name:	 "exchange_rates",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE exchange_rates (
+				base varchar(3) NOT NULL,
+				quote varchar(3) NOT NULL,
+				rate numeric(20,10) NOT NULL,
+				updated_at timestamptz NOT NULL,
+				PRIMARY KEY (base, quote),
+				CONSTRAINT chk_exchange_rates_rate CHECK (rate > 0),
+				CONSTRAINT chk_exchange_rates_pair CHECK (base <> quote)
+			)`,
+			`ALTER TABLE orders ADD COLUMN rate_currency varchar(3), ADD COLUMN exchange_rate numeric(20,10),
+				ADD COLUMN rate_updated_at timestamptz, ADD COLUMN rate_stale boolean NOT NULL DEFAULT false`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE orders DROP COLUMN IF EXISTS rate_stale, DROP COLUMN IF EXISTS rate_updated_at,
+				DROP COLUMN IF EXISTS exchange_rate, DROP COLUMN IF EXISTS rate_currency`,
+			`DROP TABLE IF EXISTS exchange_rates`,
+		)
+	},
+}
@@ -0,0 +1,79 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// coupons adds discount codes, the codes applied to carts, and the coupons an order was placed
+// with. Orders keep a copy of each coupon's terms, so editing or deleting a coupon leaves them
+// unchanged; existing orders get a subtotal equal to their total and no discount.
+var coupons = Migration{
+	Version: 16,
+	Name:	 "coupons",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE coupons (
+				id bigserial PRIMARY KEY,
+				code varchar(32) NOT NULL,
+				kind varchar(16) NOT NULL,
+				percent_off integer NOT NULL DEFAULT 0,
+				amount_off bigint NOT NULL DEFAULT 0,
+				currency varchar(3) NOT NULL,
+				scope varchar(16) NOT NULL,
+				book_id bigint,
+				category_id bigint,
+				min_spend bigint NOT NULL DEFAULT 0,
+				max_uses integer,
+				max_uses_per_user integer,
+				starts_at timestamptz,
+				ends_at timestamptz,
+				stackable boolean NOT NULL DEFAULT false,
+				created_at timestamptz,
+				updated_at timestamptz,
+				CONSTRAINT fk_coupons_book FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+				CONSTRAINT fk_coupons_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+				CONSTRAINT chk_coupons_kind CHECK (kind IN ('percent', 'fixed')),
+				CONSTRAINT chk_coupons_scope CHECK (scope IN ('order', 'book', 'category')),
+				CONSTRAINT chk_coupons_percent_off CHECK (percent_off BETWEEN 0 AND 100),
+				CONSTRAINT chk_coupons_amounts CHECK (amount_off >= 0 AND min_spend >= 0),
+				CONSTRAINT chk_coupons_window CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at)
+			)`,
+			`CREATE UNIQUE INDEX idx_coupons_code ON coupons (code)`,
+			`CREATE TABLE cart_coupons (
+				cart_id bigint NOT NULL,
+				coupon_id bigint NOT NULL,
+				PRIMARY KEY (cart_id, coupon_id),
+				CONSTRAINT fk_cart_coupons_cart FOREIGN KEY (cart_id) REFERENCES carts(id) ON DELETE CASCADE,
+				CONSTRAINT fk_cart_coupons_coupon FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE order_coupons (
+				id bigserial PRIMARY KEY,
+				order_id bigint NOT NULL,
+				coupon_id bigint,
+				code varchar(32) NOT NULL,
+				kind varchar(16) NOT NULL,
+				scope varchar(16) NOT NULL,
+				percent_off integer NOT NULL DEFAULT 0,
+				amount_off bigint NOT NULL DEFAULT 0,
+				discount_amount bigint NOT NULL,
+				discount_currency varchar(3) NOT NULL,
+				CONSTRAINT fk_order_coupons_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+				CONSTRAINT fk_order_coupons_coupon FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE SET NULL
+			)`,
+			`CREATE INDEX idx_order_coupons_order_id ON order_coupons (order_id)`,
+			`CREATE INDEX idx_order_coupons_coupon_id ON order_coupons (coupon_id)`,
+			`ALTER TABLE orders ADD COLUMN subtotal_amount bigint, ADD COLUMN subtotal_currency varchar(3),
+				ADD COLUMN discount_amount bigint NOT NULL DEFAULT 0, ADD COLUMN discount_currency varchar(3)`,
+			`UPDATE orders SET subtotal_amount = total_amount, subtotal_currency = total_currency, discount_currency = total_currency`,
+			`ALTER TABLE orders ALTER COLUMN subtotal_amount SET NOT NULL, ALTER COLUMN subtotal_currency SET NOT NULL,
+				ALTER COLUMN discount_currency SET NOT NULL`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE orders DROP COLUMN IF EXISTS discount_currency, DROP COLUMN IF EXISTS discount_amount,
+				DROP COLUMN IF EXISTS subtotal_currency, DROP COLUMN IF EXISTS subtotal_amount`,
+			`DROP TABLE IF EXISTS order_coupons`,
+			`DROP TABLE IF EXISTS cart_coupons`,
+			`DROP TABLE IF EXISTS coupons`,
+		)
+	},
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// cartPrices records the price a book had when it was put in the cart, so that price changes
+// since can be pointed out. Books already in carts get their current price.
+var cartPrices = Migration{
+	Version: 17,
+	Name:	 "cart_prices",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE cart_books ADD COLUMN price_amount bigint, ADD COLUMN price_currency varchar(3)`,
+			`UPDATE cart_books SET price_amount = books.price_amount, price_currency = books.price_currency
+				FROM books WHERE books.id = cart_books.book_id`,
+			`ALTER TABLE cart_books ALTER COLUMN price_amount SET NOT NULL, ALTER COLUMN price_currency SET NOT NULL`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE cart_books DROP COLUMN IF EXISTS price_currency, DROP COLUMN IF EXISTS price_amount`,
+		)
+	},
+}
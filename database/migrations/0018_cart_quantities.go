@@ -0,0 +1,32 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// cartQuantities keeps one line per book in a cart, with at least one copy, and lets admins cap
+// the copies of a book a cart can hold. Duplicate lines are merged into the oldest one and lines
+// without copies are dropped.
+var cartQuantities = Migration{
+	Version: 18,
+	Name:	 "cart_quantities",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`UPDATE cart_books SET quantity = merged.quantity
+				FROM (SELECT MIN(id) AS id, SUM(quantity) AS quantity FROM cart_books
+					WHERE deleted_at IS NULL GROUP BY cart_id, book_id HAVING COUNT(*) > 1) merged
+				WHERE cart_books.id = merged.id`,
+			`DELETE FROM cart_books WHERE deleted_at IS NOT NULL OR quantity <= 0 OR id NOT IN
+				(SELECT MIN(id) FROM cart_books WHERE deleted_at IS NULL GROUP BY cart_id, book_id)`,
+			`ALTER TABLE cart_books ADD CONSTRAINT chk_cart_books_quantity CHECK (quantity > 0)`,
+			`CREATE UNIQUE INDEX idx_cart_books_cart_book ON cart_books (cart_id, book_id) WHERE deleted_at IS NULL`,
+			`ALTER TABLE books ADD COLUMN max_quantity integer`,
+			`ALTER TABLE books ADD CONSTRAINT chk_books_max_quantity CHECK (max_quantity > 0)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books DROP COLUMN IF EXISTS max_quantity`,
+			`DROP INDEX IF EXISTS idx_cart_books_cart_book`,
+			`ALTER TABLE cart_books DROP CONSTRAINT IF EXISTS chk_cart_books_quantity`,
+		)
+	},
+}
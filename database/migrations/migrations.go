@@ -0,0 +1,237 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownMigration = errors.New("applied migration is not registered")
+)
+
+// Migration is a single numbered schema change. Up applies it and Down reverts it;
+// both run inside the same transaction that records the version in schema_migrations.
+type Migration struct {
+	Version		uint
+	Name		string
+	Up			func(tx *gorm.DB) error
+	Down		func(tx *gorm.DB) error
+}
+
+// SchemaMigration is a row of the schema_migrations table.
+type SchemaMigration struct {
+	Version		uint		`gorm:"primaryKey;autoIncrement:false"`
+	Name		string
+	AppliedAt	time.Time
+}
+
+// MigrationStatus reports whether a registered migration has been applied.
+type MigrationStatus struct {
+	Version		uint
+	Name		string
+	Applied		bool
+	AppliedAt	*time.Time
+}
+
+// all lists every migration in version order. New migrations are appended here.
+var all = []Migration{
+	initialSchema,
+	refreshTokens,
+	bookStockQuantity,
+	orderStatusHistory,
+	bookSearch,
+	bookRatings,
+	uniqueUserReview,
+	verifiedReviews,
+	reviewModeration,
+	reviewVotes,
+	categories,
+	bookISBN,
+	authors,
+	moneyAmounts,
+	exchangeRates,
+	coupons,
+	cartPrices,
+	cartQuantities,
+}
+
+// advisoryLockKey serializes migrations between several API instances starting at once.
+const advisoryLockKey = 7_355_608
+
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+
+// execAll runs the statements in order and stops at the first failure.
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+
+func ensureTable(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
+
+func appliedVersions(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	var rows []SchemaMigration
+
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+
+func find(version uint) (Migration, bool) {
+	for _, m := range all {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+
+// Up applies every registered migration that has not been applied yet.
+func Up(db *gorm.DB) error {
+	if err := ensureTable(db); err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		applied := false
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockKey).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+
+			applied = true
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		if applied {
+			log.Printf("applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+
+	return nil
+}
+
+
+// Down reverts the last steps applied migrations, newest first.
+func Down(db *gorm.DB, steps int) error {
+	if err := ensureTable(db); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	versions := make([]uint, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	if steps > len(versions) {
+		steps = len(versions)
+	}
+
+	for _, version := range versions[:steps] {
+		m, ok := find(version)
+		if !ok {
+			return fmt.Errorf("migration %04d: %w", version, ErrUnknownMigration)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockKey).Error; err != nil {
+				return err
+			}
+
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		log.Printf("reverted migration %04d_%s", m.Version, m.Name)
+	}
+
+	return nil
+}
+
+
+// Reset reverts every applied migration and applies them all again.
+func Reset(db *gorm.DB) error {
+	if err := Down(db, len(all)); err != nil {
+		return err
+	}
+	return Up(db)
+}
+
+
+// Status lists the registered migrations together with their applied state.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(all))
+	for _, m := range all {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"BookVault-API/auth"
+	"BookVault-API/helper"
+	"net/http"
+)
+
+
+// caller returns the principal AuthMiddleware attached to the request.
+func caller(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		helper.WriteError(w, http.StatusUnauthorized, "missing authenticated user")
+		return auth.Principal{}, false
+	}
+
+	return principal, true
+}
+
+
+// userScopedIDs parses idCount IDs that follow the first idStartIndex path segments.
+// The path may start with an extra user ID naming whose data is accessed; without it the
+// caller acts on their own behalf. Only admins may name a user other than themselves.
+func userScopedIDs(w http.ResponseWriter, r *http.Request, idStartIndex, idCount int) (uint, []int, bool) {
+	principal, ok := caller(w, r)
+	if !ok {
+		return 0, nil, false
+	}
+
+	userID := principal.UserID
+
+	IDs, err := helper.ParseIDsFromPath(r, idStartIndex + idCount + 1, idStartIndex)
+	switch err {
+	case nil:
+		userID = uint(IDs[0])
+		IDs = IDs[1:]
+	case helper.ErrInvalidPath:
+		IDs, err = helper.ParseIDsFromPath(r, idStartIndex + idCount, idStartIndex)
+		if err != nil {
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+			return 0, nil, false
+		}
+	default:
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return 0, nil, false
+	}
+
+	if !principal.CanActAs(userID) {
+		helper.WriteError(w, http.StatusForbidden, "forbidden: cannot act on behalf of another user")
+		return 0, nil, false
+	}
+
+	return userID, IDs, true
+}
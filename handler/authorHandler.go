@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"encoding/json"
+	"net/http"
+)
+
+type AuthorHandler struct {
+	service service.AuthorService
+}
+
+func NewAuthorHandler(s service.AuthorService) *AuthorHandler {
+	return &AuthorHandler{service: s}
+}
+
+
+func (a *AuthorHandler) CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var authorRequest model.AuthorRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&authorRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	author, err := a.service.CreateAuthor(authorRequest)
+	if err != nil {
+		switch err {
+		case service.ErrEmptyAuthorName, service.ErrInvalidAuthorDate, service.ErrAuthorDates:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrAuthorExists:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusCreated, author)
+}
+
+
+func (a *AuthorHandler) GetAuthor(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 2, 1)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authorID := uint(IDs[0])
+
+	author, err := a.service.GetAuthor(authorID)
+	if err != nil {
+		switch err {
+		case service.ErrAuthorNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, author)
+}
+
+
+func (a *AuthorHandler) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPatch) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authorID := uint(IDs[0])
+
+	var authorUpdate model.AuthorUpdate
+
+	if err := json.NewDecoder(r.Body).Decode(&authorUpdate); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := a.service.UpdateAuthor(authorID, authorUpdate); err != nil {
+		switch err {
+		case service.ErrEmptyAuthorName, service.ErrInvalidAuthorDate, service.ErrAuthorDates:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrAuthorNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrAuthorExists:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Author updated!"})
+}
+
+
+func (a *AuthorHandler) DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authorID := uint(IDs[0])
+
+	if err := a.service.DeleteAuthor(authorID); err != nil {
+		switch err {
+		case service.ErrAuthorNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrAuthorHasBooks:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Author deleted!"})
+}
+
+
+// MergeAuthors folds the first author of the path into the second one.
+func (a *AuthorHandler) MergeAuthors(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 4, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sourceID, targetID := uint(IDs[0]), uint(IDs[1])
+
+	if err := a.service.MergeAuthors(sourceID, targetID); err != nil {
+		switch err {
+		case service.ErrMergeSameAuthor:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrAuthorNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Authors merged!"})
+}
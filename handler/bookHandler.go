@@ -3,17 +3,45 @@ package handler
 import (
 	"BookVault-API/helper"
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"BookVault-API/service"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type BookHandler struct {
 	service	service.BookService
+	rates	service.ExchangeRateService
 }
 
-func NewBookHandler(s service.BookService) *BookHandler {
-	return &BookHandler{service: s}
+func NewBookHandler(s service.BookService, rates service.ExchangeRateService) *BookHandler {
+	return &BookHandler{service: s, rates: rates}
+}
+
+
+// writeBooks writes a response holding books, with their prices also shown in the requested currency.
+func (b *BookHandler) writeBooks(w http.ResponseWriter, currency string, data any, books ...*model.BookResponse) {
+	if err := b.rates.ConvertBooks(currency, books...); err != nil {
+		writeConversionError(w, err)
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, data)
+}
+
+
+func bookRefs(books []model.BookResponse) []*model.BookResponse {
+	refs := make([]*model.BookResponse, 0, len(books))
+	for i := range books {
+		refs = append(refs, &books[i])
+	}
+	return refs
 }
 
 
@@ -31,8 +59,11 @@ func (b *BookHandler) CreateBook(w http.ResponseWriter, r *http.Request) {
 
 	if err := b.service.CreateBook(&bookRequest); err != nil {
 		switch err {
-		case service.ErrEmptyFields:
+		case service.ErrEmptyFields, service.ErrInvalidPrice, service.ErrInvalidCurrency, service.ErrInvalidStock, service.ErrInvalidMaxQuantity,
+			service.ErrInvalidISBN:
 			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrDuplicateISBN:
+			helper.WriteError(w, http.StatusConflict, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
@@ -48,6 +79,11 @@ func (b *BookHandler) GetByTitle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
 	bookTitle, ok := helper.RequiredQueryParam(w, r, "title")
 	if !ok {
 		return
@@ -64,7 +100,40 @@ func (b *BookHandler) GetByTitle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, book)
+	b.writeBooks(w, currency, book, book)
+}
+
+
+func (b *BookHandler) GetByISBN(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	value := strings.TrimPrefix(r.URL.Path, "/book/isbn/")
+	if value == "" || strings.Contains(value, "/") {
+		helper.WriteError(w, http.StatusBadRequest, helper.ErrInvalidPath.Error())
+		return
+	}
+
+	book, err := b.service.GetByISBN(value)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidISBN:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrBookNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	b.writeBooks(w, currency, book, book)
 }
 
 
@@ -73,18 +142,85 @@ func (b *BookHandler) GetBooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	books, err := b.service.GetBooks()
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseBookFilter(r)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := helper.ParsePageRequest(r)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := b.service.GetBooks(filter, page)
 	if err != nil {
 		switch err {
 		case service.ErrNoBooks:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, books)
+	b.writeBooks(w, currency, books, bookRefs(books.Items)...)
+}
+
+
+// parseBookFilter reads the author, min_price, max_price and in_stock query params of the book list.
+// The price bounds are in the requested currency, or else the default currency.
+func parseBookFilter(r *http.Request) (model.BookFilter, error) {
+	query := r.URL.Query()
+
+	filter := model.BookFilter{Author: query.Get("author")}
+
+	currency, err := helper.ParseCurrency(r)
+	if err != nil {
+		return filter, err
+	}
+	if currency == "" {
+		currency = money.DefaultCurrency()
+	}
+
+	if filter.MinPrice, err = parsePriceParam(query.Get("min_price"), currency); err != nil {
+		return filter, errors.New("invalid min_price")
+	}
+	if filter.MaxPrice, err = parsePriceParam(query.Get("max_price"), currency); err != nil {
+		return filter, errors.New("invalid max_price")
+	}
+
+	if value := query.Get("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("invalid in_stock")
+		}
+		filter.InStock = &inStock
+	}
+
+	return filter, nil
+}
+
+
+func parsePriceParam(value, currency string) (*money.Money, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	price, err := money.Parse(value, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &price, nil
 }
 
 
@@ -93,6 +229,11 @@ func (b *BookHandler) GetBooksByAuthor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
 	bookAuthor, ok := helper.RequiredQueryParam(w, r, "author")
 	if !ok {
 		return
@@ -104,7 +245,167 @@ func (b *BookHandler) GetBooksByAuthor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, books)
+	b.writeBooks(w, currency, books, bookRefs(books)...)
+}
+
+
+func (b *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	query, ok := helper.RequiredQueryParam(w, r, "q")
+	if !ok {
+		return
+	}
+
+	page, err := helper.ParsePageRequest(r)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := b.service.SearchBooks(query, page)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	refs := make([]*model.BookResponse, 0, len(results.Items))
+	for i := range results.Items {
+		refs = append(refs, &results.Items[i].BookResponse)
+	}
+
+	b.writeBooks(w, currency, results, refs...)
+}
+
+
+func (b *BookHandler) GetBooksByCategory(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categoryID := uint(IDs[0])
+
+	includeDescendants := false
+	if value := r.URL.Query().Get("includeDescendants"); value != "" {
+		if includeDescendants, err = strconv.ParseBool(value); err != nil {
+			helper.WriteError(w, http.StatusBadRequest, "invalid includeDescendants")
+			return
+		}
+	}
+
+	page, err := helper.ParsePageRequest(r)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := b.service.GetBooksByCategory(categoryID, includeDescendants, page)
+	if err != nil {
+		switch err {
+		case service.ErrCategoryNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	b.writeBooks(w, currency, books, bookRefs(books.Items)...)
+}
+
+
+func (b *BookHandler) SetBookCategories(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bookID := uint(IDs[0])
+
+	var categoriesRequest model.BookCategoriesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&categoriesRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := b.service.SetBookCategories(bookID, categoriesRequest.CategoryIDs); err != nil {
+		switch err {
+		case service.ErrBookNotFound, service.ErrCategoryNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book categories updated!"})
+}
+
+
+func (b *BookHandler) SetBookAuthors(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bookID := uint(IDs[0])
+
+	var authorsRequest model.BookAuthorsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&authorsRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := b.service.SetBookAuthors(bookID, authorsRequest.Authors); err != nil {
+		switch err {
+		case service.ErrInvalidAuthorRole, service.ErrBookNeedsAuthor:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrBookNotFound, service.ErrAuthorNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book authors updated!"})
 }
 
 
@@ -121,10 +422,32 @@ func (b *BookHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 
 	bookID := uint(IDs[0])
 
-	if err := b.service.UpdateStock(bookID); err != nil {
+	var stockUpdate model.StockUpdate
+
+	query := r.URL.Query()
+	if value := query.Get("stock"); value != "" {
+		stock, err := strconv.Atoi(value)
+		if err != nil {
+			helper.WriteError(w, http.StatusBadRequest, "invalid stock")
+			return
+		}
+		stockUpdate.Stock = &stock
+	}
+	if value := query.Get("delta"); value != "" {
+		delta, err := strconv.Atoi(value)
+		if err != nil {
+			helper.WriteError(w, http.StatusBadRequest, "invalid delta")
+			return
+		}
+		stockUpdate.Delta = &delta
+	}
+
+	if err := b.service.UpdateStock(bookID, stockUpdate); err != nil {
 		switch err {
 		case service.ErrBookNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrInvalidStock, service.ErrInvalidStockUpdate:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
@@ -132,4 +455,216 @@ func (b *BookHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	}
 
 	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book stock updated!"})
-}
\ No newline at end of file
+}
+
+func (b *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPatch) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bookID := uint(IDs[0])
+
+	var bookRequest model.BookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&bookRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := b.service.UpdateBook(bookID, &bookRequest); err != nil {
+		switch err {
+		case service.ErrBookNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrInvalidPrice, service.ErrInvalidCurrency, service.ErrInvalidMaxQuantity, service.ErrInvalidISBN:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrDuplicateISBN:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book updated!"})
+}
+
+
+func (b *BookHandler) DeleteBook(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bookID := uint(IDs[0])
+
+	if err := b.service.DeleteBook(bookID); err != nil {
+		switch err {
+		case service.ErrBookNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book deleted!"})
+}
+
+
+func (b *BookHandler) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPatch) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bookID := uint(IDs[0])
+
+	if err := b.service.RestoreBook(bookID); err != nil {
+		switch err {
+		case service.ErrBookNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrBookNotDeleted:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book restored!"})
+}
+
+
+func (b *BookHandler) GetDeletedBooks(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	books, err := b.service.GetDeletedBooks()
+	if err != nil {
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, books)
+}
+
+
+// importFormats maps the content types of import files to their format, for requests without a format param.
+var importFormats = map[string]model.ImportFormat{
+	"text/csv":					model.ImportCSV,
+	"application/jsonl":		model.ImportJSONL,
+	"application/x-ndjson":		model.ImportJSONL,
+	"application/x-jsonlines":	model.ImportJSONL,
+}
+
+
+// ImportBooks reads the import file from the raw request body, so it is streamed rather than buffered.
+func (b *BookHandler) ImportBooks(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	format := model.ImportFormat(strings.ToLower(r.URL.Query().Get("format")))
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format = importFormats[mediaType]
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			helper.WriteError(w, http.StatusBadRequest, "invalid dryRun")
+			return
+		}
+	}
+
+	report, err := b.service.ImportBooks(r.Body, format, dryRun)
+	if err != nil {
+		switch err {
+		case service.ErrUnsupportedImportFormat, service.ErrInvalidImportHeader, service.ErrImportLineTooLong:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, report)
+}
+
+
+// exportContentTypes are the content types and file extensions of the export formats.
+var exportContentTypes = map[model.ExportFormat][2]string{
+	model.ExportCSV:	{"text/csv; charset=utf-8", "csv"},
+	model.ExportJSONL:	{"application/x-ndjson", "jsonl"},
+	model.ExportONIX:	{"application/xml; charset=utf-8", "xml"},
+}
+
+// exportWriter records whether the export has written anything, after which the status is sent
+// and an error can no longer be reported to the client.
+type exportWriter struct {
+	http.ResponseWriter
+	written	bool
+}
+
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.written = true
+	return e.ResponseWriter.Write(p)
+}
+
+
+// ExportBooks streams the catalog, narrowed by the same filters as the book list, as an attachment.
+func (b *BookHandler) ExportBooks(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	format := model.ExportFormat(strings.ToLower(r.URL.Query().Get("format")))
+	if format == "" {
+		format = model.ExportCSV
+	}
+
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		helper.WriteError(w, http.StatusBadRequest, service.ErrUnsupportedExportFormat.Error())
+		return
+	}
+
+	filter, err := parseBookFilter(r)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out := &exportWriter{ResponseWriter: w}
+	w.Header().Set("Content-Type", contentType[0])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="catalog.%s"`, contentType[1]))
+
+	if err := b.service.ExportBooks(out, format, filter); err != nil {
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		log.Printf("export books: %v", err)
+	}
+}
@@ -2,17 +2,21 @@ package handler
 
 import (
 	"BookVault-API/helper"
+	"BookVault-API/model"
 	"BookVault-API/service"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 )
 
 type CartHandler struct {
 	service		service.CartService
+	rates		service.ExchangeRateService
 }
 
-func NewCartHandler(s service.CartService) *CartHandler {
-	return &CartHandler{service: s}
+func NewCartHandler(s service.CartService, rates service.ExchangeRateService) *CartHandler {
+	return &CartHandler{service: s, rates: rates}
 }
 
 
@@ -21,13 +25,12 @@ func (c *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 4, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	userID, IDs, ok := userScopedIDs(w, r, 2, 1)
+	if !ok {
 		return
 	}
 
-	userID, bookID := uint(IDs[0]), uint(IDs[1])
+	bookID := uint(IDs[0])
 
 	q, ok := helper.RequiredQueryParam(w, r, "quantity")
 	if !ok {
@@ -41,9 +44,15 @@ func (c *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := c.service.AddToCart(userID, bookID, quantity); err != nil {
-		switch err {
-		case service.ErrBookNotFound:
+		var limitErr *service.QuantityLimitError
+
+		switch {
+		case err == service.ErrInvalidQuantity:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case err == service.ErrBookNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case errors.As(err, &limitErr):
+			helper.WriteError(w, http.StatusConflict, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
@@ -59,14 +68,11 @@ func (c *CartHandler) ClearCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	userID, _, ok := userScopedIDs(w, r, 2, 0)
+	if !ok {
 		return
 	}
 
-	userID := uint(IDs[0])
-
 	if err := c.service.ClearCart(userID); err != nil {
 		switch err {
 		case service.ErrCartNotFound:
@@ -86,13 +92,12 @@ func (c *CartHandler) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 4, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	userID, IDs, ok := userScopedIDs(w, r, 2, 1)
+	if !ok {
 		return
 	}
 
-	userID, bookID := uint(IDs[0]), uint(IDs[1])
+	bookID := uint(IDs[0])
 
 	if err := c.service.RemoveFromCart(userID, bookID); err != nil {
 		switch err {
@@ -108,18 +113,18 @@ func (c *CartHandler) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 }
 
 
+// UpdateQuantity sets the copies of a book in the cart; a quantity of 0 removes the book.
 func (c *CartHandler) UpdateQuantity(w http.ResponseWriter, r *http.Request) {
 	if !helper.RequiredMethod(w, r, http.MethodPatch) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 4, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	userID, IDs, ok := userScopedIDs(w, r, 2, 1)
+	if !ok {
 		return
 	}
 
-	userID, bookID := uint(IDs[0]), uint(IDs[1])
+	bookID := uint(IDs[0])
 
 	q, ok := helper.RequiredQueryParam(w, r, "quantity")
 	if !ok {
@@ -133,19 +138,133 @@ func (c *CartHandler) UpdateQuantity(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := c.service.UpdateQuantity(userID, bookID, quantity); err != nil {
-		switch err {
-		case service.ErrCartNotFound, service.ErrCartBookNotFound:
+		var limitErr *service.QuantityLimitError
+
+		switch {
+		case err == service.ErrNegativeQuantity:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case err == service.ErrCartNotFound, err == service.ErrCartBookNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case errors.As(err, &limitErr):
+			helper.WriteError(w, http.StatusConflict, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
 		return
 	}
 
+	if quantity == 0 {
+		helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book removed from cart!"})
+		return
+	}
+
 	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Book quantity updated!"})
 }
 
 
+// SetCartLines sets the copies of every book in the request body at once; either all lines
+// are set or none is.
+func (c *CartHandler) SetCartLines(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	userID, _, ok := userScopedIDs(w, r, 2, 0)
+	if !ok {
+		return
+	}
+
+	var linesRequest model.CartLinesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&linesRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := c.service.SetCartLines(userID, linesRequest.Lines); err != nil {
+		var limitErr *service.QuantityLimitError
+		var lineErr *service.CartLineError
+
+		switch {
+		case errors.As(err, &limitErr):
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrBookNotFound):
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case err == service.ErrNoCartLines, errors.As(err, &lineErr):
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Cart updated!"})
+}
+
+
+// ApplyCoupon adds the coupon with the code in the code query param to the cart.
+func (c *CartHandler) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	userID, _, ok := userScopedIDs(w, r, 3, 0)
+	if !ok {
+		return
+	}
+
+	code, ok := helper.RequiredQueryParam(w, r, "code")
+	if !ok {
+		return
+	}
+
+	if err := c.service.ApplyCoupon(userID, code); err != nil {
+		switch err {
+		case service.ErrCartNotFound, service.ErrCouponNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrCouponApplied, service.ErrCouponNotActive, service.ErrCouponUsedUp, service.ErrCouponUserLimit,
+			service.ErrCouponNotStackable, service.ErrCouponCurrency, service.ErrCouponMinSpend, service.ErrCouponNotApplicable,
+			service.ErrMixedCurrencies:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Coupon applied!"})
+}
+
+
+func (c *CartHandler) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	userID, _, ok := userScopedIDs(w, r, 3, 0)
+	if !ok {
+		return
+	}
+
+	code, ok := helper.RequiredQueryParam(w, r, "code")
+	if !ok {
+		return
+	}
+
+	if err := c.service.RemoveCoupon(userID, code); err != nil {
+		switch err {
+		case service.ErrCartNotFound, service.ErrCartCouponNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Coupon removed!"})
+}
+
+
 func (c *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 	if !helper.RequiredMethod(w, r, http.MethodGet) {
 		return
@@ -159,16 +278,33 @@ func (c *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
 
 	cartID := uint(IDs[0])
 
-	cart, err := c.service.GetCart(cartID)
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	cart, err := c.service.GetCart(principal, cartID)
 	if err != nil {
 		switch err {
 		case service.ErrCartNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrForbidden:
+			helper.WriteError(w, http.StatusForbidden, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
 		return
 	}
 
+	if err := c.rates.ConvertCart(currency, cart); err != nil {
+		writeConversionError(w, err)
+		return
+	}
+
 	helper.WriteJSON(w, http.StatusOK, cart)
 }
\ No newline at end of file
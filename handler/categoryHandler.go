@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"encoding/json"
+	"net/http"
+)
+
+type CategoryHandler struct {
+	service service.CategoryService
+}
+
+func NewCategoryHandler(s service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{service: s}
+}
+
+
+func (c *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var categoryRequest model.CategoryRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&categoryRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	category, err := c.service.CreateCategory(categoryRequest)
+	if err != nil {
+		switch err {
+		case service.ErrEmptyCategoryName:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrParentCategoryNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrCategoryExists:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusCreated, category)
+}
+
+
+func (c *CategoryHandler) GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	tree, err := c.service.GetCategoryTree()
+	if err != nil {
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, tree)
+}
+
+
+func (c *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPatch) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categoryID := uint(IDs[0])
+
+	var categoryUpdate model.CategoryUpdate
+
+	if err := json.NewDecoder(r.Body).Decode(&categoryUpdate); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := c.service.UpdateCategory(categoryID, categoryUpdate); err != nil {
+		switch err {
+		case service.ErrEmptyCategoryName, service.ErrCategoryCycle:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrCategoryNotFound, service.ErrParentCategoryNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrCategoryExists:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Category updated!"})
+}
+
+
+func (c *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categoryID := uint(IDs[0])
+
+	if err := c.service.DeleteCategory(categoryID); err != nil {
+		switch err {
+		case service.ErrCategoryNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrCategoryHasChildren:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Category deleted!"})
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"encoding/json"
+	"net/http"
+)
+
+type CouponHandler struct {
+	service service.CouponService
+}
+
+func NewCouponHandler(s service.CouponService) *CouponHandler {
+	return &CouponHandler{service: s}
+}
+
+
+// writeCouponError answers the errors of creating or changing a coupon.
+func writeCouponError(w http.ResponseWriter, err error) {
+	switch err {
+	case service.ErrInvalidCouponCode, service.ErrInvalidCouponKind, service.ErrInvalidCouponScope, service.ErrInvalidPercentOff,
+		service.ErrInvalidAmountOff, service.ErrInvalidMinSpend, service.ErrCouponTarget, service.ErrInvalidCouponLimit,
+		service.ErrInvalidCouponWindow, service.ErrInvalidCurrency:
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	case service.ErrCouponNotFound, service.ErrBookNotFound, service.ErrCategoryNotFound:
+		helper.WriteError(w, http.StatusNotFound, err.Error())
+	case service.ErrCouponExists:
+		helper.WriteError(w, http.StatusConflict, err.Error())
+	default:
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+
+func (c *CouponHandler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var couponRequest model.CouponRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&couponRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	coupon, err := c.service.CreateCoupon(couponRequest)
+	if err != nil {
+		writeCouponError(w, err)
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusCreated, coupon)
+}
+
+
+func (c *CouponHandler) GetCoupons(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	coupons, err := c.service.GetCoupons()
+	if err != nil {
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, coupons)
+}
+
+
+func (c *CouponHandler) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 2, 1)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	couponID := uint(IDs[0])
+
+	coupon, err := c.service.GetCoupon(couponID)
+	if err != nil {
+		switch err {
+		case service.ErrCouponNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, coupon)
+}
+
+
+// UpdateCoupon replaces the terms of a coupon with the ones in the request body.
+func (c *CouponHandler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	couponID := uint(IDs[0])
+
+	var couponRequest model.CouponRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&couponRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := c.service.UpdateCoupon(couponID, couponRequest); err != nil {
+		writeCouponError(w, err)
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Coupon updated!"})
+}
+
+
+func (c *CouponHandler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	couponID := uint(IDs[0])
+
+	if err := c.service.DeleteCoupon(couponID); err != nil {
+		switch err {
+		case service.ErrCouponNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Coupon deleted!"})
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type ExchangeRateHandler struct {
+	service	service.ExchangeRateService
+}
+
+func NewExchangeRateHandler(s service.ExchangeRateService) *ExchangeRateHandler {
+	return &ExchangeRateHandler{service: s}
+}
+
+
+// requestedCurrency reads the currency a client wants prices shown in, from the currency
+// query param or the Accept-Currency header. It is empty when the client asks for none.
+func requestedCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	currency, err := helper.ParseCurrency(r)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+
+	return currency, true
+}
+
+
+func writeConversionError(w http.ResponseWriter, err error) {
+	switch err {
+	case service.ErrNoExchangeRate:
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+
+func (e *ExchangeRateHandler) SetRate(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	var rateRequest model.ExchangeRateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&rateRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	rate, err := e.service.SetRate(rateRequest)
+	if err != nil {
+		switch err {
+		case service.ErrEmptyFields, service.ErrInvalidCurrency, service.ErrSameCurrency, service.ErrInvalidRate:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, rate)
+}
+
+
+func (e *ExchangeRateHandler) GetRates(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	rates, err := e.service.GetRates()
+	if err != nil {
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, rates)
+}
+
+
+// DeleteRate removes the rate of the pair in the path, /rates/delete/{base}/{quote}.
+func (e *ExchangeRateHandler) DeleteRate(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/rates/delete/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		helper.WriteError(w, http.StatusBadRequest, helper.ErrInvalidPath.Error())
+		return
+	}
+
+	if err := e.service.DeleteRate(parts[0], parts[1]); err != nil {
+		switch err {
+		case service.ErrRateNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Exchange rate deleted!"})
+}
+
+
+// ImportRates stores the rates of a CSV file sent as the request body.
+func (e *ExchangeRateHandler) ImportRates(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	report, err := e.service.ImportRates(r.Body)
+	if err != nil {
+		var importErr *service.RateImportError
+
+		switch {
+		case errors.As(err, &importErr), err == service.ErrInvalidRatesHeader:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, report)
+}
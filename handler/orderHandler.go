@@ -2,16 +2,40 @@ package handler
 
 import (
 	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/money"
 	"BookVault-API/service"
+	"errors"
 	"net/http"
 )
 
 type OrderHandler struct {
 	service	service.OrderService
+	rates	service.ExchangeRateService
 }
 
-func NewOrderHandler(s service.OrderService) *OrderHandler {
-	return &OrderHandler{service: s}
+func NewOrderHandler(s service.OrderService, rates service.ExchangeRateService) *OrderHandler {
+	return &OrderHandler{service: s, rates: rates}
+}
+
+
+// writeOrders writes a response holding orders, with their prices also shown in the requested currency.
+func (o *OrderHandler) writeOrders(w http.ResponseWriter, currency string, data any, orders ...*model.OrderResponse) {
+	if err := o.rates.ConvertOrders(currency, orders...); err != nil {
+		writeConversionError(w, err)
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, data)
+}
+
+
+func orderRefs(orders []model.OrderResponse) []*model.OrderResponse {
+	refs := make([]*model.OrderResponse, 0, len(orders))
+	for i := range orders {
+		refs = append(refs, &orders[i])
+	}
+	return refs
 }
 
 
@@ -20,24 +44,49 @@ func (o *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
 		return
 	}
 
-	userID := uint(IDs[0])
+	userID, _, ok := userScopedIDs(w, r, 2, 0)
+	if !ok {
+		return
+	}
 
 	address, ok := helper.RequiredQueryParam(w, r, "address")
 	if !ok {
 		return
 	}
 
-	order, err := o.service.CreateOrder(userID, address)
+	var expectedTotal *money.Decimal
+	if value := r.URL.Query().Get("expected_total"); value != "" {
+		total, err := money.ParseDecimal(value)
+		if err != nil {
+			helper.WriteError(w, http.StatusBadRequest, "invalid expected_total")
+			return
+		}
+		expectedTotal = &total
+	}
+
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := o.service.CreateOrder(principal, userID, address, currency, expectedTotal)
 	if err != nil {
-		switch err {
-		case service.ErrEmptyCart:
+		var couponErr *service.CouponError
+		var limitErr *service.QuantityLimitError
+
+		switch {
+		case errors.As(err, &couponErr), errors.As(err, &limitErr):
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		case err == service.ErrEmptyCart, err == service.ErrNoExchangeRate:
 			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case err == service.ErrBookUnavailable, err == service.ErrInsufficientStock, err == service.ErrMixedCurrencies,
+			err == service.ErrTotalChanged:
+			helper.WriteError(w, http.StatusConflict, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
@@ -61,11 +110,20 @@ func (o *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 
 	orderID := uint(IDs[0])
 
-	if err := o.service.CancelOrder(orderID); err != nil {
-		switch err {
-		case service.ErrOrderNotFound:
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	if err := o.service.CancelOrder(principal, orderID); err != nil {
+		var transitionErr *service.StatusTransitionError
+
+		switch {
+		case errors.As(err, &transitionErr):
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		case err == service.ErrOrderNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
-		case service.ErrOrderCancel:
+		case err == service.ErrForbidden:
 			helper.WriteError(w, http.StatusForbidden, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
@@ -82,6 +140,11 @@ func (o *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
 	IDs, err := helper.ParseIDsFromPath(r, 2, 1)
 	if err != nil {
 		helper.WriteError(w, http.StatusBadRequest, err.Error())
@@ -90,18 +153,25 @@ func (o *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 
 	orderID := uint(IDs[0])
 
-	order, err := o.service.GetOrder(orderID)
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := o.service.GetOrder(principal, orderID)
 	if err != nil {
 		switch err {
 		case service.ErrOrderNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrForbidden:
+			helper.WriteError(w, http.StatusForbidden, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, order)
+	o.writeOrders(w, currency, order, order)
 }
 
 
@@ -110,21 +180,34 @@ func (o *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	userID, _, ok := userScopedIDs(w, r, 2, 0)
+	if !ok {
+		return
+	}
+
+	page, err := helper.ParsePageRequest(r)
 	if err != nil {
 		helper.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	userID := uint(IDs[0])
-
-	userOrders, err := o.service.GetUserOrders(userID)
+	userOrders, err := o.service.GetUserOrders(userID, page)
 	if err != nil {
-		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		switch err {
+		case service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, userOrders)
+	o.writeOrders(w, currency, userOrders, orderRefs(userOrders.Items)...)
 }
 
 
@@ -133,18 +216,39 @@ func (o *OrderHandler) GetOrdersByStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	currency, ok := requestedCurrency(w, r)
+	if !ok {
+		return
+	}
+
 	orderStatus, ok := helper.RequiredQueryParam(w, r, "status")
 	if !ok {
 		return
 	}
 
-	orders, err := o.service.GetOrdersByStatus(orderStatus)
+	page, err := helper.ParsePageRequest(r)
 	if err != nil {
-		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, orders)
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	orders, err := o.service.GetOrdersByStatus(principal, orderStatus, page)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidStatus, service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	o.writeOrders(w, currency, orders, orderRefs(orders.Items)...)
 }
 
 
@@ -166,9 +270,20 @@ func (o *OrderHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := o.service.UpdateStatus(orderID, status); err != nil {
-		switch err {
-		case service.ErrOrderNotFound:
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	if err := o.service.UpdateStatus(principal, orderID, status); err != nil {
+		var transitionErr *service.StatusTransitionError
+
+		switch {
+		case errors.As(err, &transitionErr):
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		case err == service.ErrInvalidStatus:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case err == service.ErrOrderNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/helper"
 	"BookVault-API/model"
 	"BookVault-API/service"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 )
 
 type ReviewHandler struct {
@@ -22,13 +26,12 @@ func (r *ReviewHandler) AddReview(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(req, 4, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	userID, IDs, ok := userScopedIDs(w, req, 2, 1)
+	if !ok {
 		return
 	}
 
-	userID, bookID := uint(IDs[0]), uint(IDs[1])
+	bookID := uint(IDs[0])
 
 	var reviewRequest model.ReviewRequest
 	if err := json.NewDecoder(req.Body).Decode(&reviewRequest); err != nil {
@@ -38,10 +41,14 @@ func (r *ReviewHandler) AddReview(w http.ResponseWriter, req *http.Request) {
 
 	if err := r.service.AddReview(userID, bookID, reviewRequest); err != nil {
 		switch err {
-		case service.ErrEmptyReview:
+		case service.ErrEmptyReview, service.ErrInvalidRating:
 			helper.WriteError(w, http.StatusBadRequest, err.Error())
 		case service.ErrUserNotFound, service.ErrBookNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrUnverifiedReview:
+			helper.WriteError(w, http.StatusForbidden, err.Error())
+		case service.ErrReviewExists:
+			helper.WriteError(w, http.StatusConflict, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
@@ -65,9 +72,26 @@ func (r *ReviewHandler) GetReviewsByBook(w http.ResponseWriter, req *http.Reques
 
 	bookID := uint(IDs[0])
 
-	reviews, err := r.service.GetReviewsByBook(bookID)
+	filter, err := parseReviewFilter(req)
 	if err != nil {
-		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := helper.ParsePageRequest(req)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviews, err := r.service.GetReviewsByBook(bookID, filter, page)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
 		return
 	}
 
@@ -75,6 +99,21 @@ func (r *ReviewHandler) GetReviewsByBook(w http.ResponseWriter, req *http.Reques
 }
 
 
+func parseReviewFilter(r *http.Request) (model.ReviewFilter, error) {
+	var filter model.ReviewFilter
+
+	if value := r.URL.Query().Get("verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("invalid verified")
+		}
+		filter.Verified = &verified
+	}
+
+	return filter, nil
+}
+
+
 func (r *ReviewHandler) GetReviewsByUser(w http.ResponseWriter, req *http.Request) {
 	if !helper.RequiredMethod(w, req, http.MethodGet) {
 		return
@@ -88,7 +127,12 @@ func (r *ReviewHandler) GetReviewsByUser(w http.ResponseWriter, req *http.Reques
 
 	userID := uint(IDs[0])
 
-	reviews, err := r.service.GetReviewsByUser(userID)
+	principal, ok := caller(w, req)
+	if !ok {
+		return
+	}
+
+	reviews, err := r.service.GetReviewsByUser(principal, userID)
 	if err != nil {
 		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		return
@@ -103,13 +147,12 @@ func (r *ReviewHandler) UpdateReview(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(req, 4, 2)
-	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+	userID, IDs, ok := userScopedIDs(w, req, 2, 1)
+	if !ok {
 		return
 	}
 
-	userID, bookID := uint(IDs[0]), uint(IDs[1])
+	bookID := uint(IDs[0])
 
 	var reviewRequest model.ReviewRequest
 	if err := json.NewDecoder(req.Body).Decode(&reviewRequest); err != nil {
@@ -119,6 +162,8 @@ func (r *ReviewHandler) UpdateReview(w http.ResponseWriter, req *http.Request) {
 
 	if err := r.service.UpdateReview(userID, bookID, reviewRequest); err != nil {
 		switch err {
+		case service.ErrEmptyReview, service.ErrInvalidRating:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
 		case service.ErrReviewNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
 		default:
@@ -131,6 +176,49 @@ func (r *ReviewHandler) UpdateReview(w http.ResponseWriter, req *http.Request) {
 }
 
 
+// SetReview creates or replaces the caller's review of a book, answering 201 when it was created.
+func (r *ReviewHandler) SetReview(w http.ResponseWriter, req *http.Request) {
+	if !helper.RequiredMethod(w, req, http.MethodPut) {
+		return
+	}
+
+	userID, IDs, ok := userScopedIDs(w, req, 2, 1)
+	if !ok {
+		return
+	}
+
+	bookID := uint(IDs[0])
+
+	var reviewRequest model.ReviewRequest
+	if err := json.NewDecoder(req.Body).Decode(&reviewRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	created, err := r.service.SetReview(userID, bookID, reviewRequest)
+	if err != nil {
+		switch err {
+		case service.ErrEmptyReview, service.ErrInvalidRating:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrUserNotFound, service.ErrBookNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrUnverifiedReview:
+			helper.WriteError(w, http.StatusForbidden, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	if created {
+		helper.WriteJSON(w, http.StatusCreated, map[string]string{"message": "Review added!"})
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Review updated!"})
+}
+
+
 func (r *ReviewHandler) DeleteReviewByID(w http.ResponseWriter, req *http.Request) {
 	if !helper.RequiredMethod(w, req, http.MethodDelete) {
 		return
@@ -144,10 +232,17 @@ func (r *ReviewHandler) DeleteReviewByID(w http.ResponseWriter, req *http.Reques
 
 	reviewID := uint(IDs[0])
 
-	if err := r.service.DeleteReviewByID(reviewID); err != nil {
+	principal, ok := caller(w, req)
+	if !ok {
+		return
+	}
+
+	if err := r.service.DeleteReviewByID(principal, reviewID); err != nil {
 		switch err {
 		case service.ErrReviewNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrForbidden:
+			helper.WriteError(w, http.StatusForbidden, err.Error())
 		default:
 			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
 		}
@@ -155,4 +250,198 @@ func (r *ReviewHandler) DeleteReviewByID(w http.ResponseWriter, req *http.Reques
 	}
 
 	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Review deleted!"})
-}
\ No newline at end of file
+}
+
+
+func (r *ReviewHandler) GetModerationQueue(w http.ResponseWriter, req *http.Request) {
+	if !helper.RequiredMethod(w, req, http.MethodGet) {
+		return
+	}
+
+	page, err := helper.ParsePageRequest(req)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviews, err := r.service.GetModerationQueue(page)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidCursor, service.ErrInvalidSort:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, reviews)
+}
+
+
+func (r *ReviewHandler) ApproveReview(w http.ResponseWriter, req *http.Request) {
+	r.moderateReview(w, req, r.service.ApproveReview, "Review approved!")
+}
+
+
+func (r *ReviewHandler) RejectReview(w http.ResponseWriter, req *http.Request) {
+	r.moderateReview(w, req, r.service.RejectReview, "Review rejected!")
+}
+
+
+// moderateReview handles the approve and reject endpoints, which only differ in the decision taken.
+// The request body with the reason is optional.
+func (r *ReviewHandler) moderateReview(w http.ResponseWriter, req *http.Request, decide func(auth.Principal, uint, model.ModerationRequest) error, message string) {
+	if !helper.RequiredMethod(w, req, http.MethodPatch) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(req, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviewID := uint(IDs[0])
+
+	principal, ok := caller(w, req)
+	if !ok {
+		return
+	}
+
+	var moderationRequest model.ModerationRequest
+	if err := json.NewDecoder(req.Body).Decode(&moderationRequest); err != nil && !errors.Is(err, io.EOF) {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := decide(principal, reviewID, moderationRequest); err != nil {
+		switch err {
+		case service.ErrRejectReasonRequired:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrReviewNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrReviewAlreadyModerated:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+
+func (r *ReviewHandler) ReportReview(w http.ResponseWriter, req *http.Request) {
+	if !helper.RequiredMethod(w, req, http.MethodPost) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(req, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviewID := uint(IDs[0])
+
+	principal, ok := caller(w, req)
+	if !ok {
+		return
+	}
+
+	var reportRequest model.ReportRequest
+	if err := json.NewDecoder(req.Body).Decode(&reportRequest); err != nil && !errors.Is(err, io.EOF) {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.service.ReportReview(principal, reviewID, reportRequest); err != nil {
+		switch err {
+		case service.ErrReportOwnReview:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrReviewNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		case service.ErrReviewAlreadyReported:
+			helper.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusCreated, map[string]string{"message": "Review reported!"})
+}
+
+
+func (r *ReviewHandler) VoteReview(w http.ResponseWriter, req *http.Request) {
+	if !helper.RequiredMethod(w, req, http.MethodPost) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(req, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviewID := uint(IDs[0])
+
+	helpful, err := strconv.ParseBool(req.URL.Query().Get("helpful"))
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid helpful")
+		return
+	}
+
+	principal, ok := caller(w, req)
+	if !ok {
+		return
+	}
+
+	if err := r.service.VoteReview(principal, reviewID, helpful); err != nil {
+		switch err {
+		case service.ErrVoteOwnReview:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrReviewNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Vote recorded!"})
+}
+
+
+func (r *ReviewHandler) RetractVote(w http.ResponseWriter, req *http.Request) {
+	if !helper.RequiredMethod(w, req, http.MethodDelete) {
+		return
+	}
+
+	IDs, err := helper.ParseIDsFromPath(req, 3, 2)
+	if err != nil {
+		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviewID := uint(IDs[0])
+
+	principal, ok := caller(w, req)
+	if !ok {
+		return
+	}
+
+	if err := r.service.RetractVote(principal, reviewID); err != nil {
+		switch err {
+		case service.ErrReviewNotFound, service.ErrVoteNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Vote retracted!"})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"encoding/json"
+	"net/http"
+)
+
+type SettingsHandler struct {
+	service service.SettingsService
+}
+
+func NewSettingsHandler(s service.SettingsService) *SettingsHandler {
+	return &SettingsHandler{service: s}
+}
+
+
+func (s *SettingsHandler) GetReviewSettings(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	settings, err := s.service.GetReviewSettings()
+	if err != nil {
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, settings)
+}
+
+
+func (s *SettingsHandler) UpdateReviewSettings(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPatch) {
+		return
+	}
+
+	var settingsRequest model.ReviewSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&settingsRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	settings, err := s.service.UpdateReviewSettings(settingsRequest)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidAutoApprove, service.ErrInvalidReportThreshold:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, settings)
+}
+
+
+func (s *SettingsHandler) GetRateSettings(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	settings, err := s.service.GetRateSettings()
+	if err != nil {
+		helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, settings)
+}
+
+
+func (s *SettingsHandler) UpdateRateSettings(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPatch) {
+		return
+	}
+
+	var settingsRequest model.RateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&settingsRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	settings, err := s.service.UpdateRateSettings(settingsRequest)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidRateMaxAge:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, settings)
+}
@@ -68,22 +68,81 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, map[string]string{"token": token})
+	helper.WriteJSON(w, http.StatusOK, token)
 }
 
 
-func (u *UserHandler) CreateDetails(w http.ResponseWriter, r *http.Request) {
+func (u *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	if !helper.RequiredMethod(w, r, http.MethodPost) {
 		return
 	}
 
-	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
+	var refreshRequest model.RefreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&refreshRequest); err != nil {
+		helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	token, err := u.service.Refresh(refreshRequest.RefreshToken)
 	if err != nil {
-		helper.WriteError(w, http.StatusBadRequest, err.Error())
+		switch err {
+		case service.ErrEmptyFields:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		case service.ErrInvalidRefreshToken, service.ErrRefreshTokenReused:
+			helper.WriteError(w, http.StatusUnauthorized, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
 		return
 	}
 
-	userID := uint(IDs[0])
+	helper.WriteJSON(w, http.StatusOK, token)
+}
+
+
+func (u *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	principal, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	var refreshRequest model.RefreshRequest
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&refreshRequest); err != nil {
+			helper.WriteError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	if err := u.service.Logout(principal, refreshRequest.RefreshToken); err != nil {
+		switch err {
+		case service.ErrInvalidRefreshToken:
+			helper.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "Logged out!"})
+}
+
+
+func (u *UserHandler) CreateDetails(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	userID, _, ok := userScopedIDs(w, r, 2, 0)
+	if !ok {
+		return
+	}
 	
 	var userDetailsRequest model.UserDetailsRequest
 
@@ -111,6 +170,32 @@ func (u *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID, _, ok := userScopedIDs(w, r, 2, 0)
+	if !ok {
+		return
+	}
+
+	userDetails, err := u.service.GetUserByID(userID)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			helper.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			helper.WriteError(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	helper.WriteJSON(w, http.StatusOK, userDetails)
+}
+
+
+// DeleteUser deletes a user and revokes their refresh tokens.
+func (u *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !helper.RequiredMethod(w, r, http.MethodDelete) {
+		return
+	}
+
 	IDs, err := helper.ParseIDsFromPath(r, 3, 2)
 	if err != nil {
 		helper.WriteError(w, http.StatusBadRequest, err.Error())
@@ -119,8 +204,7 @@ func (u *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 
 	userID := uint(IDs[0])
 
-	userDetails, err := u.service.GetUserByID(userID)
-	if err != nil {
+	if err := u.service.DeleteUser(userID); err != nil {
 		switch err {
 		case service.ErrUserNotFound:
 			helper.WriteError(w, http.StatusNotFound, err.Error())
@@ -130,5 +214,5 @@ func (u *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	helper.WriteJSON(w, http.StatusOK, userDetails)
+	helper.WriteJSON(w, http.StatusOK, map[string]string{"message": "User deleted!"})
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package helper
 
 import (
+	"BookVault-API/model"
+	"BookVault-API/money"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,11 +11,15 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
-	ErrInvalidPath	= errors.New("invalid path")
-	ErrInvalidId	= errors.New("invalid id")
+	ErrInvalidPath		= errors.New("invalid path")
+	ErrInvalidId		= errors.New("invalid id")
+	ErrInvalidLimit		= errors.New("limit must be a positive number")
+	ErrInvalidDate		= errors.New("dates must be YYYY-MM-DD or RFC 3339")
+	ErrInvalidCurrency	= errors.New("currency must be a three-letter ISO 4217 code")
 )
 
 func WriteJSON(w http.ResponseWriter, httpStatusCode int, data interface{}) {
@@ -67,6 +73,77 @@ func ParseIDsFromPath(r *http.Request, expectedParts, idStartIndex int) ([]int,
 }
 
 
+// ParsePageRequest reads the query params shared by list endpoints: limit, cursor,
+// sort (a field name, prefixed with "-" for descending order), created_after and created_before.
+func ParsePageRequest(r *http.Request) (model.PageRequest, error) {
+	query := r.URL.Query()
+
+	page := model.PageRequest{Cursor: query.Get("cursor")}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return page, ErrInvalidLimit
+		}
+		page.Limit = limit
+	}
+
+	page.Sort = query.Get("sort")
+	if strings.HasPrefix(page.Sort, "-") {
+		page.Sort = page.Sort[1:]
+		page.Desc = true
+	}
+
+	var err error
+
+	if page.CreatedAfter, err = parseDate(query.Get("created_after")); err != nil {
+		return page, err
+	}
+	if page.CreatedBefore, err = parseDate(query.Get("created_before")); err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+
+// ParseCurrency reads the currency prices are to be shown in: the currency query param, or else the
+// first currency of the Accept-Currency header, e.g. "USD" of "usd, eur;q=0.5". It is empty when neither is given.
+func ParseCurrency(r *http.Request) (string, error) {
+	value := r.URL.Query().Get("currency")
+	if value == "" {
+		value, _, _ = strings.Cut(r.Header.Get("Accept-Currency"), ",")
+		value, _, _ = strings.Cut(value, ";")
+	}
+
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+
+	if !money.ValidCurrency(value) {
+		return "", ErrInvalidCurrency
+	}
+
+	return value, nil
+}
+
+
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return &date, nil
+		}
+	}
+
+	return nil, ErrInvalidDate
+}
+
+
 func AssertResponse(t *testing.T, w *httptest.ResponseRecorder, wantStatus int, wantSubstring string) {
 	t.Helper()
 
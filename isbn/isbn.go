@@ -0,0 +1,97 @@
+// Package isbn validates and normalizes International Standard Book Numbers.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidISBN = errors.New("invalid ISBN")
+)
+
+
+// clean drops the hyphens and spaces ISBNs are usually printed with and upper-cases the X check digit.
+func clean(s string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s)))
+}
+
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+
+// checkDigit10 computes the ISBN-10 check digit of the first nine digits, which is X for 10.
+func checkDigit10(first9 string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(first9[i]-'0') * (10 - i)
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+
+// checkDigit13 computes the ISBN-13 check digit of the first twelve digits.
+func checkDigit13(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(first12[i]-'0') * weight
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+
+// ValidISBN10 reports whether s, without separators, is an ISBN-10 with a correct check digit.
+func ValidISBN10(s string) bool {
+	return len(s) == 10 && digits(s[:9]) && checkDigit10(s[:9]) == s[9]
+}
+
+
+// ValidISBN13 reports whether s, without separators, is an ISBN-13 with a correct check digit.
+func ValidISBN13(s string) bool {
+	return len(s) == 13 && digits(s) && (strings.HasPrefix(s, "978") || strings.HasPrefix(s, "979")) && checkDigit13(s[:12]) == s[12]
+}
+
+
+// Normalize validates an ISBN-10 or ISBN-13, written with or without hyphens and spaces,
+// and returns it as a bare ISBN-13. ISBN-10s are converted under the 978 prefix.
+func Normalize(s string) (string, error) {
+	s = clean(s)
+
+	switch {
+	case ValidISBN13(s):
+		return s, nil
+	case ValidISBN10(s):
+		first12 := "978" + s[:9]
+		return first12 + string(checkDigit13(first12)), nil
+	default:
+		return "", ErrInvalidISBN
+	}
+}
+
+
+// ToISBN10 returns the ISBN-10 form of a normalized ISBN-13. Only 978 ISBNs have one.
+func ToISBN10(isbn13 string) (string, bool) {
+	if !ValidISBN13(isbn13) || !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+
+	first9 := isbn13[3:12]
+	return first9 + string(checkDigit10(first9)), true
+}
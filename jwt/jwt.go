@@ -1,8 +1,11 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,8 +15,21 @@ import (
 var (
 	ErrInvalidSigningMethod = errors.New("invalid signing method")
 	ErrInvalidToken			= errors.New("invalid token")
+	ErrTokenRevoked			= errors.New("token has been revoked")
 )
 
+// RevocationChecker reports whether the access token with the given ID (jti), issued to the
+// given user, was revoked.
+type RevocationChecker func(tokenID string, userID uint) (bool, error)
+
+var revocationChecker RevocationChecker
+
+
+// SetRevocationChecker makes ParseToken reject tokens the checker reports as revoked.
+func SetRevocationChecker(checker RevocationChecker) {
+	revocationChecker = checker
+}
+
 
 func getSecret() []byte {
 	secret := os.Getenv("JWT_SECRET")
@@ -21,8 +37,26 @@ func getSecret() []byte {
 }
 
 
-func GenerateToken(username, role string, duration time.Duration) (string, error) {
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+
+// GenerateToken signs an access token whose subject is the numeric ID of the user.
+// Every token gets a unique ID (jti) so that it can be revoked before it expires.
+func GenerateToken(userID uint, username, role string, duration time.Duration) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
+		"sub":		strconv.FormatUint(uint64(userID), 10),
+		"jti":		tokenID,
 		"username": username,
 		"role":		role,
 		"exp":		time.Now().Add(duration).Unix(),
@@ -45,9 +79,35 @@ func ParseToken(tokenString string) (jwt.MapClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
 	}
 
-	return nil, ErrInvalidToken
-}
\ No newline at end of file
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if revocationChecker != nil {
+		subject, err := claims.GetSubject()
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+
+		userID, err := strconv.ParseUint(subject, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+
+		revoked, err := revocationChecker(tokenID, uint(userID))
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/helper"
 	"BookVault-API/jwt"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -29,15 +31,35 @@ func AuthMiddleware(allowedRoles ...string) func(http.HandlerFunc) http.HandlerF
                 helper.WriteError(w, http.StatusUnauthorized, "invalid token")               
                 return
             }
-            
+
+            subject, err := claims.GetSubject()
+            if err != nil {
+                helper.WriteError(w, http.StatusUnauthorized, "invalid token claims")
+                return
+            }
+
+            userID, err := strconv.ParseUint(subject, 10, 64)
+            if err != nil || userID == 0 {
+                helper.WriteError(w, http.StatusUnauthorized, "invalid token claims")
+                return
+            }
+
+            role, ok := claims["role"].(string)
+            if !ok {
+                helper.WriteError(w, http.StatusUnauthorized, "invalid token claims")                    
+                return
+            }
+
+            username, _ := claims["username"].(string)
+            tokenID, _ := claims["jti"].(string)
+
+            expiresAt, err := claims.GetExpirationTime()
+            if err != nil || expiresAt == nil {
+                helper.WriteError(w, http.StatusUnauthorized, "invalid token claims")
+                return
+            }
             
             if len(allowedRoles) > 0 {
-                role, ok := claims["role"].(string)
-                if !ok {
-                    helper.WriteError(w, http.StatusUnauthorized, "invalid token claims")                    
-                    return
-                }
-
                 authorized := false
                 for _, r := range allowedRoles {
                     if role == r {
@@ -51,7 +73,15 @@ func AuthMiddleware(allowedRoles ...string) func(http.HandlerFunc) http.HandlerF
                     return
                 }
             }
-            next(w, r)
+
+            principal := auth.Principal{
+                UserID:     uint(userID),
+                Username:   username,
+                Role:       role,
+                TokenID:    tokenID,
+                ExpiresAt:  expiresAt.Time,
+            }
+            next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
         }
     }
-}
\ No newline at end of file
+}
@@ -0,0 +1,93 @@
+package model
+
+import "time"
+
+// AuthorRole is the part an author had in a book.
+type AuthorRole string
+
+const (
+	RoleAuthor		AuthorRole = "author"
+	RoleTranslator	AuthorRole = "translator"
+	RoleEditor		AuthorRole = "editor"
+)
+
+// Author is a person credited on books. Aliases are other names the author is known by,
+// e.g. "Dostoevsky" for "Fyodor Dostoevsky"; lookups by name match them too.
+type Author struct {
+	ID			uint			`gorm:"primaryKey"`
+	Name		string
+	Bio			string
+	BornOn		*time.Time		`gorm:"type:date"`
+	DiedOn		*time.Time		`gorm:"type:date"`
+	Aliases		[]AuthorAlias
+	CreatedAt	time.Time
+	UpdatedAt	time.Time
+}
+
+type AuthorAlias struct {
+	ID			uint	`gorm:"primaryKey"`
+	AuthorID	uint
+	Alias		string
+}
+
+// BookAuthor credits an author on a book in one role. Position orders the credits of a book.
+type BookAuthor struct {
+	BookID		uint		`gorm:"primaryKey;autoIncrement:false"`
+	AuthorID	uint		`gorm:"primaryKey;autoIncrement:false"`
+	Role		AuthorRole	`gorm:"primaryKey"`
+	Position	int
+	Author		Author
+}
+
+// AuthorRequest creates an author. Dates are YYYY-MM-DD.
+type AuthorRequest struct {
+	Name		string		`json:"name"`
+	Aliases		[]string	`json:"aliases"`
+	Bio			string		`json:"bio"`
+	BornOn		string		`json:"born_on"`
+	DiedOn		string		`json:"died_on"`
+}
+
+// AuthorUpdate changes an author. Nil fields are left unchanged, aliases replace the
+// current ones and an empty date clears it.
+type AuthorUpdate struct {
+	Name		*string		`json:"name"`
+	Aliases		*[]string	`json:"aliases"`
+	Bio			*string		`json:"bio"`
+	BornOn		*string		`json:"born_on"`
+	DiedOn		*string		`json:"died_on"`
+}
+
+// BookAuthorRequest credits an author on a book; the role defaults to author.
+type BookAuthorRequest struct {
+	AuthorID	uint		`json:"author_id"`
+	Role		AuthorRole	`json:"role"`
+}
+
+type BookAuthorsRequest struct {
+	Authors		[]BookAuthorRequest	`json:"authors"`
+}
+
+// BookAuthorRef is an author as credited on a book.
+type BookAuthorRef struct {
+	ID			uint		`json:"id"`
+	Name		string		`json:"name"`
+	Role		AuthorRole	`json:"role"`
+}
+
+// AuthoredBook is a book on an author page with the roles the author had in it.
+type AuthoredBook struct {
+	BookResponse
+	Roles		[]AuthorRole	`json:"roles"`
+}
+
+// AuthorResponse is the profile of an author. Books is only filled on the author page.
+type AuthorResponse struct {
+	ID			uint			`json:"id"`
+	Name		string			`json:"name"`
+	Aliases		[]string		`json:"aliases"`
+	Bio			string			`json:"bio"`
+	BornOn		string			`json:"born_on,omitempty"`
+	DiedOn		string			`json:"died_on,omitempty"`
+	Books		[]AuthoredBook	`json:"books,omitempty"`
+}
@@ -1,30 +1,82 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"BookVault-API/money"
+
+	"gorm.io/gorm"
+)
 
 type Book struct {
 	gorm.Model
 	Title       string
 	Author      string
 	Description string
-	Price       float32
-	InStock     bool
+	Price		money.Money	`gorm:"embedded;embeddedPrefix:price_"`
+	Stock		int
+	MaxQuantity	*int
+	ISBN13		*string		`gorm:"column:isbn13"`
+	ISBN10		*string		`gorm:"column:isbn10"`
 	Reviews		[]Review
+	Rating		BookRating	`gorm:"foreignKey:BookID"`
+	Categories	[]Category	`gorm:"many2many:book_categories;joinForeignKey:BookID;joinReferences:CategoryID"`
+	Authors		[]BookAuthor	`gorm:"foreignKey:BookID"`
 }
 
 
+// BookRequest creates or updates a book. MaxQuantity caps the copies of the book a cart can
+// hold; 0 removes the cap and, on updates, a missing value leaves it unchanged.
 type BookRequest struct {
 	Title		string		`json:"title"`
 	Author		string		`json:"author"`
 	Description	string		`json:"description"`
-	Price		*float32	`json:"price"`
+	Price		*money.Decimal	`json:"price"`
+	Currency	string			`json:"currency"`
+	Stock		*int			`json:"stock"`
+	MaxQuantity	*int			`json:"max_quantity"`
+	ISBN		string			`json:"isbn"`
+}
+
+
+// StockUpdate sets the stock of a book either to an absolute value or by a relative delta.
+type StockUpdate struct {
+	Stock		*int
+	Delta		*int
 }
 
 
 type BookResponse struct {
-	Title		string	`json:"title"`
-	Author		string	`json:"author"`
-	Description	string	`json:"description"`
-	Price		float32	`json:"price"`
-	InStock		bool	`json:"in_stock"`
-}
\ No newline at end of file
+	ID			uint			`json:"id"`
+	Title		string			`json:"title"`
+	Author		string			`json:"author"`
+	Authors		[]BookAuthorRef	`json:"authors"`
+	Description	string			`json:"description"`
+	Price		money.Money		`json:"price"`
+	Currency	string			`json:"currency"`
+	Stock		int				`json:"stock"`
+	InStock		bool			`json:"in_stock"`
+	MaxQuantity	*int			`json:"max_quantity,omitempty"`
+	ISBN13		string			`json:"isbn13,omitempty"`
+	ISBN10		string			`json:"isbn10,omitempty"`
+	Rating		RatingSummary	`json:"rating"`
+	Categories	[]CategoryRef	`json:"categories"`
+	Converted	*ConvertedPrice	`json:"converted,omitempty"`
+}
+
+
+// BookFilter narrows the book list. Zero values leave the list unfiltered.
+// A price bound only matches books priced in the currency of the bound.
+type BookFilter struct {
+	Author		string
+	MinPrice	*money.Money
+	MaxPrice	*money.Money
+	InStock		*bool
+}
+
+
+// BookSearchResult is a book matched by a catalog search. Highlight is an excerpt of the
+// description with the matched words wrapped in <mark> tags.
+type BookSearchResult struct {
+	BookResponse
+	Rank		float32	`json:"rank"`
+	Highlight	string	`json:"highlight"`
+}
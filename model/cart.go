@@ -1,6 +1,11 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"BookVault-API/money"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Cart struct {
 	gorm.Model
@@ -10,18 +15,79 @@ type Cart struct {
 }
 
 
+// CartBook is a line of a cart. Price is what the book cost when it was last added to the cart.
 type CartBook struct {
 	gorm.Model
-	CartID		uint	`gorm:"index"`
-	Cart		Cart	`gorm:"constraint:OnDelete:CASCADE;"`
-	BookID		uint	`gorm:"index"`
-	Book		Book	`gorm:"constraint:OnDelete:CASCADE;"`
+	CartID		uint		`gorm:"index"`
+	Cart		Cart		`gorm:"constraint:OnDelete:CASCADE;"`
+	BookID		uint		`gorm:"index"`
+	Book		Book		`gorm:"constraint:OnDelete:CASCADE;"`
 	Quantity	int		
+	Price		money.Money	`gorm:"embedded;embeddedPrefix:price_"`
+}
+
+
+// CartLineRequest sets the copies of a book in a cart; a quantity of 0 removes the book.
+type CartLineRequest struct {
+	BookID		uint	`json:"book_id"`
+	Quantity	int		`json:"quantity"`
 }
 
 
+// CartLinesRequest sets several lines of a cart at once.
+type CartLinesRequest struct {
+	Lines	[]CartLineRequest	`json:"lines"`
+}
+
+
+// CartResponse is a cart with the coupons applied to it. Totals are left out while the cart
+// holds books priced in different currencies, which cannot be ordered together.
 type CartResponse struct {
-	ID		uint			`json:"id"`
-	UserID	uint			`json:"user_id"`
-	Books	[]BookResponse	`json:"books"`
+	ID		uint				`json:"id"`
+	UserID	uint				`json:"user_id"`
+	Books	[]CartBookResponse	`json:"books"`
+	Coupons	[]AppliedCoupon		`json:"coupons"`
+	Totals	*CartTotals			`json:"totals,omitempty"`
+}
+
+
+// CartTotals is what the cart costs: the sum of its books, the discount of its coupons and the total.
+// Converted shows them in the currency asked for.
+type CartTotals struct {
+	Subtotal	money.Money			`json:"subtotal"`
+	Discount	money.Money			`json:"discount"`
+	Total		money.Money			`json:"total"`
+	Currency	string				`json:"currency"`
+	Converted	*ConvertedTotals	`json:"converted,omitempty"`
+}
+
+
+// ConvertedTotals are cart totals shown in another currency, at the rate given. The subtotal and
+// the discount are converted and the total is what is left of them, so the totals still add up.
+type ConvertedTotals struct {
+	Subtotal		money.Money	`json:"subtotal"`
+	Discount		money.Money	`json:"discount"`
+	Total			money.Money	`json:"total"`
+	Currency		string		`json:"currency"`
+	Rate			string		`json:"rate"`
+	RateUpdatedAt	time.Time	`json:"rate_updated_at"`
+	RateStale		bool		`json:"rate_stale"`
+}
+
+
+// CartBookResponse is a book in the cart. Available is false once the book was removed from the catalog.
+// Lines are charged at the current price; PriceChanged flags a price that changed since the book was
+// added, which is then given as AddedPrice, and OutOfStock flags lines with more copies than in stock.
+// ConvertedUnitPrice and ConvertedLineTotal show the line in the currency asked for.
+type CartBookResponse struct {
+	BookResponse
+	Available			bool			`json:"available"`
+	Quantity			int				`json:"quantity"`
+	UnitPrice			money.Money		`json:"unit_price"`
+	LineTotal			money.Money		`json:"line_total"`
+	ConvertedUnitPrice	*ConvertedPrice	`json:"converted_unit_price,omitempty"`
+	ConvertedLineTotal	*ConvertedPrice	`json:"converted_line_total,omitempty"`
+	AddedPrice			*money.Money	`json:"added_price,omitempty"`
+	PriceChanged		bool			`json:"price_changed"`
+	OutOfStock			bool			`json:"out_of_stock"`
 }
\ No newline at end of file
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Category is a node of the category tree, e.g. Fiction > Crime > Nordic Noir.
+// Root categories have no parent; books can belong to any number of categories.
+type Category struct {
+	ID			uint		`gorm:"primaryKey"`
+	Name		string
+	ParentID	*uint
+	Parent		*Category
+	CreatedAt	time.Time
+	UpdatedAt	time.Time
+}
+
+type CategoryRequest struct {
+	Name		string	`json:"name"`
+	ParentID	*uint	`json:"parent_id"`
+}
+
+// CategoryUpdate renames or moves a category. Nil fields are left unchanged and
+// a parent_id of 0 moves the category to the root of the tree.
+type CategoryUpdate struct {
+	Name		*string	`json:"name"`
+	ParentID	*uint	`json:"parent_id"`
+}
+
+type BookCategoriesRequest struct {
+	CategoryIDs	[]uint	`json:"category_ids"`
+}
+
+// CategoryRef is a category as listed on a book.
+type CategoryRef struct {
+	ID			uint	`json:"id"`
+	Name		string	`json:"name"`
+}
+
+// CategoryNode is a category of the tree with its subcategories. BookCount counts the books
+// assigned to the category itself and TotalBookCount the distinct books of its whole subtree.
+type CategoryNode struct {
+	ID				uint			`json:"id"`
+	Name			string			`json:"name"`
+	ParentID		*uint			`json:"parent_id,omitempty"`
+	BookCount		int				`json:"book_count"`
+	TotalBookCount	int				`json:"total_book_count"`
+	Children		[]CategoryNode	`json:"children"`
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"BookVault-API/money"
+	"time"
+)
+
+// CouponKind decides how a coupon's discount is computed.
+type CouponKind string
+
+const (
+	CouponPercent	CouponKind = "percent"
+	CouponFixed		CouponKind = "fixed"
+)
+
+// CouponScope is what a coupon takes its discount off: the whole order, the copies of one book,
+// or the books of a category and its subcategories.
+type CouponScope string
+
+const (
+	ScopeOrder		CouponScope = "order"
+	ScopeBook		CouponScope = "book"
+	ScopeCategory	CouponScope = "category"
+)
+
+// Coupon is a discount code. Percent coupons take PercentOff percent off and fixed coupons
+// AmountOff off what they apply to. AmountOff and MinSpend are minor units of Currency, and a
+// MinSpend of 0 means there is none. Nil limits and dates are unlimited; a coupon that is not
+// Stackable cannot be combined with any other coupon.
+type Coupon struct {
+	ID				uint		`gorm:"primaryKey"`
+	Code			string
+	Kind			CouponKind
+	PercentOff		int
+	AmountOff		int64
+	Currency		string
+	Scope			CouponScope
+	BookID			*uint
+	CategoryID		*uint
+	MinSpend		int64
+	MaxUses			*int
+	MaxUsesPerUser	*int
+	StartsAt		*time.Time
+	EndsAt			*time.Time
+	Stackable		bool
+	CreatedAt		time.Time
+	UpdatedAt		time.Time
+}
+
+// CartCoupon is a coupon applied to a cart.
+type CartCoupon struct {
+	CartID		uint	`gorm:"primaryKey;autoIncrement:false"`
+	CouponID	uint	`gorm:"primaryKey;autoIncrement:false"`
+}
+
+// OrderCoupon is a coupon an order was placed with. The terms are copied from the coupon at
+// checkout and CouponID is cleared when the coupon is deleted, so the order never changes.
+type OrderCoupon struct {
+	ID			uint			`gorm:"primaryKey"`
+	OrderID		uint
+	CouponID	*uint
+	Code		string
+	Kind		CouponKind
+	Scope		CouponScope
+	PercentOff	int
+	AmountOff	int64
+	Discount	money.Money		`gorm:"embedded;embeddedPrefix:discount_"`
+}
+
+// CouponRequest creates a coupon or replaces its terms. The scope defaults to order and the
+// currency, which amount_off and min_spend are in, to the catalog currency.
+type CouponRequest struct {
+	Code			string			`json:"code"`
+	Kind			CouponKind		`json:"kind"`
+	PercentOff		int				`json:"percent_off"`
+	AmountOff		*money.Decimal	`json:"amount_off"`
+	Currency		string			`json:"currency"`
+	Scope			CouponScope		`json:"scope"`
+	BookID			*uint			`json:"book_id"`
+	CategoryID		*uint			`json:"category_id"`
+	MinSpend		*money.Decimal	`json:"min_spend"`
+	MaxUses			*int			`json:"max_uses"`
+	MaxUsesPerUser	*int			`json:"max_uses_per_user"`
+	StartsAt		*time.Time		`json:"starts_at"`
+	EndsAt			*time.Time		`json:"ends_at"`
+	Stackable		bool			`json:"stackable"`
+}
+
+// CouponResponse is a coupon with the number of orders placed with it, cancelled ones excluded.
+type CouponResponse struct {
+	ID				uint			`json:"id"`
+	Code			string			`json:"code"`
+	Kind			CouponKind		`json:"kind"`
+	PercentOff		int				`json:"percent_off,omitempty"`
+	AmountOff		*money.Money	`json:"amount_off,omitempty"`
+	Currency		string			`json:"currency"`
+	Scope			CouponScope		`json:"scope"`
+	BookID			*uint			`json:"book_id,omitempty"`
+	CategoryID		*uint			`json:"category_id,omitempty"`
+	MinSpend		*money.Money	`json:"min_spend,omitempty"`
+	MaxUses			*int			`json:"max_uses,omitempty"`
+	MaxUsesPerUser	*int			`json:"max_uses_per_user,omitempty"`
+	Uses			int				`json:"uses"`
+	StartsAt		*time.Time		`json:"starts_at,omitempty"`
+	EndsAt			*time.Time		`json:"ends_at,omitempty"`
+	Stackable		bool			`json:"stackable"`
+}
+
+// AppliedCoupon is a coupon of a cart or order and the discount it gives. On a cart, Error tells
+// why a coupon gives no discount at the moment, e.g. because the minimum spend is not reached.
+type AppliedCoupon struct {
+	Code		string			`json:"code"`
+	Kind		CouponKind		`json:"kind"`
+	Scope		CouponScope		`json:"scope"`
+	PercentOff	int				`json:"percent_off,omitempty"`
+	AmountOff	*money.Money	`json:"amount_off,omitempty"`
+	Discount	money.Money		`json:"discount"`
+	Error		string			`json:"error,omitempty"`
+}
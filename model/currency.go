@@ -0,0 +1,48 @@
+package model
+
+import (
+	"BookVault-API/money"
+	"time"
+)
+
+// ExchangeRate is the price of one unit of the base currency in the quote currency.
+// The rate is kept as the decimal text it was stored with.
+type ExchangeRate struct {
+	Base		string		`gorm:"primaryKey"`
+	Quote		string		`gorm:"primaryKey"`
+	Rate		string		`gorm:"type:numeric(20,10)"`
+	UpdatedAt	time.Time
+}
+
+
+type ExchangeRateRequest struct {
+	Base		string			`json:"base"`
+	Quote		string			`json:"quote"`
+	Rate		*money.Decimal	`json:"rate"`
+}
+
+
+// ExchangeRateResponse is a stored rate. Stale is set once the rate is older than the maximum age of the rate settings.
+type ExchangeRateResponse struct {
+	Base		string		`json:"base"`
+	Quote		string		`json:"quote"`
+	Rate		string		`json:"rate"`
+	UpdatedAt	time.Time	`json:"updated_at"`
+	Stale		bool		`json:"stale"`
+}
+
+
+// RateImportReport counts the rates an import stored.
+type RateImportReport struct {
+	Imported	int		`json:"imported"`
+}
+
+
+// ConvertedPrice is a price shown in another currency than the one it is set in, at the rate given.
+type ConvertedPrice struct {
+	Price			money.Money	`json:"price"`
+	Currency		string		`json:"currency"`
+	Rate			string		`json:"rate"`
+	RateUpdatedAt	time.Time	`json:"rate_updated_at"`
+	RateStale		bool		`json:"rate_stale"`
+}
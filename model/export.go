@@ -0,0 +1,35 @@
+package model
+
+import (
+	"BookVault-API/money"
+	"time"
+)
+
+// ExportFormat is the file format of a catalog export.
+type ExportFormat string
+
+const (
+	ExportCSV	ExportFormat = "csv"
+	ExportJSONL	ExportFormat = "jsonl"
+	ExportONIX	ExportFormat = "onix"
+)
+
+
+// BookExport is a book as written to a catalog export. Its title, author, description, price,
+// currency, stock and isbn use the names an import reads, so an export can be imported again.
+type BookExport struct {
+	ID			uint			`json:"id"`
+	Title		string			`json:"title"`
+	Author		string			`json:"author"`
+	Description	string			`json:"description"`
+	Price		money.Money		`json:"price"`
+	Currency	string			`json:"currency"`
+	Stock		int				`json:"stock"`
+	ISBN		string			`json:"isbn,omitempty"`
+	ISBN10		string			`json:"isbn10,omitempty"`
+	Authors		[]BookAuthorRef	`json:"authors"`
+	Categories	[]CategoryRef	`json:"categories"`
+	Rating		RatingSummary	`json:"rating"`
+	CreatedAt	time.Time		`json:"created_at"`
+	UpdatedAt	time.Time		`json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package model
+
+// ImportFormat is the file format of a catalog import.
+type ImportFormat string
+
+const (
+	ImportCSV	ImportFormat = "csv"
+	ImportJSONL	ImportFormat = "jsonl"
+)
+
+// ImportStatus is the outcome of one imported row. On a dry run it is the outcome the row would have.
+type ImportStatus string
+
+const (
+	ImportCreated	ImportStatus = "created"
+	ImportUpdated	ImportStatus = "updated"
+	ImportFailed	ImportStatus = "failed"
+)
+
+
+// ImportRowResult reports a single row of an import. Line is the line of the file the row starts on.
+type ImportRowResult struct {
+	Line		int				`json:"line"`
+	Status		ImportStatus	`json:"status"`
+	BookID		uint			`json:"book_id,omitempty"`
+	Title		string			`json:"title,omitempty"`
+	Error		string			`json:"error,omitempty"`
+}
+
+
+// ImportReport summarizes an import together with the result of every row.
+type ImportReport struct {
+	DryRun		bool				`json:"dry_run"`
+	Created		int					`json:"created"`
+	Updated		int					`json:"updated"`
+	Failed		int					`json:"failed"`
+	Rows		[]ImportRowResult	`json:"rows"`
+}
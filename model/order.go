@@ -1,19 +1,44 @@
 package model
 
 import (
+	"BookVault-API/money"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// OrderStatus is a step of the order lifecycle:
+// pending -> approved -> shipped -> delivered, with cancelled and refunded as end states.
+type OrderStatus string
+
+const (
+	OrderPending	OrderStatus = "pending"
+	OrderApproved	OrderStatus = "approved"
+	OrderShipped	OrderStatus = "shipped"
+	OrderDelivered	OrderStatus = "delivered"
+	OrderCancelled	OrderStatus = "cancelled"
+	OrderRefunded	OrderStatus = "refunded"
+)
+
+
 type Order struct {
 	gorm.Model
 	UserID		uint
 	User		User
-	Status		string
+	Status		OrderStatus
 	Address		string
-	Total		float32
+	Subtotal	money.Money	`gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount	money.Money	`gorm:"embedded;embeddedPrefix:discount_"`
+	Total		money.Money	`gorm:"embedded;embeddedPrefix:total_"`
+	// The exchange rate the order was shown in at checkout, if any. It stays with the order,
+	// so its converted prices never change.
+	RateCurrency	*string
+	ExchangeRate	*string		`gorm:"type:numeric(20,10)"`
+	RateUpdatedAt	*time.Time
+	RateStale		bool
 	Books		[]OrderBook
+	Coupons		[]OrderCoupon
+	History		[]OrderStatusHistory
 }
 
 
@@ -24,17 +49,43 @@ type OrderBook struct {
 	BookID		uint
 	Book		Book
 	Quantity	uint
-	Price		float32
+	Price		money.Money	`gorm:"embedded;embeddedPrefix:price_"`
+}
+
+
+// OrderStatusHistory records a single status change. ChangedByID has no foreign key,
+// so the audit trail survives the deletion of the user who made the change.
+type OrderStatusHistory struct {
+	ID			uint		`gorm:"primaryKey"`
+	OrderID		uint		`gorm:"index"`
+	FromStatus	OrderStatus
+	ToStatus	OrderStatus
+	ChangedByID	uint
+	ChangedAt	time.Time
 }
 
 
 type OrderResponse struct {
 	ID			uint				`json:"id"`
-	Status		string				`json:"status"`
-	Total		float32				`json:"total"`
+	Status		OrderStatus			`json:"status"`
+	Subtotal	money.Money			`json:"subtotal"`
+	Discount	money.Money			`json:"discount"`
+	Total		money.Money			`json:"total"`
+	Currency	string				`json:"currency"`
+	Converted	*ConvertedPrice		`json:"converted,omitempty"`
 	Address	 	string	 			`json:"address"`
 	CreatedAt	time.Time			`json:"created_at"`
 	Books		[]OrderBookDetails	`json:"books"`	
+	Coupons		[]AppliedCoupon		`json:"coupons,omitempty"`
+	History		[]OrderStatusChange	`json:"history,omitempty"`
+}
+
+
+type OrderStatusChange struct {
+	From		OrderStatus	`json:"from,omitempty"`
+	To			OrderStatus	`json:"to"`
+	ChangedBy	uint		`json:"changed_by"`
+	ChangedAt	time.Time	`json:"changed_at"`
 }
 
 
@@ -42,5 +93,6 @@ type OrderBookDetails struct {
 	Title    string  `json:"title"`
     Author   string  `json:"author"`
     Quantity int     `json:"quantity"`
-    Price    float32 `json:"price"`
+    Price    money.Money `json:"price"`
+    Converted *ConvertedPrice `json:"converted,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// PageRequest asks for one page of a list. Sort names a field of the list, Desc reverses it,
+// and Cursor is the NextCursor of the previous page; it is empty for the first page.
+type PageRequest struct {
+	Limit			int
+	Cursor			string
+	Sort			string
+	Desc			bool
+	CreatedAfter	*time.Time
+	CreatedBefore	*time.Time
+}
+
+
+// Page is one page of a list. Total counts every item matching the filters, not just this page,
+// and NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items		[]T		`json:"items"`
+	NextCursor	string	`json:"next_cursor,omitempty"`
+	Total		int64	`json:"total"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// BookRating holds the rating aggregates of a book. It is recomputed from the rated
+// reviews of the book whenever one of them is added, updated or deleted.
+type BookRating struct {
+	BookID		uint	`gorm:"primaryKey;autoIncrement:false"`
+	Count		int
+	Average		float64
+	Stars1		int
+	Stars2		int
+	Stars3		int
+	Stars4		int
+	Stars5		int
+	UpdatedAt	time.Time
+}
+
+
+// RatingSummary is the rating of a book as shown to clients. Histogram[0] counts
+// the 1-star ratings and Histogram[4] the 5-star ones.
+type RatingSummary struct {
+	Average		float64	`json:"average"`
+	Count		int		`json:"count"`
+	Histogram	[5]int	`json:"histogram"`
+}
@@ -1,28 +1,116 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReviewStatus is the moderation state of a review. Only approved reviews are shown
+// on books and count towards their rating.
+type ReviewStatus string
+
+const (
+	ReviewPending	ReviewStatus = "pending"
+	ReviewApproved	ReviewStatus = "approved"
+	ReviewRejected	ReviewStatus = "rejected"
+)
 
 type Review struct {
 	gorm.Model
-	Text		string
+	Text			string
+	Rating			int
+	Verified		bool
+	Status			ReviewStatus
+	ModeratedAt		*time.Time
+	HelpfulVotes	int
+	UnhelpfulVotes	int
+	HelpfulScore	float64
+	UserID			uint
+	User			User
+	BookID			uint
+	Book			Book
+}
+
+// ReviewModeration records a change of the moderation status of a review. ModeratedByID is nil
+// when the change was automatic, such as a review going back to the queue after being reported.
+type ReviewModeration struct {
+	ID				uint			`gorm:"primaryKey"`
+	ReviewID		uint			`gorm:"index"`
+	FromStatus		ReviewStatus
+	ToStatus		ReviewStatus
+	Reason			string
+	ModeratedByID	*uint
+	CreatedAt		time.Time
+}
+
+// ReviewReport is a user's complaint about an approved review. A user can report a review once.
+type ReviewReport struct {
+	ID			uint		`gorm:"primaryKey"`
+	ReviewID	uint
 	UserID		uint
-	User		User
-	BookID		uint
-	Book		Book
+	Reason		string
+	CreatedAt	time.Time
+}
+
+// ReviewVote is a user's opinion on whether a review is helpful. A user has one vote per review.
+type ReviewVote struct {
+	ReviewID	uint		`gorm:"primaryKey;autoIncrement:false"`
+	UserID		uint		`gorm:"primaryKey;autoIncrement:false"`
+	Helpful		bool
+	CreatedAt	time.Time
+	UpdatedAt	time.Time
 }
 
 type ReviewRequest struct {
 	Text		string	`json:"text"`
+	Rating		int		`json:"rating"`
+}
+
+type ModerationRequest struct {
+	Reason		string	`json:"reason"`
+}
+
+type ReportRequest struct {
+	Reason		string	`json:"reason"`
 }
 
 type ReviewResponse struct {
+	ID				uint	`json:"id"`
 	Username		string	`json:"username"`
 	Text			string	`json:"text"`
+	Rating			int		`json:"rating"`
+	Verified		bool	`json:"verified"`
+	HelpfulVotes	int		`json:"helpful_votes"`
+	UnhelpfulVotes	int		`json:"unhelpful_votes"`
+}
+
+// ReviewFilter narrows the reviews of a book; a nil Verified keeps both verified and unverified ones.
+type ReviewFilter struct {
+	Verified		*bool
 }
 
 type UserReviewResponse struct {
-	Username		string	`json:"username"`
-	Title			string	`json:"title"`
-	Author			string	`json:"author"`
-	Text			string	`json:"text"`
-}
\ No newline at end of file
+	ID				uint			`json:"id"`
+	Username		string			`json:"username"`
+	Title			string			`json:"title"`
+	Author			string			`json:"author"`
+	Text			string			`json:"text"`
+	Rating			int				`json:"rating"`
+	Verified		bool			`json:"verified"`
+	Status			ReviewStatus	`json:"status"`
+}
+
+// ModerationQueueItem is a pending review as shown to moderators, with the number of
+// reports it received since it was last moderated.
+type ModerationQueueItem struct {
+	ID				uint		`json:"id"`
+	Username		string		`json:"username"`
+	BookID			uint		`json:"book_id"`
+	Title			string		`json:"title"`
+	Text			string		`json:"text"`
+	Rating			int			`json:"rating"`
+	Verified		bool		`json:"verified"`
+	Reports			int			`json:"reports"`
+	CreatedAt		time.Time	`json:"created_at"`
+}
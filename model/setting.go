@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Setting is a runtime option that admins change without restarting the API.
+// Values are stored as text under their key; options that were never set use their defaults.
+type Setting struct {
+	Key			string		`gorm:"primaryKey"`
+	Value		string
+	UpdatedAt	time.Time
+}
+
+// AutoApprovePolicy decides which new or edited reviews skip the moderation queue.
+type AutoApprovePolicy string
+
+const (
+	AutoApproveAll		AutoApprovePolicy = "all"
+	AutoApproveVerified	AutoApprovePolicy = "verified"
+	AutoApproveNone		AutoApprovePolicy = "none"
+)
+
+// ReviewSettings are the rules for writing and moderating reviews. ReportThreshold is the
+// number of reports that sends an approved review back to the moderation queue.
+type ReviewSettings struct {
+	AllowUnverified		bool				`json:"allow_unverified"`
+	AutoApprove			AutoApprovePolicy	`json:"auto_approve"`
+	ReportThreshold		int					`json:"report_threshold"`
+}
+
+type ReviewSettingsRequest struct {
+	AllowUnverified		*bool				`json:"allow_unverified"`
+	AutoApprove			*AutoApprovePolicy	`json:"auto_approve"`
+	ReportThreshold		*int				`json:"report_threshold"`
+}
+
+// RateSettings are the rules for exchange rates. Rates older than MaxAgeHours are flagged as stale.
+type RateSettings struct {
+	MaxAgeHours		int		`json:"max_age_hours"`
+}
+
+type RateSettingsRequest struct {
+	MaxAgeHours		*int	`json:"max_age_hours"`
+}
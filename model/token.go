@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link of a rotating refresh token chain. Every token issued from the
+// same login shares a FamilyID so that reuse of a rotated token can revoke the whole chain.
+type RefreshToken struct {
+	gorm.Model
+	UserID		uint		`gorm:"index"`
+	User		User		`gorm:"constraint:OnDelete:CASCADE;"`
+	TokenHash	string		`gorm:"uniqueIndex"`
+	FamilyID	string		`gorm:"index"`
+	ExpiresAt	time.Time
+	UsedAt		*time.Time
+	RevokedAt	*time.Time
+}
+
+
+// RevokedToken is an access token ID (jti) that must be rejected until it expires.
+type RevokedToken struct {
+	gorm.Model
+	TokenID		string		`gorm:"uniqueIndex"`
+	ExpiresAt	time.Time	`gorm:"index"`
+}
+
+
+type RefreshRequest struct {
+	RefreshToken	string	`json:"refresh_token"`
+}
+
+
+type TokenResponse struct {
+	Token			string	`json:"token"`
+	RefreshToken	string	`json:"refresh_token"`
+	ExpiresIn		int64	`json:"expires_in"`
+}
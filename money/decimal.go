@@ -0,0 +1,47 @@
+package money
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Decimal is an amount as sent by clients, before its currency is known: a JSON number
+// or string holding a plain decimal such as 12.5. It is kept as text, so no precision is
+// lost before Money rounds it.
+type Decimal string
+
+
+// ParseDecimal checks that s is a plain decimal.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return "", ErrInvalidAmount
+	}
+	return Decimal(s), nil
+}
+
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var value json.Number
+	if err := json.Unmarshal(data, &value); err != nil {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return ErrInvalidAmount
+		}
+		value = json.Number(text)
+	}
+
+	parsed, err := ParseDecimal(string(value))
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+
+// Money rounds the decimal to an amount of the currency.
+func (d Decimal) Money(currency string) (Money, error) {
+	return Parse(string(d), currency)
+}
@@ -0,0 +1,200 @@
+// Package money represents amounts of money as integer minor units of an ISO 4217 currency,
+// so that prices and totals add up exactly. All rounding goes through Round.
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount	= errors.New("invalid amount")
+	ErrInvalidCurrency	= errors.New("invalid currency")
+	ErrCurrencyMismatch	= errors.New("amounts are in different currencies")
+	ErrOverflow			= errors.New("amount is too large")
+)
+
+// Money is an amount in the minor unit of its currency, e.g. cents for EUR: 12.50 EUR is {1250, "EUR"}.
+type Money struct {
+	Amount		int64
+	Currency	string
+}
+
+// exponents lists the currencies whose minor unit is not a hundredth, by ISO 4217.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// maxAmount keeps amounts within the integers a float64, and so a JSON client, represents exactly.
+const maxAmount = 1<<53 - 1
+
+
+// DefaultCurrency is the currency of prices given without one: CATALOG_CURRENCY, or EUR when it is unset.
+func DefaultCurrency() string {
+	if currency := strings.ToUpper(strings.TrimSpace(os.Getenv("CATALOG_CURRENCY"))); ValidCurrency(currency) {
+		return currency
+	}
+	return "EUR"
+}
+
+
+// ValidCurrency reports whether code is shaped like an ISO 4217 code: three upper-case letters.
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+
+// NormalizeCurrency upper-cases a currency code and checks it, using the default currency for an empty one.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency(), nil
+	}
+	if !ValidCurrency(code) {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+
+// Exponent is the number of decimal places of the minor unit of a currency.
+func Exponent(currency string) int {
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+
+// Round converts a value in major units to minor units of the currency, rounding halves away from zero.
+// It is the only place amounts are rounded.
+func Round(value *big.Rat, currency string) (Money, error) {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Exponent(currency))), nil)
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(scale))
+
+	num := new(big.Int).Abs(scaled.Num())
+	quotient, remainder := new(big.Int).QuoRem(num, scaled.Denom(), new(big.Int))
+	if remainder.Lsh(remainder, 1).Cmp(scaled.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		quotient.Neg(quotient)
+	}
+
+	if !quotient.IsInt64() || quotient.Int64() > maxAmount || quotient.Int64() < -maxAmount {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: quotient.Int64(), Currency: currency}, nil
+}
+
+
+// Parse reads a plain decimal such as "12.5" as an amount of the currency, rounded by Round.
+func Parse(value, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if !decimalPattern.MatchString(value) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Money{}, ErrInvalidAmount
+	}
+
+	return Round(rat, currency)
+}
+
+
+// Rat is the amount in major units.
+func (m Money) Rat() *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Exponent(m.Currency))), nil)
+	return new(big.Rat).SetFrac(big.NewInt(m.Amount), scale)
+}
+
+
+// String formats the amount in major units with every decimal of the minor unit, e.g. "12.50".
+func (m Money) String() string {
+	exponent := Exponent(m.Currency)
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := big.NewInt(amount).String()
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+
+// MarshalJSON writes the amount as a JSON number with the exact decimals of the currency, e.g. 12.50.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+
+// Add sums two amounts of the same currency.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if (other.Amount > 0 && m.Amount > maxAmount-other.Amount) || (other.Amount < 0 && m.Amount < -maxAmount-other.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+
+// Convert changes the amount into another currency at the given rate, the price of one unit
+// of the amount's currency in the other one.
+func (m Money) Convert(rate *big.Rat, currency string) (Money, error) {
+	return Round(new(big.Rat).Mul(m.Rat(), rate), currency)
+}
+
+
+// Mul multiplies the amount by a whole quantity, such as the number of copies of an order line.
+func (m Money) Mul(quantity int64) (Money, error) {
+	if quantity != 0 && (m.Amount > maxAmount/abs(quantity) || m.Amount < -maxAmount/abs(quantity)) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}, nil
+}
+
+
+func abs(n int64) int64 {
+	if n < 0 {
+		if n == math.MinInt64 {
+			return math.MaxInt64
+		}
+		return -n
+	}
+	return n
+}
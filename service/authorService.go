@@ -0,0 +1,535 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuthorNotFound		= errors.New("author not found")
+	ErrEmptyAuthorName		= errors.New("author name cannot be empty")
+	ErrAuthorExists			= errors.New("another author already has this name or alias")
+	ErrInvalidAuthorDate	= errors.New("author dates must be YYYY-MM-DD")
+	ErrAuthorDates			= errors.New("died_on cannot be before born_on")
+	ErrAuthorHasBooks		= errors.New("author is still credited on books")
+	ErrMergeSameAuthor		= errors.New("an author cannot be merged into itself")
+	ErrInvalidAuthorRole	= errors.New("role must be author, translator or editor")
+	ErrBookNeedsAuthor		= errors.New("a book needs at least one author")
+)
+
+type AuthorService interface {
+	CreateAuthor(authorRequest model.AuthorRequest) (*model.AuthorResponse, error)
+
+	GetAuthor(authorID uint) (*model.AuthorResponse, error)
+
+	UpdateAuthor(authorID uint, authorUpdate model.AuthorUpdate) error
+
+	DeleteAuthor(authorID uint) error
+
+	MergeAuthors(sourceID, targetID uint) error
+}
+
+type authorService struct {
+	db *gorm.DB
+}
+
+func NewAuthorService(db *gorm.DB) AuthorService {
+	return &authorService{db: db}
+}
+
+// authorDateLayout is the layout of birth and death dates.
+const authorDateLayout = "2006-01-02"
+
+// authorsNamedSQL selects the IDs of the authors with the given name or alias, compared case-insensitively.
+const authorsNamedSQL = `
+	SELECT id FROM authors WHERE LOWER(name) = LOWER(?)
+	UNION
+	SELECT author_id FROM author_aliases WHERE LOWER(alias) = LOWER(?)`
+
+// refreshAuthorNamesSQL rewrites the display name of books from the authors credited on them.
+const refreshAuthorNamesSQL = `
+	UPDATE books SET author = credits.names
+	FROM (
+		SELECT book_authors.book_id, string_agg(authors.name, ', ' ORDER BY book_authors.position, authors.name) AS names
+		FROM book_authors JOIN authors ON authors.id = book_authors.author_id
+		WHERE book_authors.role = 'author' AND book_authors.book_id IN (?)
+		GROUP BY book_authors.book_id
+	) AS credits
+	WHERE books.id = credits.book_id`
+
+
+// lockAuthors serializes changes to author names and aliases, so that two of them cannot
+// give different authors the same name at once.
+func lockAuthors(tx *gorm.DB) error {
+	return tx.Exec("LOCK TABLE authors IN SHARE ROW EXCLUSIVE MODE").Error
+}
+
+
+func findAuthor(db *gorm.DB, authorID uint) (*model.Author, error) {
+	var author model.Author
+
+	err := db.Preload("Aliases", func(db *gorm.DB) *gorm.DB { return db.Order("LOWER(alias)") }).First(&author, authorID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAuthorNotFound
+		}
+		return nil, err
+	}
+
+	return &author, nil
+}
+
+
+// authorsNamed is a subquery selecting the IDs of the authors with the given name or alias.
+func authorsNamed(db *gorm.DB, name string) *gorm.DB {
+	return db.Raw(authorsNamedSQL, name, name)
+}
+
+
+// findAuthorByName finds the author with the given name or, failing that, alias.
+// It returns nil when there is none.
+func findAuthorByName(db *gorm.DB, name string) (*model.Author, error) {
+	var authors []model.Author
+
+	if err := db.Where("LOWER(name) = LOWER(?)", name).Limit(1).Find(&authors).Error; err != nil {
+		return nil, err
+	}
+
+	if len(authors) == 0 {
+		aliased := db.Model(&model.AuthorAlias{}).Select("author_id").Where("LOWER(alias) = LOWER(?)", name)
+		if err := db.Where("id IN (?)", aliased).Limit(1).Find(&authors).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if len(authors) == 0 {
+		return nil, nil
+	}
+
+	return &authors[0], nil
+}
+
+
+// resolveAuthor finds the author with the given name or alias and creates one if there is none.
+func resolveAuthor(tx *gorm.DB, name string) (*model.Author, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrEmptyFields
+	}
+
+	author, err := findAuthorByName(tx, name)
+	if err != nil || author != nil {
+		return author, err
+	}
+
+	if err := lockAuthors(tx); err != nil {
+		return nil, err
+	}
+
+	// Look again: the author may have been created while waiting for the lock.
+	if author, err = findAuthorByName(tx, name); err != nil || author != nil {
+		return author, err
+	}
+
+	author = &model.Author{Name: name}
+	if err := tx.Create(author).Error; err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+
+// creditAuthor makes the author with the given name, created if needed, the only author of a book.
+// Translators and editors keep their credits.
+func creditAuthor(tx *gorm.DB, bookID uint, name string) error {
+	author, err := resolveAuthor(tx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("book_id = ? AND role = ?", bookID, model.RoleAuthor).Delete(&model.BookAuthor{}).Error; err != nil {
+		return err
+	}
+
+	credit := model.BookAuthor{BookID: bookID, AuthorID: author.ID, Role: model.RoleAuthor}
+	if err := tx.Omit("Author").Create(&credit).Error; err != nil {
+		return err
+	}
+
+	return refreshAuthorNames(tx, []uint{bookID})
+}
+
+
+// refreshAuthorNames updates the flat author string of the given books, a list of IDs or a subquery.
+func refreshAuthorNames(tx *gorm.DB, bookIDs any) error {
+	return tx.Exec(refreshAuthorNamesSQL, bookIDs).Error
+}
+
+
+// booksOfAuthor is a subquery selecting the IDs of the books an author is credited on.
+func booksOfAuthor(tx *gorm.DB, authorID uint) *gorm.DB {
+	return tx.Model(&model.BookAuthor{}).Select("book_id").Where("author_id = ?", authorID)
+}
+
+
+func parseAuthorDate(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse(authorDateLayout, value)
+	if err != nil {
+		return nil, ErrInvalidAuthorDate
+	}
+
+	return &date, nil
+}
+
+
+func formatAuthorDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format(authorDateLayout)
+}
+
+
+// cleanAliases trims the aliases and drops empty ones, duplicates and those equal to the name.
+func cleanAliases(name string, aliases []string) []string {
+	seen := map[string]bool{strings.ToLower(name): true}
+	cleaned := []string{}
+
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[strings.ToLower(alias)] {
+			continue
+		}
+		seen[strings.ToLower(alias)] = true
+		cleaned = append(cleaned, alias)
+	}
+
+	return cleaned
+}
+
+
+// checkAuthorNames reports ErrAuthorExists when a name is already the name or an alias of another author.
+func checkAuthorNames(tx *gorm.DB, authorID uint, names []string) error {
+	lowered := make([]string, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(name))
+	}
+
+	var count int64
+
+	err := tx.Model(&model.Author{}).Where("id <> ? AND LOWER(name) IN ?", authorID, lowered).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAuthorExists
+	}
+
+	err = tx.Model(&model.AuthorAlias{}).Where("author_id <> ? AND LOWER(alias) IN ?", authorID, lowered).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAuthorExists
+	}
+
+	return nil
+}
+
+
+// replaceAliases sets the aliases of an author.
+func replaceAliases(tx *gorm.DB, author *model.Author, aliases []string) error {
+	if err := tx.Where("author_id = ?", author.ID).Delete(&model.AuthorAlias{}).Error; err != nil {
+		return err
+	}
+
+	author.Aliases = make([]model.AuthorAlias, 0, len(aliases))
+	for _, alias := range aliases {
+		author.Aliases = append(author.Aliases, model.AuthorAlias{AuthorID: author.ID, Alias: alias})
+	}
+
+	if len(author.Aliases) == 0 {
+		return nil
+	}
+
+	return tx.Create(&author.Aliases).Error
+}
+
+
+func toAuthorResponse(author *model.Author) *model.AuthorResponse {
+	aliases := make([]string, 0, len(author.Aliases))
+	for _, alias := range author.Aliases {
+		aliases = append(aliases, alias.Alias)
+	}
+
+	return &model.AuthorResponse{
+		ID: 		author.ID,
+		Name: 		author.Name,
+		Aliases: 	aliases,
+		Bio: 		author.Bio,
+		BornOn: 	formatAuthorDate(author.BornOn),
+		DiedOn: 	formatAuthorDate(author.DiedOn),
+	}
+}
+
+
+func checkAuthorDates(author *model.Author) error {
+	if author.BornOn != nil && author.DiedOn != nil && author.DiedOn.Before(*author.BornOn) {
+		return ErrAuthorDates
+	}
+	return nil
+}
+
+
+func (a *authorService) CreateAuthor(authorRequest model.AuthorRequest) (*model.AuthorResponse, error) {
+	name := strings.TrimSpace(authorRequest.Name)
+	if name == "" {
+		return nil, ErrEmptyAuthorName
+	}
+
+	author := model.Author{Name: name, Bio: strings.TrimSpace(authorRequest.Bio)}
+
+	var err error
+	if author.BornOn, err = parseAuthorDate(authorRequest.BornOn); err != nil {
+		return nil, err
+	}
+	if author.DiedOn, err = parseAuthorDate(authorRequest.DiedOn); err != nil {
+		return nil, err
+	}
+	if err := checkAuthorDates(&author); err != nil {
+		return nil, err
+	}
+
+	aliases := cleanAliases(name, authorRequest.Aliases)
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAuthors(tx); err != nil {
+			return err
+		}
+
+		if err := checkAuthorNames(tx, 0, append([]string{name}, aliases...)); err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Aliases").Create(&author).Error; err != nil {
+			return err
+		}
+
+		return replaceAliases(tx, &author, aliases)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toAuthorResponse(&author), nil
+}
+
+
+// GetAuthor returns the profile of an author together with the books they are credited on,
+// sorted by title, each with the roles they had in it.
+func (a *authorService) GetAuthor(authorID uint) (*model.AuthorResponse, error) {
+	author, err := findAuthor(a.db, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var credits []model.BookAuthor
+	if err := a.db.Where("author_id = ?", authorID).Order("position, role").Find(&credits).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make(map[uint][]model.AuthorRole, len(credits))
+	for _, credit := range credits {
+		roles[credit.BookID] = append(roles[credit.BookID], credit.Role)
+	}
+
+	var books []model.Book
+	if err := a.db.Scopes(withDetails).Where("id IN (?)", booksOfAuthor(a.db, authorID)).Order("LOWER(title), id").Find(&books).Error; err != nil {
+		return nil, err
+	}
+
+	response := toAuthorResponse(author)
+	response.Books = make([]model.AuthoredBook, 0, len(books))
+
+	for _, book := range books {
+		bookRoles := roles[book.ID]
+		sort.Slice(bookRoles, func(i, j int) bool { return bookRoles[i] < bookRoles[j] })
+
+		response.Books = append(response.Books, model.AuthoredBook{
+			BookResponse: 	toBookResponse(&book),
+			Roles: 			bookRoles,
+		})
+	}
+
+	return response, nil
+}
+
+
+// UpdateAuthor changes the profile of an author. A new name is also shown on their books.
+func (a *authorService) UpdateAuthor(authorID uint, authorUpdate model.AuthorUpdate) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAuthors(tx); err != nil {
+			return err
+		}
+
+		author, err := findAuthor(tx, authorID)
+		if err != nil {
+			return err
+		}
+
+		renamed := false
+		if authorUpdate.Name != nil {
+			name := strings.TrimSpace(*authorUpdate.Name)
+			if name == "" {
+				return ErrEmptyAuthorName
+			}
+			renamed = name != author.Name
+			author.Name = name
+		}
+
+		if authorUpdate.Bio != nil {
+			author.Bio = strings.TrimSpace(*authorUpdate.Bio)
+		}
+		if authorUpdate.BornOn != nil {
+			if author.BornOn, err = parseAuthorDate(*authorUpdate.BornOn); err != nil {
+				return err
+			}
+		}
+		if authorUpdate.DiedOn != nil {
+			if author.DiedOn, err = parseAuthorDate(*authorUpdate.DiedOn); err != nil {
+				return err
+			}
+		}
+		if err := checkAuthorDates(author); err != nil {
+			return err
+		}
+
+		var aliases []string
+		if authorUpdate.Aliases != nil {
+			aliases = *authorUpdate.Aliases
+		} else {
+			for _, alias := range author.Aliases {
+				aliases = append(aliases, alias.Alias)
+			}
+		}
+		aliases = cleanAliases(author.Name, aliases)
+
+		if err := checkAuthorNames(tx, author.ID, append([]string{author.Name}, aliases...)); err != nil {
+			return err
+		}
+
+		if err := replaceAliases(tx, author, aliases); err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Aliases").Save(author).Error; err != nil {
+			return err
+		}
+
+		if renamed {
+			return refreshAuthorNames(tx, booksOfAuthor(tx, author.ID))
+		}
+
+		return nil
+	})
+}
+
+
+// DeleteAuthor removes an author who is no longer credited on any book, deleted ones included.
+func (a *authorService) DeleteAuthor(authorID uint) error {
+	result := a.db.Delete(&model.Author{}, authorID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+			return ErrAuthorHasBooks
+		}
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+
+// MergeAuthors folds a duplicate author into another one: the duplicate's credits and aliases
+// move to the target, its name becomes an alias of the target, and profile fields the target
+// lacks are taken from it. The duplicate is then deleted.
+func (a *authorService) MergeAuthors(sourceID, targetID uint) error {
+	if sourceID == targetID {
+		return ErrMergeSameAuthor
+	}
+
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAuthors(tx); err != nil {
+			return err
+		}
+
+		source, err := findAuthor(tx, sourceID)
+		if err != nil {
+			return err
+		}
+		target, err := findAuthor(tx, targetID)
+		if err != nil {
+			return err
+		}
+
+		// A book crediting both authors in the same role keeps a single credit.
+		err = tx.Exec(`INSERT INTO book_authors (book_id, author_id, role, position)
+			SELECT book_id, ?, role, position FROM book_authors WHERE author_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, source.ID).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("author_id = ?", source.ID).Delete(&model.BookAuthor{}).Error; err != nil {
+			return err
+		}
+
+		// An alias equal to the target's name is dropped with the source.
+		err = tx.Model(&model.AuthorAlias{}).
+			Where("author_id = ? AND LOWER(alias) <> LOWER(?)", source.ID, target.Name).
+			Update("author_id", target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		if target.Bio == "" {
+			target.Bio = source.Bio
+		}
+		if target.BornOn == nil {
+			target.BornOn = source.BornOn
+		}
+		if target.DiedOn == nil {
+			target.DiedOn = source.DiedOn
+		}
+
+		if err := tx.Delete(&model.Author{}, source.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Aliases").Save(target).Error; err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(source.Name, target.Name) {
+			if err := tx.Create(&model.AuthorAlias{AuthorID: target.ID, Alias: source.Name}).Error; err != nil {
+				return err
+			}
+		}
+
+		return refreshAuthorNames(tx, booksOfAuthor(tx, target.ID))
+	})
+}
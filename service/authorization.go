@@ -0,0 +1,19 @@
+package service
+
+import (
+	"BookVault-API/auth"
+	"errors"
+)
+
+var (
+	ErrForbidden	= errors.New("forbidden: resource belongs to another user")
+)
+
+
+// authorizeOwner allows admins and the owner of a resource, and returns ErrForbidden for everyone else.
+func authorizeOwner(principal auth.Principal, ownerID uint) error {
+	if !principal.CanActAs(ownerID) {
+		return ErrForbidden
+	}
+	return nil
+}
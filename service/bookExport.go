@@ -0,0 +1,379 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/money"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedExportFormat	= errors.New("export format must be csv, jsonl or onix")
+)
+
+// exportColumns selects a book together with its rating aggregates, and its categories and credited
+// authors as JSON arrays.
+const exportColumns = `books.id, books.title, books.author, books.description, books.price_amount, books.price_currency, books.stock,
+	books.isbn13, books.isbn10, books.created_at, books.updated_at,
+	COALESCE(book_ratings.average, 0), COALESCE(book_ratings.count, 0),
+	COALESCE(book_ratings.stars1, 0), COALESCE(book_ratings.stars2, 0), COALESCE(book_ratings.stars3, 0),
+	COALESCE(book_ratings.stars4, 0), COALESCE(book_ratings.stars5, 0),
+	COALESCE((
+		SELECT json_agg(json_build_object('id', categories.id, 'name', categories.name) ORDER BY categories.name)
+		FROM book_categories JOIN categories ON categories.id = book_categories.category_id
+		WHERE book_categories.book_id = books.id
+	), '[]'),
+	COALESCE((
+		SELECT json_agg(json_build_object('id', authors.id, 'name', authors.name, 'role', book_authors.role) ORDER BY book_authors.position)
+		FROM book_authors JOIN authors ON authors.id = book_authors.author_id
+		WHERE book_authors.book_id = books.id
+	), '[]')`
+
+// bookExporter writes the books of an export in one file format.
+type bookExporter interface {
+	begin() error
+
+	write(book *model.BookExport) error
+
+	end() error
+}
+
+
+func newBookExporter(w io.Writer, format model.ExportFormat) (bookExporter, error) {
+	switch format {
+	case model.ExportCSV:
+		return &csvExporter{writer: csv.NewWriter(w)}, nil
+	case model.ExportJSONL:
+		return &jsonlExporter{encoder: json.NewEncoder(w)}, nil
+	case model.ExportONIX:
+		return &onixExporter{w: w, encoder: xml.NewEncoder(w)}, nil
+	default:
+		return nil, ErrUnsupportedExportFormat
+	}
+}
+
+
+// ExportBooks writes every book matching the filter, in ID order, to w. The books are read
+// row by row from the result set and written as they arrive, so the catalog is never held
+// in memory. Once writing has started an error can only cut the export short.
+func (b *bookService) ExportBooks(w io.Writer, format model.ExportFormat, filter model.BookFilter) error {
+	exporter, err := newBookExporter(w, format)
+	if err != nil {
+		return err
+	}
+
+	rows, err := b.db.Model(&model.Book{}).
+		Scopes(filterBooks(filter)).
+		Select(exportColumns).
+		Joins("LEFT JOIN book_ratings ON book_ratings.book_id = books.id").
+		Order("books.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if err := exporter.begin(); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var book model.BookExport
+		var isbn13, isbn10 *string
+		var categories, authors []byte
+		var histogram [5]int
+		var price money.Money
+
+		err := rows.Scan(
+			&book.ID, &book.Title, &book.Author, &book.Description, &price.Amount, &price.Currency, &book.Stock,
+			&isbn13, &isbn10, &book.CreatedAt, &book.UpdatedAt,
+			&book.Rating.Average, &book.Rating.Count,
+			&histogram[0], &histogram[1], &histogram[2], &histogram[3], &histogram[4],
+			&categories, &authors,
+		)
+		if err != nil {
+			return err
+		}
+
+		book.Price, book.Currency = price, price.Currency
+		book.Rating.Histogram = histogram
+		if isbn13 != nil {
+			book.ISBN = *isbn13
+		}
+		if isbn10 != nil {
+			book.ISBN10 = *isbn10
+		}
+		if err := json.Unmarshal(categories, &book.Categories); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(authors, &book.Authors); err != nil {
+			return err
+		}
+
+		if err := exporter.write(&book); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return exporter.end()
+}
+
+
+// csvExporter writes one row per book. Categories are joined with " | " and the
+// rating histogram is spread over the columns stars_1 to stars_5.
+type csvExporter struct {
+	writer	*csv.Writer
+}
+
+
+func (c *csvExporter) begin() error {
+	return c.writer.Write([]string{
+		"id", "title", "author", "description", "price", "currency", "stock", "isbn", "isbn10", "categories",
+		"rating_average", "rating_count", "stars_1", "stars_2", "stars_3", "stars_4", "stars_5",
+		"created_at", "updated_at",
+	})
+}
+
+
+func (c *csvExporter) write(book *model.BookExport) error {
+	names := make([]string, 0, len(book.Categories))
+	for _, category := range book.Categories {
+		names = append(names, category.Name)
+	}
+
+	record := []string{
+		strconv.FormatUint(uint64(book.ID), 10),
+		book.Title,
+		book.Author,
+		book.Description,
+		book.Price.String(),
+		book.Currency,
+		strconv.Itoa(book.Stock),
+		book.ISBN,
+		book.ISBN10,
+		strings.Join(names, " | "),
+		strconv.FormatFloat(book.Rating.Average, 'f', 2, 64),
+		strconv.Itoa(book.Rating.Count),
+	}
+	for _, count := range book.Rating.Histogram {
+		record = append(record, strconv.Itoa(count))
+	}
+	record = append(record, book.CreatedAt.UTC().Format(time.RFC3339), book.UpdatedAt.UTC().Format(time.RFC3339))
+
+	return c.writer.Write(record)
+}
+
+
+func (c *csvExporter) end() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+
+// jsonlExporter writes one JSON object per line.
+type jsonlExporter struct {
+	encoder	*json.Encoder
+}
+
+
+func (j *jsonlExporter) begin() error {
+	return nil
+}
+
+
+func (j *jsonlExporter) write(book *model.BookExport) error {
+	return j.encoder.Encode(book)
+}
+
+
+func (j *jsonlExporter) end() error {
+	return nil
+}
+
+
+// onixSender names the sender in the header of ONIX exports.
+const onixSender = "BookVault"
+
+// onixContributorRoles maps author roles to ONIX contributor role codes.
+var onixContributorRoles = map[model.AuthorRole]string{
+	model.RoleAuthor:		"A01",
+	model.RoleTranslator:	"B06",
+	model.RoleEditor:		"B01",
+}
+
+// onixExporter writes an ONIX for Books 3.0 message with one Product record per book.
+// Codes are from the ONIX code lists: ProductIDType 01 is proprietary and 15 is ISBN-13,
+// ProductForm BA is a book, ContributorRole A01 is an author, B06 a translator and B01 an
+// editor, SubjectSchemeIdentifier 24 is a proprietary scheme, TextType 03 is a description
+// and ProductAvailability 21 and 31 are in stock and out of stock.
+type onixExporter struct {
+	w			io.Writer
+	encoder		*xml.Encoder
+}
+
+type onixHeader struct {
+	XMLName			xml.Name	`xml:"Header"`
+	SenderName		string		`xml:"Sender>SenderName"`
+	SentDateTime	string		`xml:"SentDateTime"`
+}
+
+type onixProduct struct {
+	XMLName				xml.Name				`xml:"Product"`
+	RecordReference		string					`xml:"RecordReference"`
+	NotificationType	string					`xml:"NotificationType"`
+	Identifiers			[]onixIdentifier		`xml:"ProductIdentifier"`
+	DescriptiveDetail	onixDescriptiveDetail	`xml:"DescriptiveDetail"`
+	Description			*onixTextContent		`xml:"CollateralDetail>TextContent,omitempty"`
+	SupplyDetail		onixSupplyDetail		`xml:"ProductSupply>SupplyDetail"`
+}
+
+type onixIdentifier struct {
+	ProductIDType	string	`xml:"ProductIDType"`
+	IDValue			string	`xml:"IDValue"`
+}
+
+type onixDescriptiveDetail struct {
+	ProductComposition	string				`xml:"ProductComposition"`
+	ProductForm			string				`xml:"ProductForm"`
+	TitleType			string				`xml:"TitleDetail>TitleType"`
+	TitleElement		onixTitleElement	`xml:"TitleDetail>TitleElement"`
+	Contributors		[]onixContributor	`xml:"Contributor"`
+	Subjects			[]onixSubject		`xml:"Subject"`
+}
+
+type onixTitleElement struct {
+	TitleElementLevel	string	`xml:"TitleElementLevel"`
+	TitleText			string	`xml:"TitleText"`
+}
+
+type onixContributor struct {
+	SequenceNumber	int		`xml:"SequenceNumber"`
+	ContributorRole	string	`xml:"ContributorRole"`
+	PersonName		string	`xml:"PersonName"`
+}
+
+type onixSubject struct {
+	SubjectSchemeIdentifier	string	`xml:"SubjectSchemeIdentifier"`
+	SubjectSchemeName		string	`xml:"SubjectSchemeName"`
+	SubjectCode				string	`xml:"SubjectCode"`
+	SubjectHeadingText		string	`xml:"SubjectHeadingText"`
+}
+
+type onixTextContent struct {
+	TextType		string	`xml:"TextType"`
+	ContentAudience	string	`xml:"ContentAudience"`
+	Text			string	`xml:"Text"`
+}
+
+type onixSupplyDetail struct {
+	SupplierRole		string		`xml:"Supplier>SupplierRole"`
+	SupplierName		string		`xml:"Supplier>SupplierName"`
+	ProductAvailability	string		`xml:"ProductAvailability"`
+	OnHand				int			`xml:"Stock>OnHand"`
+	Price				onixPrice	`xml:"Price"`
+}
+
+type onixPrice struct {
+	PriceType		string	`xml:"PriceType"`
+	PriceAmount		string	`xml:"PriceAmount"`
+	CurrencyCode	string	`xml:"CurrencyCode"`
+}
+
+
+func (o *onixExporter) begin() error {
+	o.encoder.Indent("  ", "  ")
+
+	_, err := io.WriteString(o.w, xml.Header+`<ONIXMessage release="3.0" xmlns="http://ns.editeur.org/onix/3.0/reference">`+"\n")
+	if err != nil {
+		return err
+	}
+
+	return o.encoder.Encode(onixHeader{
+		SenderName:		onixSender,
+		SentDateTime:	time.Now().UTC().Format("20060102T1504Z"),
+	})
+}
+
+
+func (o *onixExporter) write(book *model.BookExport) error {
+	product := onixProduct{
+		RecordReference:	fmt.Sprintf("bookvault-%d", book.ID),
+		NotificationType:	"03",
+		Identifiers:		[]onixIdentifier{{ProductIDType: "01", IDValue: strconv.FormatUint(uint64(book.ID), 10)}},
+		DescriptiveDetail:	onixDescriptiveDetail{
+			ProductComposition:	"00",
+			ProductForm:		"BA",
+			TitleType:			"01",
+			TitleElement:		onixTitleElement{TitleElementLevel: "01", TitleText: book.Title},
+			Contributors:		onixContributors(book),
+		},
+		SupplyDetail:		onixSupplyDetail{
+			SupplierRole:			"00",
+			SupplierName:			onixSender,
+			ProductAvailability:	"31",
+			OnHand:					book.Stock,
+			Price:					onixPrice{
+				PriceType:		"01",
+				PriceAmount:	book.Price.String(),
+				CurrencyCode:	book.Currency,
+			},
+		},
+	}
+
+	if book.ISBN != "" {
+		product.Identifiers = append(product.Identifiers, onixIdentifier{ProductIDType: "15", IDValue: book.ISBN})
+	}
+	for _, category := range book.Categories {
+		product.DescriptiveDetail.Subjects = append(product.DescriptiveDetail.Subjects, onixSubject{
+			SubjectSchemeIdentifier:	"24",
+			SubjectSchemeName:			onixSender,
+			SubjectCode:				strconv.FormatUint(uint64(category.ID), 10),
+			SubjectHeadingText:			category.Name,
+		})
+	}
+	if book.Description != "" {
+		product.Description = &onixTextContent{TextType: "03", ContentAudience: "00", Text: book.Description}
+	}
+	if book.Stock > 0 {
+		product.SupplyDetail.ProductAvailability = "21"
+	}
+
+	return o.encoder.Encode(product)
+}
+
+
+// onixContributors lists the credited authors of a book in order, each in its role. A book
+// without credits falls back to its author string.
+func onixContributors(book *model.BookExport) []onixContributor {
+	if len(book.Authors) == 0 {
+		return []onixContributor{{SequenceNumber: 1, ContributorRole: onixContributorRoles[model.RoleAuthor], PersonName: book.Author}}
+	}
+
+	contributors := make([]onixContributor, 0, len(book.Authors))
+	for i, author := range book.Authors {
+		contributors = append(contributors, onixContributor{
+			SequenceNumber:		i + 1,
+			ContributorRole:	onixContributorRoles[author.Role],
+			PersonName:			author.Name,
+		})
+	}
+
+	return contributors
+}
+
+
+func (o *onixExporter) end() error {
+	_, err := io.WriteString(o.w, "\n</ONIXMessage>\n")
+	return err
+}
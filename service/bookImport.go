@@ -0,0 +1,372 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/money"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedImportFormat	= errors.New("import format must be csv or jsonl")
+	ErrInvalidImportHeader		= errors.New("CSV header must name the columns title, author, description and price once each")
+	ErrImportLineTooLong		= errors.New("import line is too long")
+)
+
+// errDryRun rolls back the transaction a dry run imports in.
+var errDryRun = errors.New("dry run")
+
+// maxImportLine caps a single JSON Lines record, so a file without line breaks cannot exhaust memory.
+const maxImportLine = 1 << 20
+
+// importColumns are the CSV columns an import understands; the first four are required.
+// Other columns, such as those of a catalog export, are ignored.
+var importColumns = []string{"title", "author", "description", "price", "currency", "stock", "isbn"}
+
+// importRow is one decoded row of an import file. Err is set when the row could not be decoded.
+type importRow struct {
+	line		int
+	request		*model.BookRequest
+	err			error
+}
+
+// importReader yields the rows of an import file one at a time and io.EOF after the last one.
+type importReader func() (importRow, error)
+
+
+func newImportReader(r io.Reader, format model.ImportFormat) (importReader, error) {
+	switch format {
+	case model.ImportCSV:
+		return csvImportReader(r)
+	case model.ImportJSONL:
+		return jsonlImportReader(r), nil
+	default:
+		return nil, ErrUnsupportedImportFormat
+	}
+}
+
+
+// csvImportReader reads a CSV file whose first record names the columns.
+func csvImportReader(r io.Reader) (importReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrInvalidImportHeader
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, ErrInvalidImportHeader
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheet programs often start UTF-8 files with a byte order mark.
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !isImportColumn(name) {
+			continue
+		}
+		if _, ok := columns[name]; ok {
+			return nil, ErrInvalidImportHeader
+		}
+		columns[name] = i
+	}
+	for _, name := range importColumns[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, ErrInvalidImportHeader
+		}
+	}
+
+	return func() (importRow, error) {
+		record, err := reader.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return importRow{line: parseErr.StartLine, err: fmt.Errorf("invalid CSV: %w", parseErr.Err)}, nil
+			}
+			return importRow{}, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		request := model.BookRequest{
+			Title:			field("title"),
+			Author:			field("author"),
+			Description:	field("description"),
+			Currency:		field("currency"),
+			ISBN:			field("isbn"),
+		}
+
+		if value := field("price"); value != "" {
+			price, err := money.ParseDecimal(value)
+			if err != nil {
+				return importRow{line: line, request: &request, err: errors.New("invalid price")}, nil
+			}
+			request.Price = &price
+		}
+
+		if value := field("stock"); value != "" {
+			stock, err := strconv.Atoi(value)
+			if err != nil {
+				return importRow{line: line, request: &request, err: errors.New("invalid stock")}, nil
+			}
+			request.Stock = &stock
+		}
+
+		return importRow{line: line, request: &request}, nil
+	}, nil
+}
+
+
+func isImportColumn(name string) bool {
+	for _, column := range importColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+
+// jsonlImportReader reads a JSON Lines file holding one book request per line. Blank lines are skipped.
+func jsonlImportReader(r io.Reader) importReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+	line := 0
+
+	return func() (importRow, error) {
+		for scanner.Scan() {
+			line++
+
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+
+			var request model.BookRequest
+			if err := json.Unmarshal(text, &request); err != nil {
+				return importRow{line: line, err: fmt.Errorf("invalid JSON: %w", err)}, nil
+			}
+
+			return importRow{line: line, request: &request}, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return importRow{}, ErrImportLineTooLong
+			}
+			return importRow{}, err
+		}
+
+		return importRow{}, io.EOF
+	}
+}
+
+
+// ImportBooks creates or updates a book for every row of a CSV or JSON Lines file. The file is read
+// row by row, so its size does not matter. Rows are validated like CreateBook requests; a row that
+// fails is reported and the import goes on. Each row is saved in its own transaction, except on a
+// dry run, where the whole import runs in one transaction that is rolled back at the end. A dry run
+// creates no authors, so that it never holds the authors lock for the length of the file.
+func (b *bookService) ImportBooks(r io.Reader, format model.ImportFormat, dryRun bool) (*model.ImportReport, error) {
+	next, err := newImportReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.ImportReport{DryRun: dryRun, Rows: []model.ImportRowResult{}}
+
+	run := func(db *gorm.DB) error {
+		for {
+			row, err := next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			result, err := importBook(db, row, dryRun)
+			if err != nil {
+				return err
+			}
+
+			switch result.Status {
+			case model.ImportCreated:
+				report.Created++
+				// The ID of a book created on a dry run is rolled back with it.
+				if dryRun {
+					result.BookID = 0
+				}
+			case model.ImportUpdated:
+				report.Updated++
+			case model.ImportFailed:
+				report.Failed++
+			}
+			report.Rows = append(report.Rows, result)
+		}
+	}
+
+	if dryRun {
+		err = b.db.Transaction(func(tx *gorm.DB) error {
+			if err := run(tx); err != nil {
+				return err
+			}
+			return errDryRun
+		})
+		if errors.Is(err, errDryRun) {
+			err = nil
+		}
+	} else {
+		err = run(b.db)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+
+// importBook saves a single row. Invalid rows come back as failed results; only database
+// failures are returned as errors and stop the import.
+func importBook(db *gorm.DB, row importRow, dryRun bool) (model.ImportRowResult, error) {
+	result := model.ImportRowResult{Line: row.line}
+	if row.request != nil {
+		result.Title = row.request.Title
+	}
+
+	fail := func(err error) (model.ImportRowResult, error) {
+		result.Status = model.ImportFailed
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	if row.err != nil {
+		return fail(row.err)
+	}
+
+	book, err := newBook(row.request)
+	if err != nil {
+		return fail(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		existing, err := importMatch(tx, book)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			if err := tx.Create(book).Error; err != nil {
+				return err
+			}
+			result.Status = model.ImportCreated
+			result.BookID = book.ID
+			return creditImportedAuthor(tx, book.ID, book.Author, dryRun)
+		}
+
+		relinkAuthor := !strings.EqualFold(existing.Author, book.Author)
+
+		existing.Title 			= book.Title
+		existing.Author 		= book.Author
+		existing.Description 	= book.Description
+		existing.Price 			= book.Price
+
+		if row.request.Stock != nil {
+			existing.Stock = book.Stock
+		}
+		if row.request.MaxQuantity != nil {
+			existing.MaxQuantity = book.MaxQuantity
+		}
+		if book.ISBN13 != nil {
+			existing.ISBN13 = book.ISBN13
+			existing.ISBN10 = book.ISBN10
+		}
+
+		if err := tx.Save(existing).Error; err != nil {
+			return err
+		}
+		result.Status = model.ImportUpdated
+		result.BookID = existing.ID
+
+		if relinkAuthor {
+			return creditImportedAuthor(tx, existing.ID, existing.Author, dryRun)
+		}
+		return nil
+	})
+	if err = saveBook(err); err != nil {
+		if errors.Is(err, ErrDuplicateISBN) {
+			return fail(err)
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+
+// creditImportedAuthor credits the author of an imported book. On a dry run an author who does not
+// exist yet is not created, which would lock the authors table; the book keeps the name alone,
+// which importMatch still matches later rows on.
+func creditImportedAuthor(tx *gorm.DB, bookID uint, name string, dryRun bool) error {
+	if dryRun {
+		author, err := findAuthorByName(tx, strings.TrimSpace(name))
+		if err != nil || author == nil {
+			return err
+		}
+	}
+
+	return creditAuthor(tx, bookID, name)
+}
+
+
+// importMatch finds the book an imported row updates: the book with the same ISBN, or else the
+// oldest book with the same title by the same author, known by name or alias, that does not
+// carry a different ISBN.
+func importMatch(tx *gorm.DB, book *model.Book) (*model.Book, error) {
+	var matches []model.Book
+
+	if book.ISBN13 != nil {
+		if err := tx.Where("isbn13 = ?", *book.ISBN13).Limit(1).Find(&matches).Error; err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			return &matches[0], nil
+		}
+	}
+
+	query := tx.Where("LOWER(title) = LOWER(?)", book.Title).Where(writtenBy(tx, book.Author))
+	if book.ISBN13 != nil {
+		query = query.Where("isbn13 IS NULL")
+	}
+
+	if err := query.Order("id").Limit(1).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	return &matches[0], nil
+}
@@ -1,14 +1,26 @@
 package service
 
 import (
+	"BookVault-API/isbn"
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"errors"
+	"io"
+	"strings"
 	"gorm.io/gorm"
 )
 
 var (
 	ErrBookNotFound 			= errors.New("book not found")
 	ErrNoBooks					= errors.New("no books found")
+	ErrInvalidPrice				= errors.New("price cannot be negative")
+	ErrInvalidCurrency			= errors.New("currency must be a three-letter ISO 4217 code")
+	ErrBookNotDeleted			= errors.New("book is not deleted")
+	ErrInvalidStock				= errors.New("stock cannot be negative")
+	ErrInvalidStockUpdate		= errors.New("either stock or delta must be given")
+	ErrInvalidMaxQuantity		= errors.New("max quantity cannot be negative")
+	ErrInvalidISBN				= errors.New("invalid ISBN")
+	ErrDuplicateISBN			= errors.New("a book with this ISBN already exists")
 )
 
 type BookService interface{
@@ -16,11 +28,33 @@ type BookService interface{
 
 	GetByTitle(bookTitle string) (*model.BookResponse, error)
 
-	GetBooks() ([]model.BookResponse, error)
+	GetByISBN(value string) (*model.BookResponse, error)
+
+	GetBooks(filter model.BookFilter, page model.PageRequest) (*model.Page[model.BookResponse], error)
 
 	GetBooksByAuthor(author string) ([]model.BookResponse, error)
 
-	UpdateStock(bookID uint) error
+	SearchBooks(query string, page model.PageRequest) (*model.Page[model.BookSearchResult], error)
+
+	GetBooksByCategory(categoryID uint, includeDescendants bool, page model.PageRequest) (*model.Page[model.BookResponse], error)
+
+	SetBookCategories(bookID uint, categoryIDs []uint) error
+
+	SetBookAuthors(bookID uint, authors []model.BookAuthorRequest) error
+
+	UpdateStock(bookID uint, stockUpdate model.StockUpdate) error
+
+	UpdateBook(bookID uint, bookRequest *model.BookRequest) error
+
+	DeleteBook(bookID uint) error
+
+	RestoreBook(bookID uint) error
+
+	GetDeletedBooks() ([]model.BookResponse, error)
+
+	ImportBooks(r io.Reader, format model.ImportFormat, dryRun bool) (*model.ImportReport, error)
+
+	ExportBooks(w io.Writer, format model.ExportFormat, filter model.BookFilter) error
 }
 
 type bookService struct {
@@ -31,10 +65,188 @@ func NewBookService(db *gorm.DB) BookService {
 	return &bookService{db: db}
 }
 
+// bookSortKeys are the fields the book list can be sorted by.
+var bookSortKeys = sortKeys[model.Book]{
+	"title":		{"title", func(book *model.Book) any { return book.Title }, false},
+	"author":		{"author", func(book *model.Book) any { return book.Author }, false},
+	"price":		{"price_amount", func(book *model.Book) any { return book.Price.Amount }, false},
+	"stock":		{"stock", func(book *model.Book) any { return book.Stock }, false},
+	"created_at":	{"created_at", func(book *model.Book) any { return book.CreatedAt }, false},
+	"rating":		{ratingColumn, func(book *model.Book) any { return book.Rating.Average }, false},
+}
+
+// ratingColumn is the average rating of a book, 0 while it has no rated reviews.
+const ratingColumn = "COALESCE((SELECT average FROM book_ratings WHERE book_ratings.book_id = books.id), 0)"
+
+// searchSortKeys are the fields search results can be sorted by; by default they are ranked.
+var searchSortKeys = sortKeys[bookSearchRow]{
+	"rank":			{"rank", func(row *bookSearchRow) any { return row.Rank }, false},
+	"title":		{"title", func(row *bookSearchRow) any { return row.Title }, false},
+	"price":		{"price_amount", func(row *bookSearchRow) any { return row.Price.Amount }, false},
+	"created_at":	{"created_at", func(row *bookSearchRow) any { return row.CreatedAt }, false},
+}
+
+// bookSearchRow is a book together with its search rank and highlighted excerpt.
+type bookSearchRow struct {
+	model.Book
+	Rank		float32
+	Headline	string
+}
+
+
+func toBookResponse(book *model.Book) model.BookResponse {
+	authors := make([]model.BookAuthorRef, 0, len(book.Authors))
+	for _, credit := range book.Authors {
+		authors = append(authors, model.BookAuthorRef{ID: credit.AuthorID, Name: credit.Author.Name, Role: credit.Role})
+	}
+
+	categories := make([]model.CategoryRef, 0, len(book.Categories))
+	for _, category := range book.Categories {
+		categories = append(categories, model.CategoryRef{ID: category.ID, Name: category.Name})
+	}
+
+	var isbn13, isbn10 string
+	if book.ISBN13 != nil {
+		isbn13 = *book.ISBN13
+	}
+	if book.ISBN10 != nil {
+		isbn10 = *book.ISBN10
+	}
+
+	return model.BookResponse{
+		ID: 			book.ID,
+		Title: 			book.Title,
+		Author: 		book.Author,
+		Authors: 		authors,
+		Description: 	book.Description,
+		Price: 			book.Price,
+		Currency: 		book.Price.Currency,
+		Stock: 			book.Stock,
+		InStock: 		book.Stock > 0,
+		MaxQuantity: 	book.MaxQuantity,
+		ISBN13: 		isbn13,
+		ISBN10: 		isbn10,
+		Rating: 		model.RatingSummary{
+			Average: 	book.Rating.Average,
+			Count: 		book.Rating.Count,
+			Histogram: 	[5]int{book.Rating.Stars1, book.Rating.Stars2, book.Rating.Stars3, book.Rating.Stars4, book.Rating.Stars5},
+		},
+		Categories: 	categories,
+	}
+}
+
+
+// withDetails preloads the rating aggregates, credited authors and categories that toBookResponse reports.
+func withDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Rating").
+		Preload("Authors", func(db *gorm.DB) *gorm.DB { return db.Order("position, role") }).
+		Preload("Authors.Author").
+		Preload("Categories", func(db *gorm.DB) *gorm.DB { return db.Order("categories.name") })
+}
+
+
+// filterBooks narrows a book query to the books matching the filter.
+func filterBooks(filter model.BookFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Author != "" {
+			db = db.Where(writtenBy(db, filter.Author))
+		}
+		if filter.MinPrice != nil {
+			db = db.Where(priceBound(db, ">=", *filter.MinPrice))
+		}
+		if filter.MaxPrice != nil {
+			db = db.Where(priceBound(db, "<=", *filter.MaxPrice))
+		}
+		if filter.InStock != nil {
+			if *filter.InStock {
+				db = db.Where("books.stock > 0")
+			} else {
+				db = db.Where("books.stock = 0")
+			}
+		}
+		return db
+	}
+}
+
+
+// priceBound is a condition comparing the prices of books with a bound, operator being >= or <=.
+// Books priced in another currency are compared with the bound converted at the current exchange
+// rate, and never match when there is none.
+func priceBound(db *gorm.DB, operator string, bound money.Money) *gorm.DB {
+	comparison := "books.price_currency = ? AND books.price_amount " + operator + " ?"
+	condition := db.Session(&gorm.Session{NewDB: true}).Where(comparison, bound.Currency, bound.Amount)
+
+	table, err := loadRateTable(db.Session(&gorm.Session{NewDB: true}))
+	if err != nil {
+		db.AddError(err)
+		return condition
+	}
+
+	for _, currency := range table.counterparts(bound.Currency) {
+		converted, err := table.convert(bound, currency)
+		if err != nil {
+			continue
+		}
+		condition = condition.Or(comparison, currency, converted.Price.Amount)
+	}
+
+	return condition
+}
+
+
+// writtenBy is a condition matching the books credited to an author with the given name or alias,
+// and books whose author string is exactly that name.
+func writtenBy(db *gorm.DB, name string) *gorm.DB {
+	credited := db.Session(&gorm.Session{NewDB: true}).Model(&model.BookAuthor{}).
+		Select("book_id").
+		Where("role = ? AND author_id IN (?)", model.RoleAuthor, authorsNamed(db.Session(&gorm.Session{NewDB: true}), name))
+
+	return db.Session(&gorm.Session{NewDB: true}).Where("books.id IN (?)", credited).Or("LOWER(books.author) = LOWER(?)", name)
+}
+
+
+// unscoped is used when preloading books of carts and orders, so soft-deleted books still resolve.
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
 
 func (b *bookService) CreateBook(bookRequest *model.BookRequest) error {
+	book, err := newBook(bookRequest)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(book).Error; err != nil {
+			return err
+		}
+		return creditAuthor(tx, book.ID, book.Author)
+	})
+
+	return saveBook(err)
+}
+
+
+// newBook validates a request for a new book and builds the book from it.
+// Imported rows go through the same rules.
+func newBook(bookRequest *model.BookRequest) (*model.Book, error) {
 	if bookRequest.Title == "" || bookRequest.Author == "" || bookRequest.Description == "" || bookRequest.Price == nil {
-		return ErrEmptyFields
+		return nil, ErrEmptyFields
+	}
+
+	price, err := bookPrice(*bookRequest.Price, bookRequest.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if bookRequest.Stock != nil && *bookRequest.Stock < 0 {
+		return nil, ErrInvalidStock
+	}
+
+	maxQuantity, err := bookMaxQuantity(bookRequest.MaxQuantity)
+	if err != nil {
+		return nil, err
 	}
 
 	var book model.Book
@@ -42,87 +254,312 @@ func (b *bookService) CreateBook(bookRequest *model.BookRequest) error {
 	book.Title 			= bookRequest.Title
 	book.Author 		= bookRequest.Author
 	book.Description 	= bookRequest.Description
-	book.Price 			= *bookRequest.Price
-	book.InStock 		= true
+	book.Price 			= price
+	book.MaxQuantity 	= maxQuantity
+
+	if bookRequest.Stock != nil {
+		book.Stock = *bookRequest.Stock
+	}
 
-	return b.db.Create(&book).Error
+	if bookRequest.ISBN != "" {
+		if err := setISBN(&book, bookRequest.ISBN); err != nil {
+			return nil, err
+		}
+	}
+
+	return &book, nil
 }
 
 
-func (b *bookService) GetByTitle(bookTitle string) (*model.BookResponse, error) {
+// bookMaxQuantity validates the cap on the copies of a book in a cart; 0 means there is none.
+func bookMaxQuantity(value *int) (*int, error) {
+	if value == nil || *value == 0 {
+		return nil, nil
+	}
+	if *value < 0 {
+		return nil, ErrInvalidMaxQuantity
+	}
+
+	maxQuantity := *value
+	return &maxQuantity, nil
+}
+
+
+// bookPrice rounds a requested price to the minor unit of its currency, the default currency when none is given.
+func bookPrice(value money.Decimal, currency string) (money.Money, error) {
+	currency, err := money.NormalizeCurrency(currency)
+	if err != nil {
+		return money.Money{}, ErrInvalidCurrency
+	}
+
+	price, err := value.Money(currency)
+	if err != nil || price.IsNegative() {
+		return money.Money{}, ErrInvalidPrice
+	}
+
+	return price, nil
+}
+
+
+// setISBN validates an ISBN-10 or ISBN-13 and stores it on the book in both of its forms.
+func setISBN(book *model.Book, value string) error {
+	isbn13, err := isbn.Normalize(value)
+	if err != nil {
+		return ErrInvalidISBN
+	}
+
+	book.ISBN13 = &isbn13
+	book.ISBN10 = nil
+	if isbn10, ok := isbn.ToISBN10(isbn13); ok {
+		book.ISBN10 = &isbn10
+	}
+
+	return nil
+}
+
+
+// saveBook maps a violation of the unique ISBN index to ErrDuplicateISBN.
+func saveBook(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateISBN
+	}
+	return err
+}
+
+
+// GetByISBN finds a book by its ISBN-10 or ISBN-13, with or without hyphens.
+func (b *bookService) GetByISBN(value string) (*model.BookResponse, error) {
+	isbn13, err := isbn.Normalize(value)
+	if err != nil {
+		return nil, ErrInvalidISBN
+	}
+
 	var book model.Book
 
-	if err := b.db.Where("LOWER(title) = LOWER(?)", bookTitle).First(&book).Error; err != nil {
+	if err := b.db.Scopes(withDetails).Where("isbn13 = ?", isbn13).First(&book).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrBookNotFound
 		}
 		return nil, err
 	}
 
-	bookResponse := &model.BookResponse{
-		Title: 			book.Title,
-		Author: 		book.Author,
-		Description: 	book.Description,
-		Price: 			book.Price,
-		InStock: 		book.InStock,
-	}
+	bookResponse := toBookResponse(&book)
 
-	return bookResponse, nil
+	return &bookResponse, nil
 }
 
 
-func (b *bookService) GetBooks() ([]model.BookResponse, error) {
-	var books []model.Book
-	var booksResponse []model.BookResponse
+func (b *bookService) GetByTitle(bookTitle string) (*model.BookResponse, error) {
+	var book model.Book
 
-	if err := b.db.Find(&books).Error; err != nil {
+	if err := b.db.Scopes(withDetails).Where("LOWER(title) = LOWER(?)", bookTitle).First(&book).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookNotFound
+		}
 		return nil, err
 	}
 
-	if len(books) == 0 {
-		return nil, ErrNoBooks
-	}
+	bookResponse := toBookResponse(&book)
 
-	for _, book := range books {
-		bookResponse := model.BookResponse{
-			Title: 			book.Title,
-			Author: 		book.Author,
-			Description: 	book.Description,
-			Price: 			book.Price,
-			InStock: 		book.InStock,
-		}
+	return &bookResponse, nil
+}
+
+
+func (b *bookService) GetBooks(filter model.BookFilter, page model.PageRequest) (*model.Page[model.BookResponse], error) {
+	query := b.db.Model(&model.Book{}).Scopes(filterBooks(filter))
 
-		booksResponse = append(booksResponse, bookResponse)
+	books, err := paginate(query, page, bookSortKeys, withDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	if books.Total == 0 {
+		return nil, ErrNoBooks
 	}
 
-	return booksResponse, nil
+	return mapPage(books, toBookResponse), nil
 }
 
 
 func (b *bookService) GetBooksByAuthor(author string) ([]model.BookResponse, error) {
 	var books []model.Book
 
-	if err := b.db.Where("LOWER(author) = LOWER(?)", author).Find(&books).Error; err != nil {
+	if err := b.db.Scopes(withDetails).Where(writtenBy(b.db, author)).Find(&books).Error; err != nil {
 		return nil, err
 	}
 
 	bookResponses := make([]model.BookResponse, 0, len(books))
 
 	for _, book := range books {
-		bookResponses = append(bookResponses, model.BookResponse{
-			Title: 			book.Title,
-			Author: 		book.Author,
-			Description: 	book.Description,
-			Price: 			book.Price,
-			InStock: 		book.InStock,
-		})
+		bookResponses = append(bookResponses, toBookResponse(&book))
 	}
 
 	return bookResponses, nil
 }
 
 
-func (b *bookService) UpdateStock(bookID uint) error {
+// SearchBooks runs a full-text search over title, author and description. The query uses web search
+// syntax: words are matched after stemming, "quoted phrases" must appear in order and -word excludes.
+func (b *bookService) SearchBooks(query string, page model.PageRequest) (*model.Page[model.BookSearchResult], error) {
+	matches := b.db.Model(&model.Book{}).
+		Select(`books.*,
+			ts_rank_cd(books.search_vector, search_query) AS rank,
+			ts_headline('english', books.description, search_query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15') AS headline`).
+		Joins("CROSS JOIN websearch_to_tsquery('english', ?) AS search_query", query).
+		Where("books.search_vector @@ search_query")
+
+	if page.Sort == "" {
+		page.Sort = "rank"
+		page.Desc = true
+	}
+
+	rows, err := paginate(b.db.Table("(?) AS matches", matches), page, searchSortKeys, withDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPage(rows, func(row *bookSearchRow) model.BookSearchResult {
+		return model.BookSearchResult{
+			BookResponse: 	toBookResponse(&row.Book),
+			Rank: 			row.Rank,
+			Highlight: 		row.Headline,
+		}
+	}), nil
+}
+
+
+// GetBooksByCategory lists the books of a category and, with includeDescendants, of all its subcategories.
+func (b *bookService) GetBooksByCategory(categoryID uint, includeDescendants bool, page model.PageRequest) (*model.Page[model.BookResponse], error) {
+	if _, err := findCategory(b.db, categoryID, ErrCategoryNotFound); err != nil {
+		return nil, err
+	}
+
+	var categories any = categoryID
+	if includeDescendants {
+		categories = categorySubtree(b.db, categoryID)
+	}
+
+	query := b.db.Model(&model.Book{}).
+		Where("books.id IN (SELECT book_id FROM book_categories WHERE category_id IN (?))", categories)
+
+	books, err := paginate(query, page, bookSortKeys, withDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPage(books, toBookResponse), nil
+}
+
+
+// SetBookCategories replaces the categories of a book with the given ones.
+func (b *bookService) SetBookCategories(bookID uint, categoryIDs []uint) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var book model.Book
+
+		if err := tx.First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		categories := []model.Category{}
+		if len(categoryIDs) > 0 {
+			if err := tx.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+				return err
+			}
+		}
+
+		unique := make(map[uint]bool, len(categoryIDs))
+		for _, id := range categoryIDs {
+			unique[id] = true
+		}
+		if len(categories) != len(unique) {
+			return ErrCategoryNotFound
+		}
+
+		return tx.Model(&book).Omit("Categories.*").Association("Categories").Replace(categories)
+	})
+}
+
+
+// SetBookAuthors replaces the credits of a book, in the order given. The book needs at least one
+// author; translators and editors are optional. Its author string becomes the authors' names.
+func (b *bookService) SetBookAuthors(bookID uint, authors []model.BookAuthorRequest) error {
+	credits := make([]model.BookAuthor, 0, len(authors))
+	seen := make(map[model.BookAuthorRequest]bool, len(authors))
+	authorIDs := make(map[uint]bool, len(authors))
+	hasAuthor := false
+
+	for _, author := range authors {
+		if author.Role == "" {
+			author.Role = model.RoleAuthor
+		}
+
+		switch author.Role {
+		case model.RoleAuthor:
+			hasAuthor = true
+		case model.RoleTranslator, model.RoleEditor:
+		default:
+			return ErrInvalidAuthorRole
+		}
+
+		if seen[author] {
+			continue
+		}
+		seen[author] = true
+		authorIDs[author.AuthorID] = true
+
+		credits = append(credits, model.BookAuthor{BookID: bookID, AuthorID: author.AuthorID, Role: author.Role, Position: len(credits)})
+	}
+
+	if !hasAuthor {
+		return ErrBookNeedsAuthor
+	}
+
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var book model.Book
+
+		if err := tx.First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		ids := make([]uint, 0, len(authorIDs))
+		for id := range authorIDs {
+			ids = append(ids, id)
+		}
+
+		var count int64
+		if err := tx.Model(&model.Author{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(ids) {
+			return ErrAuthorNotFound
+		}
+
+		if err := tx.Where("book_id = ?", bookID).Delete(&model.BookAuthor{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Author").Create(&credits).Error; err != nil {
+			return err
+		}
+
+		return refreshAuthorNames(tx, []uint{bookID})
+	})
+}
+
+
+// UpdateStock sets the stock to an absolute value or changes it by a delta. Deltas are
+// applied in a single UPDATE so concurrent orders and restocks are never lost.
+func (b *bookService) UpdateStock(bookID uint, stockUpdate model.StockUpdate) error {
+	if (stockUpdate.Stock == nil) == (stockUpdate.Delta == nil) {
+		return ErrInvalidStockUpdate
+	}
+
 	var book model.Book
 
 	if err := b.db.First(&book, bookID).Error; err != nil {
@@ -132,12 +569,141 @@ func (b *bookService) UpdateStock(bookID uint) error {
 		return err
 	}
 
-	switch book.InStock {
-	case true:
-		book.InStock = false
-	case false:
-		book.InStock = true
+	if stockUpdate.Stock != nil {
+		if *stockUpdate.Stock < 0 {
+			return ErrInvalidStock
+		}
+		return b.db.Model(&book).Update("stock", *stockUpdate.Stock).Error
+	}
+
+	delta := *stockUpdate.Delta
+
+	result := b.db.Model(&model.Book{}).
+		Where("id = ? AND stock + ? >= 0", bookID, delta).
+		Update("stock", gorm.Expr("stock + ?", delta))
+	if result.Error != nil {
+		return result.Error
 	}
 
-	return b.db.Save(&book).Error
+	if result.RowsAffected == 0 {
+		return ErrInvalidStock
+	}
+
+	return nil
+}
+
+
+// UpdateBook applies a partial update: empty strings, a nil price and a nil max quantity leave
+// the field unchanged.
+func (b *bookService) UpdateBook(bookID uint, bookRequest *model.BookRequest) error {
+	var book model.Book
+
+	if err := b.db.First(&book, bookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBookNotFound
+		}
+		return err
+	}
+
+	// A new author string replaces the credited authors; the same string leaves them as they are.
+	relinkAuthor := bookRequest.Author != "" && !strings.EqualFold(bookRequest.Author, book.Author)
+
+	if bookRequest.Title != "" {
+		book.Title = bookRequest.Title
+	}
+	if bookRequest.Author != "" {
+		book.Author = bookRequest.Author
+	}
+	if bookRequest.Description != "" {
+		book.Description = bookRequest.Description
+	}
+	if bookRequest.Price != nil || bookRequest.Currency != "" {
+		// A currency alone relabels the current price, a price alone keeps the current currency.
+		value, currency := money.Decimal(book.Price.String()), book.Price.Currency
+		if bookRequest.Price != nil {
+			value = *bookRequest.Price
+		}
+		if bookRequest.Currency != "" {
+			currency = bookRequest.Currency
+		}
+
+		price, err := bookPrice(value, currency)
+		if err != nil {
+			return err
+		}
+		book.Price = price
+	}
+	if bookRequest.MaxQuantity != nil {
+		maxQuantity, err := bookMaxQuantity(bookRequest.MaxQuantity)
+		if err != nil {
+			return err
+		}
+		book.MaxQuantity = maxQuantity
+	}
+	if bookRequest.ISBN != "" {
+		if err := setISBN(&book, bookRequest.ISBN); err != nil {
+			return err
+		}
+	}
+
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&book).Error; err != nil {
+			return err
+		}
+		if relinkAuthor {
+			return creditAuthor(tx, book.ID, book.Author)
+		}
+		return nil
+	})
+
+	return saveBook(err)
+}
+
+
+// DeleteBook soft-deletes the book so that orders and carts referencing it keep working.
+func (b *bookService) DeleteBook(bookID uint) error {
+	result := b.db.Delete(&model.Book{}, bookID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrBookNotFound
+	}
+
+	return nil
+}
+
+
+func (b *bookService) RestoreBook(bookID uint) error {
+	var book model.Book
+
+	if err := b.db.Unscoped().First(&book, bookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBookNotFound
+		}
+		return err
+	}
+
+	if !book.DeletedAt.Valid {
+		return ErrBookNotDeleted
+	}
+
+	return b.db.Unscoped().Model(&book).Update("deleted_at", nil).Error
+}
+
+
+func (b *bookService) GetDeletedBooks() ([]model.BookResponse, error) {
+	var books []model.Book
+
+	if err := b.db.Unscoped().Scopes(withDetails).Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&books).Error; err != nil {
+		return nil, err
+	}
+
+	bookResponses := make([]model.BookResponse, 0, len(books))
+	for _, book := range books {
+		bookResponses = append(bookResponses, toBookResponse(&book))
+	}
+
+	return bookResponses, nil
 }
\ No newline at end of file
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/money"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// couponUsageSQL counts the orders placed with each coupon, in total and by one user.
+// Cancelled orders give their use back.
+const couponUsageSQL = `
+	SELECT order_coupons.coupon_id,
+		COUNT(*) AS total,
+		COUNT(*) FILTER (WHERE orders.user_id = ?) AS by_user
+	FROM order_coupons
+	JOIN orders ON orders.id = order_coupons.order_id AND orders.deleted_at IS NULL
+	WHERE order_coupons.coupon_id IN ? AND orders.status <> 'cancelled'
+	GROUP BY order_coupons.coupon_id`
+
+// couponUsage is the number of orders placed with a coupon, in total and by one user.
+type couponUsage struct {
+	CouponID	uint
+	Total		int
+	ByUser		int
+}
+
+// CouponError is returned when an order cannot be placed because one of the coupons of the cart
+// cannot be redeemed.
+type CouponError struct {
+	Code	string
+	Err		error
+}
+
+// couponDiscount is the discount a coupon gives on a cart, or the reason it gives none.
+type couponDiscount struct {
+	coupon		model.Coupon
+	discount	money.Money
+	err			error
+}
+
+// cartPricing is the price of a cart: the sum of its lines, the discount of every coupon and
+// what is left to pay.
+type cartPricing struct {
+	subtotal	money.Money
+	discounts	[]couponDiscount
+	discount	money.Money
+	total		money.Money
+}
+
+
+func (e *CouponError) Error() string {
+	return fmt.Sprintf("coupon %s: %v", e.Code, e.Err)
+}
+
+
+func (e *CouponError) Unwrap() error {
+	return e.Err
+}
+
+
+// cartCoupons loads the coupons applied to a cart, sorted by code.
+func cartCoupons(db *gorm.DB, cartID uint) ([]model.Coupon, error) {
+	var coupons []model.Coupon
+
+	err := db.Joins("JOIN cart_coupons ON cart_coupons.coupon_id = coupons.id").
+		Where("cart_coupons.cart_id = ?", cartID).
+		Order("coupons.code").
+		Find(&coupons).Error
+
+	return coupons, err
+}
+
+
+func loadCouponUsage(db *gorm.DB, userID uint, coupons []model.Coupon) (map[uint]couponUsage, error) {
+	usage := make(map[uint]couponUsage, len(coupons))
+	if len(coupons) == 0 {
+		return usage, nil
+	}
+
+	couponIDs := make([]uint, 0, len(coupons))
+	for _, coupon := range coupons {
+		couponIDs = append(couponIDs, coupon.ID)
+	}
+
+	var rows []couponUsage
+	if err := db.Raw(couponUsageSQL, userID, couponIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		usage[row.CouponID] = row
+	}
+
+	return usage, nil
+}
+
+
+// couponBooks returns which of the books a coupon applies to; nil means every book.
+func couponBooks(db *gorm.DB, coupon model.Coupon, bookIDs []uint) (map[uint]bool, error) {
+	switch coupon.Scope {
+	case model.ScopeBook:
+		return map[uint]bool{*coupon.BookID: true}, nil
+	case model.ScopeCategory:
+		var matched []uint
+
+		err := db.Table("book_categories").
+			Where("category_id IN (?)", categorySubtree(db, *coupon.CategoryID)).
+			Where("book_id IN ?", bookIDs).
+			Distinct().
+			Pluck("book_id", &matched).Error
+		if err != nil {
+			return nil, err
+		}
+
+		books := make(map[uint]bool, len(matched))
+		for _, bookID := range matched {
+			books[bookID] = true
+		}
+		return books, nil
+	}
+
+	return nil, nil
+}
+
+
+// checkCoupon tells why a coupon cannot be redeemed on a cart with the given subtotal, if it cannot.
+func checkCoupon(coupon model.Coupon, usage couponUsage, subtotal money.Money, couponCount int, now time.Time) error {
+	if (coupon.StartsAt != nil && now.Before(*coupon.StartsAt)) || (coupon.EndsAt != nil && !now.Before(*coupon.EndsAt)) {
+		return ErrCouponNotActive
+	}
+
+	if coupon.MaxUses != nil && usage.Total >= *coupon.MaxUses {
+		return ErrCouponUsedUp
+	}
+	if coupon.MaxUsesPerUser != nil && usage.ByUser >= *coupon.MaxUsesPerUser {
+		return ErrCouponUserLimit
+	}
+
+	if !coupon.Stackable && couponCount > 1 {
+		return ErrCouponNotStackable
+	}
+
+	if (coupon.Kind == model.CouponFixed || coupon.MinSpend > 0) && coupon.Currency != subtotal.Currency {
+		return ErrCouponCurrency
+	}
+	if subtotal.Amount < coupon.MinSpend {
+		return ErrCouponMinSpend
+	}
+
+	return nil
+}
+
+
+// sortCoupons orders coupons the way they are applied: coupons on books and categories before
+// coupons on the whole order, and within each, percentages before fixed amounts.
+func sortCoupons(coupons []model.Coupon) []model.Coupon {
+	sorted := append([]model.Coupon(nil), coupons...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.Scope == model.ScopeOrder) != (b.Scope == model.ScopeOrder) {
+			return b.Scope == model.ScopeOrder
+		}
+		if a.Kind != b.Kind {
+			return a.Kind == model.CouponPercent
+		}
+		return a.Code < b.Code
+	})
+
+	return sorted
+}
+
+
+// priceCart sums up the lines of a cart and takes the discounts of its coupons off. Every coupon
+// is applied to what earlier coupons left of the books it covers, so the discounts never exceed
+// the price of the books. A coupon that cannot be redeemed gives no discount and keeps the reason.
+func priceCart(db *gorm.DB, userID uint, lines []model.CartBook, coupons []model.Coupon, now time.Time) (*cartPricing, error) {
+	currency := money.DefaultCurrency()
+	if len(lines) > 0 {
+		currency = lines[0].Book.Price.Currency
+	}
+
+	pricing := &cartPricing{subtotal: money.New(0, currency), discount: money.New(0, currency)}
+
+	remaining := make([]int64, len(lines))
+	bookIDs := make([]uint, 0, len(lines))
+
+	for i, line := range lines {
+		lineTotal, err := line.Book.Price.Mul(int64(line.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		if pricing.subtotal, err = pricing.subtotal.Add(lineTotal); err != nil {
+			if errors.Is(err, money.ErrCurrencyMismatch) {
+				return nil, ErrMixedCurrencies
+			}
+			return nil, err
+		}
+
+		remaining[i] = lineTotal.Amount
+		bookIDs = append(bookIDs, line.BookID)
+	}
+
+	usage, err := loadCouponUsage(db, userID, coupons)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, coupon := range sortCoupons(coupons) {
+		applied := couponDiscount{coupon: coupon, discount: money.New(0, currency)}
+
+		applied.err = checkCoupon(coupon, usage[coupon.ID], pricing.subtotal, len(coupons), now)
+		if applied.err == nil {
+			if applied.discount, applied.err = takeDiscount(db, coupon, lines, bookIDs, remaining); applied.err != nil && !isCouponError(applied.err) {
+				return nil, applied.err
+			}
+		}
+
+		if pricing.discount, err = pricing.discount.Add(applied.discount); err != nil {
+			return nil, err
+		}
+
+		pricing.discounts = append(pricing.discounts, applied)
+	}
+
+	pricing.total = money.New(pricing.subtotal.Amount-pricing.discount.Amount, currency)
+
+	return pricing, nil
+}
+
+
+// takeDiscount computes the discount of a coupon on the books it covers and takes it off
+// what is left of their lines, line by line.
+func takeDiscount(db *gorm.DB, coupon model.Coupon, lines []model.CartBook, bookIDs []uint, remaining []int64) (money.Money, error) {
+	currency := money.DefaultCurrency()
+	if len(lines) > 0 {
+		currency = lines[0].Book.Price.Currency
+	}
+
+	books, err := couponBooks(db, coupon, bookIDs)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	var covered []int
+	var base int64
+	for i, line := range lines {
+		if books == nil || books[line.BookID] {
+			covered = append(covered, i)
+			base += remaining[i]
+		}
+	}
+
+	if base == 0 {
+		return money.New(0, currency), ErrCouponNotApplicable
+	}
+
+	discount := money.New(min(coupon.AmountOff, base), currency)
+	if coupon.Kind == model.CouponPercent {
+		percent := new(big.Rat).Mul(money.New(base, currency).Rat(), big.NewRat(int64(coupon.PercentOff), 100))
+		if discount, err = money.Round(percent, currency); err != nil {
+			return money.Money{}, err
+		}
+	}
+
+	left := discount.Amount
+	for _, i := range covered {
+		taken := min(left, remaining[i])
+		remaining[i] -= taken
+		left -= taken
+	}
+
+	return discount, nil
+}
+
+
+// isCouponError tells whether the error is a reason a coupon cannot be redeemed.
+func isCouponError(err error) bool {
+	switch err {
+	case ErrCouponNotActive, ErrCouponUsedUp, ErrCouponUserLimit, ErrCouponNotStackable,
+		ErrCouponCurrency, ErrCouponMinSpend, ErrCouponNotApplicable:
+		return true
+	}
+	return false
+}
+
+
+// appliedCoupons lists the discounts of a priced cart in the order they were applied.
+func appliedCoupons(pricing *cartPricing) []model.AppliedCoupon {
+	applied := make([]model.AppliedCoupon, 0, len(pricing.discounts))
+
+	for _, discount := range pricing.discounts {
+		coupon := toAppliedCoupon(discount.coupon.Code, discount.coupon.Kind, discount.coupon.Scope, discount.coupon.PercentOff, discount.coupon.AmountOff, discount.coupon.Currency, discount.discount)
+		if discount.err != nil {
+			coupon.Error = discount.err.Error()
+		}
+		applied = append(applied, coupon)
+	}
+
+	return applied
+}
+
+
+func toAppliedCoupon(code string, kind model.CouponKind, scope model.CouponScope, percentOff int, amountOff int64, currency string, discount money.Money) model.AppliedCoupon {
+	applied := model.AppliedCoupon{Code: code, Kind: kind, Scope: scope, Discount: discount}
+
+	if kind == model.CouponPercent {
+		applied.PercentOff = percentOff
+	} else {
+		amount := money.New(amountOff, currency)
+		applied.AmountOff = &amount
+	}
+
+	return applied
+}
@@ -1,17 +1,57 @@
 package service
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrCartNotFound 	= errors.New("cart not found")
 	ErrCartBookNotFound = errors.New("book in cart not found")
+	ErrCouponApplied	= errors.New("coupon is already applied to the cart")
+	ErrCartCouponNotFound	= errors.New("coupon is not applied to the cart")
+	ErrInvalidQuantity		= errors.New("quantity must be at least 1")
+	ErrNegativeQuantity		= errors.New("quantity cannot be negative")
+	ErrNoCartLines			= errors.New("at least one cart line must be given")
+	ErrDuplicateCartLine	= errors.New("book is given more than once")
 )
 
+
+// QuantityLimitError is returned when a cart line would hold more copies of a book than the
+// max quantity admins set for it.
+type QuantityLimitError struct {
+	Max	int
+}
+
+
+// CartLineError tells which line of a batch of cart lines could not be set.
+type CartLineError struct {
+	BookID	uint
+	Err		error
+}
+
+
+func (e *QuantityLimitError) Error() string {
+	return fmt.Sprintf("at most %d copies of the book can be in a cart", e.Max)
+}
+
+
+func (e *CartLineError) Error() string {
+	return fmt.Sprintf("book %d: %v", e.BookID, e.Err)
+}
+
+
+func (e *CartLineError) Unwrap() error {
+	return e.Err
+}
+
 type CartService interface {
 	AddToCart(userID uint, bookID uint, quantity int) error
 
@@ -20,8 +60,14 @@ type CartService interface {
 	RemoveFromCart(userID uint, bookID uint) error
 
 	UpdateQuantity(userID uint, bookID uint, quantity int) error
+
+	SetCartLines(userID uint, lines []model.CartLineRequest) error
+
+	ApplyCoupon(userID uint, code string) error
+
+	RemoveCoupon(userID uint, code string) error
 	
-	GetCart(cartID uint) (*model.CartResponse, error)
+	GetCart(principal auth.Principal, cartID uint) (*model.CartResponse, error)
 }
 
 type cartService struct {
@@ -33,45 +79,88 @@ func NewCartService(db *gorm.DB) CartService {
 }
 
 
+// AddToCart puts copies of a book in the cart. When the book is already in the cart the copies
+// are added to its line, as long as the line stays within the book's max quantity.
 func (c *cartService) AddToCart(userID uint, bookID uint, quantity int) error {
-	var cart model.Cart
+	if quantity < 1 {
+		return ErrInvalidQuantity
+	}
 
-	if err := c.db.Where("user_id = ?", userID).First(&cart).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			cart = model.Cart{UserID: userID}
-			if err := c.db.Create(&cart).Error; err != nil {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := lockCart(tx, userID, true)
+		if err != nil {
+			return err
+		}
+
+		var book model.Book
+
+		if err := tx.First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		var cartBook model.CartBook
+
+		if err := tx.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&cartBook).Error; err == nil {
+			if err := checkQuantityLimit(&book, cartBook.Quantity + quantity); err != nil {
 				return err
 			}
-		} else {
+			cartBook.Quantity += quantity
+			cartBook.Price = book.Price
+			return tx.Save(&cartBook).Error
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
 		}
-	}
 
-	var book model.Book
+		if err := checkQuantityLimit(&book, quantity); err != nil {
+			return err
+		}
 
-	if err := c.db.First(&book, bookID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrBookNotFound
+		cartBook = model.CartBook{
+			CartID: cart.ID,
+			BookID: bookID,
+			Quantity: quantity,
+			Price: book.Price,
 		}
-		return err
-	}
 
-	var cartBook model.CartBook
+		return tx.Create(&cartBook).Error
+	})
+}
 
-	if err := c.db.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&cartBook).Error; err == nil {
-		cartBook.Quantity += quantity
-		return c.db.Save(&cartBook).Error
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return err
+
+// lockCart loads the user's cart and locks it, so that changes to the same cart queue up and
+// a book never ends up on two lines. A missing cart is created when create is set.
+func lockCart(tx *gorm.DB, userID uint, create bool) (*model.Cart, error) {
+	var cart model.Cart
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&cart).Error
+	if err == nil {
+		return &cart, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !create {
+		return nil, ErrCartNotFound
 	}
 
-	cartBook = model.CartBook{
-		CartID: cart.ID,
-		BookID: bookID,
-		Quantity: quantity,
+	cart = model.Cart{UserID: userID}
+	if err := tx.Create(&cart).Error; err != nil {
+		return nil, err
 	}
 
-	return c.db.Create(&cartBook).Error
+	return &cart, nil
+}
+
+
+// checkQuantityLimit refuses more copies of a book than admins allow in one cart.
+func checkQuantityLimit(book *model.Book, quantity int) error {
+	if book.MaxQuantity != nil && quantity > *book.MaxQuantity {
+		return &QuantityLimitError{Max: *book.MaxQuantity}
+	}
+	return nil
 }
 
 
@@ -85,11 +174,13 @@ func (c *cartService) ClearCart(userID uint) error {
 		return err
 	}
 
-	if err := c.db.Where("cart_id = ?", cart.ID).Delete(&model.CartBook{}).Error; err != nil {
-		return err
-	}
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&model.CartBook{}).Error; err != nil {
+			return err
+		}
 
-	return nil
+		return tx.Where("cart_id = ?", cart.ID).Delete(&model.CartCoupon{}).Error
+	})
 }
 
 
@@ -116,51 +207,275 @@ func (c *cartService) RemoveFromCart(userID uint, bookID uint) error {
 }
 
 
+// UpdateQuantity sets the copies of a book in the cart; a quantity of 0 removes the book.
 func (c *cartService) UpdateQuantity(userID uint, bookID uint, quantity int) error {
-	var cart model.Cart
+	if quantity < 0 {
+		return ErrNegativeQuantity
+	}
 
-	if err := c.db.Where("user_id = ?", userID).First(&cart).Error; err != nil {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := lockCart(tx, userID, false)
+		if err != nil {
+			return err
+		}
+
+		var cartBook model.CartBook
+
+		if err := tx.Preload("Book", unscoped).Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&cartBook).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCartBookNotFound
+			}
+			return err
+		}
+
+		return setCartLine(tx, &cartBook, &cartBook.Book, quantity)
+	})
+}
+
+
+// SetCartLines sets the copies of several books in the cart at once, creating the cart if
+// needed. Either every line is set or, when one of them cannot be, none is; books that are
+// not given stay as they are.
+func (c *cartService) SetCartLines(userID uint, lines []model.CartLineRequest) error {
+	if len(lines) == 0 {
+		return ErrNoCartLines
+	}
+
+	given := make(map[uint]bool, len(lines))
+	for _, line := range lines {
+		if line.Quantity < 0 {
+			return &CartLineError{BookID: line.BookID, Err: ErrNegativeQuantity}
+		}
+		if given[line.BookID] {
+			return &CartLineError{BookID: line.BookID, Err: ErrDuplicateCartLine}
+		}
+		given[line.BookID] = true
+	}
+
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := lockCart(tx, userID, true)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			if err := setCartBook(tx, cart.ID, line); err != nil {
+				var limitErr *QuantityLimitError
+				if err == ErrBookNotFound || errors.As(err, &limitErr) {
+					return &CartLineError{BookID: line.BookID, Err: err}
+				}
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+
+// setCartBook sets one line of a batch. Books already in the cart resolve even when they were
+// removed from the catalog, like in UpdateQuantity; books new to the cart must be in it.
+func setCartBook(tx *gorm.DB, cartID uint, line model.CartLineRequest) error {
+	var cartBook model.CartBook
+
+	err := tx.Preload("Book", unscoped).Where("cart_id = ? AND book_id = ?", cartID, line.BookID).First(&cartBook).Error
+	if err == nil {
+		return setCartLine(tx, &cartBook, &cartBook.Book, line.Quantity)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if line.Quantity == 0 {
+		return nil
+	}
+
+	var book model.Book
+
+	if err := tx.First(&book, line.BookID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrCartNotFound
+			return ErrBookNotFound
 		}
 		return err
 	}
 
-	var cartBook model.CartBook
+	if err := checkQuantityLimit(&book, line.Quantity); err != nil {
+		return err
+	}
+
+	return tx.Create(&model.CartBook{CartID: cartID, BookID: book.ID, Quantity: line.Quantity, Price: book.Price}).Error
+}
+
+
+// setCartLine changes the copies on an existing line, or removes it for a quantity of 0.
+// The line keeps the price the book had when it was added.
+func setCartLine(tx *gorm.DB, cartBook *model.CartBook, book *model.Book, quantity int) error {
+	if quantity == 0 {
+		return tx.Delete(cartBook).Error
+	}
+
+	if err := checkQuantityLimit(book, quantity); err != nil {
+		return err
+	}
+
+	return tx.Model(cartBook).Update("quantity", quantity).Error
+}
+
+
+// ApplyCoupon adds a coupon to the user's cart. It is only added when it gives a discount on
+// the cart as it is now and can be combined with the coupons already applied.
+func (c *cartService) ApplyCoupon(userID uint, code string) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		var cart model.Cart
+
+		if err := tx.Where("user_id = ?", userID).First(&cart).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCartNotFound
+			}
+			return err
+		}
+
+		coupon, err := findCouponByCode(tx, code)
+		if err != nil {
+			return err
+		}
+
+		coupons, err := cartCoupons(tx, cart.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, applied := range coupons {
+			if applied.ID == coupon.ID {
+				return ErrCouponApplied
+			}
+			if !applied.Stackable || !coupon.Stackable {
+				return ErrCouponNotStackable
+			}
+		}
 
-	if err := c.db.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&cartBook).Error; err != nil {
+		if err := tx.Preload("Book", unscoped).Where("cart_id = ?", cart.ID).Find(&cart.Books).Error; err != nil {
+			return err
+		}
+
+		pricing, err := priceCart(tx, userID, cart.Books, append(coupons, *coupon), time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, discount := range pricing.discounts {
+			if discount.coupon.ID == coupon.ID && discount.err != nil {
+				return discount.err
+			}
+		}
+
+		return tx.Create(&model.CartCoupon{CartID: cart.ID, CouponID: coupon.ID}).Error
+	})
+}
+
+
+func (c *cartService) RemoveCoupon(userID uint, code string) error {
+	var cart model.Cart
+
+	if err := c.db.Where("user_id = ?", userID).First(&cart).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrCartBookNotFound
+			return ErrCartNotFound
 		}
 		return err
 	}
 
-	cartBook.Quantity = quantity
+	result := c.db.Where("cart_id = ? AND coupon_id IN (?)", cart.ID, c.db.Model(&model.Coupon{}).Select("id").Where("code = ?", normalizeCouponCode(code))).
+		Delete(&model.CartCoupon{})
+	if result.Error != nil {
+		return result.Error
+	}
 
-	return c.db.Save(&cartBook).Error
+	if result.RowsAffected == 0 {
+		return ErrCartCouponNotFound
+	}
+
+	return nil
 }
 
 
-func (c *cartService) GetCart(cartID uint) (*model.CartResponse, error) {
+func toCartBookResponse(cartBook *model.CartBook) (model.CartBookResponse, error) {
+	lineTotal, err := cartBook.Book.Price.Mul(int64(cartBook.Quantity))
+	if err != nil {
+		return model.CartBookResponse{}, err
+	}
+
+	line := model.CartBookResponse{
+		BookResponse: 	toBookResponse(&cartBook.Book),
+		Available: 		!cartBook.Book.DeletedAt.Valid,
+		Quantity: 		cartBook.Quantity,
+		UnitPrice: 		cartBook.Book.Price,
+		LineTotal: 		lineTotal,
+		PriceChanged: 	cartBook.Price != cartBook.Book.Price,
+		OutOfStock: 	cartBook.Book.Stock < cartBook.Quantity,
+	}
+
+	if line.PriceChanged {
+		addedPrice := cartBook.Price
+		line.AddedPrice = &addedPrice
+	}
+
+	return line, nil
+}
+
+
+// GetCart returns the cart with the quantity and price of every line, its coupons and its totals.
+func (c *cartService) GetCart(principal auth.Principal, cartID uint) (*model.CartResponse, error) {
 	var cart model.Cart
 
-	if err := c.db.Preload("Books.Book").First(&cart, cartID).Error; err != nil {
+	detailedBook := func(db *gorm.DB) *gorm.DB { return db.Unscoped().Scopes(withDetails) }
+
+	if err := c.db.Preload("Books.Book", detailedBook).First(&cart, cartID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrCartNotFound
 		}
 		return nil, err
 	}
 
-	bookResponses := make([]model.BookResponse, 0, len(cart.Books))
+	if err := authorizeOwner(principal, cart.UserID); err != nil {
+		return nil, err
+	}
+
+	bookResponses := make([]model.CartBookResponse, 0, len(cart.Books))
 	for _, b := range cart.Books {
-		bookResponses = append(bookResponses, model.BookResponse{
-			Title: 			b.Book.Title,
-			Author: 		b.Book.Author,
-			Description: 	b.Book.Description,
-			Price: 			b.Book.Price,
-			InStock: 		b.Book.InStock,
-		})
+		line, err := toCartBookResponse(&b)
+		if err != nil {
+			return nil, err
+		}
+		bookResponses = append(bookResponses, line)
+	}
+
+	response := &model.CartResponse{ID: cart.ID, UserID: cart.UserID, Books: bookResponses}
+
+	coupons, err := cartCoupons(c.db, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pricing, err := priceCart(c.db, cart.UserID, cart.Books, coupons, time.Now())
+	switch err {
+	case nil:
+		response.Coupons = appliedCoupons(pricing)
+		response.Totals = &model.CartTotals{
+			Subtotal: 	pricing.subtotal,
+			Discount: 	pricing.discount,
+			Total: 		pricing.total,
+			Currency: 	pricing.total.Currency,
+		}
+	case ErrMixedCurrencies:
+		response.Coupons = make([]model.AppliedCoupon, 0, len(coupons))
+		for _, coupon := range coupons {
+			applied := toAppliedCoupon(coupon.Code, coupon.Kind, coupon.Scope, coupon.PercentOff, coupon.AmountOff, coupon.Currency, money.New(0, coupon.Currency))
+			applied.Error = err.Error()
+			response.Coupons = append(response.Coupons, applied)
+		}
+	default:
+		return nil, err
 	}
 
-	return &model.CartResponse{ID: cart.ID, UserID: cart.UserID, Books: bookResponses}, nil
+	return response, nil
 }
\ No newline at end of file
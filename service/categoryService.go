@@ -0,0 +1,255 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNotFound			= errors.New("category not found")
+	ErrParentCategoryNotFound	= errors.New("parent category not found")
+	ErrEmptyCategoryName		= errors.New("category name cannot be empty")
+	ErrCategoryExists			= errors.New("a category with this name already exists at this level")
+	ErrCategoryCycle			= errors.New("a category cannot be moved under itself or one of its subcategories")
+	ErrCategoryHasChildren		= errors.New("category still has subcategories")
+)
+
+type CategoryService interface {
+	CreateCategory(categoryRequest model.CategoryRequest) (*model.CategoryNode, error)
+
+	GetCategoryTree() ([]model.CategoryNode, error)
+
+	UpdateCategory(categoryID uint, categoryUpdate model.CategoryUpdate) error
+
+	DeleteCategory(categoryID uint) error
+}
+
+type categoryService struct {
+	db *gorm.DB
+}
+
+func NewCategoryService(db *gorm.DB) CategoryService {
+	return &categoryService{db: db}
+}
+
+// categorySubtreeSQL selects the IDs of a category and of all its descendants.
+const categorySubtreeSQL = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION ALL
+		SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+	)
+	SELECT id FROM subtree`
+
+// categoryCountsSQL counts the distinct live books of every category, both directly assigned
+// and across the whole subtree below it.
+const categoryCountsSQL = `
+	WITH RECURSIVE tree AS (
+		SELECT id AS root_id, id FROM categories
+		UNION ALL
+		SELECT tree.root_id, categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+	)
+	SELECT tree.root_id AS category_id,
+		COUNT(DISTINCT book_categories.book_id) FILTER (WHERE book_categories.category_id = tree.root_id) AS book_count,
+		COUNT(DISTINCT book_categories.book_id) AS total_book_count
+	FROM tree
+	JOIN book_categories ON book_categories.category_id = tree.id
+	JOIN books ON books.id = book_categories.book_id AND books.deleted_at IS NULL
+	GROUP BY tree.root_id`
+
+
+// categorySubtree is a subquery selecting the IDs of the category and its descendants.
+func categorySubtree(db *gorm.DB, categoryID uint) *gorm.DB {
+	return db.Raw(categorySubtreeSQL, categoryID)
+}
+
+
+func findCategory(db *gorm.DB, categoryID uint, notFound error) (*model.Category, error) {
+	var category model.Category
+
+	if err := db.First(&category, categoryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFound
+		}
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+
+func (c *categoryService) CreateCategory(categoryRequest model.CategoryRequest) (*model.CategoryNode, error) {
+	name := strings.TrimSpace(categoryRequest.Name)
+	if name == "" {
+		return nil, ErrEmptyCategoryName
+	}
+
+	if categoryRequest.ParentID != nil {
+		if _, err := findCategory(c.db, *categoryRequest.ParentID, ErrParentCategoryNotFound); err != nil {
+			return nil, err
+		}
+	}
+
+	category := model.Category{Name: name, ParentID: categoryRequest.ParentID}
+
+	if err := c.db.Create(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrCategoryExists
+		}
+		return nil, err
+	}
+
+	return &model.CategoryNode{
+		ID: 		category.ID,
+		Name: 		category.Name,
+		ParentID: 	category.ParentID,
+		Children: 	[]model.CategoryNode{},
+	}, nil
+}
+
+
+// GetCategoryTree returns the root categories with their subcategories nested below them,
+// siblings sorted by name, each with the number of books it holds.
+func (c *categoryService) GetCategoryTree() ([]model.CategoryNode, error) {
+	var categories []model.Category
+
+	if err := c.db.Order("LOWER(name), id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		CategoryID		uint
+		BookCount		int
+		TotalBookCount	int
+	}
+
+	if err := c.db.Raw(categoryCountsSQL).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*model.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &model.CategoryNode{
+			ID: 		category.ID,
+			Name: 		category.Name,
+			ParentID: 	category.ParentID,
+		}
+	}
+
+	for _, count := range counts {
+		if node, ok := nodes[count.CategoryID]; ok {
+			node.BookCount = count.BookCount
+			node.TotalBookCount = count.TotalBookCount
+		}
+	}
+
+	children := make(map[uint][]uint, len(categories))
+	var roots []uint
+
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category.ID)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	var build func(id uint) model.CategoryNode
+	build = func(id uint) model.CategoryNode {
+		node := *nodes[id]
+		node.Children = make([]model.CategoryNode, 0, len(children[id]))
+		for _, childID := range children[id] {
+			node.Children = append(node.Children, build(childID))
+		}
+		return node
+	}
+
+	tree := make([]model.CategoryNode, 0, len(roots))
+	for _, id := range roots {
+		tree = append(tree, build(id))
+	}
+
+	return tree, nil
+}
+
+
+// UpdateCategory renames a category or moves it, together with its subtree, under another parent.
+// Moves lock the category table, so that two concurrent moves cannot close a cycle between them.
+func (c *categoryService) UpdateCategory(categoryID uint, categoryUpdate model.CategoryUpdate) error {
+	var name string
+	if categoryUpdate.Name != nil {
+		if name = strings.TrimSpace(*categoryUpdate.Name); name == "" {
+			return ErrEmptyCategoryName
+		}
+	}
+
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if categoryUpdate.ParentID != nil {
+			if err := tx.Exec("LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+		}
+
+		category, err := findCategory(tx, categoryID, ErrCategoryNotFound)
+		if err != nil {
+			return err
+		}
+
+		if name != "" {
+			category.Name = name
+		}
+
+		if categoryUpdate.ParentID != nil {
+			if *categoryUpdate.ParentID == 0 {
+				category.ParentID = nil
+			} else {
+				parentID := *categoryUpdate.ParentID
+
+				if _, err := findCategory(tx, parentID, ErrParentCategoryNotFound); err != nil {
+					return err
+				}
+
+				var subtree []uint
+				if err := categorySubtree(tx, categoryID).Scan(&subtree).Error; err != nil {
+					return err
+				}
+				for _, id := range subtree {
+					if id == parentID {
+						return ErrCategoryCycle
+					}
+				}
+
+				category.ParentID = &parentID
+			}
+		}
+
+		return tx.Omit("Parent").Save(category).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrCategoryExists
+	}
+
+	return err
+}
+
+
+// DeleteCategory removes a category without subcategories. Its books only lose the assignment.
+func (c *categoryService) DeleteCategory(categoryID uint) error {
+	result := c.db.Delete(&model.Category{}, categoryID)
+	if result.Error != nil {
+		// The parent foreign key keeps categories with subcategories from being deleted.
+		if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+			return ErrCategoryHasChildren
+		}
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
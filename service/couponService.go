@@ -0,0 +1,319 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/money"
+	"errors"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponNotFound		= errors.New("coupon not found")
+	ErrInvalidCouponCode	= errors.New("coupon code must be 3 to 32 letters, digits, dashes or underscores")
+	ErrInvalidCouponKind	= errors.New("kind must be percent or fixed")
+	ErrInvalidCouponScope	= errors.New("scope must be order, book or category")
+	ErrInvalidPercentOff	= errors.New("percent coupons need a percent_off from 1 to 100")
+	ErrInvalidAmountOff		= errors.New("fixed coupons need a positive amount_off")
+	ErrInvalidMinSpend		= errors.New("min_spend cannot be negative")
+	ErrCouponTarget			= errors.New("book coupons need a book_id and category coupons a category_id")
+	ErrInvalidCouponLimit	= errors.New("usage limits must be at least 1")
+	ErrInvalidCouponWindow	= errors.New("ends_at must be after starts_at")
+	ErrCouponExists			= errors.New("a coupon with this code already exists")
+
+	ErrCouponNotActive		= errors.New("coupon is not valid at this time")
+	ErrCouponUsedUp			= errors.New("coupon has reached its usage limit")
+	ErrCouponUserLimit		= errors.New("coupon has already been used the maximum number of times by this user")
+	ErrCouponNotStackable	= errors.New("coupon cannot be combined with other coupons")
+	ErrCouponCurrency		= errors.New("coupon is not valid for the currency of the cart")
+	ErrCouponMinSpend		= errors.New("cart does not reach the minimum spend of the coupon")
+	ErrCouponNotApplicable	= errors.New("coupon does not apply to any book in the cart")
+)
+
+type CouponService interface {
+	CreateCoupon(couponRequest model.CouponRequest) (*model.CouponResponse, error)
+
+	GetCoupons() ([]model.CouponResponse, error)
+
+	GetCoupon(couponID uint) (*model.CouponResponse, error)
+
+	UpdateCoupon(couponID uint, couponRequest model.CouponRequest) error
+
+	DeleteCoupon(couponID uint) error
+}
+
+type couponService struct {
+	db *gorm.DB
+}
+
+func NewCouponService(db *gorm.DB) CouponService {
+	return &couponService{db: db}
+}
+
+// couponCodePattern matches a coupon code after it was uppercased.
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+
+// normalizeCouponCode uppercases a code, so codes are matched case-insensitively.
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+
+func findCoupon(db *gorm.DB, couponID uint) (*model.Coupon, error) {
+	var coupon model.Coupon
+
+	if err := db.First(&coupon, couponID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+
+func findCouponByCode(db *gorm.DB, code string) (*model.Coupon, error) {
+	var coupon model.Coupon
+
+	if err := db.Where("code = ?", normalizeCouponCode(code)).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+
+// couponAmount reads an optional amount of a coupon in minor units of its currency.
+func couponAmount(value *money.Decimal, currency string, invalid error) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+
+	amount, err := value.Money(currency)
+	if err != nil || amount.IsNegative() {
+		return 0, invalid
+	}
+
+	return amount.Amount, nil
+}
+
+
+// newCoupon validates a coupon request and turns it into the terms of a coupon.
+func newCoupon(db *gorm.DB, couponRequest model.CouponRequest) (*model.Coupon, error) {
+	coupon := model.Coupon{
+		Code: 			normalizeCouponCode(couponRequest.Code),
+		Kind: 			couponRequest.Kind,
+		Scope: 			couponRequest.Scope,
+		MaxUses: 		couponRequest.MaxUses,
+		MaxUsesPerUser: couponRequest.MaxUsesPerUser,
+		StartsAt: 		couponRequest.StartsAt,
+		EndsAt: 		couponRequest.EndsAt,
+		Stackable: 		couponRequest.Stackable,
+	}
+
+	if !couponCodePattern.MatchString(coupon.Code) {
+		return nil, ErrInvalidCouponCode
+	}
+
+	currency, err := money.NormalizeCurrency(couponRequest.Currency)
+	if err != nil {
+		return nil, ErrInvalidCurrency
+	}
+	coupon.Currency = currency
+
+	switch coupon.Kind {
+	case model.CouponPercent:
+		if couponRequest.PercentOff < 1 || couponRequest.PercentOff > 100 {
+			return nil, ErrInvalidPercentOff
+		}
+		coupon.PercentOff = couponRequest.PercentOff
+	case model.CouponFixed:
+		if couponRequest.AmountOff == nil {
+			return nil, ErrInvalidAmountOff
+		}
+		if coupon.AmountOff, err = couponAmount(couponRequest.AmountOff, currency, ErrInvalidAmountOff); err != nil {
+			return nil, err
+		}
+		if coupon.AmountOff == 0 {
+			return nil, ErrInvalidAmountOff
+		}
+	default:
+		return nil, ErrInvalidCouponKind
+	}
+
+	if coupon.MinSpend, err = couponAmount(couponRequest.MinSpend, currency, ErrInvalidMinSpend); err != nil {
+		return nil, err
+	}
+
+	switch coupon.Scope {
+	case "", model.ScopeOrder:
+		coupon.Scope = model.ScopeOrder
+	case model.ScopeBook:
+		if couponRequest.BookID == nil {
+			return nil, ErrCouponTarget
+		}
+		if err := db.Select("id").First(&model.Book{}, *couponRequest.BookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrBookNotFound
+			}
+			return nil, err
+		}
+		coupon.BookID = couponRequest.BookID
+	case model.ScopeCategory:
+		if couponRequest.CategoryID == nil {
+			return nil, ErrCouponTarget
+		}
+		if _, err := findCategory(db, *couponRequest.CategoryID, ErrCategoryNotFound); err != nil {
+			return nil, err
+		}
+		coupon.CategoryID = couponRequest.CategoryID
+	default:
+		return nil, ErrInvalidCouponScope
+	}
+
+	if (coupon.MaxUses != nil && *coupon.MaxUses < 1) || (coupon.MaxUsesPerUser != nil && *coupon.MaxUsesPerUser < 1) {
+		return nil, ErrInvalidCouponLimit
+	}
+
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.StartsAt.Before(*coupon.EndsAt) {
+		return nil, ErrInvalidCouponWindow
+	}
+
+	return &coupon, nil
+}
+
+
+func toCouponResponse(coupon *model.Coupon, uses int) model.CouponResponse {
+	response := model.CouponResponse{
+		ID: 			coupon.ID,
+		Code: 			coupon.Code,
+		Kind: 			coupon.Kind,
+		PercentOff: 	coupon.PercentOff,
+		Currency: 		coupon.Currency,
+		Scope: 			coupon.Scope,
+		BookID: 		coupon.BookID,
+		CategoryID: 	coupon.CategoryID,
+		MaxUses: 		coupon.MaxUses,
+		MaxUsesPerUser: coupon.MaxUsesPerUser,
+		Uses: 			uses,
+		StartsAt: 		coupon.StartsAt,
+		EndsAt: 		coupon.EndsAt,
+		Stackable: 		coupon.Stackable,
+	}
+
+	if coupon.Kind == model.CouponFixed {
+		amountOff := money.New(coupon.AmountOff, coupon.Currency)
+		response.AmountOff = &amountOff
+	}
+	if coupon.MinSpend > 0 {
+		minSpend := money.New(coupon.MinSpend, coupon.Currency)
+		response.MinSpend = &minSpend
+	}
+
+	return response
+}
+
+
+func (c *couponService) CreateCoupon(couponRequest model.CouponRequest) (*model.CouponResponse, error) {
+	coupon, err := newCoupon(c.db, couponRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.db.Create(coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrCouponExists
+		}
+		return nil, err
+	}
+
+	response := toCouponResponse(coupon, 0)
+	return &response, nil
+}
+
+
+// GetCoupons lists every coupon sorted by code, with the number of times each was used.
+func (c *couponService) GetCoupons() ([]model.CouponResponse, error) {
+	var coupons []model.Coupon
+
+	if err := c.db.Order("code").Find(&coupons).Error; err != nil {
+		return nil, err
+	}
+
+	usage, err := loadCouponUsage(c.db, 0, coupons)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]model.CouponResponse, 0, len(coupons))
+	for i := range coupons {
+		responses = append(responses, toCouponResponse(&coupons[i], usage[coupons[i].ID].Total))
+	}
+
+	return responses, nil
+}
+
+
+func (c *couponService) GetCoupon(couponID uint) (*model.CouponResponse, error) {
+	coupon, err := findCoupon(c.db, couponID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := loadCouponUsage(c.db, 0, []model.Coupon{*coupon})
+	if err != nil {
+		return nil, err
+	}
+
+	response := toCouponResponse(coupon, usage[coupon.ID].Total)
+	return &response, nil
+}
+
+
+// UpdateCoupon replaces the terms of a coupon. Carts it is applied to get the new terms,
+// while orders already placed with it keep the ones they were placed with.
+func (c *couponService) UpdateCoupon(couponID uint, couponRequest model.CouponRequest) error {
+	existing, err := findCoupon(c.db, couponID)
+	if err != nil {
+		return err
+	}
+
+	coupon, err := newCoupon(c.db, couponRequest)
+	if err != nil {
+		return err
+	}
+
+	coupon.ID = existing.ID
+	coupon.CreatedAt = existing.CreatedAt
+
+	if err := c.db.Save(coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrCouponExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+
+// DeleteCoupon removes a coupon from the carts it is applied to. Orders placed with it keep their discount.
+func (c *couponService) DeleteCoupon(couponID uint) error {
+	result := c.db.Delete(&model.Coupon{}, couponID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrCouponNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,512 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/money"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRate			= errors.New("rate must be a positive decimal below 10000000000")
+	ErrSameCurrency			= errors.New("base and quote must be different currencies")
+	ErrRateNotFound			= errors.New("exchange rate not found")
+	ErrNoExchangeRate		= errors.New("no exchange rate to the requested currency")
+	ErrInvalidRatesHeader	= errors.New("CSV header must name the columns base, quote and rate once each")
+)
+
+// rateDecimals is the number of decimals exchange rates are stored with.
+const rateDecimals = 10
+
+// maxRate is the bound of the numeric(20,10) rate column.
+var maxRate = big.NewRat(10_000_000_000, 1)
+
+// RateImportError is returned when a line of a rates file is invalid. Nothing of the file is stored then.
+type RateImportError struct {
+	Line	int
+	Err		error
+}
+
+
+func (e *RateImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+
+func (e *RateImportError) Unwrap() error {
+	return e.Err
+}
+
+
+type ExchangeRateService interface {
+	SetRate(rateRequest model.ExchangeRateRequest) (*model.ExchangeRateResponse, error)
+
+	GetRates() ([]model.ExchangeRateResponse, error)
+
+	DeleteRate(base, quote string) error
+
+	ImportRates(r io.Reader) (*model.RateImportReport, error)
+
+	ConvertBooks(currency string, books ...*model.BookResponse) error
+
+	ConvertCart(currency string, cart *model.CartResponse) error
+
+	ConvertOrders(currency string, orders ...*model.OrderResponse) error
+}
+
+type exchangeRateService struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateService(db *gorm.DB) ExchangeRateService {
+	return &exchangeRateService{db: db}
+}
+
+// appliedRate is a rate as used for a conversion, with value parsed from its decimal text.
+type appliedRate struct {
+	value		*big.Rat
+	text		string
+	updatedAt	time.Time
+}
+
+// rateTable holds every stored rate, so that the prices of a response are converted with a single query.
+type rateTable struct {
+	rates	map[[2]string]model.ExchangeRate
+	maxAge	time.Duration
+}
+
+
+func loadRateTable(db *gorm.DB) (*rateTable, error) {
+	settings, err := loadRateSettings(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.ExchangeRate
+	if err := db.Find(&rates).Error; err != nil {
+		return nil, err
+	}
+
+	table := &rateTable{rates: make(map[[2]string]model.ExchangeRate, len(rates)), maxAge: time.Duration(settings.MaxAgeHours) * time.Hour}
+	for _, rate := range rates {
+		table.rates[[2]string{rate.Base, rate.Quote}] = rate
+	}
+
+	return table, nil
+}
+
+
+// lookup finds the price of one unit of from in to: the rate stored for the pair,
+// or else the inverse of the rate stored the other way round.
+func (t *rateTable) lookup(from, to string) (appliedRate, bool) {
+	if rate, ok := t.rates[[2]string{from, to}]; ok {
+		value, err := parseRate(rate.Rate)
+		return appliedRate{value: value, text: rate.Rate, updatedAt: rate.UpdatedAt}, err == nil
+	}
+
+	if rate, ok := t.rates[[2]string{to, from}]; ok {
+		value, err := parseRate(rate.Rate)
+		if err != nil {
+			return appliedRate{}, false
+		}
+		text := new(big.Rat).Inv(value).FloatString(rateDecimals)
+		value, err = parseRate(text)
+		return appliedRate{value: value, text: text, updatedAt: rate.UpdatedAt}, err == nil
+	}
+
+	return appliedRate{}, false
+}
+
+
+// counterparts are the currencies that have a rate to or from the currency.
+func (t *rateTable) counterparts(currency string) []string {
+	seen := make(map[string]bool)
+	var currencies []string
+
+	for pair := range t.rates {
+		var other string
+		switch currency {
+		case pair[0]:
+			other = pair[1]
+		case pair[1]:
+			other = pair[0]
+		default:
+			continue
+		}
+		if !seen[other] {
+			seen[other] = true
+			currencies = append(currencies, other)
+		}
+	}
+
+	return currencies
+}
+
+
+func (t *rateTable) stale(updatedAt time.Time) bool {
+	return time.Since(updatedAt) > t.maxAge
+}
+
+
+// convert shows a price in the currency at the current rate. A price already in the currency needs no conversion.
+func (t *rateTable) convert(price money.Money, currency string) (*model.ConvertedPrice, error) {
+	if price.Currency == currency {
+		return nil, nil
+	}
+
+	rate, ok := t.lookup(price.Currency, currency)
+	if !ok {
+		return nil, ErrNoExchangeRate
+	}
+
+	return convertAt(price, currency, rate, t.stale(rate.updatedAt))
+}
+
+
+func convertAt(price money.Money, currency string, rate appliedRate, stale bool) (*model.ConvertedPrice, error) {
+	converted, err := price.Convert(rate.value, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ConvertedPrice{
+		Price: 			converted,
+		Currency: 		currency,
+		Rate: 			formatRate(rate.text),
+		RateUpdatedAt: 	rate.updatedAt,
+		RateStale: 		stale,
+	}, nil
+}
+
+
+// lockedRate is the rate an order was shown in at checkout, if it has one.
+func lockedRate(order *model.Order) (appliedRate, bool) {
+	if order.RateCurrency == nil || order.ExchangeRate == nil || order.RateUpdatedAt == nil {
+		return appliedRate{}, false
+	}
+
+	value, err := parseRate(*order.ExchangeRate)
+	if err != nil {
+		return appliedRate{}, false
+	}
+
+	return appliedRate{value: value, text: *order.ExchangeRate, updatedAt: *order.RateUpdatedAt}, true
+}
+
+
+func parseRate(text string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(text)
+	if !ok || value.Sign() <= 0 || value.Cmp(maxRate) >= 0 {
+		return nil, ErrInvalidRate
+	}
+	return value, nil
+}
+
+
+// formatRate drops the trailing zeros of a stored rate, e.g. 1.0850000000 becomes 1.085.
+func formatRate(text string) string {
+	if !strings.Contains(text, ".") {
+		return text
+	}
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
+
+
+func toExchangeRateResponse(rate *model.ExchangeRate, table *rateTable) model.ExchangeRateResponse {
+	return model.ExchangeRateResponse{
+		Base: 		rate.Base,
+		Quote: 		rate.Quote,
+		Rate: 		formatRate(rate.Rate),
+		UpdatedAt: 	rate.UpdatedAt,
+		Stale: 		table.stale(rate.UpdatedAt),
+	}
+}
+
+
+// newExchangeRate validates a rate. The base defaults to the default currency and the rate is
+// rounded to the decimals it is stored with.
+func newExchangeRate(rateRequest model.ExchangeRateRequest) (*model.ExchangeRate, error) {
+	if rateRequest.Quote == "" || rateRequest.Rate == nil {
+		return nil, ErrEmptyFields
+	}
+
+	base, err := money.NormalizeCurrency(rateRequest.Base)
+	if err != nil {
+		return nil, ErrInvalidCurrency
+	}
+
+	quote, err := money.NormalizeCurrency(rateRequest.Quote)
+	if err != nil {
+		return nil, ErrInvalidCurrency
+	}
+
+	if base == quote {
+		return nil, ErrSameCurrency
+	}
+
+	value, ok := new(big.Rat).SetString(string(*rateRequest.Rate))
+	if !ok {
+		return nil, ErrInvalidRate
+	}
+
+	text := value.FloatString(rateDecimals)
+	if _, err := parseRate(text); err != nil {
+		return nil, err
+	}
+
+	return &model.ExchangeRate{Base: base, Quote: quote, Rate: text, UpdatedAt: time.Now()}, nil
+}
+
+
+func saveExchangeRate(db *gorm.DB, rate *model.ExchangeRate) error {
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(rate).Error
+}
+
+
+// SetRate stores the rate of a currency pair, replacing the previous one.
+func (e *exchangeRateService) SetRate(rateRequest model.ExchangeRateRequest) (*model.ExchangeRateResponse, error) {
+	rate, err := newExchangeRate(rateRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveExchangeRate(e.db, rate); err != nil {
+		return nil, err
+	}
+
+	table, err := loadRateTable(e.db)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toExchangeRateResponse(rate, table)
+	return &response, nil
+}
+
+
+func (e *exchangeRateService) GetRates() ([]model.ExchangeRateResponse, error) {
+	table, err := loadRateTable(e.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.ExchangeRate
+	if err := e.db.Order("base, quote").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]model.ExchangeRateResponse, 0, len(rates))
+	for i := range rates {
+		responses = append(responses, toExchangeRateResponse(&rates[i], table))
+	}
+
+	return responses, nil
+}
+
+
+func (e *exchangeRateService) DeleteRate(base, quote string) error {
+	result := e.db.Where("base = ? AND quote = ?", strings.ToUpper(base), strings.ToUpper(quote)).Delete(&model.ExchangeRate{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrRateNotFound
+	}
+
+	return nil
+}
+
+
+// ImportRates stores the rates of a CSV file with the columns base, quote and rate, as published
+// by most rate feeds once converted. A file is stored completely or, when a line is invalid, not at all.
+func (e *exchangeRateService) ImportRates(r io.Reader) (*model.RateImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.Is(err, io.EOF) || errors.As(err, &parseErr) {
+			return nil, ErrInvalidRatesHeader
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; ok {
+			return nil, ErrInvalidRatesHeader
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"base", "quote", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, ErrInvalidRatesHeader
+		}
+	}
+
+	var rates []*model.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, &RateImportError{Line: line, Err: err}
+		}
+
+		field := func(name string) string {
+			return strings.TrimSpace(record[columns[name]])
+		}
+
+		rate, err := money.ParseDecimal(field("rate"))
+		if err != nil {
+			return nil, &RateImportError{Line: line, Err: ErrInvalidRate}
+		}
+
+		exchangeRate, err := newExchangeRate(model.ExchangeRateRequest{Base: field("base"), Quote: field("quote"), Rate: &rate})
+		if err != nil {
+			return nil, &RateImportError{Line: line, Err: err}
+		}
+		rates = append(rates, exchangeRate)
+	}
+
+	err = e.db.Transaction(func(tx *gorm.DB) error {
+		for _, rate := range rates {
+			if err := saveExchangeRate(tx, rate); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RateImportReport{Imported: len(rates)}, nil
+}
+
+
+// ConvertBooks adds the prices of the books in the currency. An empty currency leaves them as they are.
+func (e *exchangeRateService) ConvertBooks(currency string, books ...*model.BookResponse) error {
+	if currency == "" || len(books) == 0 {
+		return nil
+	}
+
+	table, err := loadRateTable(e.db)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if book.Converted, err = table.convert(book.Price, currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+// ConvertCart shows the books, lines and totals of a cart in the currency at the current rate.
+// An empty currency or an empty cart leaves it as it is.
+func (e *exchangeRateService) ConvertCart(currency string, cart *model.CartResponse) error {
+	if currency == "" || len(cart.Books) == 0 {
+		return nil
+	}
+
+	table, err := loadRateTable(e.db)
+	if err != nil {
+		return err
+	}
+
+	for i := range cart.Books {
+		line := &cart.Books[i]
+
+		if line.Converted, err = table.convert(line.Price, currency); err != nil {
+			return err
+		}
+		if line.ConvertedUnitPrice, err = table.convert(line.UnitPrice, currency); err != nil {
+			return err
+		}
+		if line.ConvertedLineTotal, err = table.convert(line.LineTotal, currency); err != nil {
+			return err
+		}
+	}
+
+	if cart.Totals != nil {
+		if cart.Totals.Converted, err = table.convertTotals(cart.Totals, currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+// convertTotals shows cart totals in the currency at the current rate. Totals already in the currency need no conversion.
+func (t *rateTable) convertTotals(totals *model.CartTotals, currency string) (*model.ConvertedTotals, error) {
+	subtotal, err := t.convert(totals.Subtotal, currency)
+	if err != nil || subtotal == nil {
+		return nil, err
+	}
+
+	discount, err := t.convert(totals.Discount, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ConvertedTotals{
+		Subtotal: 		subtotal.Price,
+		Discount: 		discount.Price,
+		Total: 			money.New(subtotal.Price.Amount - discount.Price.Amount, subtotal.Price.Currency),
+		Currency: 		currency,
+		Rate: 			subtotal.Rate,
+		RateUpdatedAt: 	subtotal.RateUpdatedAt,
+		RateStale: 		subtotal.RateStale,
+	}, nil
+}
+
+
+// ConvertOrders shows orders in the currency at the current rate. Orders keep the rate they were
+// placed with when asked for in that currency or in none.
+func (e *exchangeRateService) ConvertOrders(currency string, orders ...*model.OrderResponse) error {
+	var table *rateTable
+
+	for _, order := range orders {
+		if currency == "" || (order.Converted != nil && order.Converted.Currency == currency) {
+			continue
+		}
+
+		if table == nil {
+			var err error
+			if table, err = loadRateTable(e.db); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if order.Converted, err = table.convert(order.Total, currency); err != nil {
+			return err
+		}
+		for i := range order.Books {
+			if order.Books[i].Converted, err = table.convert(order.Books[i].Price, currency); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
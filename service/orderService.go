@@ -1,29 +1,37 @@
 package service
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"errors"
+	"sort"
+	"time"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrEmptyCart 		= errors.New("cart is empty")
 	ErrOrderNotFound 	= errors.New("order not found")
-	ErrOrderCancel		= errors.New("order cannot be cancelled")
+	ErrBookUnavailable	= errors.New("cart contains books that are no longer available")
+	ErrInsufficientStock	= errors.New("not enough copies in stock")
+	ErrMixedCurrencies	= errors.New("cart contains books priced in different currencies")
+	ErrTotalChanged		= errors.New("cart total has changed, review the cart before ordering")
 )
 
 type OrderService interface {
-	CreateOrder(userID uint, address string) (*model.OrderResponse, error)
+	CreateOrder(principal auth.Principal, userID uint, address, currency string, expectedTotal *money.Decimal) (*model.OrderResponse, error)
 
-	CancelOrder(orderID uint) error
+	CancelOrder(principal auth.Principal, orderID uint) error
 
-	GetOrder(orderID uint) (*model.OrderResponse, error)
+	GetOrder(principal auth.Principal, orderID uint) (*model.OrderResponse, error)
 
-	GetUserOrders(userID uint) ([]model.OrderResponse, error)
+	GetUserOrders(userID uint, page model.PageRequest) (*model.Page[model.OrderResponse], error)
 
-	GetOrdersByStatus(status string) ([]model.OrderResponse, error)
+	GetOrdersByStatus(principal auth.Principal, status string, page model.PageRequest) (*model.Page[model.OrderResponse], error)
 
-	UpdateStatus(orderID uint, status string) error
+	UpdateStatus(principal auth.Principal, orderID uint, status string) error
 }
 
 type orderService struct {
@@ -34,6 +42,12 @@ func NewOrderService(db *gorm.DB) OrderService {
 	return &orderService{db: db}
 }
 
+// orderSortKeys are the fields order lists can be sorted by.
+var orderSortKeys = sortKeys[model.Order]{
+	"created_at":	{"created_at", func(order *model.Order) any { return order.CreatedAt }, false},
+	"total":		{"total_amount", func(order *model.Order) any { return order.Total.Amount }, false},
+}
+
 
 func (o *orderService) toOrderResponse(order *model.Order) *model.OrderResponse {
 	var books []model.OrderBookDetails
@@ -47,140 +61,378 @@ func (o *orderService) toOrderResponse(order *model.Order) *model.OrderResponse
 		})
 	}
 
-	return &model.OrderResponse{
+	var history []model.OrderStatusChange
+
+	for _, change := range order.History {
+		history = append(history, model.OrderStatusChange{
+			From: 		change.FromStatus,
+			To: 		change.ToStatus,
+			ChangedBy: 	change.ChangedByID,
+			ChangedAt: 	change.ChangedAt,
+		})
+	}
+
+	var coupons []model.AppliedCoupon
+
+	for _, coupon := range order.Coupons {
+		coupons = append(coupons, toAppliedCoupon(coupon.Code, coupon.Kind, coupon.Scope, coupon.PercentOff, coupon.AmountOff, coupon.Discount.Currency, coupon.Discount))
+	}
+
+	response := &model.OrderResponse{
 		ID: 		order.ID,
 		Status: 	order.Status,
+		Subtotal: 	order.Subtotal,
+		Discount: 	order.Discount,
 		Total: 		order.Total,
+		Currency: 	order.Total.Currency,
 		Address: 	order.Address,
 		CreatedAt: 	order.CreatedAt,
 		Books: 		books,
+		Coupons: 	coupons,
+		History: 	history,
 	}
-}
-
 
-func (o *orderService) CreateOrder(userID uint, address string) (*model.OrderResponse, error) {
-	var cart model.Cart
-	
-	if err := o.db.Preload("Books.Book").Where("user_id = ?", userID).First(&cart).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrEmptyCart
+	// Converting at a stored rate only fails on an overflow, which leaves the price unconverted.
+	if rate, ok := lockedRate(order); ok {
+		response.Converted, _ = convertAt(order.Total, *order.RateCurrency, rate, order.RateStale)
+		for i := range response.Books {
+			response.Books[i].Converted, _ = convertAt(response.Books[i].Price, *order.RateCurrency, rate, order.RateStale)
 		}
-		return nil, err
 	}
 
-	if len(cart.Books) == 0 {
-		return nil, ErrEmptyCart
+	return response
+}
+
+
+// inCreationOrder preloads rows, such as the coupons of an order, in the order they were created.
+func inCreationOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+
+// lockRate stores the current exchange rate from the order's currency to the given one on the order.
+func lockRate(tx *gorm.DB, order *model.Order, currency string) error {
+	table, err := loadRateTable(tx)
+	if err != nil {
+		return err
 	}
 
-	order := model.Order{
-		UserID: 	userID,
-		Status: 	"pending",
-		Address: 	address,
+	rate, ok := table.lookup(order.Total.Currency, currency)
+	if !ok {
+		return ErrNoExchangeRate
 	}
 
-	var total float32
-	var orderBooks []model.OrderBook
+	order.RateCurrency 	= &currency
+	order.ExchangeRate 	= &rate.text
+	order.RateUpdatedAt = &rate.updatedAt
+	order.RateStale 	= table.stale(rate.updatedAt)
 
-	for _, book := range cart.Books {
-		linePrice := book.Book.Price * float32(book.Quantity)
-		total += linePrice
+	return nil
+}
 
-		orderBooks = append(orderBooks, model.OrderBook{
-			BookID: 	book.BookID,
-			Quantity: 	uint(book.Quantity),
-			Price: 		book.Book.Price,
-		})
-	}
 
-	order.Total = total
-	order.Books = orderBooks
+// CreateOrder turns the user's cart into an order. Reserving stock, creating the order and
+// clearing the cart happen in one transaction, so a failure leaves neither an order nor a changed cart.
+// The coupons of the cart are copied onto the order, and checkout fails while one of them cannot
+// be redeemed. When the client confirmed the total it saw, checkout fails if the cart no longer costs
+// that much, e.g. because a price changed. When the order is shown in another currency, the exchange
+// rate is locked into the order. The history records the principal as the one who placed the
+// order, which is an admin when they check out on behalf of the user.
+func (o *orderService) CreateOrder(principal auth.Principal, userID uint, address, currency string, expectedTotal *money.Decimal) (*model.OrderResponse, error) {
+	var order model.Order
+
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var cart model.Cart
 
-	o.db.Create(&order)
-	o.db.Where("cart_id = ?", cart.ID).Delete(&model.CartBook{})
-	o.db.Preload("Books.Book").First(&order, order.ID)
+		// Locking the cart keeps two concurrent checkouts from ordering the same cart twice.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&cart).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmptyCart
+			}
+			return err
+		}
+
+		if err := tx.Preload("Book", unscoped).Where("cart_id = ?", cart.ID).Find(&cart.Books).Error; err != nil {
+			return err
+		}
+
+		if len(cart.Books) == 0 {
+			return ErrEmptyCart
+		}
+
+		order = model.Order{
+			UserID: 	userID,
+			Status: 	model.OrderPending,
+			Address: 	address,
+			History: 	[]model.OrderStatusHistory{{
+				ToStatus: 		model.OrderPending,
+				ChangedByID: 	principal.UserID,
+				ChangedAt: 		time.Now(),
+			}},
+		}
+
+		for _, book := range cart.Books {
+			if book.Book.DeletedAt.Valid {
+				return ErrBookUnavailable
+			}
+
+			order.Books = append(order.Books, model.OrderBook{
+				BookID: 	book.BookID,
+				Quantity: 	uint(book.Quantity),
+				Price: 		book.Book.Price,
+			})
+		}
+
+		// Locking the coupons queues up checkouts with the same coupon, so its usage limits hold.
+		var coupons []model.Coupon
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN (?)", tx.Model(&model.CartCoupon{}).Select("coupon_id").Where("cart_id = ?", cart.ID)).
+			Order("id").
+			Find(&coupons).Error
+		if err != nil {
+			return err
+		}
+
+		pricing, err := priceCart(tx, userID, cart.Books, coupons, time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, discount := range pricing.discounts {
+			if discount.err != nil {
+				return &CouponError{Code: discount.coupon.Code, Err: discount.err}
+			}
+
+			couponID := discount.coupon.ID
+			order.Coupons = append(order.Coupons, model.OrderCoupon{
+				CouponID: 	&couponID,
+				Code: 		discount.coupon.Code,
+				Kind: 		discount.coupon.Kind,
+				Scope: 		discount.coupon.Scope,
+				PercentOff: discount.coupon.PercentOff,
+				AmountOff: 	discount.coupon.AmountOff,
+				Discount: 	discount.discount,
+			})
+		}
+
+		order.Subtotal 	= pricing.subtotal
+		order.Discount 	= pricing.discount
+		order.Total 	= pricing.total
+
+		if expectedTotal != nil {
+			if expected, err := expectedTotal.Money(order.Total.Currency); err != nil || expected != order.Total {
+				return ErrTotalChanged
+			}
+		}
+
+		if currency != "" && currency != order.Total.Currency {
+			if err := lockRate(tx, &order, currency); err != nil {
+				return err
+			}
+		}
+
+		if err := reserveStock(tx, cart.Books); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&model.CartBook{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&model.CartCoupon{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("Books.Book", unscoped).Preload("Coupons", inCreationOrder).First(&order, order.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return o.toOrderResponse(&order), nil
 }
 
 
-func (o *orderService) CancelOrder(orderID uint) error {
-	var order model.Order
+// reserveStock takes the ordered copies out of stock, refusing lines with more copies than the
+// book allows in a cart. Book rows are locked in ID order,
+// so concurrent orders for the same books queue up instead of deadlocking or overselling.
+func reserveStock(tx *gorm.DB, lines []model.CartBook) error {
+	sorted := append([]model.CartBook(nil), lines...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].BookID < sorted[j].BookID })
 
-	if err := o.db.First(&order, orderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrOrderNotFound
+	for _, line := range sorted {
+		var book model.Book
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, line.BookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookUnavailable
+			}
+			return err
+		}
+
+		if err := checkQuantityLimit(&book, line.Quantity); err != nil {
+			return err
+		}
+		if book.Stock < line.Quantity {
+			return ErrInsufficientStock
+		}
+
+		if err := tx.Model(&book).Update("stock", gorm.Expr("stock - ?", line.Quantity)).Error; err != nil {
+			return err
 		}
-		return err
 	}
 
-	if order.Status != "pending" && order.Status != "approved" {
-		return ErrOrderCancel
+	return nil
+}
+
+
+// changeStatus moves a locked order to the next status and records the change.
+// Cancelling puts the ordered copies back in stock.
+func changeStatus(tx *gorm.DB, order *model.Order, status model.OrderStatus, changedByID uint) error {
+	if !canTransition(order.Status, status) {
+		return &StatusTransitionError{From: order.Status, To: status}
 	}
 
-	order.Status = "cancelled"
+	if status == model.OrderCancelled {
+		var orderBooks []model.OrderBook
+		if err := tx.Where("order_id = ?", order.ID).Order("book_id").Find(&orderBooks).Error; err != nil {
+			return err
+		}
+
+		for _, orderBook := range orderBooks {
+			err := tx.Unscoped().Model(&model.Book{}).
+				Where("id = ?", orderBook.BookID).
+				Update("stock", gorm.Expr("stock + ?", orderBook.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
 
-	return o.db.Save(&order).Error
+	history := model.OrderStatusHistory{
+		OrderID: 		order.ID,
+		FromStatus: 	order.Status,
+		ToStatus: 		status,
+		ChangedByID: 	changedByID,
+		ChangedAt: 		time.Now(),
+	}
+
+	if err := tx.Model(order).Update("status", status).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&history).Error
 }
 
 
-func (o *orderService) GetOrder(orderID uint) (*model.OrderResponse, error) {
+// lockOrder loads an order for update, so that concurrent status changes are applied one after another.
+func lockOrder(tx *gorm.DB, orderID uint) (*model.Order, error) {
 	var order model.Order
 
-	if err := o.db.Preload("Books.Book").First(&order, orderID).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrOrderNotFound
 		}
 		return nil, err
 	}
 
-	return o.toOrderResponse(&order), nil
+	return &order, nil
 }
 
 
-func (o *orderService) GetUserOrders(userID uint) ([]model.OrderResponse, error) {
-	var orders []model.Order
+// CancelOrder lets the owner of an order, or an admin, cancel it while it is still pending or approved.
+func (o *orderService) CancelOrder(principal auth.Principal, orderID uint) error {
+	return o.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if err := authorizeOwner(principal, order.UserID); err != nil {
+			return err
+		}
 
-	if err := o.db.Preload("Books.Book").Where("user_id = ?", userID).Find(&orders).Error; err != nil {
+		return changeStatus(tx, order, model.OrderCancelled, principal.UserID)
+	})
+}
+
+
+func (o *orderService) GetOrder(principal auth.Principal, orderID uint) (*model.OrderResponse, error) {
+	var order model.Order
+
+	err := o.db.
+		Preload("Books.Book", unscoped).
+		Preload("Coupons", inCreationOrder).
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("changed_at, id") }).
+		First(&order, orderID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
 		return nil, err
 	}
 
-	var orderReponses []model.OrderResponse
-
-	for _, order := range orders {
-		orderReponses = append(orderReponses, *o.toOrderResponse(&order))
+	if err := authorizeOwner(principal, order.UserID); err != nil {
+		return nil, err
 	}
 
-	return orderReponses, nil
+	return o.toOrderResponse(&order), nil
 }
 
 
-func (o *orderService) GetOrdersByStatus(status string) ([]model.OrderResponse, error) {
-	var orders []model.Order
+func (o *orderService) GetUserOrders(userID uint, page model.PageRequest) (*model.Page[model.OrderResponse], error) {
+	return o.listOrders(o.db.Model(&model.Order{}).Where("user_id = ?", userID), page)
+}
+
 
-	if err := o.db.Preload("Books.Book").Where("LOWER(status) = LOWER(?)", status).Find(&orders).Error; err != nil {
+// GetOrdersByStatus lists the orders with a status. Admins see every customer's orders,
+// everyone else only their own.
+func (o *orderService) GetOrdersByStatus(principal auth.Principal, status string, page model.PageRequest) (*model.Page[model.OrderResponse], error) {
+	orderStatus, err := parseOrderStatus(status)
+	if err != nil {
 		return nil, err
 	}
 
-	orderResponses := make([]model.OrderResponse, 0, len(orders))
-	for _, order := range orders {
-		orderResponses = append(orderResponses, *o.toOrderResponse(&order))
+	query := o.db.Model(&model.Order{}).Where("status = ?", orderStatus)
+	if !principal.IsAdmin() {
+		query = query.Where("user_id = ?", principal.UserID)
 	}
 
-	return orderResponses, nil
+	return o.listOrders(query, page)
 }
 
 
-func (o *orderService) UpdateStatus(orderID uint, status string) error {
-	var order model.Order
+func (o *orderService) listOrders(query *gorm.DB, page model.PageRequest) (*model.Page[model.OrderResponse], error) {
+	orders, err := paginate(query, page, orderSortKeys, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Books.Book", unscoped).Preload("Coupons", inCreationOrder)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	if err := o.db.First(&order, orderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrOrderNotFound
-		}
+	return mapPage(orders, func(order *model.Order) model.OrderResponse {
+		return *o.toOrderResponse(order)
+	}), nil
+}
+
+
+// UpdateStatus moves an order along its lifecycle and records who changed it.
+func (o *orderService) UpdateStatus(principal auth.Principal, orderID uint, status string) error {
+	orderStatus, err := parseOrderStatus(status)
+	if err != nil {
 		return err
 	}
 
-	order.Status = status
+	return o.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
 
-	return o.db.Save(&order).Error
-}
\ No newline at end of file
+		return changeStatus(tx, order, orderStatus, principal.UserID)
+	})
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidStatus = errors.New("invalid order status")
+)
+
+// orderTransitions lists, for every status, the statuses an order may move to next.
+var orderTransitions = map[model.OrderStatus][]model.OrderStatus{
+	model.OrderPending:		{model.OrderApproved, model.OrderCancelled},
+	model.OrderApproved:	{model.OrderShipped, model.OrderCancelled},
+	model.OrderShipped:		{model.OrderDelivered},
+	model.OrderDelivered:	{model.OrderRefunded},
+	model.OrderCancelled:	{},
+	model.OrderRefunded:	{},
+}
+
+// StatusTransitionError is returned when an order cannot move from its current status to the requested one.
+type StatusTransitionError struct {
+	From	model.OrderStatus
+	To		model.OrderStatus
+}
+
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+
+// parseOrderStatus accepts a status in any letter case and rejects statuses outside the lifecycle.
+func parseOrderStatus(status string) (model.OrderStatus, error) {
+	orderStatus := model.OrderStatus(strings.ToLower(strings.TrimSpace(status)))
+
+	if _, ok := orderTransitions[orderStatus]; !ok {
+		return "", ErrInvalidStatus
+	}
+
+	return orderStatus, nil
+}
+
+
+// canTransition reports whether an order may move from one status to another. Orders stuck
+// on a status outside the lifecycle, left over from before it was enforced, may move anywhere.
+func canTransition(from, to model.OrderStatus) bool {
+	allowed, known := orderTransitions[from]
+	if !known {
+		return true
+	}
+
+	for _, next := range allowed {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCursor	= errors.New("invalid cursor")
+	ErrInvalidSort		= errors.New("invalid sort field")
+)
+
+const (
+	DefaultPageLimit	= 20
+	MaxPageLimit		= 100
+)
+
+// sortKey is a field a list can be sorted by: the SQL expression to order by, the value of
+// that expression for a loaded row, which goes into the cursor, and whether the field is
+// always sorted in descending order, as for rankings read from the top.
+type sortKey[T any] struct {
+	column	string
+	value	func(*T) any
+	desc	bool
+}
+
+// sortKeys maps the sort names a list accepts to their keys.
+type sortKeys[T any] map[string]sortKey[T]
+
+// cursor marks the last item of a page: its value in the sort column and its ID as tie-breaker.
+type cursor struct {
+	Sort	string	`json:"s"`
+	Desc	bool	`json:"d"`
+	Value	any		`json:"v"`
+	ID		uint	`json:"id"`
+}
+
+
+func encodeCursor(c cursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+
+// decodeCursor rejects malformed cursors and cursors issued for a different sort order.
+func decodeCursor(encoded string, page model.PageRequest) (cursor, error) {
+	var c cursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if c.Sort != page.Sort || c.Desc != page.Desc || c.Value == nil {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+
+// paginate loads one page of the rows matched by query using keyset pagination: rows are ordered by
+// the requested sort column with the ID as tie-breaker, and the cursor resumes after the last row seen,
+// so pages stay consistent while rows are inserted. Without a sort the rows are ordered by ID; keys
+// marked desc are descending whether or not the sort asks for it.
+// Scopes, such as preloads, only apply to the query loading the items, not to the count.
+func paginate[T any](query *gorm.DB, page model.PageRequest, keys sortKeys[T], scopes ...func(*gorm.DB) *gorm.DB) (*model.Page[T], error) {
+	key := sortKey[T]{column: "id", value: func(row *T) any { return rowID(row) }}
+	if page.Sort != "" {
+		var ok bool
+		if key, ok = keys[page.Sort]; !ok {
+			return nil, ErrInvalidSort
+		}
+	}
+
+	if key.desc {
+		page.Desc = true
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	if page.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *page.CreatedAfter)
+	}
+	if page.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *page.CreatedBefore)
+	}
+
+	result := &model.Page[T]{Items: make([]T, 0, limit)}
+
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if page.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor, page)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", key.column, comparison), after.Value, after.ID)
+	}
+
+	var rows []T
+
+	err := query.Scopes(scopes...).
+		Order(fmt.Sprintf("%s %s, id %s", key.column, direction, direction)).
+		Limit(limit + 1).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) > limit {
+		rows = rows[:limit]
+
+		last := &rows[limit-1]
+		next, err := encodeCursor(cursor{Sort: page.Sort, Desc: page.Desc, Value: key.value(last), ID: rowID(last)})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = next
+	}
+
+	result.Items = append(result.Items, rows...)
+
+	return result, nil
+}
+
+
+// rowID reads the ID of a model embedding gorm.Model.
+func rowID[T any](row *T) uint {
+	return uint(reflect.ValueOf(row).Elem().FieldByName("ID").Uint())
+}
+
+
+// mapPage converts the items of a page, keeping its cursor and total.
+func mapPage[T, R any](page *model.Page[T], convert func(*T) R) *model.Page[R] {
+	mapped := &model.Page[R]{
+		Items: 		make([]R, 0, len(page.Items)),
+		NextCursor: page.NextCursor,
+		Total: 		page.Total,
+	}
+
+	for i := range page.Items {
+		mapped.Items = append(mapped.Items, convert(&page.Items[i]))
+	}
+
+	return mapped
+}
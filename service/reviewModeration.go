@@ -0,0 +1,240 @@
+package service
+
+import (
+	"BookVault-API/auth"
+	"BookVault-API/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrReviewAlreadyModerated	= errors.New("review already has this moderation status")
+	ErrRejectReasonRequired		= errors.New("a reason is required to reject a review")
+	ErrReportOwnReview			= errors.New("you cannot report your own review")
+	ErrReviewAlreadyReported	= errors.New("you have already reported this review")
+)
+
+
+// submissionStatus is the moderation status a review gets when it is written under the given policy.
+func submissionStatus(policy model.AutoApprovePolicy, verified bool) model.ReviewStatus {
+	switch {
+	case policy == model.AutoApproveAll:
+		return model.ReviewApproved
+	case policy == model.AutoApproveVerified && verified:
+		return model.ReviewApproved
+	default:
+		return model.ReviewPending
+	}
+}
+
+
+func recordModeration(tx *gorm.DB, reviewID uint, from, to model.ReviewStatus, reason string, moderatorID *uint) error {
+	return tx.Create(&model.ReviewModeration{
+		ReviewID: 		reviewID,
+		FromStatus: 	from,
+		ToStatus: 		to,
+		Reason: 		reason,
+		ModeratedByID: 	moderatorID,
+		CreatedAt: 		time.Now(),
+	}).Error
+}
+
+
+// lockReview loads a review for update. Its book is locked first, in the same order as every
+// other review change, so that the rating of the book can be recomputed afterwards.
+func lockReview(tx *gorm.DB, reviewID uint) (*model.Review, error) {
+	var review model.Review
+
+	if err := tx.Select("id", "book_id").First(&review, reviewID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewNotFound
+		}
+		return nil, err
+	}
+
+	if err := lockBookRating(tx.Unscoped(), review.BookID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, reviewID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewNotFound
+		}
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+
+// openReportCounts counts the reports each review received since it was last moderated.
+func openReportCounts(db *gorm.DB, reviewIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		ReviewID	uint
+		Reports		int
+	}
+
+	err := db.Model(&model.ReviewReport{}).
+		Select("review_reports.review_id, COUNT(*) AS reports").
+		Joins("JOIN reviews ON reviews.id = review_reports.review_id").
+		Where("review_reports.review_id IN ?", reviewIDs).
+		Where("reviews.moderated_at IS NULL OR review_reports.created_at > reviews.moderated_at").
+		Group("review_reports.review_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.ReviewID] = row.Reports
+	}
+
+	return counts, nil
+}
+
+
+// GetModerationQueue lists the pending reviews, oldest first unless another sort is requested.
+func (r *reviewService) GetModerationQueue(page model.PageRequest) (*model.Page[model.ModerationQueueItem], error) {
+	query := r.db.Model(&model.Review{}).Where("status = ?", model.ReviewPending)
+
+	reviews, err := paginate(query, page, reviewSortKeys, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("User").Preload("Book", unscoped)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reviewIDs := make([]uint, 0, len(reviews.Items))
+	for _, review := range reviews.Items {
+		reviewIDs = append(reviewIDs, review.ID)
+	}
+
+	reports := map[uint]int{}
+	if len(reviewIDs) > 0 {
+		if reports, err = openReportCounts(r.db, reviewIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return mapPage(reviews, func(review *model.Review) model.ModerationQueueItem {
+		return model.ModerationQueueItem{
+			ID: 		review.ID,
+			Username: 	review.User.Username,
+			BookID: 	review.BookID,
+			Title: 		review.Book.Title,
+			Text: 		review.Text,
+			Rating: 	review.Rating,
+			Verified: 	review.Verified,
+			Reports: 	reports[review.ID],
+			CreatedAt: 	review.CreatedAt,
+		}
+	}), nil
+}
+
+
+// moderate sets the moderation status of a review on behalf of a moderator and records the decision.
+// Reports received before the decision no longer count towards sending the review back to the queue.
+func (r *reviewService) moderate(principal auth.Principal, reviewID uint, status model.ReviewStatus, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, reviewID)
+		if err != nil {
+			return err
+		}
+
+		if review.Status == status {
+			return ErrReviewAlreadyModerated
+		}
+
+		previousStatus := review.Status
+
+		if err := tx.Model(review).Updates(map[string]any{"status": status, "moderated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		moderatorID := principal.UserID
+		if err := recordModeration(tx, review.ID, previousStatus, status, reason, &moderatorID); err != nil {
+			return err
+		}
+
+		return refreshBookRating(tx, review.BookID)
+	})
+}
+
+
+func (r *reviewService) ApproveReview(principal auth.Principal, reviewID uint, moderationRequest model.ModerationRequest) error {
+	return r.moderate(principal, reviewID, model.ReviewApproved, strings.TrimSpace(moderationRequest.Reason))
+}
+
+
+func (r *reviewService) RejectReview(principal auth.Principal, reviewID uint, moderationRequest model.ModerationRequest) error {
+	reason := strings.TrimSpace(moderationRequest.Reason)
+	if reason == "" {
+		return ErrRejectReasonRequired
+	}
+
+	return r.moderate(principal, reviewID, model.ReviewRejected, reason)
+}
+
+
+// ReportReview records a user's report of an approved review. Once the review collects as many
+// reports as the configured threshold, it leaves the book and goes back to the moderation queue.
+func (r *reviewService) ReportReview(principal auth.Principal, reviewID uint, reportRequest model.ReportRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, reviewID)
+		if err != nil {
+			return err
+		}
+
+		if review.Status != model.ReviewApproved {
+			return ErrReviewNotFound
+		}
+
+		if review.UserID == principal.UserID {
+			return ErrReportOwnReview
+		}
+
+		report := model.ReviewReport{
+			ReviewID: 	review.ID,
+			UserID: 	principal.UserID,
+			Reason: 	strings.TrimSpace(reportRequest.Reason),
+			CreatedAt: 	time.Now(),
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrReviewAlreadyReported
+			}
+			return err
+		}
+
+		reports, err := openReportCounts(tx, []uint{review.ID})
+		if err != nil {
+			return err
+		}
+
+		settings, err := loadReviewSettings(tx)
+		if err != nil {
+			return err
+		}
+
+		if reports[review.ID] < settings.ReportThreshold {
+			return nil
+		}
+
+		if err := tx.Model(review).Update("status", model.ReviewPending).Error; err != nil {
+			return err
+		}
+
+		reason := fmt.Sprintf("reported %d times", reports[review.ID])
+		if err := recordModeration(tx, review.ID, model.ReviewApproved, model.ReviewPending, reason, nil); err != nil {
+			return err
+		}
+
+		return refreshBookRating(tx, review.BookID)
+	})
+}
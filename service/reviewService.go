@@ -1,27 +1,46 @@
 package service
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/model"
 	"errors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrEmptyReview 		= errors.New("review cannot be empty")
 	ErrReviewNotFound 	= errors.New("review not found")
+	ErrInvalidRating	= errors.New("rating must be between 1 and 5")
+	ErrReviewExists		= errors.New("you have already reviewed this book")
+	ErrUnverifiedReview	= errors.New("only customers who received this book can review it")
 )
 
 type ReviewService interface {
 	AddReview(userID, bookID uint, reviewRequest model.ReviewRequest) error
 
-	GetReviewsByBook(bookID uint) ([]model.ReviewResponse, error)
+	GetReviewsByBook(bookID uint, filter model.ReviewFilter, page model.PageRequest) (*model.Page[model.ReviewResponse], error)
 
-	GetReviewsByUser(userID	uint) ([]model.UserReviewResponse, error)
+	GetReviewsByUser(principal auth.Principal, userID uint) ([]model.UserReviewResponse, error)
 
 	UpdateReview(userID, bookID uint, reviewRequest model.ReviewRequest) error
 
-	DeleteReviewByID(reviewID uint) error
+	SetReview(userID, bookID uint, reviewRequest model.ReviewRequest) (bool, error)
+
+	DeleteReviewByID(principal auth.Principal, reviewID uint) error
+
+	GetModerationQueue(page model.PageRequest) (*model.Page[model.ModerationQueueItem], error)
+
+	ApproveReview(principal auth.Principal, reviewID uint, moderationRequest model.ModerationRequest) error
+
+	RejectReview(principal auth.Principal, reviewID uint, moderationRequest model.ModerationRequest) error
+
+	ReportReview(principal auth.Principal, reviewID uint, reportRequest model.ReportRequest) error
+
+	VoteReview(principal auth.Principal, reviewID uint, helpful bool) error
+
+	RetractVote(principal auth.Principal, reviewID uint) error
 }
 
 type reviewService struct {
@@ -32,12 +51,107 @@ func NewReviewService(db *gorm.DB) ReviewService {
 	return &reviewService{db: db}
 }
 
+// reviewSortKeys are the fields the reviews of a book can be sorted by.
+var reviewSortKeys = sortKeys[model.Review]{
+	"created_at":	{"created_at", func(review *model.Review) any { return review.CreatedAt }, false},
+	"helpful":		{"helpful_score", func(review *model.Review) any { return review.HelpfulScore }, true},
+}
 
-func (r *reviewService) AddReview(userID, bookID uint, reviewRequest model.ReviewRequest) error {
+
+// lockBookRating locks the book row, so that concurrent review changes of the same book
+// recompute its rating one after another and none of them is lost.
+func lockBookRating(tx *gorm.DB, bookID uint) error {
+	var book model.Book
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&book, bookID).Error
+}
+
+
+// refreshBookRating recomputes the rating aggregates of a book from its approved, rated reviews.
+func refreshBookRating(tx *gorm.DB, bookID uint) error {
+	return tx.Exec(`
+		INSERT INTO book_ratings (book_id, count, average, stars1, stars2, stars3, stars4, stars5, updated_at)
+		SELECT ?, COUNT(*), COALESCE(AVG(rating), 0),
+			COUNT(*) FILTER (WHERE rating = 1),
+			COUNT(*) FILTER (WHERE rating = 2),
+			COUNT(*) FILTER (WHERE rating = 3),
+			COUNT(*) FILTER (WHERE rating = 4),
+			COUNT(*) FILTER (WHERE rating = 5),
+			NOW()
+		FROM reviews
+		WHERE book_id = ? AND rating > 0 AND status = 'approved' AND deleted_at IS NULL
+		ON CONFLICT (book_id) DO UPDATE SET
+			count = EXCLUDED.count,
+			average = EXCLUDED.average,
+			stars1 = EXCLUDED.stars1,
+			stars2 = EXCLUDED.stars2,
+			stars3 = EXCLUDED.stars3,
+			stars4 = EXCLUDED.stars4,
+			stars5 = EXCLUDED.stars5,
+			updated_at = EXCLUDED.updated_at`,
+		bookID, bookID).Error
+}
+
+
+// purchasedBook reports whether the user received the book in a delivered order.
+func purchasedBook(tx *gorm.DB, userID, bookID uint) (bool, error) {
+	var delivered int64
+
+	err := tx.Model(&model.OrderBook{}).
+		Joins("JOIN orders ON orders.id = order_books.order_id AND orders.deleted_at IS NULL").
+		Where("orders.user_id = ? AND order_books.book_id = ? AND orders.status = ?", userID, bookID, model.OrderDelivered).
+		Count(&delivered).Error
+
+	return delivered > 0, err
+}
+
+
+// reviewState decides whether a review being written is a verified purchase and which moderation
+// status it gets. New unverified reviews fail with ErrUnverifiedReview when admins do not allow them.
+func reviewState(tx *gorm.DB, userID, bookID uint, isNew bool) (bool, model.ReviewStatus, error) {
+	verified, err := purchasedBook(tx, userID, bookID)
+	if err != nil {
+		return false, "", err
+	}
+
+	settings, err := loadReviewSettings(tx)
+	if err != nil {
+		return false, "", err
+	}
+
+	if isNew && !verified && !settings.AllowUnverified {
+		return false, "", ErrUnverifiedReview
+	}
+
+	return verified, submissionStatus(settings.AutoApprove, verified), nil
+}
+
+
+// editedStatus is the moderation status of an edited review. Only approved reviews go through
+// the auto-approve policy again; rejected reviews and reviews waiting for moderation, whether
+// new or sent back by reports, keep their status so that editing cannot undo moderation.
+func editedStatus(current, submitted model.ReviewStatus) model.ReviewStatus {
+	if current != model.ReviewApproved {
+		return current
+	}
+	return submitted
+}
+
+
+func validRating(rating int) bool {
+	return rating >= 1 && rating <= 5
+}
+
+
+// validateNewReview checks a review request that must carry both text and rating, and that the reviewer exists.
+func (r *reviewService) validateNewReview(userID uint, reviewRequest model.ReviewRequest) error {
 	if reviewRequest.Text == "" {
 		return ErrEmptyReview
 	}
 
+	if !validRating(reviewRequest.Rating) {
+		return ErrInvalidRating
+	}
+
 	var user model.User
 	if err := r.db.First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -46,56 +160,105 @@ func (r *reviewService) AddReview(userID, bookID uint, reviewRequest model.Revie
 		return err
 	}
 
-	var book model.Book
-	if err := r.db.First(&book, bookID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrBookNotFound
-		}
+	return nil
+}
+
+
+// AddReview adds the first review of a user for a book. A user has at most one review per book,
+// which the unique index on (user_id, book_id) enforces, so a second one fails with ErrReviewExists.
+func (r *reviewService) AddReview(userID, bookID uint, reviewRequest model.ReviewRequest) error {
+	if err := r.validateNewReview(userID, reviewRequest); err != nil {
 		return err
 	}
 
-	review := &model.Review{
-		Text: 	reviewRequest.Text,
-		UserID: userID,
-		BookID: bookID,
-	}
-	return r.db.Create(review).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBookRating(tx, bookID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		verified, status, err := reviewState(tx, userID, bookID, true)
+		if err != nil {
+			return err
+		}
+
+		review := &model.Review{
+			Text: 		reviewRequest.Text,
+			Rating: 	reviewRequest.Rating,
+			Verified: 	verified,
+			Status: 	status,
+			UserID: 	userID,
+			BookID: 	bookID,
+		}
+		if err := tx.Create(review).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrReviewExists
+			}
+			return err
+		}
+
+		return refreshBookRating(tx, bookID)
+	})
 }
 
 
-func (r *reviewService) GetReviewsByBook(bookID uint) ([]model.ReviewResponse, error) {
-	var reviews []model.Review
-	
-	if err := r.db.Preload("User").Where("book_id = ?", bookID).Find(&reviews).Error; err != nil {
-		return nil, err
+// GetReviewsByBook lists the approved reviews of a book. Sorting by helpful lists the most
+// helpful reviews first.
+func (r *reviewService) GetReviewsByBook(bookID uint, filter model.ReviewFilter, page model.PageRequest) (*model.Page[model.ReviewResponse], error) {
+	query := r.db.Model(&model.Review{}).Where("book_id = ? AND status = ?", bookID, model.ReviewApproved)
+
+	if filter.Verified != nil {
+		query = query.Where("verified = ?", *filter.Verified)
 	}
 
-	reviewResponses := make([]model.ReviewResponse, 0, len(reviews))
-	for _, review := range reviews {
-		reviewResponses = append(reviewResponses, model.ReviewResponse{
-			Username: 	review.User.Username,
-			Text: 		review.Text,
-		})
+	reviews, err := paginate(query, page, reviewSortKeys, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("User")
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return reviewResponses, nil
+	return mapPage(reviews, func(review *model.Review) model.ReviewResponse {
+		return model.ReviewResponse{
+			ID:				review.ID,
+			Username:		review.User.Username,
+			Text:			review.Text,
+			Rating:			review.Rating,
+			Verified:		review.Verified,
+			HelpfulVotes:	review.HelpfulVotes,
+			UnhelpfulVotes:	review.UnhelpfulVotes,
+		}
+	}), nil
 }
 
 
-func (r *reviewService) GetReviewsByUser(userID	uint) ([]model.UserReviewResponse, error) {
+// GetReviewsByUser lists the reviews of a user. The user and admins see every review with its
+// moderation status; everyone else only sees the approved ones.
+func (r *reviewService) GetReviewsByUser(principal auth.Principal, userID uint) ([]model.UserReviewResponse, error) {
 	var reviews []model.Review
 
-	if err := r.db.Preload("User").Preload("Book").Where("user_id = ?", userID).Find(&reviews).Error; err != nil {
+	query := r.db.Preload("User").Preload("Book").Where("user_id = ?", userID)
+	if authorizeOwner(principal, userID) != nil {
+		query = query.Where("status = ?", model.ReviewApproved)
+	}
+
+	if err := query.Find(&reviews).Error; err != nil {
 		return nil, err
 	}
 
 	userReviewResponses := make([]model.UserReviewResponse, 0, len(reviews))
 	for _, review := range reviews {
 		userReviewResponses = append(userReviewResponses, model.UserReviewResponse{
+			ID: 		review.ID,
 			Username: 	review.User.Username,
 			Title: 		review.Book.Title,
 			Author: 	review.Book.Author,
 			Text: 		review.Text,
+			Rating: 	review.Rating,
+			Verified: 	review.Verified,
+			Status: 	review.Status,
 		})
 	}
 
@@ -103,23 +266,133 @@ func (r *reviewService) GetReviewsByUser(userID	uint) ([]model.UserReviewRespons
 }
 
 
+// UpdateReview changes the text and rating of a review. Empty text keeps the current text and
+// a rating of 0 keeps the current rating, but at least one of them must be given.
+// The verified flag is refreshed, so a review written before the book was delivered becomes verified,
+// and an approved review goes through the auto-approve policy again.
 func (r *reviewService) UpdateReview(userID, bookID uint, reviewRequest model.ReviewRequest) error {
-	var review model.Review
+	if reviewRequest.Text == "" && reviewRequest.Rating == 0 {
+		return ErrEmptyReview
+	}
 
-	if err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&review).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrReviewNotFound
+	if reviewRequest.Rating != 0 && !validRating(reviewRequest.Rating) {
+		return ErrInvalidRating
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBookRating(tx.Unscoped(), bookID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReviewNotFound
+			}
+			return err
 		}
-		return err
+
+		var review model.Review
+
+		if err := tx.Where("user_id = ? AND book_id = ?", userID, bookID).First(&review).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReviewNotFound
+			}
+			return err
+		}
+
+		verified, status, err := reviewState(tx, userID, bookID, false)
+		if err != nil {
+			return err
+		}
+
+		previousStatus := review.Status
+		status = editedStatus(previousStatus, status)
+
+		if reviewRequest.Text != "" {
+			review.Text = reviewRequest.Text
+		}
+		review.Verified = verified
+		review.Status = status
+		if reviewRequest.Rating != 0 {
+			review.Rating = reviewRequest.Rating
+		}
+
+		if err := tx.Save(&review).Error; err != nil {
+			return err
+		}
+
+		if previousStatus != status {
+			if err := recordModeration(tx, review.ID, previousStatus, status, "review edited", nil); err != nil {
+				return err
+			}
+		}
+
+		return refreshBookRating(tx, bookID)
+	})
+}
+
+
+// SetReview creates the user's review of a book, or replaces its text and rating if there already is one.
+// It reports whether a new review was created. Only creating a review is subject to the unverified review rule.
+// New reviews go through the auto-approve policy, and replaced ones keep their status unless they were approved.
+func (r *reviewService) SetReview(userID, bookID uint, reviewRequest model.ReviewRequest) (bool, error) {
+	if err := r.validateNewReview(userID, reviewRequest); err != nil {
+		return false, err
 	}
 
-	review.Text = reviewRequest.Text
+	created := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBookRating(tx, bookID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		var review model.Review
+
+		err := tx.Where("user_id = ? AND book_id = ?", userID, bookID).First(&review).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			review = model.Review{UserID: userID, BookID: bookID}
+			created = true
+		case err != nil:
+			return err
+		}
+
+		verified, status, err := reviewState(tx, userID, bookID, created)
+		if err != nil {
+			return err
+		}
+
+		previousStatus := review.Status
+		if !created {
+			status = editedStatus(previousStatus, status)
+		}
+
+		review.Text = reviewRequest.Text
+		review.Rating = reviewRequest.Rating
+		review.Verified = verified
+		review.Status = status
+
+		if err := tx.Save(&review).Error; err != nil {
+			return err
+		}
+
+		if !created && previousStatus != status {
+			if err := recordModeration(tx, review.ID, previousStatus, status, "review edited", nil); err != nil {
+				return err
+			}
+		}
+
+		return refreshBookRating(tx, bookID)
+	})
+	if err != nil {
+		return false, err
+	}
 
-	return r.db.Save(&review).Error
+	return created, nil
 }
 
 
-func (r *reviewService) DeleteReviewByID(reviewID uint) error {
+func (r *reviewService) DeleteReviewByID(principal auth.Principal, reviewID uint) error {
 	var review model.Review
 
 	if err := r.db.First(&review, reviewID).Error; err != nil {
@@ -129,5 +402,19 @@ func (r *reviewService) DeleteReviewByID(reviewID uint) error {
 		return err
 	}
 
-	return r.db.Delete(&review).Error
+	if err := authorizeOwner(principal, review.UserID); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBookRating(tx.Unscoped(), review.BookID); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&review).Error; err != nil {
+			return err
+		}
+
+		return refreshBookRating(tx, review.BookID)
+	})
 }
\ No newline at end of file
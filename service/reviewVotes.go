@@ -0,0 +1,133 @@
+package service
+
+import (
+	"BookVault-API/auth"
+	"BookVault-API/model"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVoteOwnReview	= errors.New("you cannot vote on your own review")
+	ErrVoteNotFound		= errors.New("you have not voted on this review")
+)
+
+// wilsonZ is the z-score of the 95% confidence level used by helpfulScore.
+const wilsonZ = 1.96
+
+
+// helpfulScore ranks a review by the lower bound of the Wilson score interval of its helpful votes.
+// Unlike the raw share of helpful votes, it favours 30 out of 32 over 1 out of 1, and a review
+// without votes scores 0.
+func helpfulScore(helpful, unhelpful int) float64 {
+	n := float64(helpful + unhelpful)
+	if n == 0 {
+		return 0
+	}
+
+	p := float64(helpful) / n
+	z2 := wilsonZ * wilsonZ
+
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+
+// lockVotedReview loads an approved review for update, so that concurrent votes recount it one after another.
+func lockVotedReview(tx *gorm.DB, reviewID uint) (*model.Review, error) {
+	var review model.Review
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", model.ReviewApproved).
+		First(&review, reviewID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewNotFound
+		}
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+
+// refreshVotes recounts the votes of a review and updates its helpfulness score.
+func refreshVotes(tx *gorm.DB, review *model.Review) error {
+	var counts struct {
+		Helpful		int
+		Unhelpful	int
+	}
+
+	err := tx.Model(&model.ReviewVote{}).
+		Select("COUNT(*) FILTER (WHERE helpful) AS helpful, COUNT(*) FILTER (WHERE NOT helpful) AS unhelpful").
+		Where("review_id = ?", review.ID).
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	// UpdateColumns leaves updated_at alone: a vote is not an edit of the review.
+	return tx.Model(review).UpdateColumns(map[string]any{
+		"helpful_votes": 	counts.Helpful,
+		"unhelpful_votes": 	counts.Unhelpful,
+		"helpful_score": 	helpfulScore(counts.Helpful, counts.Unhelpful),
+	}).Error
+}
+
+
+// VoteReview records whether the user finds an approved review helpful, replacing an earlier vote.
+func (r *reviewService) VoteReview(principal auth.Principal, reviewID uint, helpful bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockVotedReview(tx, reviewID)
+		if err != nil {
+			return err
+		}
+
+		if review.UserID == principal.UserID {
+			return ErrVoteOwnReview
+		}
+
+		now := time.Now()
+		vote := model.ReviewVote{
+			ReviewID: 	review.ID,
+			UserID: 	principal.UserID,
+			Helpful: 	helpful,
+			CreatedAt: 	now,
+			UpdatedAt: 	now,
+		}
+
+		err = tx.Clauses(clause.OnConflict{
+			Columns: 	[]clause.Column{{Name: "review_id"}, {Name: "user_id"}},
+			DoUpdates: 	clause.AssignmentColumns([]string{"helpful", "updated_at"}),
+		}).Create(&vote).Error
+		if err != nil {
+			return err
+		}
+
+		return refreshVotes(tx, review)
+	})
+}
+
+
+func (r *reviewService) RetractVote(principal auth.Principal, reviewID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockVotedReview(tx, reviewID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("review_id = ? AND user_id = ?", review.ID, principal.UserID).Delete(&model.ReviewVote{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrVoteNotFound
+		}
+
+		return refreshVotes(tx, review)
+	})
+}
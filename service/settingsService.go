@@ -0,0 +1,209 @@
+package service
+
+import (
+	"BookVault-API/model"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidAutoApprove		= errors.New("auto_approve must be one of all, verified or none")
+	ErrInvalidReportThreshold	= errors.New("report_threshold must be at least 1")
+	ErrInvalidRateMaxAge		= errors.New("max_age_hours must be at least 1")
+)
+
+const (
+	settingAllowUnverifiedReviews	= "reviews.allow_unverified"
+	settingAutoApproveReviews		= "reviews.auto_approve"
+	settingReportThreshold			= "reviews.report_threshold"
+	settingRateMaxAge				= "rates.max_age_hours"
+)
+
+// defaultReviewSettings apply until an admin changes them.
+var defaultReviewSettings = model.ReviewSettings{
+	AllowUnverified: 	true,
+	AutoApprove: 		model.AutoApproveAll,
+	ReportThreshold: 	3,
+}
+
+// defaultRateSettings apply until an admin changes them.
+var defaultRateSettings = model.RateSettings{
+	MaxAgeHours: 	24,
+}
+
+type SettingsService interface {
+	GetReviewSettings() (*model.ReviewSettings, error)
+
+	UpdateReviewSettings(settingsRequest model.ReviewSettingsRequest) (*model.ReviewSettings, error)
+
+	GetRateSettings() (*model.RateSettings, error)
+
+	UpdateRateSettings(settingsRequest model.RateSettingsRequest) (*model.RateSettings, error)
+}
+
+type settingsService struct {
+	db *gorm.DB
+}
+
+func NewSettingsService(db *gorm.DB) SettingsService {
+	return &settingsService{db: db}
+}
+
+
+// loadSettings returns the stored values of the given keys. Keys that were never set are missing from the map.
+func loadSettings(db *gorm.DB, keys ...string) (map[string]string, error) {
+	var rows []model.Setting
+
+	if err := db.Where("key IN ?", keys).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(rows))
+	for _, row := range rows {
+		values[row.Key] = row.Value
+	}
+
+	return values, nil
+}
+
+
+func saveSetting(db *gorm.DB, key, value string) error {
+	setting := model.Setting{Key: key, Value: value, UpdatedAt: time.Now()}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&setting).Error
+}
+
+
+func validAutoApprove(policy model.AutoApprovePolicy) bool {
+	switch policy {
+	case model.AutoApproveAll, model.AutoApproveVerified, model.AutoApproveNone:
+		return true
+	}
+	return false
+}
+
+
+// loadReviewSettings reads the review rules, falling back to the defaults for options that were never set.
+func loadReviewSettings(db *gorm.DB) (model.ReviewSettings, error) {
+	settings := defaultReviewSettings
+
+	values, err := loadSettings(db, settingAllowUnverifiedReviews, settingAutoApproveReviews, settingReportThreshold)
+	if err != nil {
+		return settings, err
+	}
+
+	if value, ok := values[settingAllowUnverifiedReviews]; ok {
+		if allow, err := strconv.ParseBool(value); err == nil {
+			settings.AllowUnverified = allow
+		}
+	}
+
+	if value, ok := values[settingAutoApproveReviews]; ok && validAutoApprove(model.AutoApprovePolicy(value)) {
+		settings.AutoApprove = model.AutoApprovePolicy(value)
+	}
+
+	if value, ok := values[settingReportThreshold]; ok {
+		if threshold, err := strconv.Atoi(value); err == nil && threshold > 0 {
+			settings.ReportThreshold = threshold
+		}
+	}
+
+	return settings, nil
+}
+
+
+func (s *settingsService) GetReviewSettings() (*model.ReviewSettings, error) {
+	settings, err := loadReviewSettings(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+
+// UpdateReviewSettings changes the options present in the request and returns the resulting settings.
+func (s *settingsService) UpdateReviewSettings(settingsRequest model.ReviewSettingsRequest) (*model.ReviewSettings, error) {
+	if settingsRequest.AutoApprove != nil && !validAutoApprove(*settingsRequest.AutoApprove) {
+		return nil, ErrInvalidAutoApprove
+	}
+
+	if settingsRequest.ReportThreshold != nil && *settingsRequest.ReportThreshold < 1 {
+		return nil, ErrInvalidReportThreshold
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if settingsRequest.AllowUnverified != nil {
+			if err := saveSetting(tx, settingAllowUnverifiedReviews, strconv.FormatBool(*settingsRequest.AllowUnverified)); err != nil {
+				return err
+			}
+		}
+
+		if settingsRequest.AutoApprove != nil {
+			if err := saveSetting(tx, settingAutoApproveReviews, string(*settingsRequest.AutoApprove)); err != nil {
+				return err
+			}
+		}
+
+		if settingsRequest.ReportThreshold != nil {
+			if err := saveSetting(tx, settingReportThreshold, strconv.Itoa(*settingsRequest.ReportThreshold)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReviewSettings()
+}
+
+
+// loadRateSettings reads the exchange rate rules, falling back to the defaults for options that were never set.
+func loadRateSettings(db *gorm.DB) (model.RateSettings, error) {
+	settings := defaultRateSettings
+
+	values, err := loadSettings(db, settingRateMaxAge)
+	if err != nil {
+		return settings, err
+	}
+
+	if value, ok := values[settingRateMaxAge]; ok {
+		if hours, err := strconv.Atoi(value); err == nil && hours > 0 {
+			settings.MaxAgeHours = hours
+		}
+	}
+
+	return settings, nil
+}
+
+
+func (s *settingsService) GetRateSettings() (*model.RateSettings, error) {
+	settings, err := loadRateSettings(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+
+// UpdateRateSettings changes the options present in the request and returns the resulting settings.
+func (s *settingsService) UpdateRateSettings(settingsRequest model.RateSettingsRequest) (*model.RateSettings, error) {
+	if settingsRequest.MaxAgeHours != nil {
+		if *settingsRequest.MaxAgeHours < 1 {
+			return nil, ErrInvalidRateMaxAge
+		}
+
+		if err := saveSetting(s.db, settingRateMaxAge, strconv.Itoa(*settingsRequest.MaxAgeHours)); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetRateSettings()
+}
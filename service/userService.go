@@ -1,12 +1,18 @@
 package service
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/jwt"
 	"BookVault-API/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -18,6 +24,13 @@ var (
 	ErrUserNotFound			= errors.New("user not found")
 	ErrUserDetailsNotFound	= errors.New("user details not found")
 	ErrHashPassword			= errors.New("couldn't generate hash password")
+	ErrInvalidRefreshToken	= errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused	= errors.New("refresh token was already used, please log in again")
+)
+
+const (
+	accessTokenDuration		= 15 * time.Minute
+	refreshTokenDuration	= 7 * 24 * time.Hour
 )
 
 type UserService interface {
@@ -25,11 +38,19 @@ type UserService interface {
 
 	Register(registerRequest *model.RegisterRequest) error
 
-	Login(loginRequest *model.LoginRequest) (string, error)
+	Login(loginRequest *model.LoginRequest) (*model.TokenResponse, error)
+
+	Refresh(refreshToken string) (*model.TokenResponse, error)
+
+	Logout(principal auth.Principal, refreshToken string) error
+
+	IsTokenRevoked(tokenID string, userID uint) (bool, error)
 
 	CreateDetails(userID uint, userDetailsRequest *model.UserDetailsRequest) error
 	
 	GetUserByID(userID uint) (*model.UserResponse, error)
+
+	DeleteUser(userID uint) error
 }
 
 type userService struct {
@@ -86,30 +107,192 @@ func (u *userService) Register(registerRequest *model.RegisterRequest) error {
 }
 
 
-func (u *userService) Login(loginRequest *model.LoginRequest) (string, error) {
+func (u *userService) Login(loginRequest *model.LoginRequest) (*model.TokenResponse, error) {
 	var user model.User
 
 	if loginRequest.Username == "" || loginRequest.Password == "" {
-		return "", ErrEmptyFields
+		return nil, ErrEmptyFields
 	}
 	
 	if err := u.db.Where("username = ?", loginRequest.Username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", ErrInvalidCredentials
+			return nil, ErrInvalidCredentials
 		}
-		return "", err
+		return nil, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password)); err != nil {
-		return "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
+	}
+
+	familyID, err := randomToken()
+	if err != nil {
+		return nil, ErrCreatingToken
+	}
+
+	return u.issueTokens(u.db, &user, familyID)
+}
+
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+
+// issueTokens signs a new access token and stores a new refresh token in the given family.
+// Only the hash of the refresh token is persisted.
+func (u *userService) issueTokens(tx *gorm.DB, user *model.User, familyID string) (*model.TokenResponse, error) {
+	accessToken, err := jwt.GenerateToken(user.ID, user.Username, user.Role, accessTokenDuration)
+	if err != nil {
+		return nil, ErrCreatingToken
 	}
 
-	token, err := jwt.GenerateToken(loginRequest.Username, user.Role, 24 * time.Hour)
+	refreshToken, err := randomToken()
 	if err != nil {
-		return "", ErrCreatingToken
+		return nil, ErrCreatingToken
 	}
 
-	return token, nil
+	stored := model.RefreshToken{
+		UserID: 	user.ID,
+		TokenHash: 	hashToken(refreshToken),
+		FamilyID: 	familyID,
+		ExpiresAt: 	time.Now().Add(refreshTokenDuration),
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	return &model.TokenResponse{
+		Token: 			accessToken,
+		RefreshToken: 	refreshToken,
+		ExpiresIn: 		int64(accessTokenDuration.Seconds()),
+	}, nil
+}
+
+
+func (u *userService) revokeFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+
+// revokeUserTokens revokes every refresh token family of a user.
+func (u *userService) revokeUserTokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token works once;
+// presenting one that was already rotated or revoked revokes every token of its family.
+// Tokens of a deleted user are invalid.
+func (u *userService) Refresh(refreshToken string) (*model.TokenResponse, error) {
+	if refreshToken == "" {
+		return nil, ErrEmptyFields
+	}
+
+	var response *model.TokenResponse
+	reused := false
+
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		var stored model.RefreshToken
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").Where("token_hash = ?", hashToken(refreshToken)).First(&stored).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if stored.User.ID == 0 {
+			return ErrInvalidRefreshToken
+		}
+
+		if stored.UsedAt != nil || stored.RevokedAt != nil {
+			reused = true
+			return u.revokeFamily(tx, stored.FamilyID)
+		}
+
+		if time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&stored).Update("used_at", &now).Error; err != nil {
+			return err
+		}
+
+		response, err = u.issueTokens(tx, &stored.User, stored.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return response, nil
+}
+
+
+// Logout revokes the caller's access token and, when given, the refresh token family it belongs to.
+func (u *userService) Logout(principal auth.Principal, refreshToken string) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		revoked := model.RevokedToken{TokenID: principal.TokenID, ExpiresAt: principal.ExpiresAt}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+			return err
+		}
+
+		if refreshToken == "" {
+			return nil
+		}
+
+		var stored model.RefreshToken
+		if err := tx.Where("token_hash = ? AND user_id = ?", hashToken(refreshToken), principal.UserID).First(&stored).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		return u.revokeFamily(tx, stored.FamilyID)
+	})
+}
+
+
+// IsTokenRevoked reports whether an access token was revoked on logout or belongs to a
+// user who has since been deleted.
+func (u *userService) IsTokenRevoked(tokenID string, userID uint) (bool, error) {
+	var count int64
+
+	if err := u.db.Model(&model.RevokedToken{}).Where("token_id = ? AND expires_at > ?", tokenID, time.Now()).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := u.db.Model(&model.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
 }
 
 
@@ -153,4 +336,22 @@ func (u *userService) GetUserByID(userID uint) (*model.UserResponse, error) {
 		FullName: 		user.Details.FullName,
 	}
 	return &userResponse, nil
+}
+
+
+// DeleteUser soft-deletes a user and revokes all of their refresh tokens. Access tokens the
+// user still holds are rejected by IsTokenRevoked.
+func (u *userService) DeleteUser(userID uint) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.User{}, userID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		return u.revokeUserTokens(tx, userID)
+	})
 }
\ No newline at end of file
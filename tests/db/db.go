@@ -1,13 +1,14 @@
 package db
 
 import (
-	"BookVault-API/model"
+	"BookVault-API/database"
+	"BookVault-API/database/migrations"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -17,39 +18,41 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("no env file found: %v", err)
 	}
 
-	host 		:= 	os.Getenv("DB_HOST")
-	port 		:= 	os.Getenv("DB_PORT")
-	user 		:= 	os.Getenv("DB_USER")
-	password 	:= 	os.Getenv("DB_PASSWORD")
-	dbName 		:= 	os.Getenv("DB_NAME_TEST")
-	sslMode 	:= 	os.Getenv("DB_SSLMODE")
-
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", host, port, user, password, dbName, sslMode)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := database.Connect(os.Getenv("DB_NAME_TEST"))
 	if err != nil {
 		t.Fatalf("failed to connect database: %v", err)
 	}
 
-	err = db.Migrator().DropTable(&model.User{}, &model.UserDetails{}, &model.Book{}, &model.Cart{}, &model.CartBook{}, &model.Order{}, &model.OrderBook{}, &model.Review{})
-	if err != nil {
-		t.Fatalf("failed to drop tables: %v", err)
-	}
-	
-	err = db.AutoMigrate(&model.User{}, &model.UserDetails{}, &model.Book{}, &model.Cart{}, &model.CartBook{}, &model.Order{}, &model.OrderBook{}, &model.Review{})
-	if err != nil {
-		t.Fatalf("failed to migrate models: %v", err)
+	if err := migrations.Reset(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
 	}
 
-	if err := db.Exec("TRUNCATE users, user_details, books, carts, cart_books, orders, order_books, reviews RESTART IDENTITY CASCADE;").Error; err != nil {
+	if err := truncateTables(db); err != nil {
 		t.Fatalf("failed to TRUNCATE db tables: %v", err)
 	}
 
 	t.Cleanup(func() {
-		if err := db.Exec("TRUNCATE users, user_details, books, carts, cart_books, orders, order_books, reviews RESTART IDENTITY CASCADE;").Error; err != nil {
+		if err := truncateTables(db); err != nil {
 			t.Fatalf("failed to TRUNCATE db tables: %v", err)
 		}
 	})
 
 	return db
-}
\ No newline at end of file
+}
+
+
+// truncateTables empties every table created by the migrations, keeping schema_migrations intact.
+func truncateTables(db *gorm.DB) error {
+	var tables []string
+
+	err := db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'").Scan(&tables).Error
+	if err != nil {
+		return err
+	}
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	return db.Exec(fmt.Sprintf("TRUNCATE %s RESTART IDENTITY CASCADE;", strings.Join(tables, ", "))).Error
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"BookVault-API/auth"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var testAdmin = auth.Principal{UserID: 99999, Username: "admin", Role: auth.RoleAdmin}
+
+
+// principalOf returns the regular user principal that AuthMiddleware would build for userID.
+func principalOf(userID uint) auth.Principal {
+	return auth.Principal{UserID: userID, Username: "user", Role: "user"}
+}
+
+
+// asUser attaches a regular user principal to the request, as AuthMiddleware would.
+func asUser(req *http.Request, userID uint) *http.Request {
+	principal := auth.Principal{UserID: userID, Username: "user", Role: "user", TokenID: fmt.Sprintf("test-token-%d", userID), ExpiresAt: time.Now().Add(time.Hour)}
+	return req.WithContext(auth.WithPrincipal(req.Context(), principal))
+}
+
+
+// asAdmin attaches an admin principal to the request, as AuthMiddleware would.
+func asAdmin(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithPrincipal(req.Context(), testAdmin))
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"BookVault-API/handler"
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+
+func TestAuthorHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+	authorService := service.NewAuthorService(testDB)
+	authorHandler := handler.NewAuthorHandler(authorService)
+
+	book := createTestBook(t, testDB, bookService, "The Trial", "Kafka", "11")
+
+	translator, err := authorService.CreateAuthor(model.AuthorRequest{Name: "Willa Muir"})
+	if err != nil {
+		t.Fatalf("failed to create author: %v", err)
+	}
+
+	var kafka model.Author
+	if err := testDB.Where("name = ?", "Kafka").First(&kafka).Error; err != nil {
+		t.Fatalf("failed to fetch author: %v", err)
+	}
+
+	testCases := []struct {
+		name		string
+		method		string
+		urlPath		string
+		reqBody		string
+		handle		http.HandlerFunc
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test create author", http.MethodPost, "/author/create", `{"name":"Edwin Muir","born_on":"1887-05-15"}`, authorHandler.CreateAuthor, http.StatusCreated, `"born_on":"1887-05-15"`},
+		{"test create author with taken alias", http.MethodPost, "/author/create", `{"name":"F. Kafka","aliases":["kafka"]}`, authorHandler.CreateAuthor, http.StatusConflict, service.ErrAuthorExists.Error()},
+		{"test create author with invalid date", http.MethodPost, "/author/create", `{"name":"Max Brod","born_on":"1884"}`, authorHandler.CreateAuthor, http.StatusBadRequest, service.ErrInvalidAuthorDate.Error()},
+		{"test update author", http.MethodPatch, fmt.Sprintf("/author/update/%d", kafka.ID), `{"name":"Franz Kafka","aliases":["Kafka"]}`, authorHandler.UpdateAuthor, http.StatusOK, "Author updated!"},
+		{"test update author dates out of order", http.MethodPatch, fmt.Sprintf("/author/update/%d", kafka.ID), `{"born_on":"1924-06-03","died_on":"1883-07-03"}`, authorHandler.UpdateAuthor, http.StatusBadRequest, service.ErrAuthorDates.Error()},
+		{"test credit translator", http.MethodPut, fmt.Sprintf("/book/authors/%d", book.ID), fmt.Sprintf(`{"authors":[{"author_id":%d},{"author_id":%d,"role":"translator"}]}`, kafka.ID, translator.ID), bookHandler.SetBookAuthors, http.StatusOK, "Book authors updated!"},
+		{"test credits without author", http.MethodPut, fmt.Sprintf("/book/authors/%d", book.ID), fmt.Sprintf(`{"authors":[{"author_id":%d,"role":"translator"}]}`, translator.ID), bookHandler.SetBookAuthors, http.StatusBadRequest, service.ErrBookNeedsAuthor.Error()},
+		{"test credit unknown author", http.MethodPut, fmt.Sprintf("/book/authors/%d", book.ID), `{"authors":[{"author_id":9999}]}`, bookHandler.SetBookAuthors, http.StatusNotFound, service.ErrAuthorNotFound.Error()},
+		{"test author page", http.MethodGet, fmt.Sprintf("/author/%d", translator.ID), "", authorHandler.GetAuthor, http.StatusOK, `"roles":["translator"]`},
+		{"test book lists authors", http.MethodGet, "/book?title=The%20Trial", "", bookHandler.GetByTitle, http.StatusOK, `"author":"Franz Kafka","authors":[{"id":`},
+		{"test books by alias", http.MethodGet, "/book/author?author=kafka", "", bookHandler.GetBooksByAuthor, http.StatusOK, "The Trial"},
+		{"test unknown author page", http.MethodGet, "/author/9999", "", authorHandler.GetAuthor, http.StatusNotFound, service.ErrAuthorNotFound.Error()},
+		{"test delete credited author", http.MethodDelete, fmt.Sprintf("/author/delete/%d", translator.ID), "", authorHandler.DeleteAuthor, http.StatusConflict, service.ErrAuthorHasBooks.Error()},
+		{"test merge into itself", http.MethodPost, fmt.Sprintf("/author/merge/%d/%d", kafka.ID, kafka.ID), "", authorHandler.MergeAuthors, http.StatusBadRequest, service.ErrMergeSameAuthor.Error()},
+		{"test merge authors", http.MethodPost, fmt.Sprintf("/author/merge/%d/%d", translator.ID, kafka.ID), "", authorHandler.MergeAuthors, http.StatusOK, "Authors merged!"},
+		{"test delete merged author", http.MethodDelete, fmt.Sprintf("/author/delete/%d", translator.ID), "", authorHandler.DeleteAuthor, http.StatusNotFound, service.ErrAuthorNotFound.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(test.method, test.urlPath, strings.NewReader(test.reqBody)))
+			w := httptest.NewRecorder()
+
+			test.handle(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
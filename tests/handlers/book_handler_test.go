@@ -4,6 +4,7 @@ import (
 	"BookVault-API/handler"
 	"BookVault-API/helper"
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
 	"bytes"
@@ -11,30 +12,45 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"gorm.io/gorm"
 )
 
-func float32Ptr(f float32) *float32 {
-	return &f
+func decimalPtr(s string) *money.Decimal {
+	d := money.Decimal(s)
+	return &d
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// priceOf is an amount in the default currency, which prices given without a currency are in.
+func priceOf(s string) money.Money {
+	price, err := money.Parse(s, money.DefaultCurrency())
+	if err != nil {
+		panic(err)
+	}
+	return price
 }
 
 func initBookTestHandler(t *testing.T) (*gorm.DB, service.BookService, *handler.BookHandler) {
 	testDB := db.SetupTestDB(t)
 	bookService := service.NewBookService(testDB)
-	bookHandler := handler.NewBookHandler(bookService)
+	bookHandler := handler.NewBookHandler(bookService, service.NewExchangeRateService(testDB))
 	return testDB, bookService, bookHandler
 }
 
-func createTestBook(t *testing.T,testDB *gorm.DB, bookService service.BookService, title, author string, price float32) model.Book {
+func createTestBook(t *testing.T,testDB *gorm.DB, bookService service.BookService, title, author, price string) model.Book {
 	t.Helper()
 
 	bookReq := &model.BookRequest{
 		Title: title,
 		Author: author,
 		Description: "Test book description",
-		Price: &price,
+		Price: decimalPtr(price),
 	}
 
 	err := bookService.CreateBook(bookReq)
@@ -61,7 +77,7 @@ func TestCreateBookHandler(t *testing.T) {
 		wantRespBody	string
 	}{
 		{"test empty fields", model.BookRequest{Title: "", Author: "", Description: "", Price: nil}, http.StatusBadRequest, service.ErrEmptyFields.Error()},
-		{"test valid book", model.BookRequest{Title: "The Idiot", Author: "Dostoevsky", Description: "One of the 5 major books from Dostoevsky", Price: float32Ptr(20.00)}, http.StatusCreated, "Book created!"},
+		{"test valid book", model.BookRequest{Title: "The Idiot", Author: "Dostoevsky", Description: "One of the 5 major books from Dostoevsky", Price: decimalPtr("20.00")}, http.StatusCreated, "Book created!"},
 	}
 
 	for _, test := range testCases {
@@ -81,7 +97,7 @@ func TestCreateBookHandler(t *testing.T) {
 func TestGetByTitleHandler(t *testing.T) {
 	testDB, bookService, bookHandler := initBookTestHandler(t)
 
-	createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", 20.00)
+	createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", "20.00")
 
 	testCases := []struct{
 		name			string
@@ -107,23 +123,66 @@ func TestGetByTitleHandler(t *testing.T) {
 }
 
 
+func TestGetByISBNHandler(t *testing.T) {
+	_, bookService, bookHandler := initBookTestHandler(t)
+
+	err := bookService.CreateBook(&model.BookRequest{Title: "The Idiot", Author: "Dostoevsky", Description: "Test book description", Price: decimalPtr("20.00"), ISBN: "9780306406157"})
+	if err != nil {
+		t.Fatalf("failed to create test book: %v", err)
+	}
+
+	testCases := []struct{
+		name			string
+		urlPath			string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test invalid ISBN", "/book/isbn/12345", http.StatusBadRequest, service.ErrInvalidISBN.Error()},
+		{"test missing ISBN", "/book/isbn/", http.StatusBadRequest, helper.ErrInvalidPath.Error()},
+		{"test book not found", "/book/isbn/978-3-16-148410-0", http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test valid ISBN-10", "/book/isbn/0-306-40615-2", http.StatusOK, `"isbn13":"9780306406157"`},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			w := httptest.NewRecorder()
+
+			bookHandler.GetByISBN(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
 func TestGetBooksHandler(t *testing.T) {
 	testDB, bookService, bookHandler := initBookTestHandler(t)
 	
 	testCases := []struct{
 		testName		string
 		prepareDB		func()
+		urlPath			string
 		wantStatus		int
 		wantRespBody	string
 	}{
-		{"test no books", func(){}, http.StatusNotFound, service.ErrNoBooks.Error()},
-		{"test books exist", func(){createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", 20.00)}, http.StatusOK, "The Idiot"},
+		{"test no books", func(){}, "/book/all", http.StatusNotFound, service.ErrNoBooks.Error()},
+		{"test books exist", func(){createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", "20.00")}, "/book/all", http.StatusOK, "The Idiot"},
+		{"test price in minor units", func(){}, "/book/all", http.StatusOK, `"price":20.00,"currency":"` + money.DefaultCurrency() + `"`},
+		{"test page envelope", func(){createTestBook(t, testDB, bookService, "Demons", "Dostoevsky", "25.00")}, "/book/all?limit=1&sort=-price", http.StatusOK, `"total":2`},
+		{"test filter by price", func(){}, "/book/all?max_price=22.5", http.StatusOK, "The Idiot"},
+		{"test invalid price filter", func(){}, "/book/all?min_price=cheap", http.StatusBadRequest, "invalid min_price"},
+		{"test invalid currency filter", func(){}, "/book/all?max_price=22.5&currency=euro", http.StatusBadRequest, helper.ErrInvalidCurrency.Error()},
+		{"test invalid limit", func(){}, "/book/all?limit=0", http.StatusBadRequest, helper.ErrInvalidLimit.Error()},
+		{"test invalid date", func(){}, "/book/all?created_after=yesterday", http.StatusBadRequest, helper.ErrInvalidDate.Error()},
+		{"test invalid sort", func(){}, "/book/all?sort=description", http.StatusBadRequest, service.ErrInvalidSort.Error()},
+		{"test invalid cursor", func(){}, "/book/all?cursor=abc", http.StatusBadRequest, service.ErrInvalidCursor.Error()},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
 			test.prepareDB()
-			req := httptest.NewRequest(http.MethodGet, "/book/all", nil)
+			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
 			w := httptest.NewRecorder()
 
 			bookHandler.GetBooks(w, req)
@@ -137,7 +196,7 @@ func TestGetBooksHandler(t *testing.T) {
 func TestGetBooksByAuthorHandler(t *testing.T) {
 	testDB, bookService, bookHandler := initBookTestHandler(t)
 
-	createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", 20.00)
+	createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", "20.00")
 
 	testCases := []struct{
 		 name			string
@@ -166,7 +225,7 @@ func TestGetBooksByAuthorHandler(t *testing.T) {
 func TestUpdateStockHandler(t *testing.T) {
 	testDB, bookService, bookHandler := initBookTestHandler(t)
 
-	book := createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", 20.00)
+	book := createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", "20.00")
 
 	testCases := []struct{
 		name			string
@@ -174,8 +233,12 @@ func TestUpdateStockHandler(t *testing.T) {
 		wantStatus		int
 		wantRespBody	string
 	}{
-		{"test book not found", "/book/updateStock/9999", http.StatusNotFound, service.ErrBookNotFound.Error()},
-		{"test book stock updated", fmt.Sprintf("/book/updateStock/%d", book.ID), http.StatusOK, "Book stock updated!"},
+		{"test book not found", "/book/updateStock/9999?stock=5", http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test book stock set", fmt.Sprintf("/book/updateStock/%d?stock=5", book.ID), http.StatusOK, "Book stock updated!"},
+		{"test book stock adjusted", fmt.Sprintf("/book/updateStock/%d?delta=-2", book.ID), http.StatusOK, "Book stock updated!"},
+		{"test stock below zero", fmt.Sprintf("/book/updateStock/%d?delta=-10", book.ID), http.StatusBadRequest, service.ErrInvalidStock.Error()},
+		{"test missing stock and delta", fmt.Sprintf("/book/updateStock/%d", book.ID), http.StatusBadRequest, service.ErrInvalidStockUpdate.Error()},
+		{"test invalid delta", fmt.Sprintf("/book/updateStock/%d?delta=many", book.ID), http.StatusBadRequest, "invalid delta"},
 		{"test missing id in path", "/book/updateStock/", http.StatusBadRequest, helper.ErrInvalidPath.Error()},
 	}
 
@@ -189,4 +252,169 @@ func TestUpdateStockHandler(t *testing.T) {
 			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
 		})
 	}
-}
\ No newline at end of file
+}
+
+
+func TestUpdateBookHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+
+	book := createTestBook(t, testDB, bookService, "Crime and Punishmnet", "Dostoevsky", "30")
+
+	testCases := []struct {
+		name			string
+		urlPath			string
+		reqBody			model.BookRequest
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test update title", fmt.Sprintf("/book/update/%d", book.ID), model.BookRequest{Title: "Crime and Punishment"}, http.StatusOK, "Book updated!"},
+		{"test negative price", fmt.Sprintf("/book/update/%d", book.ID), model.BookRequest{Price: decimalPtr("-5")}, http.StatusBadRequest, service.ErrInvalidPrice.Error()},
+		{"test invalid currency", fmt.Sprintf("/book/update/%d", book.ID), model.BookRequest{Currency: "euro"}, http.StatusBadRequest, service.ErrInvalidCurrency.Error()},
+		{"test set max quantity", fmt.Sprintf("/book/update/%d", book.ID), model.BookRequest{MaxQuantity: intPtr(3)}, http.StatusOK, "Book updated!"},
+		{"test negative max quantity", fmt.Sprintf("/book/update/%d", book.ID), model.BookRequest{MaxQuantity: intPtr(-3)}, http.StatusBadRequest, service.ErrInvalidMaxQuantity.Error()},
+		{"test book not found", "/book/update/9999", model.BookRequest{Title: "Crime and Punishment"}, http.StatusNotFound, service.ErrBookNotFound.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(test.reqBody)
+			req := httptest.NewRequest(http.MethodPatch, test.urlPath, bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			bookHandler.UpdateBook(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
+func TestDeleteAndRestoreBookHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+
+	book := createTestBook(t, testDB, bookService, "The Double", "Dostoevsky", "9")
+
+	testCases := []struct {
+		name			string
+		method			string
+		urlPath			string
+		handle			func(http.ResponseWriter, *http.Request)
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test restore book that is not deleted", http.MethodPatch, fmt.Sprintf("/book/restore/%d", book.ID), bookHandler.RestoreBook, http.StatusConflict, service.ErrBookNotDeleted.Error()},
+		{"test delete book", http.MethodDelete, fmt.Sprintf("/book/delete/%d", book.ID), bookHandler.DeleteBook, http.StatusOK, "Book deleted!"},
+		{"test delete missing book", http.MethodDelete, "/book/delete/9999", bookHandler.DeleteBook, http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test list deleted books", http.MethodGet, "/book/deleted", bookHandler.GetDeletedBooks, http.StatusOK, "The Double"},
+		{"test restore book", http.MethodPatch, fmt.Sprintf("/book/restore/%d", book.ID), bookHandler.RestoreBook, http.StatusOK, "Book restored!"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.urlPath, nil)
+			w := httptest.NewRecorder()
+
+			test.handle(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
+func TestSearchBooksHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+
+	createTestBook(t, testDB, bookService, "Crime and Punishment", "Dostoevsky", "30")
+
+	testCases := []struct {
+		name			string
+		urlPath			string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test ranked match", "/book/search?q=crime+punish", http.StatusOK, "Crime and Punishment"},
+		{"test no match", "/book/search?q=whale", http.StatusOK, `"items":[]`},
+		{"test missing query", "/book/search", http.StatusBadRequest, "q query param is required"},
+		{"test invalid sort", "/book/search?q=crime&sort=stock", http.StatusBadRequest, service.ErrInvalidSort.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			w := httptest.NewRecorder()
+
+			bookHandler.SearchBooks(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
+func TestImportBooksHandler(t *testing.T) {
+	_, _, bookHandler := initBookTestHandler(t)
+
+	csvFile := "title,author,description,price\nThe Idiot,Dostoevsky,A novel,20\n"
+
+	testCases := []struct{
+		name			string
+		urlPath			string
+		contentType		string
+		body			string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test unsupported format", "/book/import", "application/pdf", csvFile, http.StatusBadRequest, service.ErrUnsupportedImportFormat.Error()},
+		{"test invalid dryRun", "/book/import?format=csv&dryRun=maybe", "", csvFile, http.StatusBadRequest, "invalid dryRun"},
+		{"test invalid header", "/book/import?format=csv", "", "name,price\n", http.StatusBadRequest, service.ErrInvalidImportHeader.Error()},
+		{"test dry run", "/book/import?dryRun=true", "text/csv; charset=utf-8", csvFile, http.StatusOK, `"dry_run":true,"created":1`},
+		{"test import", "/book/import?format=csv", "", csvFile, http.StatusOK, `"status":"created"`},
+		{"test reimport updates", "/book/import", "application/x-ndjson", `{"title":"The Idiot","author":"Dostoevsky","description":"A novel","price":22}`, http.StatusOK, `"status":"updated"`},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.urlPath, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			bookHandler.ImportBooks(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
+func TestExportBooksHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+
+	createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", "20.00")
+
+	testCases := []struct{
+		name			string
+		urlPath			string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test unsupported format", "/book/export?format=pdf", http.StatusBadRequest, service.ErrUnsupportedExportFormat.Error()},
+		{"test invalid filter", "/book/export?min_price=cheap", http.StatusBadRequest, "invalid min_price"},
+		{"test default CSV", "/book/export", http.StatusOK, "The Idiot,Dostoevsky"},
+		{"test JSONL", "/book/export?format=jsonl&in_stock=false", http.StatusOK, `"title":"The Idiot"`},
+		{"test ONIX", "/book/export?format=onix", http.StatusOK, "<TitleText>The Idiot</TitleText>"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			w := httptest.NewRecorder()
+
+			bookHandler.ExportBooks(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
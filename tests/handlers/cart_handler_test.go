@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"gorm.io/gorm"
@@ -19,19 +20,19 @@ func initCartTestHandler(t *testing.T) (*gorm.DB, service.UserService, service.C
 	userService := service.NewUserService(testDB)
 	cartService := service.NewCartService(testDB)
 	bookService := service.NewBookService(testDB)
-	cartHandler := handler.NewCartHandler(cartService)
+	cartHandler := handler.NewCartHandler(cartService, service.NewExchangeRateService(testDB))
 
 	return testDB, userService, cartService, bookService, cartHandler
 }
 
-func createTestCartBook(t *testing.T, testDB *gorm.DB, bookService service.BookService, title, author string, price float32) model.Book {
+func createTestCartBook(t *testing.T, testDB *gorm.DB, bookService service.BookService, title, author, price string) model.Book {
 	t.Helper()
 
 	bookReq := model.BookRequest{
 		Title:       title,
 		Author:      author,
 		Description: "Test book description",
-		Price:       &price,
+		Price:       decimalPtr(price),
 	}
 	err := bookService.CreateBook(&bookReq)
 	if err != nil {
@@ -50,7 +51,7 @@ func TestAddToCartHandler(t *testing.T) {
 	testDB, userService, _, bookService, cartHandler := initCartTestHandler(t)
 
 	user := createTestUser(t, testDB, userService, "cartUser1", "cartUser1@gmail.com")
-	book := createTestCartBook(t, testDB, bookService, "Crime and Punishment", "Dostoevsky", 30.00)
+	book := createTestCartBook(t, testDB, bookService, "Crime and Punishment", "Dostoevsky", "30.00")
 
 	testCases := []struct{
 		testName		string
@@ -61,11 +62,12 @@ func TestAddToCartHandler(t *testing.T) {
 		{"test add to cart succeed", fmt.Sprintf("/cart/add/%d/%d?quantity=1", user.ID, book.ID), http.StatusCreated, "Book added to cart!"},
 		{"test book not found", fmt.Sprintf("/cart/add/%d/%d?quantity=1", user.ID, 9999), http.StatusNotFound, service.ErrBookNotFound.Error()},
 		{"test invalid quantity", fmt.Sprintf("/cart/add/%d/%d?quantity=invalidQuantity", user.ID, book.ID), http.StatusBadRequest, "invalid quantity"},
+		{"test zero quantity", fmt.Sprintf("/cart/add/%d/%d?quantity=0", user.ID, book.ID), http.StatusBadRequest, service.ErrInvalidQuantity.Error()},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodPost, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodPost, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			cartHandler.AddToCart(w, req)
@@ -80,7 +82,7 @@ func TestClearCartHandler(t *testing.T) {
 	testDB, userService, cartService, bookService, cartHandler := initCartTestHandler(t)
 
 	user := createTestUser(t, testDB, userService, "cartUser2", "cartUser2@gmail.com")
-	book := createTestCartBook(t, testDB, bookService, "John Stainbeck", "East of Eden", 30.00)
+	book := createTestCartBook(t, testDB, bookService, "John Stainbeck", "East of Eden", "30.00")
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
 	testCases := []struct{
@@ -96,7 +98,7 @@ func TestClearCartHandler(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
 			url := fmt.Sprintf("/cart/clear/%d", test.userID)
-			req := httptest.NewRequest(http.MethodDelete, url, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodDelete, url, nil))
 			w := httptest.NewRecorder()
 
 			cartHandler.ClearCart(w, req)
@@ -111,7 +113,7 @@ func TestRemoveFromCart(t *testing.T) {
 	testDB,userService,  cartService, bookService, cartHandler := initCartTestHandler(t)
 
 	user := createTestUser(t,testDB, userService, "cartUser3", "cartUser3@gmail.com")
-	book := createTestCartBook(t, testDB, bookService, "The Gambler", "Fyodor Dostoevsky", 15.00)
+	book := createTestCartBook(t, testDB, bookService, "The Gambler", "Fyodor Dostoevsky", "15.00")
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
 	testCases := []struct {
@@ -129,7 +131,7 @@ func TestRemoveFromCart(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
 			url := fmt.Sprintf("/cart/remove/%d/%d", test.userID, test.bookID)
-			req := httptest.NewRequest(http.MethodDelete, url, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodDelete, url, nil))
 			w := httptest.NewRecorder()
 
 			cartHandler.RemoveFromCart(w, req)
@@ -144,7 +146,7 @@ func TestUpdateQuantityHandler(t *testing.T){
 	testDB, userService, cartService, bookService, cartHandler := initCartTestHandler(t)
 
 	user := createTestUser(t,testDB, userService, "cartUser4", "cartUser4@gmail.com")
-	book := createTestCartBook(t, testDB, bookService, "Rudin", "Ivan Turgenev", 10.00)
+	book := createTestCartBook(t, testDB, bookService, "Rudin", "Ivan Turgenev", "10.00")
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
 	testCases := []struct{
@@ -156,11 +158,13 @@ func TestUpdateQuantityHandler(t *testing.T){
 		{"test update existing book", fmt.Sprintf("/cart/update/%d/%d?quantity=2", user.ID, book.ID), http.StatusOK, "Book quantity updated!"},
 		{"test update missing cart", fmt.Sprintf("/cart/update/%d/%d?quantity=2", 9999, book.ID), http.StatusNotFound, service.ErrCartNotFound.Error()},
 		{"test update missing book", fmt.Sprintf("/cart/update/%d/%d?quantity=2", user.ID, 9999), http.StatusNotFound, service.ErrCartBookNotFound.Error()},
+		{"test negative quantity", fmt.Sprintf("/cart/update/%d/%d?quantity=-1", user.ID, book.ID), http.StatusBadRequest, service.ErrNegativeQuantity.Error()},
+		{"test zero quantity removes book", fmt.Sprintf("/cart/update/%d/%d?quantity=0", user.ID, book.ID), http.StatusOK, "Book removed from cart!"},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodPatch, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodPatch, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			cartHandler.UpdateQuantity(w, req)
@@ -171,27 +175,156 @@ func TestUpdateQuantityHandler(t *testing.T){
 }
 
 
+func TestSetCartLinesHandler(t *testing.T) {
+	testDB, userService, _, bookService, cartHandler := initCartTestHandler(t)
+
+	user := createTestUser(t, testDB, userService, "cartUser9", "cartUser9@gmail.com")
+	book := createTestCartBook(t, testDB, bookService, "Fathers and Sons", "Ivan Turgenev", "11.00")
+	limited := createTestCartBook(t, testDB, bookService, "First Love", "Ivan Turgenev", "7.00")
+
+	if err := bookService.UpdateBook(limited.ID, &model.BookRequest{MaxQuantity: intPtr(1)}); err != nil {
+		t.Fatalf("failed to set max quantity: %v", err)
+	}
+
+	urlPath := fmt.Sprintf("/cart/lines/%d", user.ID)
+
+	testCases := []struct{
+		testName		string
+		reqBody			string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test set lines", fmt.Sprintf(`{"lines":[{"book_id":%d,"quantity":2},{"book_id":%d,"quantity":1}]}`, book.ID, limited.ID), http.StatusOK, "Cart updated!"},
+		{"test no lines", `{"lines":[]}`, http.StatusBadRequest, service.ErrNoCartLines.Error()},
+		{"test invalid body", `{"lines":`, http.StatusBadRequest, "invalid request body"},
+		{"test book given twice", fmt.Sprintf(`{"lines":[{"book_id":%d,"quantity":1},{"book_id":%d,"quantity":0}]}`, book.ID, book.ID), http.StatusBadRequest, service.ErrDuplicateCartLine.Error()},
+		{"test unknown book", `{"lines":[{"book_id":9999,"quantity":1}]}`, http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test line above the limit", fmt.Sprintf(`{"lines":[{"book_id":%d,"quantity":2}]}`, limited.ID), http.StatusConflict, "at most 1 copies"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(http.MethodPut, urlPath, strings.NewReader(test.reqBody)))
+			w := httptest.NewRecorder()
+
+			cartHandler.SetCartLines(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
 func TestGetCartHandler(t *testing.T){
 	testDB, userService,  cartService, bookService, cartHandler := initCartTestHandler(t)
 
 	user := createTestUser(t,testDB, userService, "cartUser5", "cartUser5@gmail.com")
-	book := createTestCartBook(t, testDB, bookService, "The Picture of Dorian Gray", "Oscar Wilde", 20.00)
+	book := createTestCartBook(t, testDB, bookService, "The Picture of Dorian Gray", "Oscar Wilde", "20.00")
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
+	if _, err := service.NewExchangeRateService(testDB).SetRate(model.ExchangeRateRequest{Quote: "USD", Rate: decimalPtr("1.5")}); err != nil {
+		t.Fatalf("failed to set rate: %v", err)
+	}
+
 	testCases := []struct {
 		testName       	string
 		cartID     		uint
+		query			string
+		wantStatus 		int
+		wantRespBody	string
+	}{
+		{"test get existing cart", 1, "", http.StatusOK, "Oscar Wilde"},
+		{"test cart line authors", 1, "", http.StatusOK, `"name":"Oscar Wilde","role":"author"`},
+		{"test cart line prices", 1, "", http.StatusOK, `"quantity":1,"unit_price":20.00,"line_total":20.00`},
+		{"test cart line in requested currency", 1, "?currency=usd", http.StatusOK, `"converted_line_total":{"price":30.00,"currency":"USD"`},
+		{"test cart totals in requested currency", 1, "?currency=usd", http.StatusOK, `"converted":{"subtotal":30.00,"discount":0.00,"total":30.00,"currency":"USD","rate":"1.5"`},
+		{"test cart without rate", 1, "?currency=gbp", http.StatusBadRequest, service.ErrNoExchangeRate.Error()},
+		{"test get non-existent cart", 9999, "", http.StatusNotFound, service.ErrCartNotFound.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			url := fmt.Sprintf("/cart/%d%s", test.cartID, test.query)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, url, nil))
+			w := httptest.NewRecorder()
+
+			cartHandler.GetCart(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+func TestCartHandlerActingUser(t *testing.T) {
+	testDB, userService, cartService, bookService, cartHandler := initCartTestHandler(t)
+
+	user := createTestUser(t, testDB, userService, "cartUser6", "cartUser6@gmail.com")
+	otherUser := createTestUser(t, testDB, userService, "cartUser7", "cartUser7@gmail.com")
+	book := createTestCartBook(t, testDB, bookService, "Fathers and Sons", "Ivan Turgenev", "12.00")
+	_ = cartService.AddToCart(otherUser.ID, book.ID, 1)
+
+	testCases := []struct{
+		testName		string
+		req				*http.Request
+		handle			func(http.ResponseWriter, *http.Request)
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test add to own cart without user id", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/cart/add/%d?quantity=1", book.ID), nil), user.ID), cartHandler.AddToCart, http.StatusCreated, "Book added to cart!"},
+		{"test add to own cart with user id", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/cart/add/%d/%d?quantity=1", user.ID, book.ID), nil), user.ID), cartHandler.AddToCart, http.StatusCreated, "Book added to cart!"},
+		{"test add to another user's cart", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/cart/add/%d/%d?quantity=1", otherUser.ID, book.ID), nil), user.ID), cartHandler.AddToCart, http.StatusForbidden, "forbidden"},
+		{"test update another user's cart", asUser(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/cart/update/%d/%d?quantity=5", otherUser.ID, book.ID), nil), user.ID), cartHandler.UpdateQuantity, http.StatusForbidden, "forbidden"},
+		{"test remove from another user's cart", asUser(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/cart/remove/%d/%d", otherUser.ID, book.ID), nil), user.ID), cartHandler.RemoveFromCart, http.StatusForbidden, "forbidden"},
+		{"test clear another user's cart", asUser(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/cart/clear/%d", otherUser.ID), nil), user.ID), cartHandler.ClearCart, http.StatusForbidden, "forbidden"},
+		{"test clear own cart", asUser(httptest.NewRequest(http.MethodDelete, "/cart/clear", nil), user.ID), cartHandler.ClearCart, http.StatusOK, "Cart has been cleared!"},
+		{"test missing principal", httptest.NewRequest(http.MethodDelete, "/cart/clear", nil), cartHandler.ClearCart, http.StatusUnauthorized, "missing authenticated user"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			test.handle(w, test.req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+
+	var count int64
+	testDB.Model(&model.CartBook{}).Joins("JOIN carts ON carts.id = cart_books.cart_id").Where("carts.user_id = ?", otherUser.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("expected other user's cart to be untouched, got %d lines", count)
+	}
+}
+
+
+func TestGetCartHandlerOwnership(t *testing.T){
+	testDB, userService, cartService, bookService, cartHandler := initCartTestHandler(t)
+
+	owner := createTestUser(t, testDB, userService, "cartUser8", "cartUser8@gmail.com")
+	otherUser := createTestUser(t, testDB, userService, "cartUser9", "cartUser9@gmail.com")
+	book := createTestCartBook(t, testDB, bookService, "Dubliners", "James Joyce", "14.00")
+	_ = cartService.AddToCart(owner.ID, book.ID, 1)
+
+	var cart model.Cart
+	if err := testDB.Where("user_id = ?", owner.ID).First(&cart).Error; err != nil {
+		t.Fatalf("failed to fetch cart: %v", err)
+	}
+
+	testCases := []struct {
+		testName       	string
+		userID     		uint
 		wantStatus 		int
 		wantRespBody	string
 	}{
-		{"test get existing cart", 1, http.StatusOK, "Oscar Wilde"},
-		{"test get non-existent cart", 9999, http.StatusNotFound, service.ErrCartNotFound.Error()},
+		{"test get another user's cart", otherUser.ID, http.StatusForbidden, service.ErrForbidden.Error()},
+		{"test get own cart", owner.ID, http.StatusOK, "James Joyce"},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
-			url := fmt.Sprintf("/cart/%d", test.cartID)
-			req := httptest.NewRequest(http.MethodGet, url, nil)
+			url := fmt.Sprintf("/cart/%d", cart.ID)
+			req := asUser(httptest.NewRequest(http.MethodGet, url, nil), test.userID)
 			w := httptest.NewRecorder()
 
 			cartHandler.GetCart(w, req)
@@ -199,4 +332,4 @@ func TestGetCartHandler(t *testing.T){
 			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
 		})
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"BookVault-API/handler"
+	"BookVault-API/helper"
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+
+func TestCategoryHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+	categoryService := service.NewCategoryService(testDB)
+	categoryHandler := handler.NewCategoryHandler(categoryService)
+
+	fiction, err := categoryService.CreateCategory(model.CategoryRequest{Name: "Fiction"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+
+	book := createTestBook(t, testDB, bookService, "The Trial", "Kafka", "11")
+
+	testCases := []struct {
+		name		string
+		method		string
+		urlPath		string
+		reqBody		string
+		handle		http.HandlerFunc
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test create category", http.MethodPost, "/category/create", fmt.Sprintf(`{"name":"Absurdist","parent_id":%d}`, fiction.ID), categoryHandler.CreateCategory, http.StatusCreated, `"name":"Absurdist"`},
+		{"test create duplicate category", http.MethodPost, "/category/create", `{"name":"fiction"}`, categoryHandler.CreateCategory, http.StatusConflict, service.ErrCategoryExists.Error()},
+		{"test create category without name", http.MethodPost, "/category/create", `{}`, categoryHandler.CreateCategory, http.StatusBadRequest, service.ErrEmptyCategoryName.Error()},
+		{"test assign categories", http.MethodPut, fmt.Sprintf("/book/categories/%d", book.ID), fmt.Sprintf(`{"category_ids":[%d]}`, fiction.ID), bookHandler.SetBookCategories, http.StatusOK, "Book categories updated!"},
+		{"test assign unknown category", http.MethodPut, fmt.Sprintf("/book/categories/%d", book.ID), `{"category_ids":[9999]}`, bookHandler.SetBookCategories, http.StatusNotFound, service.ErrCategoryNotFound.Error()},
+		{"test category tree", http.MethodGet, "/category/all", "", categoryHandler.GetCategoryTree, http.StatusOK, `"book_count":1`},
+		{"test browse category", http.MethodGet, fmt.Sprintf("/book/category/%d?includeDescendants=true", fiction.ID), "", bookHandler.GetBooksByCategory, http.StatusOK, "The Trial"},
+		{"test browse invalid flag", http.MethodGet, fmt.Sprintf("/book/category/%d?includeDescendants=maybe", fiction.ID), "", bookHandler.GetBooksByCategory, http.StatusBadRequest, "invalid includeDescendants"},
+		{"test browse unknown category", http.MethodGet, "/book/category/9999", "", bookHandler.GetBooksByCategory, http.StatusNotFound, service.ErrCategoryNotFound.Error()},
+		{"test rename category", http.MethodPatch, fmt.Sprintf("/category/update/%d", fiction.ID), `{"name":"Novels"}`, categoryHandler.UpdateCategory, http.StatusOK, "Category updated!"},
+		{"test delete category with children", http.MethodDelete, fmt.Sprintf("/category/delete/%d", fiction.ID), "", categoryHandler.DeleteCategory, http.StatusConflict, service.ErrCategoryHasChildren.Error()},
+		{"test delete unknown category", http.MethodDelete, "/category/delete/9999", "", categoryHandler.DeleteCategory, http.StatusNotFound, service.ErrCategoryNotFound.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(test.method, test.urlPath, strings.NewReader(test.reqBody)))
+			w := httptest.NewRecorder()
+
+			test.handle(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
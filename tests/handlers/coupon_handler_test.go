@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"BookVault-API/handler"
+	"BookVault-API/helper"
+	"BookVault-API/service"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+
+func TestCouponHandler(t *testing.T) {
+	testDB, _, cartService, orderHandler := initOrderTestHandler(t)
+	couponHandler := handler.NewCouponHandler(service.NewCouponService(testDB))
+	cartHandler := handler.NewCartHandler(cartService, service.NewExchangeRateService(testDB))
+
+	user := createTestOrderUser(t, testDB, "couponUser", "couponUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "The Gambler", "Dostoevsky", "30")
+	addBookToCart(t, cartService, user.ID, book.ID, 1)
+
+	coupon := `{"code":"save5","kind":"fixed","amount_off":"5","min_spend":20,"stackable":true}`
+
+	testCases := []struct {
+		name		string
+		method		string
+		urlPath		string
+		reqBody		string
+		handle		http.HandlerFunc
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test create coupon", http.MethodPost, "/coupon/create", coupon, couponHandler.CreateCoupon, http.StatusCreated, `"code":"SAVE5","kind":"fixed","amount_off":5.00`},
+		{"test create duplicate coupon", http.MethodPost, "/coupon/create", coupon, couponHandler.CreateCoupon, http.StatusConflict, service.ErrCouponExists.Error()},
+		{"test create invalid coupon", http.MethodPost, "/coupon/create", `{"code":"HALF","kind":"percent","percent_off":0}`, couponHandler.CreateCoupon, http.StatusBadRequest, service.ErrInvalidPercentOff.Error()},
+		{"test create coupon of unknown book", http.MethodPost, "/coupon/create", `{"code":"BOOK","kind":"percent","percent_off":10,"scope":"book","book_id":9999}`, couponHandler.CreateCoupon, http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test get coupon", http.MethodGet, "/coupon/1", "", couponHandler.GetCoupon, http.StatusOK, `"min_spend":20.00`},
+		{"test get missing coupon", http.MethodGet, "/coupon/9999", "", couponHandler.GetCoupon, http.StatusNotFound, service.ErrCouponNotFound.Error()},
+		{"test apply coupon", http.MethodPost, fmt.Sprintf("/cart/coupon/apply/%d?code=save5", user.ID), "", cartHandler.ApplyCoupon, http.StatusOK, "Coupon applied!"},
+		{"test apply coupon twice", http.MethodPost, fmt.Sprintf("/cart/coupon/apply/%d?code=SAVE5", user.ID), "", cartHandler.ApplyCoupon, http.StatusConflict, service.ErrCouponApplied.Error()},
+		{"test apply unknown coupon", http.MethodPost, fmt.Sprintf("/cart/coupon/apply/%d?code=NOPE", user.ID), "", cartHandler.ApplyCoupon, http.StatusNotFound, service.ErrCouponNotFound.Error()},
+		{"test apply without code", http.MethodPost, fmt.Sprintf("/cart/coupon/apply/%d", user.ID), "", cartHandler.ApplyCoupon, http.StatusBadRequest, "code query param is required"},
+		{"test cart totals", http.MethodGet, "/cart/1", "", cartHandler.GetCart, http.StatusOK, `"totals":{"subtotal":30.00,"discount":5.00,"total":25.00`},
+		{"test order with coupon", http.MethodPost, fmt.Sprintf("/order/create/%d?address=Addr", user.ID), "", orderHandler.CreateOrder, http.StatusCreated, `"subtotal":30.00,"discount":5.00,"total":25.00`},
+		{"test update coupon", http.MethodPut, "/coupon/update/1", `{"code":"SAVE5","kind":"fixed","amount_off":8}`, couponHandler.UpdateCoupon, http.StatusOK, "Coupon updated!"},
+		{"test list coupons", http.MethodGet, "/coupon/all", "", couponHandler.GetCoupons, http.StatusOK, `"scope":"order","uses":1`},
+		{"test remove coupon not in cart", http.MethodDelete, fmt.Sprintf("/cart/coupon/remove/%d?code=SAVE5", user.ID), "", cartHandler.RemoveCoupon, http.StatusNotFound, service.ErrCartCouponNotFound.Error()},
+		{"test delete coupon", http.MethodDelete, "/coupon/delete/1", "", couponHandler.DeleteCoupon, http.StatusOK, "Coupon deleted!"},
+		{"test delete missing coupon", http.MethodDelete, "/coupon/delete/1", "", couponHandler.DeleteCoupon, http.StatusNotFound, service.ErrCouponNotFound.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(test.method, test.urlPath, strings.NewReader(test.reqBody)))
+			w := httptest.NewRecorder()
+
+			test.handle(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
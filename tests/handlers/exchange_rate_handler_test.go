@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"BookVault-API/handler"
+	"BookVault-API/helper"
+	"BookVault-API/money"
+	"BookVault-API/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+
+func TestExchangeRateHandler(t *testing.T) {
+	testDB, bookService, bookHandler := initBookTestHandler(t)
+	rateHandler := handler.NewExchangeRateHandler(service.NewExchangeRateService(testDB))
+	settingsHandler := handler.NewSettingsHandler(service.NewSettingsService(testDB))
+	base := money.DefaultCurrency()
+
+	createTestBook(t, testDB, bookService, "The Idiot", "Dostoevsky", "20")
+
+	testCases := []struct {
+		name			string
+		method			string
+		urlPath			string
+		acceptCurrency	string
+		reqBody			string
+		handle			http.HandlerFunc
+		wantStatus		int
+		wantResp		string
+	}{
+		{"test set rate", http.MethodPut, "/rates/set", "", `{"quote":"usd","rate":"1.0850"}`, rateHandler.SetRate, http.StatusOK, `"rate":"1.085"`},
+		{"test set rate of same currency", http.MethodPut, "/rates/set", "", `{"base":"` + base + `","quote":"` + base + `","rate":1}`, rateHandler.SetRate, http.StatusBadRequest, service.ErrSameCurrency.Error()},
+		{"test set negative rate", http.MethodPut, "/rates/set", "", `{"quote":"JPY","rate":-160}`, rateHandler.SetRate, http.StatusBadRequest, service.ErrInvalidRate.Error()},
+		{"test import rates", http.MethodPost, "/rates/import", "", "base,quote,rate\n" + base + ",JPY,160\n", rateHandler.ImportRates, http.StatusOK, `"imported":1`},
+		{"test import invalid line", http.MethodPost, "/rates/import", "", "base,quote,rate\n" + base + ",GBP,cheap\n", rateHandler.ImportRates, http.StatusBadRequest, "line 2: " + service.ErrInvalidRate.Error()},
+		{"test import without header", http.MethodPost, "/rates/import", "", "", rateHandler.ImportRates, http.StatusBadRequest, service.ErrInvalidRatesHeader.Error()},
+		{"test list rates", http.MethodGet, "/rates", "", "", rateHandler.GetRates, http.StatusOK, `"quote":"USD","rate":"1.085"`},
+		{"test book in requested currency", http.MethodGet, "/book?title=The%20Idiot&currency=usd", "", "", bookHandler.GetByTitle, http.StatusOK, `"converted":{"price":21.70,"currency":"USD","rate":"1.085"`},
+		{"test book in accepted currency", http.MethodGet, "/book/all", "JPY, USD;q=0.5", "", bookHandler.GetBooks, http.StatusOK, `"converted":{"price":3200,"currency":"JPY"`},
+		{"test book without rate", http.MethodGet, "/book/all?currency=GBP", "", "", bookHandler.GetBooks, http.StatusBadRequest, service.ErrNoExchangeRate.Error()},
+		{"test invalid currency", http.MethodGet, "/book/all", "dollars", "", bookHandler.GetBooks, http.StatusBadRequest, helper.ErrInvalidCurrency.Error()},
+		{"test shorten rate age", http.MethodPatch, "/settings/rates/update", "", `{"max_age_hours":0}`, settingsHandler.UpdateRateSettings, http.StatusBadRequest, service.ErrInvalidRateMaxAge.Error()},
+		{"test rate settings", http.MethodPatch, "/settings/rates/update", "", `{"max_age_hours":12}`, settingsHandler.UpdateRateSettings, http.StatusOK, `"max_age_hours":12`},
+		{"test delete rate", http.MethodDelete, "/rates/delete/" + base + "/usd", "", "", rateHandler.DeleteRate, http.StatusOK, "Exchange rate deleted!"},
+		{"test delete missing rate", http.MethodDelete, "/rates/delete/" + base + "/USD", "", "", rateHandler.DeleteRate, http.StatusNotFound, service.ErrRateNotFound.Error()},
+		{"test delete with invalid path", http.MethodDelete, "/rates/delete/USD", "", "", rateHandler.DeleteRate, http.StatusBadRequest, helper.ErrInvalidPath.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(test.method, test.urlPath, strings.NewReader(test.reqBody)))
+			if test.acceptCurrency != "" {
+				req.Header.Set("Accept-Currency", test.acceptCurrency)
+			}
+			w := httptest.NewRecorder()
+
+			test.handle(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
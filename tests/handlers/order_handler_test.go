@@ -6,9 +6,11 @@ import (
 	"BookVault-API/model"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"gorm.io/gorm"
@@ -18,15 +20,15 @@ func initOrderTestHandler(t *testing.T) (*gorm.DB, service.OrderService, service
 	testDB := db.SetupTestDB(t)
 	cartService := service.NewCartService(testDB)
 	orderService := service.NewOrderService(testDB)
-	orderHandler := handler.NewOrderHandler(orderService)
+	orderHandler := handler.NewOrderHandler(orderService, service.NewExchangeRateService(testDB))
 
 	return testDB, orderService, cartService, orderHandler
 }
 
-func createTestOrderBook(t *testing.T, testDB *gorm.DB, title, author string, price float32) model.Book {
+func createTestOrderBook(t *testing.T, testDB *gorm.DB, title, author, price string) model.Book {
 	t.Helper()
 
-	book := model.Book{Title: title, Author: author, Description: "desc", Price: price}
+	book := model.Book{Title: title, Author: author, Description: "desc", Price: priceOf(price), Stock: 100}
 	if err := testDB.Create(&book).Error; err != nil {
 		t.Fatalf("failed to create book: %v", err)
 	}
@@ -57,24 +59,35 @@ func addBookToCart(t *testing.T, cartService service.CartService, userID, bookID
 func TestCreateOrderHandler(t *testing.T) {
 	testDB, _, cartService, orderHandler := initOrderTestHandler(t)
 	user := createTestOrderUser(t, testDB, "orderUser1", "orderUser1@gmail.com")
-	book := createTestOrderBook(t, testDB, "Book 1", "Author 1", 10)
+	book := createTestOrderBook(t, testDB, "Book 1", "Author 1", "10")
 
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
+	soldOutUser := createTestOrderUser(t, testDB, "orderUser1b", "orderUser1b@gmail.com")
+	soldOutBook := model.Book{Title: "Book 1b", Author: "Author 1", Description: "desc", Price: priceOf("10")}
+	if err := testDB.Create(&soldOutBook).Error; err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+
+	_ = cartService.AddToCart(soldOutUser.ID, soldOutBook.ID, 1)
+
 	testCases := []struct {
 		name       string
 		urlPath    string
 		wantStatus int
 		wantBody   string
 	}{
-		{"test create order success", fmt.Sprintf("/order/create/%d?address=Addr1", user.ID), http.StatusCreated, "Book 1"},
+		{"test invalid expected total", fmt.Sprintf("/order/create/%d?address=Addr1&expected_total=ten", user.ID), http.StatusBadRequest, "invalid expected_total"},
+		{"test stale expected total", fmt.Sprintf("/order/create/%d?address=Addr1&expected_total=9.99", user.ID), http.StatusConflict, service.ErrTotalChanged.Error()},
+		{"test create order success", fmt.Sprintf("/order/create/%d?address=Addr1&expected_total=10", user.ID), http.StatusCreated, "Book 1"},
+		{"test book out of stock", fmt.Sprintf("/order/create/%d?address=Addr1", soldOutUser.ID), http.StatusConflict, service.ErrInsufficientStock.Error()},
 		{"test empty cart", fmt.Sprintf("/order/create/%d?address=Addr1", 9999), http.StatusBadRequest, service.ErrEmptyCart.Error()},
 		{"test missing address", fmt.Sprintf("/order/create/%d", user.ID), http.StatusBadRequest, "address query param is required"},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodPost, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodPost, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			orderHandler.CreateOrder(w, req)
@@ -89,13 +102,11 @@ func TestCancelOrderHandler(t *testing.T) {
 	testDB, orderService, cartService, orderHandler := initOrderTestHandler(t)
 
 	user := createTestOrderUser(t, testDB, "orderUser2", "orderUser2@gmail.com")
-	book := createTestOrderBook(t, testDB, "Book 2", "Author 2", 15)
+	book := createTestOrderBook(t, testDB, "Book 2", "Author 2", "15")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	order, _ := orderService.CreateOrder(user.ID, "Addr2")
-
-	_ = orderService.UpdateStatus(order.ID, "pending") 
+	order, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr2", "", nil)
 
 	testCases := []struct {
 		name       string
@@ -104,12 +115,13 @@ func TestCancelOrderHandler(t *testing.T) {
 		wantBody   string
 	}{
 		{"test cancel order success", fmt.Sprintf("/order/cancel/%d", order.ID), http.StatusOK, "Order cancelled!"},
+		{"test cancel cancelled order", fmt.Sprintf("/order/cancel/%d", order.ID), http.StatusConflict, "order cannot move from cancelled to cancelled"},
 		{"test not found", "/order/cancel/9999", http.StatusNotFound, service.ErrOrderNotFound.Error()},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodPatch, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodPatch, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			orderHandler.CancelOrder(w, req)
@@ -124,11 +136,11 @@ func TestGetOrderHandler(t *testing.T) {
 	testDB, orderService, cartService, orderHandler := initOrderTestHandler(t)
 
 	user := createTestOrderUser(t, testDB, "orderUser3", "orderUser3@gmail.com")
-	book := createTestOrderBook(t, testDB, "Book 3", "Author 3", 20)
+	book := createTestOrderBook(t, testDB, "Book 3", "Author 3", "20")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	order, _ := orderService.CreateOrder(user.ID, "Addr3")
+	order, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr3", "", nil)
 
 	testCases := []struct {
 		name       string
@@ -137,12 +149,13 @@ func TestGetOrderHandler(t *testing.T) {
 		wantBody   string
 	}{
 		{"test get existing order", fmt.Sprintf("/order/%d", order.ID), http.StatusOK, "Book 3"},
+		{"test get order history", fmt.Sprintf("/order/%d", order.ID), http.StatusOK, `"history":[{"to":"pending"`},
 		{"test get non-existing order", "/order/9999", http.StatusNotFound, service.ErrOrderNotFound.Error()},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			orderHandler.GetOrder(w, req)
@@ -157,14 +170,14 @@ func TestGetUserOrdersHandler(t *testing.T) {
 	testDB, orderService, cartService, orderHandler := initOrderTestHandler(t)
 
 	user := createTestOrderUser(t, testDB, "orderUser4", "orderUser4@gmail.com")
-	book1 := createTestOrderBook(t, testDB, "Book 4A", "Author 4", 10)
-	book2 := createTestOrderBook(t, testDB, "Book 4B", "Author 4", 15)
+	book1 := createTestOrderBook(t, testDB, "Book 4A", "Author 4", "10")
+	book2 := createTestOrderBook(t, testDB, "Book 4B", "Author 4", "15")
 
 	addBookToCart(t, cartService, user.ID, book1.ID, 1)
 	addBookToCart(t, cartService, user.ID, book2.ID, 1)
 
-	_, _ = orderService.CreateOrder(user.ID, "Addr4")
-	_, _ = orderService.CreateOrder(user.ID, "Addr4")
+	_, _ = orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr4", "", nil)
+	_, _ = orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr4", "", nil)
 
 	testCases := []struct {
 		name       string
@@ -178,7 +191,7 @@ func TestGetUserOrdersHandler(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			orderHandler.GetUserOrders(w, req)
@@ -193,12 +206,13 @@ func TestGetOrdersByStatusHandler(t *testing.T) {
 	testDB, orderService, cartService, orderHandler := initOrderTestHandler(t)
 
 	user := createTestOrderUser(t, testDB, "orderUser5", "orderUser5@gmail.com")
-	book := createTestOrderBook(t, testDB, "Book 5", "Author 5", 25)
+	book := createTestOrderBook(t, testDB, "Book 5", "Author 5", "25")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	_, _ = orderService.CreateOrder(user.ID, "Addr5")
-	_ = orderService.UpdateStatus(1, "shipped")
+	order, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr5", "", nil)
+	_ = orderService.UpdateStatus(testAdmin, order.ID, "approved")
+	_ = orderService.UpdateStatus(testAdmin, order.ID, "shipped")
 
 	testCases := []struct {
 		name       string
@@ -207,13 +221,14 @@ func TestGetOrdersByStatusHandler(t *testing.T) {
 		wantBody   string
 	}{
 		{"test existing status", "/order/status?status=shipped", http.StatusOK, "Book 5"},
-		{"test non-existing status", "/order/status?status=cancelled", http.StatusOK, ""},
+		{"test status without orders", "/order/status?status=cancelled", http.StatusOK, ""},
+		{"test unknown status", "/order/status?status=completed", http.StatusBadRequest, service.ErrInvalidStatus.Error()},
 		{"test missing status param", "/order/status", http.StatusBadRequest, "status query param is required"},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			orderHandler.GetOrdersByStatus(w, req)
@@ -228,11 +243,11 @@ func TestUpdateStatusHandler(t *testing.T) {
 	testDB, orderService, cartService, orderHandler := initOrderTestHandler(t)
 
 	user := createTestOrderUser(t, testDB, "orderUser6", "orderUser6@gmail.com")
-	book := createTestOrderBook(t, testDB, "Book 6", "Author 6", 30)
+	book := createTestOrderBook(t, testDB, "Book 6", "Author 6", "30")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	order, _ := orderService.CreateOrder(user.ID, "Addr6")
+	order, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr6", "", nil)
 
 	testCases := []struct {
 		name       string
@@ -240,14 +255,16 @@ func TestUpdateStatusHandler(t *testing.T) {
 		wantStatus int
 		wantBody   string
 	}{
-		{"test update existing order", fmt.Sprintf("/order/update/%d?status=shipped", order.ID), http.StatusOK, "Order status updated!"},
+		{"test update existing order", fmt.Sprintf("/order/update/%d?status=approved", order.ID), http.StatusOK, "Order status updated!"},
+		{"test skip a step", fmt.Sprintf("/order/update/%d?status=delivered", order.ID), http.StatusConflict, "order cannot move from approved to delivered"},
+		{"test unknown status", fmt.Sprintf("/order/update/%d?status=completed", order.ID), http.StatusBadRequest, service.ErrInvalidStatus.Error()},
 		{"test update non-existing order", "/order/update/9999?status=shipped", http.StatusNotFound, service.ErrOrderNotFound.Error()},
 		{"test missing status param", fmt.Sprintf("/order/update/%d", order.ID), http.StatusBadRequest, "status query param is required"},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodPatch, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodPatch, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			orderHandler.UpdateStatus(w, req)
@@ -255,4 +272,122 @@ func TestUpdateStatusHandler(t *testing.T) {
 			helper.AssertResponse(t, w, test.wantStatus, test.wantBody)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestOrderHandlerActingUser(t *testing.T) {
+	testDB, _, cartService, orderHandler := initOrderTestHandler(t)
+
+	user := createTestOrderUser(t, testDB, "orderUser7", "orderUser7@gmail.com")
+	otherUser := createTestOrderUser(t, testDB, "orderUser8", "orderUser8@gmail.com")
+	book := createTestOrderBook(t, testDB, "Book 7", "Author 7", "10")
+
+	addBookToCart(t, cartService, user.ID, book.ID, 1)
+	addBookToCart(t, cartService, otherUser.ID, book.ID, 1)
+
+	testCases := []struct {
+		name       string
+		req        *http.Request
+		handle     func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   string
+	}{
+		{"test create order for another user", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/order/create/%d?address=Addr7", otherUser.ID), nil), user.ID), orderHandler.CreateOrder, http.StatusForbidden, "forbidden"},
+		{"test create own order", asUser(httptest.NewRequest(http.MethodPost, "/order/create?address=Addr7", nil), user.ID), orderHandler.CreateOrder, http.StatusCreated, "Book 7"},
+		{"test list another user's orders", asUser(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/order/user/%d", otherUser.ID), nil), user.ID), orderHandler.GetUserOrders, http.StatusForbidden, "forbidden"},
+		{"test list own orders", asUser(httptest.NewRequest(http.MethodGet, "/order/user", nil), user.ID), orderHandler.GetUserOrders, http.StatusOK, "Addr7"},
+		{"test admin creates order for user", asAdmin(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/order/create/%d?address=Addr8", otherUser.ID), nil)), orderHandler.CreateOrder, http.StatusCreated, "Addr8"},
+		{"test admin lists every order by status", asAdmin(httptest.NewRequest(http.MethodGet, "/order/status/?status=pending", nil)), orderHandler.GetOrdersByStatus, http.StatusOK, `"total":2`},
+		{"test user lists own orders by status", asUser(httptest.NewRequest(http.MethodGet, "/order/status/?status=pending", nil), user.ID), orderHandler.GetOrdersByStatus, http.StatusOK, `"total":1`},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			test.handle(w, test.req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantBody)
+		})
+	}
+
+	t.Run("test status list leaves out another user's orders", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		orderHandler.GetOrdersByStatus(w, asUser(httptest.NewRequest(http.MethodGet, "/order/status/?status=pending", nil), user.ID))
+
+		helper.AssertResponse(t, w, http.StatusOK, "Addr7")
+		if strings.Contains(w.Body.String(), "Addr8") {
+			t.Errorf("expected another user's order to be left out, got %s", w.Body.String())
+		}
+	})
+}
+
+
+func TestOrderHandlerOwnership(t *testing.T) {
+	testDB, orderService, cartService, orderHandler := initOrderTestHandler(t)
+
+	owner := createTestOrderUser(t, testDB, "orderUser9", "orderUser9@gmail.com")
+	otherUser := createTestOrderUser(t, testDB, "orderUser10", "orderUser10@gmail.com")
+	book := createTestOrderBook(t, testDB, "Book 9", "Author 9", "10")
+
+	addBookToCart(t, cartService, owner.ID, book.ID, 1)
+
+	order, err := orderService.CreateOrder(principalOf(owner.ID), owner.ID, "Addr9", "", nil)
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		req        *http.Request
+		handle     func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   string
+	}{
+		{"test get another user's order", asUser(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/order/%d", order.ID), nil), otherUser.ID), orderHandler.GetOrder, http.StatusForbidden, service.ErrForbidden.Error()},
+		{"test cancel another user's order", asUser(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/order/cancel/%d", order.ID), nil), otherUser.ID), orderHandler.CancelOrder, http.StatusForbidden, service.ErrForbidden.Error()},
+		{"test get own order", asUser(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/order/%d", order.ID), nil), owner.ID), orderHandler.GetOrder, http.StatusOK, "Book 9"},
+		{"test cancel own order", asUser(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/order/cancel/%d", order.ID), nil), owner.ID), orderHandler.CancelOrder, http.StatusOK, "Order cancelled!"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			test.handle(w, test.req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantBody)
+		})
+	}
+}
+
+
+func TestCreateOrderHandlerFailure(t *testing.T) {
+	testDB, _, cartService, orderHandler := initOrderTestHandler(t)
+	user := createTestOrderUser(t, testDB, "orderUser7", "orderUser7@gmail.com")
+	book := createTestOrderBook(t, testDB, "Book 7", "Author 7", "10")
+
+	_ = cartService.AddToCart(user.ID, book.ID, 1)
+
+	err := testDB.Callback().Create().Before("gorm:create").Register("test:fail_order_insert", func(tx *gorm.DB) {
+		if tx.Statement.Table == "orders" {
+			tx.AddError(errors.New("injected failure"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	req := asAdmin(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/order/create/%d?address=Addr7", user.ID), nil))
+	w := httptest.NewRecorder()
+
+	orderHandler.CreateOrder(w, req)
+
+	helper.AssertResponse(t, w, http.StatusInternalServerError, "internal server error")
+
+	var orders int64
+	testDB.Model(&model.Order{}).Where("user_id = ?", user.ID).Count(&orders)
+	if orders != 0 {
+		t.Errorf("expected no order to be persisted, got %d", orders)
+	}
+}
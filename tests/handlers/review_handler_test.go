@@ -26,10 +26,10 @@ func initReviewTestHandler(t *testing.T) (*gorm.DB, service.ReviewService, servi
 	return testDB, reviewService, userService, bookService, reviewHandler
 }
 
-func createTestReviewBook(t *testing.T, testDB *gorm.DB, title, author string, price float32) model.Book {
+func createTestReviewBook(t *testing.T, testDB *gorm.DB, title, author, price string) model.Book {
 	t.Helper()
 
-	book := model.Book{Title: title, Author: author, Description: "desc", Price: price}
+	book := model.Book{Title: title, Author: author, Description: "desc", Price: priceOf(price)}
 	if err := testDB.Create(&book).Error; err != nil {
 		t.Fatalf("failed to create book: %v", err)
 	}
@@ -52,7 +52,7 @@ func createTestReviewUser(t *testing.T, testDB *gorm.DB, username, email string)
 func TestAddReviewHandler(t *testing.T) {
 	testDB, _, _, _, reviewHandler := initReviewTestHandler(t)
 
-	user := createTestReviewBook(t, testDB, "The Humilated and Insulted", "Dostoevsky", 20.00)
+	user := createTestReviewBook(t, testDB, "The Humilated and Insulted", "Dostoevsky", "20.00")
 	book := createTestReviewUser(t, testDB, "testReviewUser1", "testReviewUser1@gmail.com")
 
 	testCases := []struct {
@@ -63,15 +63,17 @@ func TestAddReviewHandler(t *testing.T) {
 		wantResp	string
 	}{
 		{"test empty text", fmt.Sprintf("/review/add/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: ""}, http.StatusBadRequest, service.ErrEmptyReview.Error()},
-		{"test book not found", fmt.Sprintf("/review/add/%d/%d", user.ID, 9999), model.ReviewRequest{Text: "Great book!"}, http.StatusNotFound, service.ErrBookNotFound.Error()},
-		{"test user not found", fmt.Sprintf("/review/add/%d/%d", 9999, book.ID), model.ReviewRequest{Text: "Great book!"}, http.StatusNotFound, service.ErrUserNotFound.Error()},
-		{"test review added successfully", fmt.Sprintf("/review/add/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Great book!"}, http.StatusCreated, "Review added!"},
+		{"test invalid rating", fmt.Sprintf("/review/add/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Great book!", Rating: 0}, http.StatusBadRequest, service.ErrInvalidRating.Error()},
+		{"test book not found", fmt.Sprintf("/review/add/%d/%d", user.ID, 9999), model.ReviewRequest{Text: "Great book!", Rating: 4}, http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test user not found", fmt.Sprintf("/review/add/%d/%d", 9999, book.ID), model.ReviewRequest{Text: "Great book!", Rating: 4}, http.StatusNotFound, service.ErrUserNotFound.Error()},
+		{"test review added successfully", fmt.Sprintf("/review/add/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Great book!", Rating: 4}, http.StatusCreated, "Review added!"},
+		{"test review already exists", fmt.Sprintf("/review/add/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Still great!", Rating: 5}, http.StatusConflict, service.ErrReviewExists.Error()},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
 			body, _ := json.Marshal(test.reqBody)
-			req := httptest.NewRequest(http.MethodPost, test.urlPath, bytes.NewReader(body))
+			req := asAdmin(httptest.NewRequest(http.MethodPost, test.urlPath, bytes.NewReader(body)))
 			w := httptest.NewRecorder()
 
 			reviewHandler.AddReview(w, req)
@@ -85,10 +87,10 @@ func TestAddReviewHandler(t *testing.T) {
 func TestGetReviewsByBookHandler(t *testing.T) {
 	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
 
-	user := createTestReviewBook(t, testDB, "The Cousine Bette", "Balzac", 20.00)
+	user := createTestReviewBook(t, testDB, "The Cousine Bette", "Balzac", "20.00")
 	book := createTestReviewUser(t, testDB, "testReviewUser2", "testReviewUser2@gmail.com")
 
-	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Excellent book!"})
+	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Excellent book!", Rating: 4})
 	if err != nil {
 		t.Fatalf("failed to add book review: %v", err)
 	}
@@ -100,13 +102,15 @@ func TestGetReviewsByBookHandler(t *testing.T) {
 		wantResp	string
 	}{
 		{"test valid book with reviews", fmt.Sprintf("/review/get/%d", book.ID), http.StatusOK, "Excellent book!"},
-		{"test book with no reviews", "/review/get/9999", http.StatusOK, "[]"},
+		{"test book with no reviews", "/review/get/9999", http.StatusOK, `"items":[]`},
+		{"test verified reviews only", fmt.Sprintf("/review/get/%d?verified=true", book.ID), http.StatusOK, `"items":[]`},
+		{"test invalid verified", fmt.Sprintf("/review/get/%d?verified=maybe", book.ID), http.StatusBadRequest, "invalid verified"},
 		{"test invalid id in path", "/review/get/invalidId", http.StatusBadRequest, helper.ErrInvalidId.Error()},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			reviewHandler.GetReviewsByBook(w, req)
@@ -121,9 +125,9 @@ func TestGetReviewsByUserHandler(t *testing.T) {
 	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
 
 	user := createTestReviewUser(t, testDB, "testUser", "testUser@gmail.com")
-	book := createTestReviewBook(t, testDB, "The Idiot", "Dostoevsky", 15.00)
+	book := createTestReviewBook(t, testDB, "The Idiot", "Dostoevsky", "15.00")
 
-	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Very deep and psychological"})
+	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Very deep and psychological", Rating: 4})
 	if err != nil {
 		t.Fatalf("failed to add user review: %v", err)
 	}
@@ -141,7 +145,7 @@ func TestGetReviewsByUserHandler(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			reviewHandler.GetReviewsByUser(w, req)
@@ -156,9 +160,9 @@ func TestUpdateReviewHandler(t *testing.T) {
 	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
 
 	user := createTestReviewUser(t, testDB, "testUser", "testUser@gmail.com")
-	book := createTestReviewBook(t, testDB, "Demons", "Dostoevsky", 15.00)
+	book := createTestReviewBook(t, testDB, "Demons", "Dostoevsky", "15.00")
 
-	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Very deep and psychological"})
+	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Very deep and psychological", Rating: 4})
 	if err != nil {
 		t.Fatalf("failed to add user review: %v", err)
 	}
@@ -178,7 +182,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
 			body, _ := json.Marshal(test.reviewRequest)
-			req := httptest.NewRequest(http.MethodPatch, test.urlPath, bytes.NewReader(body))
+			req := asAdmin(httptest.NewRequest(http.MethodPatch, test.urlPath, bytes.NewReader(body)))
 			w := httptest.NewRecorder()
 
 			reviewHandler.UpdateReview(w, req)
@@ -189,13 +193,47 @@ func TestUpdateReviewHandler(t *testing.T) {
 }
 
 
+func TestSetReviewHandler(t *testing.T) {
+	testDB, _, _, _, reviewHandler := initReviewTestHandler(t)
+
+	user := createTestReviewUser(t, testDB, "testSetReviewUser", "testSetReviewUser@gmail.com")
+	book := createTestReviewBook(t, testDB, "The Gambler", "Dostoevsky", "11.00")
+
+	testCases := []struct {
+		name			string
+		urlPath			string
+		reviewRequest	model.ReviewRequest
+		wantStatus		int
+		wantResp		string
+	}{
+		{"test invalid rating", fmt.Sprintf("/review/set/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Short and sharp"}, http.StatusBadRequest, service.ErrInvalidRating.Error()},
+		{"test book not found", fmt.Sprintf("/review/set/%d/%d", user.ID, 9999), model.ReviewRequest{Text: "Short and sharp", Rating: 4}, http.StatusNotFound, service.ErrBookNotFound.Error()},
+		{"test review created", fmt.Sprintf("/review/set/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Short and sharp", Rating: 4}, http.StatusCreated, "Review added!"},
+		{"test review replaced", fmt.Sprintf("/review/set/%d/%d", user.ID, book.ID), model.ReviewRequest{Text: "Better on a second read", Rating: 5}, http.StatusOK, "Review updated!"},
+		{"test invalid id in path", "/review/set/9999/invalidId", model.ReviewRequest{Text: "Short and sharp", Rating: 4}, http.StatusBadRequest, helper.ErrInvalidId.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(test.reviewRequest)
+			req := asAdmin(httptest.NewRequest(http.MethodPut, test.urlPath, bytes.NewReader(body)))
+			w := httptest.NewRecorder()
+
+			reviewHandler.SetReview(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
+
+
 func TestDeleteReviewByIDHandler(t *testing.T){
 	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
 
 	user := createTestReviewUser(t, testDB, "testUser", "testUser@gmail.com")
-	book := createTestReviewBook(t, testDB, "Ana Karenina", "Tolstoy", 15.00)
+	book := createTestReviewBook(t, testDB, "Ana Karenina", "Tolstoy", "15.00")
 
-	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "The best book by Tolstoy"})
+	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "The best book by Tolstoy", Rating: 4})
 	if err != nil {
 		t.Fatalf("failed to add user review: %v", err)
 	}
@@ -218,7 +256,7 @@ func TestDeleteReviewByIDHandler(t *testing.T){
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodDelete, test.urlPath, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodDelete, test.urlPath, nil))
 			w := httptest.NewRecorder()
 
 			reviewHandler.DeleteReviewByID(w, req)
@@ -226,4 +264,172 @@ func TestDeleteReviewByIDHandler(t *testing.T){
 			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestReviewHandlerActingUser(t *testing.T) {
+	testDB, _, _, _, reviewHandler := initReviewTestHandler(t)
+
+	user := createTestReviewUser(t, testDB, "testReviewUser3", "testReviewUser3@gmail.com")
+	otherUser := createTestReviewUser(t, testDB, "testReviewUser4", "testReviewUser4@gmail.com")
+	book := createTestReviewBook(t, testDB, "War and Peace", "Tolstoy", "25.00")
+
+	testCases := []struct {
+		name		string
+		urlPath		string
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test review as another user", fmt.Sprintf("/review/add/%d/%d", otherUser.ID, book.ID), http.StatusForbidden, "forbidden"},
+		{"test review as self", fmt.Sprintf("/review/add/%d", book.ID), http.StatusCreated, "Review added!"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(model.ReviewRequest{Text: "Long but worth it", Rating: 4})
+			req := asUser(httptest.NewRequest(http.MethodPost, test.urlPath, bytes.NewReader(body)), user.ID)
+			w := httptest.NewRecorder()
+
+			reviewHandler.AddReview(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+
+	var review model.Review
+	if err := testDB.Where("book_id = ?", book.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+	if review.UserID != user.ID {
+		t.Errorf("expected review by user %d, got %d", user.ID, review.UserID)
+	}
+}
+
+
+func TestDeleteReviewByIDHandlerOwnership(t *testing.T){
+	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
+
+	author := createTestReviewUser(t, testDB, "testReviewUser5", "testReviewUser5@gmail.com")
+	otherUser := createTestReviewUser(t, testDB, "testReviewUser6", "testReviewUser6@gmail.com")
+	book := createTestReviewBook(t, testDB, "Resurrection", "Tolstoy", "15.00")
+
+	if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: "Underrated", Rating: 4}); err != nil {
+		t.Fatalf("failed to add user review: %v", err)
+	}
+
+	var review model.Review
+	if err := testDB.Where("user_id = ? AND book_id = ?", author.ID, book.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+
+	testCases := []struct{
+		name		string
+		userID		uint
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test delete another user's review", otherUser.ID, http.StatusForbidden, service.ErrForbidden.Error()},
+		{"test delete own review", author.ID, http.StatusOK, "Review deleted!"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asUser(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/review/delete/%d", review.ID), nil), test.userID)
+			w := httptest.NewRecorder()
+
+			reviewHandler.DeleteReviewByID(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
+
+
+func TestReviewModerationHandler(t *testing.T) {
+	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
+
+	author := createTestReviewUser(t, testDB, "testModeratedAuthor", "testModeratedAuthor@gmail.com")
+	reporter := createTestReviewUser(t, testDB, "testModerationReporter", "testModerationReporter@gmail.com")
+	book := createTestReviewBook(t, testDB, "Poor Folk", "Dostoevsky", "8.00")
+
+	if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: "A debut worth reading", Rating: 4}); err != nil {
+		t.Fatalf("failed to add review: %v", err)
+	}
+
+	var review model.Review
+	if err := testDB.Where("user_id = ?", author.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+
+	testCases := []struct {
+		name		string
+		req			*http.Request
+		handle		http.HandlerFunc
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test approve approved review", asAdmin(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/review/approve/%d", review.ID), nil)), reviewHandler.ApproveReview, http.StatusConflict, service.ErrReviewAlreadyModerated.Error()},
+		{"test report own review", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/review/report/%d", review.ID), nil), author.ID), reviewHandler.ReportReview, http.StatusBadRequest, service.ErrReportOwnReview.Error()},
+		{"test report review", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/review/report/%d", review.ID), bytes.NewReader([]byte(`{"reason":"Spam"}`))), reporter.ID), reviewHandler.ReportReview, http.StatusCreated, "Review reported!"},
+		{"test report review twice", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/review/report/%d", review.ID), nil), reporter.ID), reviewHandler.ReportReview, http.StatusConflict, service.ErrReviewAlreadyReported.Error()},
+		{"test reject without reason", asAdmin(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/review/reject/%d", review.ID), nil)), reviewHandler.RejectReview, http.StatusBadRequest, service.ErrRejectReasonRequired.Error()},
+		{"test reject review", asAdmin(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/review/reject/%d", review.ID), bytes.NewReader([]byte(`{"reason":"Spam"}`)))), reviewHandler.RejectReview, http.StatusOK, "Review rejected!"},
+		{"test rejected review is hidden", asAdmin(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/review/get/%d", book.ID), nil)), reviewHandler.GetReviewsByBook, http.StatusOK, `"items":[]`},
+		{"test empty moderation queue", asAdmin(httptest.NewRequest(http.MethodGet, "/review/queue", nil)), reviewHandler.GetModerationQueue, http.StatusOK, `"items":[]`},
+		{"test approve review not found", asAdmin(httptest.NewRequest(http.MethodPatch, "/review/approve/9999", nil)), reviewHandler.ApproveReview, http.StatusNotFound, service.ErrReviewNotFound.Error()},
+		{"test approve rejected review", asAdmin(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/review/approve/%d", review.ID), nil)), reviewHandler.ApproveReview, http.StatusOK, "Review approved!"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			test.handle(w, test.req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
+
+
+func TestReviewVoteHandler(t *testing.T) {
+	testDB, reviewService, _, _, reviewHandler := initReviewTestHandler(t)
+
+	author := createTestReviewUser(t, testDB, "testVoteAuthor", "testVoteAuthor@gmail.com")
+	voter := createTestReviewUser(t, testDB, "testVoter", "testVoter@gmail.com")
+	book := createTestReviewBook(t, testDB, "The Landlady", "Dostoevsky", "6.00")
+
+	if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: "Strange and haunting", Rating: 3}); err != nil {
+		t.Fatalf("failed to add review: %v", err)
+	}
+
+	var review model.Review
+	if err := testDB.Where("user_id = ?", author.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+
+	testCases := []struct {
+		name		string
+		req			*http.Request
+		handle		http.HandlerFunc
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test missing helpful", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/review/vote/%d", review.ID), nil), voter.ID), reviewHandler.VoteReview, http.StatusBadRequest, "invalid helpful"},
+		{"test vote own review", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/review/vote/%d?helpful=true", review.ID), nil), author.ID), reviewHandler.VoteReview, http.StatusBadRequest, service.ErrVoteOwnReview.Error()},
+		{"test vote review not found", asUser(httptest.NewRequest(http.MethodPost, "/review/vote/9999?helpful=true", nil), voter.ID), reviewHandler.VoteReview, http.StatusNotFound, service.ErrReviewNotFound.Error()},
+		{"test vote", asUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/review/vote/%d?helpful=true", review.ID), nil), voter.ID), reviewHandler.VoteReview, http.StatusOK, "Vote recorded!"},
+		{"test vote counts", asUser(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/review/get/%d?sort=helpful", book.ID), nil), voter.ID), reviewHandler.GetReviewsByBook, http.StatusOK, `"helpful_votes":1`},
+		{"test retract vote", asUser(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/review/unvote/%d", review.ID), nil), voter.ID), reviewHandler.RetractVote, http.StatusOK, "Vote retracted!"},
+		{"test retract missing vote", asUser(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/review/unvote/%d", review.ID), nil), voter.ID), reviewHandler.RetractVote, http.StatusNotFound, service.ErrVoteNotFound.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			test.handle(w, test.req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
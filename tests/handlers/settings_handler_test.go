@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"BookVault-API/handler"
+	"BookVault-API/helper"
+	"BookVault-API/service"
+	"BookVault-API/tests/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+
+func TestReviewSettingsHandler(t *testing.T) {
+	settingsHandler := handler.NewSettingsHandler(service.NewSettingsService(db.SetupTestDB(t)))
+
+	testCases := []struct {
+		name		string
+		method		string
+		urlPath		string
+		reqBody		string
+		wantStatus	int
+		wantResp	string
+	}{
+		{"test default settings", http.MethodGet, "/settings/reviews", "", http.StatusOK, `"allow_unverified":true`},
+		{"test invalid body", http.MethodPatch, "/settings/reviews/update", "{", http.StatusBadRequest, "invalid request body"},
+		{"test disallow unverified reviews", http.MethodPatch, "/settings/reviews/update", `{"allow_unverified":false}`, http.StatusOK, `"allow_unverified":false`},
+		{"test updated settings", http.MethodGet, "/settings/reviews", "", http.StatusOK, `"allow_unverified":false`},
+		{"test invalid auto approve policy", http.MethodPatch, "/settings/reviews/update", `{"auto_approve":"sometimes"}`, http.StatusBadRequest, service.ErrInvalidAutoApprove.Error()},
+		{"test report threshold", http.MethodPatch, "/settings/reviews/update", `{"report_threshold":5}`, http.StatusOK, `"report_threshold":5`},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(test.method, test.urlPath, strings.NewReader(test.reqBody)))
+			w := httptest.NewRecorder()
+
+			if test.method == http.MethodGet {
+				settingsHandler.GetReviewSettings(w, req)
+			} else {
+				settingsHandler.UpdateReviewSettings(w, req)
+			}
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantResp)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"BookVault-API/handler"
 	"BookVault-API/helper"
+	"BookVault-API/jwt"
+	"BookVault-API/middleware"
 	"BookVault-API/model"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
@@ -123,7 +125,7 @@ func TestCreateDetailsHandler(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			body, _ := json.Marshal(test.reqBody)
 			url := "/user/createDetails/" + strconv.Itoa(int(test.userID))
-			req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			req := asAdmin(httptest.NewRequest(http.MethodPost, url, bytes.NewReader(body)))
 			w := httptest.NewRecorder()
 
 			userHandler.CreateDetails(w, req)
@@ -157,7 +159,7 @@ func TestGetUserByIDHandler(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
 			url := "/user/getById/" + strconv.Itoa(int(test.userID))
-			req := httptest.NewRequest(http.MethodGet, url, nil)
+			req := asAdmin(httptest.NewRequest(http.MethodGet, url, nil))
 			w := httptest.NewRecorder()
 
 			userHandler.GetUserByID(w, req)
@@ -165,4 +167,168 @@ func TestGetUserByIDHandler(t *testing.T) {
 			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestGetUserByIDHandlerActingUser(t *testing.T) {
+	testDB, userService, userHandler := initUserTestHandler(t)
+
+	user := createTestUser(t, testDB, userService, "validUser3003", "validUser3003@gmail.com")
+	otherUser := createTestUser(t, testDB, userService, "validUser4004", "validUser4004@gmail.com")
+
+	testCases := []struct{
+		name 			string
+		url				string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test own details without user id", "/user/getById", http.StatusOK, user.Username},
+		{"test own details with user id", "/user/getById/" + strconv.Itoa(int(user.ID)), http.StatusOK, user.Username},
+		{"test another user's details", "/user/getById/" + strconv.Itoa(int(otherUser.ID)), http.StatusForbidden, "forbidden"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asUser(httptest.NewRequest(http.MethodGet, test.url, nil), user.ID)
+			w := httptest.NewRecorder()
+
+			userHandler.GetUserByID(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
+func TestRefreshHandler(t *testing.T) {
+	testDB, userService, userHandler := initUserTestHandler(t)
+
+	createTestUser(t, testDB, userService, "refreshUser", "refreshUser@gmail.com")
+
+	login, err := userService.Login(&model.LoginRequest{Username: "refreshUser", Password: "1234"})
+	if err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	testCases := []struct{
+		name			string
+		reqBody			model.RefreshRequest
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test empty refresh token", model.RefreshRequest{}, http.StatusBadRequest, service.ErrEmptyFields.Error()},
+		{"test valid refresh", model.RefreshRequest{RefreshToken: login.RefreshToken}, http.StatusOK, `"refresh_token"`},
+		{"test reused refresh token", model.RefreshRequest{RefreshToken: login.RefreshToken}, http.StatusUnauthorized, service.ErrRefreshTokenReused.Error()},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(test.reqBody)
+			req := httptest.NewRequest(http.MethodPost, "/user/refresh", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			userHandler.Refresh(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+}
+
+
+func TestLogoutHandler(t *testing.T) {
+	testDB, userService, userHandler := initUserTestHandler(t)
+
+	user := createTestUser(t, testDB, userService, "logoutUser", "logoutUser@gmail.com")
+
+	login, err := userService.Login(&model.LoginRequest{Username: "logoutUser", Password: "1234"})
+	if err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	body, _ := json.Marshal(model.RefreshRequest{RefreshToken: login.RefreshToken})
+	req := asUser(httptest.NewRequest(http.MethodPost, "/user/logout", bytes.NewReader(body)), user.ID)
+	w := httptest.NewRecorder()
+
+	userHandler.Logout(w, req)
+
+	helper.AssertResponse(t, w, http.StatusOK, "Logged out!")
+
+	if _, err := userService.Refresh(login.RefreshToken); err != service.ErrRefreshTokenReused {
+		t.Errorf("expected %v after logout, got %v", service.ErrRefreshTokenReused, err)
+	}
+}
+
+
+func TestDeleteUserHandler(t *testing.T) {
+	testDB, userService, userHandler := initUserTestHandler(t)
+
+	user := createTestUser(t, testDB, userService, "deleteUser", "deleteUser@gmail.com")
+
+	login, err := userService.Login(&model.LoginRequest{Username: "deleteUser", Password: "1234"})
+	if err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	testCases := []struct{
+		name 			string
+		url				string
+		wantStatus		int
+		wantRespBody	string
+	}{
+		{"test delete user", "/user/delete/" + strconv.Itoa(int(user.ID)), http.StatusOK, "User deleted!"},
+		{"test delete deleted user", "/user/delete/" + strconv.Itoa(int(user.ID)), http.StatusNotFound, service.ErrUserNotFound.Error()},
+		{"test invalid user id", "/user/delete/abc", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			req := asAdmin(httptest.NewRequest(http.MethodDelete, test.url, nil))
+			w := httptest.NewRecorder()
+
+			userHandler.DeleteUser(w, req)
+
+			helper.AssertResponse(t, w, test.wantStatus, test.wantRespBody)
+		})
+	}
+
+	body, _ := json.Marshal(model.RefreshRequest{RefreshToken: login.RefreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/user/refresh", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	userHandler.Refresh(w, req)
+
+	helper.AssertResponse(t, w, http.StatusUnauthorized, service.ErrInvalidRefreshToken.Error())
+}
+
+
+func TestDeletedUserAccessToken(t *testing.T) {
+	testDB, userService, userHandler := initUserTestHandler(t)
+
+	jwt.SetRevocationChecker(userService.IsTokenRevoked)
+	t.Cleanup(func() { jwt.SetRevocationChecker(nil) })
+
+	user := createTestUser(t, testDB, userService, "deletedAccessUser", "deletedAccessUser@gmail.com")
+
+	login, err := userService.Login(&model.LoginRequest{Username: "deletedAccessUser", Password: "1234"})
+	if err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	getUser := middleware.AuthMiddleware("admin", "user")(userHandler.GetUserByID)
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/user/getById", nil)
+		req.Header.Set("Authorization", "Bearer " + login.Token)
+		w := httptest.NewRecorder()
+
+		getUser(w, req)
+		return w
+	}
+
+	helper.AssertResponse(t, request(), http.StatusOK, user.Username)
+
+	if err := userService.DeleteUser(user.ID); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+
+	helper.AssertResponse(t, request(), http.StatusUnauthorized, "invalid token")
+}
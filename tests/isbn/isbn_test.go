@@ -0,0 +1,67 @@
+package isbn
+
+import (
+	"BookVault-API/isbn"
+	"errors"
+	"testing"
+)
+
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		name	string
+		input	string
+		want	string
+		wantErr	error
+	}{
+		{"test bare isbn-13", "9780306406157", "9780306406157", nil},
+		{"test hyphenated isbn-13", "978-0-306-40615-7", "9780306406157", nil},
+		{"test isbn-10 converted", "0-306-40615-2", "9780306406157", nil},
+		{"test isbn-10 with x check digit", "0-8044-2957-x", "9780804429573", nil},
+		{"test 979 isbn-13", "979-10-90636-07-1", "9791090636071", nil},
+		{"test spaces", " 978 0 306 40615 7 ", "9780306406157", nil},
+		{"test wrong isbn-13 check digit", "9780306406158", "", isbn.ErrInvalidISBN},
+		{"test wrong isbn-10 check digit", "0306406153", "", isbn.ErrInvalidISBN},
+		{"test unknown isbn-13 prefix", "9770306406150", "", isbn.ErrInvalidISBN},
+		{"test x inside isbn-10", "03064X6152", "", isbn.ErrInvalidISBN},
+		{"test letters", "978030640615A", "", isbn.ErrInvalidISBN},
+		{"test too short", "12345", "", isbn.ErrInvalidISBN},
+		{"test empty", "", "", isbn.ErrInvalidISBN},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := isbn.Normalize(test.input)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+
+func TestToISBN10(t *testing.T) {
+	testCases := []struct {
+		name	string
+		isbn13	string
+		want	string
+		wantOK	bool
+	}{
+		{"test 978 isbn", "9780306406157", "0306406152", true},
+		{"test x check digit", "9780804429573", "080442957X", true},
+		{"test 979 isbn has no isbn-10", "9791090636071", "", false},
+		{"test invalid isbn", "9780306406158", "", false},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := isbn.ToISBN10(test.isbn13)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("expected %q, %v, got %q, %v", test.want, test.wantOK, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package money
+
+import (
+	"BookVault-API/money"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name		string
+		input		string
+		currency	string
+		want		money.Money
+		wantErr		error
+	}{
+		{"test whole amount", "20", "EUR", money.New(2000, "EUR"), nil},
+		{"test cents", "12.5", "EUR", money.New(1250, "EUR"), nil},
+		{"test half rounds up", "0.125", "EUR", money.New(13, "EUR"), nil},
+		{"test below half rounds down", "0.1249", "EUR", money.New(12, "EUR"), nil},
+		{"test negative half rounds away from zero", "-0.125", "EUR", money.New(-13, "EUR"), nil},
+		{"test no minor unit", "1500.5", "JPY", money.New(1501, "JPY"), nil},
+		{"test three decimals", "1.2345", "KWD", money.New(1235, "KWD"), nil},
+		{"test float notation", "1e3", "EUR", money.Money{}, money.ErrInvalidAmount},
+		{"test letters", "cheap", "EUR", money.Money{}, money.ErrInvalidAmount},
+		{"test too large", "100000000000000000", "EUR", money.Money{}, money.ErrOverflow},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := money.Parse(test.input, test.currency)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if got != test.want {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+
+func TestString(t *testing.T) {
+	testCases := []struct {
+		name	string
+		amount	money.Money
+		want	string
+	}{
+		{"test cents", money.New(1250, "EUR"), "12.50"},
+		{"test below one", money.New(5, "USD"), "0.05"},
+		{"test zero", money.New(0, "EUR"), "0.00"},
+		{"test negative", money.New(-1999, "EUR"), "-19.99"},
+		{"test no minor unit", money.New(1500, "JPY"), "1500"},
+		{"test three decimals", money.New(1005, "BHD"), "1.005"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.amount.String(); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+
+func TestArithmetic(t *testing.T) {
+	t.Run("test float drift", func(t *testing.T) {
+		total := money.New(0, "EUR")
+		for i := 0; i < 1000; i++ {
+			var err error
+			if total, err = total.Add(money.New(10, "EUR")); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if total.String() != "100.00" {
+			t.Errorf("expected 100.00, got %s", total)
+		}
+	})
+
+	t.Run("test multiply", func(t *testing.T) {
+		line, err := money.New(1999, "EUR").Mul(7)
+		if err != nil || line != money.New(13993, "EUR") {
+			t.Errorf("expected 139.93 EUR, got %s %s, %v", line, line.Currency, err)
+		}
+	})
+
+	t.Run("test currency mismatch", func(t *testing.T) {
+		_, err := money.New(100, "EUR").Add(money.New(100, "USD"))
+		if !errors.Is(err, money.ErrCurrencyMismatch) {
+			t.Errorf("expected %v, got %v", money.ErrCurrencyMismatch, err)
+		}
+	})
+
+	t.Run("test overflow", func(t *testing.T) {
+		_, err := money.New(1<<52, "EUR").Mul(4)
+		if !errors.Is(err, money.ErrOverflow) {
+			t.Errorf("expected %v, got %v", money.ErrOverflow, err)
+		}
+	})
+}
+
+
+func TestJSON(t *testing.T) {
+	t.Run("test amount is a decimal number", func(t *testing.T) {
+		out, err := json.Marshal(map[string]money.Money{"price": money.New(1250, "EUR")})
+		if err != nil || string(out) != `{"price":12.50}` {
+			t.Errorf("expected {\"price\":12.50}, got %s, %v", out, err)
+		}
+	})
+
+	testCases := []struct {
+		name	string
+		input	string
+		want	money.Decimal
+		wantErr	bool
+	}{
+		{"test number", `12.5`, "12.5", false},
+		{"test string", `"12.50"`, "12.50", false},
+		{"test exponent", `1.25e1`, "", true},
+		{"test words", `"cheap"`, "", true},
+		{"test boolean", `true`, "", true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			var got money.Decimal
+			err := json.Unmarshal([]byte(test.input), &got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package services
+
+import "BookVault-API/auth"
+
+var testAdmin = auth.Principal{UserID: 99999, Username: "admin", Role: auth.RoleAdmin}
+
+
+// principalOf returns the regular user principal that AuthMiddleware would build for userID.
+func principalOf(userID uint) auth.Principal {
+	return auth.Principal{UserID: userID, Username: "user", Role: "user"}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"errors"
+	"testing"
+)
+
+
+func TestBookAuthorCredits(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+	authorService := service.NewAuthorService(testDB)
+
+	idiot := createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+	createTestBook(t, bookService, testDB, "Demons", "dostoevsky", "18.00")
+	crime := createTestBook(t, bookService, testDB, "Crime and Punishment", "Fyodor Dostoevsky", "15.00")
+
+	var authors []model.Author
+	if err := testDB.Order("id").Find(&authors).Error; err != nil {
+		t.Fatalf("failed to fetch authors: %v", err)
+	}
+	if len(authors) != 2 {
+		t.Fatalf("expected the author names to resolve to 2 authors, got %+v", authors)
+	}
+	short, full := authors[0], authors[1]
+
+	t.Run("test merge authors", func(t *testing.T) {
+		if err := authorService.MergeAuthors(short.ID, full.ID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		author, err := authorService.GetAuthor(full.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(author.Books) != 3 || len(author.Aliases) != 1 || author.Aliases[0] != "Dostoevsky" {
+			t.Errorf("expected 3 books and the alias Dostoevsky, got %+v", author)
+		}
+
+		book, err := bookService.GetByTitle("Demons")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if book.Author != "Fyodor Dostoevsky" || len(book.Authors) != 1 || book.Authors[0].ID != full.ID {
+			t.Errorf("expected Demons to be credited to Fyodor Dostoevsky, got %+v", book)
+		}
+
+		if _, err := authorService.GetAuthor(short.ID); !errors.Is(err, service.ErrAuthorNotFound) {
+			t.Errorf("expected %v, got %v", service.ErrAuthorNotFound, err)
+		}
+	})
+
+	t.Run("test lookup by alias", func(t *testing.T) {
+		books, err := bookService.GetBooksByAuthor("DOSTOEVSKY")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(books) != 3 {
+			t.Errorf("expected 3 books, got %d", len(books))
+		}
+
+		createTestBook(t, bookService, testDB, "The Gambler", "Dostoevsky", "9.00")
+
+		var count int64
+		testDB.Model(&model.Author{}).Count(&count)
+		if count != 1 {
+			t.Errorf("expected a new book by an alias to reuse the author, got %d authors", count)
+		}
+	})
+
+	t.Run("test credits with roles", func(t *testing.T) {
+		translator, err := authorService.CreateAuthor(model.AuthorRequest{Name: "Constance Garnett", BornOn: "1861-12-19", DiedOn: "1946-12-17"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err = bookService.SetBookAuthors(idiot.ID, []model.BookAuthorRequest{{AuthorID: full.ID}, {AuthorID: translator.ID, Role: model.RoleTranslator}})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err = bookService.SetBookAuthors(idiot.ID, []model.BookAuthorRequest{{AuthorID: full.ID, Role: "illustrator"}})
+		if !errors.Is(err, service.ErrInvalidAuthorRole) {
+			t.Errorf("expected %v, got %v", service.ErrInvalidAuthorRole, err)
+		}
+
+		author, err := authorService.GetAuthor(translator.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(author.Books) != 1 || author.Books[0].ID != idiot.ID || author.Books[0].Roles[0] != model.RoleTranslator {
+			t.Errorf("expected The Idiot as translator, got %+v", author.Books)
+		}
+
+		books, err := bookService.GetBooksByAuthor("Constance Garnett")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(books) != 0 {
+			t.Errorf("expected translations not to count as written books, got %d", len(books))
+		}
+
+		if err := authorService.DeleteAuthor(translator.ID); !errors.Is(err, service.ErrAuthorHasBooks) {
+			t.Errorf("expected %v, got %v", service.ErrAuthorHasBooks, err)
+		}
+	})
+
+	t.Run("test new author string relinks the book", func(t *testing.T) {
+		if err := bookService.UpdateBook(crime.ID, &model.BookRequest{Author: "Anonymous"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		book, err := bookService.GetByTitle("Crime and Punishment")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if book.Author != "Anonymous" || len(book.Authors) != 1 || book.Authors[0].Name != "Anonymous" {
+			t.Errorf("expected the book to be credited to Anonymous, got %+v", book)
+		}
+	})
+
+	t.Run("test author names must be unique", func(t *testing.T) {
+		_, err := authorService.CreateAuthor(model.AuthorRequest{Name: "F. M. Dostoevsky", Aliases: []string{"dostoevsky"}})
+		if !errors.Is(err, service.ErrAuthorExists) {
+			t.Errorf("expected %v, got %v", service.ErrAuthorExists, err)
+		}
+	})
+}
@@ -2,15 +2,38 @@ package services
 
 import (
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 	"gorm.io/gorm"
 )
 
-func float32Ptr(f float32) *float32 {
-	return &f
+func decimalPtr(s string) *money.Decimal {
+	d := money.Decimal(s)
+	return &d
+}
+
+// priceOf is an amount in the default currency, which prices given without a currency are in.
+func priceOf(s string) money.Money {
+	price, err := money.Parse(s, money.DefaultCurrency())
+	if err != nil {
+		panic(err)
+	}
+	return price
+}
+
+func pricePtr(s string) *money.Money {
+	price := priceOf(s)
+	return &price
+}
+
+func intPtr(i int) *int {
+	return &i
 }
 
 func initBookTestServices(t *testing.T) (*gorm.DB, service.BookService){
@@ -18,14 +41,14 @@ func initBookTestServices(t *testing.T) (*gorm.DB, service.BookService){
 	return testDB, service.NewBookService(testDB)
 }
 
-func createTestBook(t *testing.T, bookService service.BookService, testDB *gorm.DB, title, author string, price float32) model.Book {
+func createTestBook(t *testing.T, bookService service.BookService, testDB *gorm.DB, title, author, price string) model.Book {
 	t.Helper()
 	
 	err := bookService.CreateBook(&model.BookRequest{
 		Title: title,
 		Author: author,
 		Description: "Test Book",
-		Price: &price,
+		Price: decimalPtr(price),
 	})
 	if err != nil {
 		t.Fatalf("failed to create book: %v", err)
@@ -49,7 +72,7 @@ func TestCreateBook(t *testing.T) {
 		wantErr			error
 	}{
 		{"test empty fields", model.BookRequest{Title: "", Author: "", Description: "test book", Price: nil}, service.ErrEmptyFields},
-		{"test create book succeeds", model.BookRequest{Title: "The Idiot", Author: "Dostoevsky", Description: "The Idiot one of Dostoevsky 5 major books", Price: float32Ptr(20.00)}, nil},
+		{"test create book succeeds", model.BookRequest{Title: "The Idiot", Author: "Dostoevsky", Description: "The Idiot one of Dostoevsky 5 major books", Price: decimalPtr("20.00")}, nil},
 	}
 
 	for _, test := range testCases {
@@ -70,7 +93,7 @@ func TestCreateBook(t *testing.T) {
 func TestGetByTitle(t *testing.T) {
 	testDB, bookService := initBookTestServices(t)
 
-	createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", 20.00)
+	createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
 
 	testCases := []struct{
 		testName		string
@@ -79,7 +102,7 @@ func TestGetByTitle(t *testing.T) {
 		wantBook		*model.BookResponse
 	}{
 		{"test book not found", "Crime and Punishment", service.ErrBookNotFound, nil},
-		{"test book found", "The Idiot", nil, &model.BookResponse{Title: "The Idiot", Author: "Dostoevsky", Description: "Test Book", Price: 20.00, InStock: true}},
+		{"test book found", "The Idiot", nil, &model.BookResponse{Title: "The Idiot", Author: "Dostoevsky", Description: "Test Book", Price: priceOf("20")}},
 	}
 
 	for _, test := range testCases {
@@ -105,11 +128,75 @@ func TestGetByTitle(t *testing.T) {
 }
 
 
+func TestBookISBN(t *testing.T) {
+	_, bookService := initBookTestServices(t)
+
+	createRequest := func(title, value string) *model.BookRequest {
+		return &model.BookRequest{Title: title, Author: "Dostoevsky", Description: "Test Book", Price: decimalPtr("20.00"), ISBN: value}
+	}
+
+	if err := bookService.CreateBook(createRequest("The Idiot", "978-0-306-40615-7")); err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+
+	createCases := []struct{
+		testName		string
+		isbn			string
+		wantErr			error
+	}{
+		{"test invalid checksum", "978-0-306-40615-8", service.ErrInvalidISBN},
+		{"test duplicate ISBN-13", "9780306406157", service.ErrDuplicateISBN},
+		{"test duplicate given as ISBN-10", "0-306-40615-2", service.ErrDuplicateISBN},
+		{"test ISBN-10 is converted", "0-14-044913-2", nil},
+	}
+
+	for i, test := range createCases {
+		t.Run(test.testName, func(t *testing.T) {
+			err := bookService.CreateBook(createRequest(fmt.Sprintf("Book %d", i), test.isbn))
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	lookupCases := []struct{
+		testName		string
+		isbn			string
+		wantErr			error
+		wantISBN13		string
+		wantISBN10		string
+	}{
+		{"test invalid ISBN", "12345", service.ErrInvalidISBN, "", ""},
+		{"test unknown ISBN", "978-3-16-148410-0", service.ErrBookNotFound, "", ""},
+		{"test lookup by ISBN-13", "978-0-306-40615-7", nil, "9780306406157", "0306406152"},
+		{"test lookup by ISBN-10", "0140449132", nil, "9780140449136", "0140449132"},
+	}
+
+	for _, test := range lookupCases {
+		t.Run(test.testName, func(t *testing.T) {
+			book, err := bookService.GetByISBN(test.isbn)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected success, got %v", err)
+			}
+			if book.ISBN13 != test.wantISBN13 || book.ISBN10 != test.wantISBN10 {
+				t.Errorf("expected %s/%s, got %s/%s", test.wantISBN13, test.wantISBN10, book.ISBN13, book.ISBN10)
+			}
+		})
+	}
+}
+
+
 func TestGetBooks(t *testing.T) {
 	testDB, bookService := initBookTestServices(t)
 
 	t.Run("test no books", func(t *testing.T) {
-		books, err := bookService.GetBooks()
+		books, err := bookService.GetBooks(model.BookFilter{}, model.PageRequest{})
 		if err == nil || err != service.ErrNoBooks {
 			t.Errorf("expected ErrNoBooks, got %v", err)
 		}
@@ -119,27 +206,103 @@ func TestGetBooks(t *testing.T) {
 	})
 
 	t.Run("test books exist", func(t *testing.T) {
-		createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", 20.00)
-		createTestBook(t, bookService, testDB, "Anna Karenina", "Tolstoy", 20.00)
+		createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+		createTestBook(t, bookService, testDB, "Anna Karenina", "Tolstoy", "20.00")
 
-		books, err := bookService.GetBooks()
+		books, err := bookService.GetBooks(model.BookFilter{}, model.PageRequest{})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
-		if len(books) != 2 {
-			t.Errorf("expected 2 books, got %d", len(books))
+		if len(books.Items) != 2 || books.Total != 2 {
+			t.Errorf("expected 2 books, got %d of %d", len(books.Items), books.Total)
 		}
 	})
 }
 
 
+func TestGetBooksPaged(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+
+	prices := map[string]string{"Poor Folk": "8", "The Double": "12", "The Idiot": "20", "Demons": "25", "The Adolescent": "15"}
+	for title, price := range prices {
+		createTestBook(t, bookService, testDB, title, "Dostoevsky", price)
+	}
+	createTestBook(t, bookService, testDB, "Anna Karenina", "Tolstoy", "30")
+
+	if err := testDB.Model(&model.Book{}).Where("title = ?", "The Idiot").Update("stock", 3).Error; err != nil {
+		t.Fatalf("failed to update stock: %v", err)
+	}
+
+	t.Run("test pages follow the sort order", func(t *testing.T) {
+		var titles []string
+		page := model.PageRequest{Limit: 2, Sort: "price", Desc: true}
+
+		for {
+			books, err := bookService.GetBooks(model.BookFilter{Author: "dostoevsky"}, page)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if books.Total != 5 {
+				t.Errorf("expected total 5, got %d", books.Total)
+			}
+			for _, book := range books.Items {
+				titles = append(titles, book.Title)
+			}
+			if books.NextCursor == "" {
+				break
+			}
+			page.Cursor = books.NextCursor
+		}
+
+		want := []string{"Demons", "The Idiot", "The Adolescent", "The Double", "Poor Folk"}
+		if len(titles) != len(want) {
+			t.Fatalf("expected %v, got %v", want, titles)
+		}
+		for i := range want {
+			if titles[i] != want[i] {
+				t.Errorf("expected %v, got %v", want, titles)
+				break
+			}
+		}
+	})
+
+	inStock := true
+
+	testCases := []struct{
+		testName		string
+		filter			model.BookFilter
+		page			model.PageRequest
+		wantErr			error
+		wantTotal		int64
+	}{
+		{"test price range", model.BookFilter{MinPrice: pricePtr("12"), MaxPrice: pricePtr("20")}, model.PageRequest{}, nil, 3},
+		{"test in stock", model.BookFilter{InStock: &inStock}, model.PageRequest{}, nil, 1},
+		{"test no match", model.BookFilter{MinPrice: pricePtr("100")}, model.PageRequest{}, service.ErrNoBooks, 0},
+		{"test unknown sort field", model.BookFilter{}, model.PageRequest{Sort: "description"}, service.ErrInvalidSort, 0},
+		{"test malformed cursor", model.BookFilter{}, model.PageRequest{Cursor: "not-a-cursor"}, service.ErrInvalidCursor, 0},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			books, err := bookService.GetBooks(test.filter, test.page)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if err == nil && books.Total != test.wantTotal {
+				t.Errorf("expected total %d, got %d", test.wantTotal, books.Total)
+			}
+		})
+	}
+}
+
+
 func TestGetBooksByAuthor(t *testing.T) {
 	testDB, bookService := initBookTestServices(t)
 
-	createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", 20.00)
-	createTestBook(t, bookService, testDB, "Crime and Punishment", "Dostoevsky", 20.00)
-	createTestBook(t, bookService, testDB, "Ana Karenina", "Tolstoy", 20.00)
-	createTestBook(t, bookService, testDB, "East of Eden", "Stainbeck", 20.00)
+	createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+	createTestBook(t, bookService, testDB, "Crime and Punishment", "Dostoevsky", "20.00")
+	createTestBook(t, bookService, testDB, "Ana Karenina", "Tolstoy", "20.00")
+	createTestBook(t, bookService, testDB, "East of Eden", "Stainbeck", "20.00")
 
 	testCases := []struct{
 		testName		string
@@ -167,28 +330,405 @@ func TestGetBooksByAuthor(t *testing.T) {
 func TestUpdateStock(t *testing.T) {
 	testDB, bookService := initBookTestServices(t)
 
-	book := createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", 20.00)
+	book := createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+
+	testCases := []struct{
+		testName		string
+		bookID			uint
+		stockUpdate		model.StockUpdate
+		wantErr			error
+		wantStock		int
+	}{
+		{"test book not found", 9999, model.StockUpdate{Stock: intPtr(5)}, service.ErrBookNotFound, 0},
+		{"test neither stock nor delta", book.ID, model.StockUpdate{}, service.ErrInvalidStockUpdate, 0},
+		{"test both stock and delta", book.ID, model.StockUpdate{Stock: intPtr(5), Delta: intPtr(1)}, service.ErrInvalidStockUpdate, 0},
+		{"test negative stock", book.ID, model.StockUpdate{Stock: intPtr(-1)}, service.ErrInvalidStock, 0},
+		{"test set stock", book.ID, model.StockUpdate{Stock: intPtr(5)}, nil, 5},
+		{"test add copies", book.ID, model.StockUpdate{Delta: intPtr(3)}, nil, 8},
+		{"test remove copies", book.ID, model.StockUpdate{Delta: intPtr(-8)}, nil, 0},
+		{"test remove more than in stock", book.ID, model.StockUpdate{Delta: intPtr(-1)}, service.ErrInvalidStock, 0},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			err := bookService.UpdateStock(test.bookID, test.stockUpdate)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var updatedBook model.Book
+			if err := testDB.First(&updatedBook, book.ID).Error; err != nil {
+				t.Fatalf("failed to fetch updated book: %v", err)
+			}
+			if updatedBook.Stock != test.wantStock {
+				t.Errorf("expected stock %d, got %d", test.wantStock, updatedBook.Stock)
+			}
+		})
+	}
+}
+
+func TestUpdateBook(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
 
-	t.Run("test book not found", func(t *testing.T) {
-		err := bookService.UpdateStock(9999)
-		if err == nil || err != service.ErrBookNotFound {
-			t.Errorf("expected ErrBookNotFound, got %v", err)
+	book := createTestBook(t, bookService, testDB, "The Idoit", "Dostoevsky", "20.00")
+
+	testCases := []struct{
+		testName		string
+		bookID			uint
+		bookRequest		model.BookRequest
+		wantErr			error
+		wantTitle		string
+		wantPrice		string
+	}{
+		{"test book not found", 9999, model.BookRequest{Title: "The Idiot"}, service.ErrBookNotFound, "", ""},
+		{"test negative price", book.ID, model.BookRequest{Price: decimalPtr("-1")}, service.ErrInvalidPrice, "", ""},
+		{"test invalid currency", book.ID, model.BookRequest{Currency: "euro"}, service.ErrInvalidCurrency, "", ""},
+		{"test fix title keeps price", book.ID, model.BookRequest{Title: "The Idiot"}, nil, "The Idiot", "20.00"},
+		{"test change price keeps title", book.ID, model.BookRequest{Price: decimalPtr("25.50")}, nil, "The Idiot", "25.50"},
+		{"test price rounded to cents", book.ID, model.BookRequest{Price: decimalPtr("19.995")}, nil, "The Idiot", "20.00"},
+		{"test price in yen", book.ID, model.BookRequest{Price: decimalPtr("2100.5"), Currency: "jpy"}, nil, "The Idiot", "2101"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			err := bookService.UpdateBook(test.bookID, &test.bookRequest)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var updated model.Book
+			if err := testDB.First(&updated, test.bookID).Error; err != nil {
+				t.Fatalf("failed to fetch book: %v", err)
+			}
+			if updated.Title != test.wantTitle || updated.Price.String() != test.wantPrice {
+				t.Errorf("expected %q at %s, got %q at %s", test.wantTitle, test.wantPrice, updated.Title, updated.Price)
+			}
+			if updated.Description != "Test Book" {
+				t.Errorf("expected description to be unchanged, got %q", updated.Description)
+			}
+		})
+	}
+}
+
+
+func TestDeleteAndRestoreBook(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+
+	book := createTestBook(t, bookService, testDB, "Netochka Nezvanova", "Dostoevsky", "12.00")
+
+	if err := bookService.RestoreBook(book.ID); !errors.Is(err, service.ErrBookNotDeleted) {
+		t.Errorf("expected %v, got %v", service.ErrBookNotDeleted, err)
+	}
+
+	if err := bookService.DeleteBook(book.ID); err != nil {
+		t.Fatalf("expected delete to succeed, got %v", err)
+	}
+
+	if err := bookService.DeleteBook(book.ID); !errors.Is(err, service.ErrBookNotFound) {
+		t.Errorf("expected %v for already deleted book, got %v", service.ErrBookNotFound, err)
+	}
+
+	if _, err := bookService.GetByTitle(book.Title); !errors.Is(err, service.ErrBookNotFound) {
+		t.Errorf("expected deleted book to be hidden, got %v", err)
+	}
+
+	deleted, err := bookService.GetDeletedBooks()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != book.ID {
+		t.Errorf("expected deleted book %d to be listed, got %+v", book.ID, deleted)
+	}
+
+	if err := bookService.RestoreBook(book.ID); err != nil {
+		t.Fatalf("expected restore to succeed, got %v", err)
+	}
+
+	if _, err := bookService.GetByTitle(book.Title); err != nil {
+		t.Errorf("expected restored book to be visible, got %v", err)
+	}
+
+	if err := bookService.RestoreBook(9999); !errors.Is(err, service.ErrBookNotFound) {
+		t.Errorf("expected %v, got %v", service.ErrBookNotFound, err)
+	}
+}
+
+
+func TestSearchBooks(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+
+	requests := []model.BookRequest{
+		{Title: "Crime and Punishment", Author: "Dostoevsky", Description: "A student plans a murder in St. Petersburg.", Price: decimalPtr("30")},
+		{Title: "The Idiot", Author: "Dostoevsky", Description: "A prince returns to Russia. Crime and guilt run through the plot.", Price: decimalPtr("20")},
+		{Title: "Anna Karenina", Author: "Tolstoy", Description: "A tragic love story.", Price: decimalPtr("25")},
+	}
+	for i := range requests {
+		if err := bookService.CreateBook(&requests[i]); err != nil {
+			t.Fatalf("failed to create book: %v", err)
+		}
+	}
+
+	testCases := []struct{
+		testName		string
+		query			string
+		wantTitles		[]string
+	}{
+		{"test stemmed words across fields", "crime punish", []string{"Crime and Punishment"}},
+		{"test title match ranks first", "crime", []string{"Crime and Punishment", "The Idiot"}},
+		{"test author match", "tolstoy", []string{"Anna Karenina"}},
+		{"test excluded word", "dostoevsky -prince", []string{"Crime and Punishment"}},
+		{"test no match", "whale", []string{}},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			results, err := bookService.SearchBooks(test.query, model.PageRequest{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(results.Items) != len(test.wantTitles) {
+				t.Fatalf("expected %d results, got %d", len(test.wantTitles), len(results.Items))
+			}
+			for i, title := range test.wantTitles {
+				if results.Items[i].Title != title {
+					t.Errorf("expected result %d to be %q, got %q", i, title, results.Items[i].Title)
+				}
+			}
+		})
+	}
+
+	t.Run("test description is highlighted", func(t *testing.T) {
+		results, err := bookService.SearchBooks("murder", model.PageRequest{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(results.Items) != 1 || !strings.Contains(results.Items[0].Highlight, "<mark>murder</mark>") {
+			t.Errorf("expected highlighted match, got %+v", results.Items)
+		}
+	})
+
+	t.Run("test updated book is found by its new title", func(t *testing.T) {
+		var book model.Book
+		if err := testDB.Where("title = ?", "Anna Karenina").First(&book).Error; err != nil {
+			t.Fatalf("failed to fetch book: %v", err)
+		}
+		if err := bookService.UpdateBook(book.ID, &model.BookRequest{Title: "War and Peace"}); err != nil {
+			t.Fatalf("failed to update book: %v", err)
+		}
+
+		results, err := bookService.SearchBooks("war peace", model.PageRequest{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(results.Items) != 1 || results.Items[0].ID != book.ID {
+			t.Errorf("expected the updated book, got %+v", results.Items)
+		}
+	})
+}
+
+
+func TestImportBooks(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+
+	existing := createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+
+	csvFile := strings.Join([]string{
+		"title,author,description,price,stock,isbn",
+		"the idiot,dostoevsky,Updated description,25.50,,",
+		"Crime and Punishment,Dostoevsky,A novel,15,4,0-14-044913-2",
+		"Demons,Dostoevsky,A novel,-1,,",
+		"Notes from Underground,Dostoevsky,A novella,abc,,",
+		"The Gambler,Dostoevsky,A novella,9,,978-0-306-40615-8",
+		"Crime and Punishment (2nd),Dostoevsky,Second printing,16,,9780140449136",
+	}, "\n")
+
+	t.Run("test dry run saves nothing", func(t *testing.T) {
+		report, err := bookService.ImportBooks(strings.NewReader(csvFile), model.ImportCSV, true)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !report.DryRun || report.Created != 1 || report.Updated != 2 || report.Failed != 3 {
+			t.Errorf("expected 1 created, 2 updated and 3 failed, got %+v", report)
+		}
+
+		var count int64
+		testDB.Model(&model.Book{}).Count(&count)
+		if count != 1 {
+			t.Errorf("expected only the existing book, got %d books", count)
+		}
+	})
+
+	t.Run("test dry run with a new author does not wait for the authors lock", func(t *testing.T) {
+		lock := testDB.Begin()
+		defer lock.Rollback()
+
+		if err := lock.Exec("LOCK TABLE authors IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			t.Fatalf("failed to lock authors: %v", err)
+		}
+
+		done := make(chan *model.ImportReport, 1)
+		go func() {
+			report, _ := bookService.ImportBooks(strings.NewReader("title,author,description,price\nThe Master and Margarita,Bulgakov,A novel,12"), model.ImportCSV, true)
+			done <- report
+		}()
+
+		select {
+		case report := <-done:
+			if report == nil || report.Created != 1 {
+				t.Errorf("expected 1 created, got %+v", report)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the dry run not to wait for the authors lock")
+		}
+	})
+
+	t.Run("test CSV import", func(t *testing.T) {
+		report, err := bookService.ImportBooks(strings.NewReader(csvFile), model.ImportCSV, false)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		wantStatuses := []model.ImportStatus{model.ImportUpdated, model.ImportCreated, model.ImportFailed, model.ImportFailed, model.ImportFailed, model.ImportUpdated}
+		if len(report.Rows) != len(wantStatuses) {
+			t.Fatalf("expected %d rows, got %+v", len(wantStatuses), report.Rows)
+		}
+		for i, row := range report.Rows {
+			if row.Status != wantStatuses[i] {
+				t.Errorf("line %d: expected %s, got %s (%s)", row.Line, wantStatuses[i], row.Status, row.Error)
+			}
+		}
+		if report.Rows[0].Line != 2 || report.Rows[0].BookID != existing.ID {
+			t.Errorf("expected line 2 to update book %d, got %+v", existing.ID, report.Rows[0])
+		}
+		if report.Rows[2].Error != service.ErrInvalidPrice.Error() || report.Rows[4].Error != service.ErrInvalidISBN.Error() {
+			t.Errorf("expected validation errors, got %+v", report.Rows)
+		}
+
+		book, err := bookService.GetByISBN("9780140449136")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if book.Title != "Crime and Punishment (2nd)" || book.Stock != 4 {
+			t.Errorf("expected the second row to update the first, got %+v", book)
 		}
 	})
 
-	t.Run("test stock update succeeds", func(t *testing.T) {
-		initial := book.InStock
+	t.Run("test JSONL import", func(t *testing.T) {
+		jsonlFile := "{\"title\":\"Demons\",\"author\":\"Dostoevsky\",\"description\":\"A novel\",\"price\":18}\n\nnot json\n"
 
-		if err := bookService.UpdateStock(book.ID); err != nil {
-			t.Errorf("expected no error, got %v", err)
+		report, err := bookService.ImportBooks(strings.NewReader(jsonlFile), model.ImportJSONL, false)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
 		}
+		if report.Created != 1 || report.Failed != 1 || report.Rows[1].Line != 3 {
+			t.Errorf("expected one created row and a failed line 3, got %+v", report)
+		}
+	})
 
-		var updatedBook model.Book
-		if err := testDB.First(&updatedBook, book.ID).Error; err != nil {
-			t.Fatalf("failed to fetch updated book: %v", err)
+	t.Run("test invalid header", func(t *testing.T) {
+		_, err := bookService.ImportBooks(strings.NewReader("title,author,price\n"), model.ImportCSV, false)
+		if !errors.Is(err, service.ErrInvalidImportHeader) {
+			t.Errorf("expected %v, got %v", service.ErrInvalidImportHeader, err)
 		}
-		if updatedBook.InStock == initial {
-			t.Errorf("expected stock change, got %v", updatedBook.InStock)
-		}	
 	})
-}
\ No newline at end of file
+}
+
+
+func TestExportBooks(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+	categoryService := service.NewCategoryService(testDB)
+
+	idiot := createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+	createTestBook(t, bookService, testDB, "Anna Karenina", "Tolstoy", "25.00")
+
+	crime := createTestCategory(t, categoryService, "Crime", nil)
+	classics := createTestCategory(t, categoryService, "Classics", nil)
+	if err := bookService.SetBookCategories(idiot.ID, []uint{crime, classics}); err != nil {
+		t.Fatalf("failed to set categories: %v", err)
+	}
+	if err := bookService.UpdateBook(idiot.ID, &model.BookRequest{ISBN: "978-0-306-40615-7"}); err != nil {
+		t.Fatalf("failed to set ISBN: %v", err)
+	}
+
+	export := func(format model.ExportFormat, filter model.BookFilter) string {
+		var out strings.Builder
+		if err := bookService.ExportBooks(&out, format, filter); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return out.String()
+	}
+
+	t.Run("test unsupported format", func(t *testing.T) {
+		err := bookService.ExportBooks(&strings.Builder{}, "pdf", model.BookFilter{})
+		if !errors.Is(err, service.ErrUnsupportedExportFormat) {
+			t.Errorf("expected %v, got %v", service.ErrUnsupportedExportFormat, err)
+		}
+	})
+
+	t.Run("test CSV export", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(export(model.ExportCSV, model.BookFilter{})), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected a header and 2 books, got %q", lines)
+		}
+		if !strings.HasPrefix(lines[1], fmt.Sprintf("%d,The Idiot,Dostoevsky,Test Book,20.00,%s,0,9780306406157,0306406152,Classics | Crime,", idiot.ID, money.DefaultCurrency())) {
+			t.Errorf("unexpected first row %q", lines[1])
+		}
+	})
+
+	t.Run("test JSONL export is filtered", func(t *testing.T) {
+		out := export(model.ExportJSONL, model.BookFilter{Author: "tolstoy"})
+		if strings.Count(out, "\n") != 1 || !strings.Contains(out, `"title":"Anna Karenina"`) || !strings.Contains(out, `"categories":[]`) {
+			t.Errorf("expected only Anna Karenina, got %q", out)
+		}
+	})
+
+	t.Run("test ONIX export", func(t *testing.T) {
+		out := export(model.ExportONIX, model.BookFilter{Author: "Dostoevsky"})
+		for _, want := range []string{`<ONIXMessage release="3.0"`, "<IDValue>9780306406157</IDValue>", "<SubjectHeadingText>Crime</SubjectHeadingText>", "<PriceAmount>20.00</PriceAmount>", "</ONIXMessage>"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in %q", want, out)
+			}
+		}
+	})
+
+	t.Run("test ONIX contributors", func(t *testing.T) {
+		translator, err := service.NewAuthorService(testDB).CreateAuthor(model.AuthorRequest{Name: "Louise Maude"})
+		if err != nil {
+			t.Fatalf("failed to create author: %v", err)
+		}
+
+		var tolstoy model.BookAuthor
+		if err := testDB.Joins("JOIN books ON books.id = book_authors.book_id").Where("books.title = ?", "Anna Karenina").First(&tolstoy).Error; err != nil {
+			t.Fatalf("failed to fetch credit: %v", err)
+		}
+
+		err = bookService.SetBookAuthors(tolstoy.BookID, []model.BookAuthorRequest{{AuthorID: tolstoy.AuthorID}, {AuthorID: translator.ID, Role: model.RoleTranslator}})
+		if err != nil {
+			t.Fatalf("failed to set authors: %v", err)
+		}
+
+		out := export(model.ExportONIX, model.BookFilter{Author: "Tolstoy"})
+		for _, want := range []string{"<ContributorRole>A01</ContributorRole>", "<PersonName>Tolstoy</PersonName>",
+			"<SequenceNumber>2</SequenceNumber>", "<ContributorRole>B06</ContributorRole>", "<PersonName>Louise Maude</PersonName>"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in %q", want, out)
+			}
+		}
+	})
+
+	t.Run("test CSV export imports again", func(t *testing.T) {
+		report, err := bookService.ImportBooks(strings.NewReader(export(model.ExportCSV, model.BookFilter{})), model.ImportCSV, true)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if report.Updated != 2 || report.Created != 0 || report.Failed != 0 {
+			t.Errorf("expected both books to be updated, got %+v", report)
+		}
+	})
+}
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/model"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
+	"errors"
 	"testing"
 
 	"gorm.io/gorm"
@@ -21,7 +23,7 @@ func initCartTestServices(t *testing.T) (*gorm.DB, service.CartService, service.
 func TestAddToCart(t *testing.T) {
 	testDB, cartService, bookService := initCartTestServices(t)
 	user := createTestUser(t, "testUser10001", "testUser@gmail.com")
-	book := createTestBook(t, bookService, testDB ,"The Idiot", "Dostoevsky", 20.0)
+	book := createTestBook(t, bookService, testDB ,"The Idiot", "Dostoevsky", "20.0")
 
 	testCases := []struct{
 		testName		string
@@ -34,6 +36,8 @@ func TestAddToCart(t *testing.T) {
 		{"test new cart created and book added", user.ID, book.ID, 2, nil, 2},
 		{"test add same book increases quantity", user.ID, book.ID, 1, nil, 3},
 		{"test book not found", user.ID, 9999, 1, service.ErrBookNotFound, 0},
+		{"test zero quantity", user.ID, book.ID, 0, service.ErrInvalidQuantity, 3},
+		{"test negative quantity", user.ID, book.ID, -1, service.ErrInvalidQuantity, 3},
 	}
 
 	for _, test := range testCases {
@@ -60,7 +64,7 @@ func TestClearCart(t *testing.T) {
 	testDB, cartService, bookService := initCartTestServices(t)
 
 	user := createTestUser(t, "testUser5006", "testUser2006@gmail.com")
-	book := createTestBook(t, bookService, testDB, "Notes From the Underground", "Fyodor Dostoevsky", 15.00)
+	book := createTestBook(t, bookService, testDB, "Notes From the Underground", "Fyodor Dostoevsky", "15.00")
 
 	_ = cartService.AddToCart(user.ID, book.ID, 2)
 
@@ -88,7 +92,7 @@ func TestRemoveFromCart(t *testing.T) {
 	testDB, cartService, bookService := initCartTestServices(t)
 
 	user := createTestUser(t, "testUser2000001", "testUser2000001@gmail.com")
-	book := createTestBook(t, bookService, testDB, "The Gambler", "Фьодор Достоевски", 10.00)
+	book := createTestBook(t, bookService, testDB, "The Gambler", "Фьодор Достоевски", "10.00")
 
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
@@ -118,7 +122,7 @@ func TestUpdateQuantity(t *testing.T) {
 	testDB, cartService, bookService := initCartTestServices(t)
 
 	user := createTestUser(t, "testUser2000001111", "testUser2000001111@gmail.com")
-	book := createTestBook(t, bookService, testDB, "The Last Temptation", "Nikos Kazantsakis", 20.00)
+	book := createTestBook(t, bookService, testDB, "The Last Temptation", "Nikos Kazantsakis", "20.00")
 
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
@@ -133,6 +137,8 @@ func TestUpdateQuantity(t *testing.T) {
 		{"test update existing book quantity", user.ID, book.ID, 2, nil, 2},
 		{"test update quantity in missing cart", 9999, book.ID, 3, service.ErrCartNotFound, 0},
 		{"test missing book in existing cart", user.ID, 9999, 3, service.ErrCartBookNotFound, 0},
+		{"test negative quantity", user.ID, book.ID, -1, service.ErrNegativeQuantity, 2},
+		{"test zero quantity removes book", user.ID, book.ID, 0, nil, 0},
 	}
 
 	for _, test := range testCases{
@@ -152,6 +158,156 @@ func TestUpdateQuantity(t *testing.T) {
 			}
 		})
 	}
+
+	if quantity := cartQuantity(t, testDB, user.ID, book.ID); quantity != 0 {
+		t.Errorf("expected the book to be removed, got quantity %d", quantity)
+	}
+}
+
+
+// cartQuantity returns the copies of a book in the user's cart, 0 when it is not in the cart.
+func cartQuantity(t *testing.T, testDB *gorm.DB, userID, bookID uint) int {
+	t.Helper()
+
+	var quantities []int
+	err := testDB.Model(&model.CartBook{}).
+		Joins("JOIN carts ON carts.id = cart_books.cart_id").
+		Where("carts.user_id = ? AND cart_books.book_id = ?", userID, bookID).
+		Pluck("cart_books.quantity", &quantities).Error
+	if err != nil {
+		t.Fatalf("failed to fetch cart quantity: %v", err)
+	}
+	if len(quantities) > 1 {
+		t.Fatalf("expected one cart line, got %d", len(quantities))
+	}
+	if len(quantities) == 0 {
+		return 0
+	}
+	return quantities[0]
+}
+
+
+func TestCartQuantityLimit(t *testing.T) {
+	testDB, cartService, bookService := initCartTestServices(t)
+
+	user := createTestUser(t, "testUser3800", "testUser3800@gmail.com")
+	book := createTestBook(t, bookService, testDB, "Dead Souls", "Nikolai Gogol", "18.00")
+
+	if err := bookService.UpdateBook(book.ID, &model.BookRequest{MaxQuantity: intPtr(-1)}); err != service.ErrInvalidMaxQuantity {
+		t.Fatalf("expected %v, got %v", service.ErrInvalidMaxQuantity, err)
+	}
+	if err := bookService.UpdateBook(book.ID, &model.BookRequest{MaxQuantity: intPtr(2)}); err != nil {
+		t.Fatalf("failed to set max quantity: %v", err)
+	}
+
+	addToCart := func(quantity int) error { return cartService.AddToCart(user.ID, book.ID, quantity) }
+	updateQuantity := func(quantity int) error { return cartService.UpdateQuantity(user.ID, book.ID, quantity) }
+
+	testCases := []struct {
+		name			string
+		change			func(quantity int) error
+		quantity		int
+		wantLimit		bool
+		wantQuantity	int
+	}{
+		{"test add up to the limit", addToCart, 2, false, 2},
+		{"test add above the limit", addToCart, 1, true, 2},
+		{"test update above the limit", updateQuantity, 3, true, 2},
+		{"test update within the limit", updateQuantity, 1, false, 1},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.change(test.quantity)
+
+			var limitErr *service.QuantityLimitError
+			if test.wantLimit != errors.As(err, &limitErr) {
+				t.Fatalf("expected a quantity limit error: %v, got %v", test.wantLimit, err)
+			}
+			if !test.wantLimit && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if test.wantLimit && limitErr.Max != 2 {
+				t.Errorf("expected a max of 2, got %d", limitErr.Max)
+			}
+			if quantity := cartQuantity(t, testDB, user.ID, book.ID); quantity != test.wantQuantity {
+				t.Errorf("expected quantity %d, got %d", test.wantQuantity, quantity)
+			}
+		})
+	}
+
+	t.Run("test removing the limit", func(t *testing.T) {
+		if err := bookService.UpdateBook(book.ID, &model.BookRequest{MaxQuantity: intPtr(0)}); err != nil {
+			t.Fatalf("failed to clear max quantity: %v", err)
+		}
+		if err := addToCart(5); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if quantity := cartQuantity(t, testDB, user.ID, book.ID); quantity != 6 {
+			t.Errorf("expected quantity 6, got %d", quantity)
+		}
+	})
+}
+
+
+func TestSetCartLines(t *testing.T) {
+	testDB, cartService, bookService := initCartTestServices(t)
+
+	user := createTestUser(t, "testUser3900", "testUser3900@gmail.com")
+	nose := createTestBook(t, bookService, testDB, "The Nose", "Nikolai Gogol", "8.00")
+	overcoat := createTestBook(t, bookService, testDB, "The Overcoat", "Nikolai Gogol", "9.00")
+	inspector := createTestBook(t, bookService, testDB, "The Government Inspector", "Nikolai Gogol", "12.00")
+
+	if err := bookService.UpdateBook(inspector.ID, &model.BookRequest{MaxQuantity: intPtr(1)}); err != nil {
+		t.Fatalf("failed to set max quantity: %v", err)
+	}
+
+	_ = cartService.AddToCart(user.ID, nose.ID, 1)
+
+	testCases := []struct {
+		name		string
+		lines		[]model.CartLineRequest
+		wantErr		error
+		wantLimit	bool
+		wantLines	map[uint]int
+	}{
+		{"test set and remove lines", []model.CartLineRequest{{BookID: nose.ID, Quantity: 0}, {BookID: overcoat.ID, Quantity: 2}, {BookID: inspector.ID, Quantity: 1}},
+			nil, false, map[uint]int{nose.ID: 0, overcoat.ID: 2, inspector.ID: 1}},
+		{"test no lines", nil, service.ErrNoCartLines, false, map[uint]int{overcoat.ID: 2}},
+		{"test negative quantity", []model.CartLineRequest{{BookID: overcoat.ID, Quantity: -1}}, service.ErrNegativeQuantity, false, map[uint]int{overcoat.ID: 2}},
+		{"test book given twice", []model.CartLineRequest{{BookID: overcoat.ID, Quantity: 1}, {BookID: overcoat.ID, Quantity: 3}},
+			service.ErrDuplicateCartLine, false, map[uint]int{overcoat.ID: 2}},
+		{"test unknown book sets nothing", []model.CartLineRequest{{BookID: overcoat.ID, Quantity: 4}, {BookID: 9999, Quantity: 1}},
+			service.ErrBookNotFound, false, map[uint]int{overcoat.ID: 2}},
+		{"test line above the limit sets nothing", []model.CartLineRequest{{BookID: overcoat.ID, Quantity: 4}, {BookID: inspector.ID, Quantity: 2}},
+			nil, true, map[uint]int{overcoat.ID: 2, inspector.ID: 1}},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			err := cartService.SetCartLines(user.ID, test.lines)
+
+			var limitErr *service.QuantityLimitError
+			switch {
+			case test.wantErr != nil:
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+			case test.wantLimit:
+				if !errors.As(err, &limitErr) {
+					t.Fatalf("expected a quantity limit error, got %v", err)
+				}
+			case err != nil:
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for bookID, want := range test.wantLines {
+				if quantity := cartQuantity(t, testDB, user.ID, bookID); quantity != want {
+					t.Errorf("expected quantity %d of book %d, got %d", want, bookID, quantity)
+				}
+			}
+		})
+	}
 }
 
 
@@ -159,7 +315,7 @@ func TestGetCart(t *testing.T){
 	testDB, cartService, bookService := initCartTestServices(t)
 
 	user := createTestUser(t, "testUser3400", "testUser3400@gmail.com")
-	book := createTestBook(t, bookService, testDB, "1984", "George Orwell", 20.00)
+	book := createTestBook(t, bookService, testDB, "1984", "George Orwell", "20.00")
 
 	_ = cartService.AddToCart(user.ID, book.ID, 1)
 
@@ -175,7 +331,7 @@ func TestGetCart(t *testing.T){
 
 	for _, test := range testCases {
 		t.Run(test.testName, func(t *testing.T) {
-			cart, err := cartService.GetCart(test.cartID)
+			cart, err := cartService.GetCart(testAdmin, test.cartID)
 			if err != test.wantErr {
 				t.Errorf("expected error %v, got %v", test.wantErr, err)
 			}
@@ -183,7 +339,132 @@ func TestGetCart(t *testing.T){
 				if cart.Books[0].Title != test.wantBook {
 					t.Errorf("expected %s, got %s", test.wantBook, cart.Books[0].Title)
 				}
+				authors := cart.Books[0].Authors
+				if len(authors) != 1 || authors[0].Name != "George Orwell" || authors[0].Role != model.RoleAuthor {
+					t.Errorf("expected George Orwell as the only author, got %+v", authors)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCartOwnership(t *testing.T){
+	testDB, cartService, bookService := initCartTestServices(t)
+
+	owner := createTestUser(t, "testUser3500", "testUser3500@gmail.com")
+	book := createTestBook(t, bookService, testDB, "Brave New World", "Aldous Huxley", "18.00")
+
+	_ = cartService.AddToCart(owner.ID, book.ID, 1)
+
+	var cart model.Cart
+	if err := testDB.Where("user_id = ?", owner.ID).First(&cart).Error; err != nil {
+		t.Fatalf("failed to fetch cart: %v", err)
+	}
+
+	testCases := []struct{
+		testName		string
+		principal		auth.Principal
+		wantErr			error
+	}{
+		{"test other user is forbidden", principalOf(owner.ID + 1), service.ErrForbidden},
+		{"test owner is allowed", principalOf(owner.ID), nil},
+		{"test admin is allowed", testAdmin, nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := cartService.GetCart(test.principal, cart.ID)
+			if err != test.wantErr {
+				t.Errorf("expected error %v, got %v", test.wantErr, err)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+
+func TestGetCartWithDeletedBook(t *testing.T){
+	testDB, cartService, bookService := initCartTestServices(t)
+
+	user := createTestUser(t, "testUser3600", "testUser3600@gmail.com")
+	book := createTestBook(t, bookService, testDB, "Animal Farm", "George Orwell", "11.00")
+
+	_ = cartService.AddToCart(user.ID, book.ID, 1)
+
+	if err := bookService.DeleteBook(book.ID); err != nil {
+		t.Fatalf("failed to delete book: %v", err)
+	}
+
+	cart, err := cartService.GetCart(principalOf(user.ID), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cart.Books) != 1 {
+		t.Fatalf("expected 1 book in cart, got %d", len(cart.Books))
+	}
+	if cart.Books[0].Available || cart.Books[0].Title != "Animal Farm" {
+		t.Errorf("expected unavailable %q, got %+v", "Animal Farm", cart.Books[0])
+	}
+}
+
+
+func TestGetCartLines(t *testing.T){
+	testDB, cartService, bookService := initCartTestServices(t)
+
+	user := createTestUser(t, "testUser3700", "testUser3700@gmail.com")
+	trial := createTestBook(t, bookService, testDB, "The Trial", "Franz Kafka", "12.50")
+	castle := createTestBook(t, bookService, testDB, "The Castle", "Franz Kafka", "20.00")
+
+	_ = cartService.AddToCart(user.ID, trial.ID, 2)
+	_ = cartService.AddToCart(user.ID, castle.ID, 1)
+
+	if err := bookService.UpdateBook(trial.ID, &model.BookRequest{Price: decimalPtr("14")}); err != nil {
+		t.Fatalf("failed to update price: %v", err)
+	}
+	if err := bookService.UpdateStock(castle.ID, model.StockUpdate{Stock: intPtr(0)}); err != nil {
+		t.Fatalf("failed to update stock: %v", err)
+	}
+
+	lines := func(t *testing.T) map[string]model.CartBookResponse {
+		cart, err := cartService.GetCart(principalOf(user.ID), 1)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if cart.Totals == nil || cart.Totals.Subtotal.String() != "48.00" {
+			t.Errorf("expected a subtotal of 48.00, got %+v", cart.Totals)
+		}
+
+		byTitle := make(map[string]model.CartBookResponse)
+		for _, line := range cart.Books {
+			byTitle[line.Title] = line
+		}
+		return byTitle
+	}
+
+	t.Run("test changed price and stock are flagged", func(t *testing.T) {
+		byTitle := lines(t)
+
+		trialLine := byTitle["The Trial"]
+		if trialLine.Quantity != 2 || trialLine.UnitPrice.String() != "14.00" || trialLine.LineTotal.String() != "28.00" {
+			t.Errorf("expected 2 x 14.00 = 28.00, got %+v", trialLine)
+		}
+		if !trialLine.PriceChanged || trialLine.AddedPrice == nil || trialLine.AddedPrice.String() != "12.50" || trialLine.OutOfStock {
+			t.Errorf("expected a price changed from 12.50, got %+v", trialLine)
+		}
+
+		castleLine := byTitle["The Castle"]
+		if castleLine.PriceChanged || castleLine.AddedPrice != nil || !castleLine.OutOfStock {
+			t.Errorf("expected an out of stock line at an unchanged price, got %+v", castleLine)
+		}
+	})
+
+	t.Run("test adding again takes the current price", func(t *testing.T) {
+		if err := testDB.Model(&model.CartBook{}).Where("book_id = ?", trial.ID).Update("quantity", 1).Error; err != nil {
+			t.Fatalf("failed to update quantity: %v", err)
+		}
+		_ = cartService.AddToCart(user.ID, trial.ID, 1)
+
+		if trialLine := lines(t)["The Trial"]; trialLine.PriceChanged || trialLine.AddedPrice != nil {
+			t.Errorf("expected the price change to be cleared, got %+v", trialLine)
+		}
+	})
+}
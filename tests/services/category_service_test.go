@@ -0,0 +1,177 @@
+package services
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"errors"
+	"testing"
+)
+
+func createTestCategory(t *testing.T, categoryService service.CategoryService, name string, parentID *uint) uint {
+	t.Helper()
+
+	category, err := categoryService.CreateCategory(model.CategoryRequest{Name: name, ParentID: parentID})
+	if err != nil {
+		t.Fatalf("failed to create category %q: %v", name, err)
+	}
+
+	return category.ID
+}
+
+func uintPtr(u uint) *uint {
+	return &u
+}
+
+
+func TestCreateCategory(t *testing.T) {
+	testDB, _ := initBookTestServices(t)
+	categoryService := service.NewCategoryService(testDB)
+
+	fiction := createTestCategory(t, categoryService, "Fiction", nil)
+
+	testCases := []struct {
+		name			string
+		categoryRequest	model.CategoryRequest
+		wantErr			error
+	}{
+		{"test empty name", model.CategoryRequest{Name: "  "}, service.ErrEmptyCategoryName},
+		{"test parent not found", model.CategoryRequest{Name: "Crime", ParentID: uintPtr(9999)}, service.ErrParentCategoryNotFound},
+		{"test subcategory", model.CategoryRequest{Name: "Crime", ParentID: &fiction}, nil},
+		{"test duplicate sibling", model.CategoryRequest{Name: "crime", ParentID: &fiction}, service.ErrCategoryExists},
+		{"test same name elsewhere", model.CategoryRequest{Name: "Crime"}, nil},
+		{"test duplicate root", model.CategoryRequest{Name: "FICTION"}, service.ErrCategoryExists},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := categoryService.CreateCategory(test.categoryRequest)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+
+func TestCategoryTree(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+	categoryService := service.NewCategoryService(testDB)
+
+	fiction := createTestCategory(t, categoryService, "Fiction", nil)
+	crime := createTestCategory(t, categoryService, "Crime", &fiction)
+	nordic := createTestCategory(t, categoryService, "Nordic Noir", &crime)
+	poetry := createTestCategory(t, categoryService, "Poetry", nil)
+
+	smilla := createTestBook(t, bookService, testDB, "Smilla's Sense of Snow", "Hoeg", "14")
+	dragon := createTestBook(t, bookService, testDB, "The Girl with the Dragon Tattoo", "Larsson", "12")
+	crimeBook := createTestBook(t, bookService, testDB, "Crime and Punishment", "Dostoevsky", "20")
+
+	assign := func(bookID uint, categoryIDs ...uint) {
+		t.Helper()
+		if err := bookService.SetBookCategories(bookID, categoryIDs); err != nil {
+			t.Fatalf("failed to set categories: %v", err)
+		}
+	}
+
+	assign(smilla.ID, nordic)
+	assign(dragon.ID, nordic, crime)
+	assign(crimeBook.ID, crime, fiction)
+
+	t.Run("test counts", func(t *testing.T) {
+		tree, err := categoryService.GetCategoryTree()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(tree) != 2 || tree[0].ID != fiction || tree[1].ID != poetry {
+			t.Fatalf("expected Fiction and Poetry at the root, got %+v", tree)
+		}
+
+		fictionNode := tree[0]
+		crimeNode := fictionNode.Children[0]
+		nordicNode := crimeNode.Children[0]
+
+		counts := []struct {
+			node		model.CategoryNode
+			wantDirect	int
+			wantTotal	int
+		}{
+			{fictionNode, 1, 3},
+			{crimeNode, 2, 3},
+			{nordicNode, 2, 2},
+			{tree[1], 0, 0},
+		}
+
+		for _, count := range counts {
+			if count.node.BookCount != count.wantDirect || count.node.TotalBookCount != count.wantTotal {
+				t.Errorf("expected %s to hold %d/%d books, got %d/%d", count.node.Name, count.wantDirect, count.wantTotal, count.node.BookCount, count.node.TotalBookCount)
+			}
+		}
+	})
+
+	t.Run("test browse category", func(t *testing.T) {
+		direct, err := bookService.GetBooksByCategory(crime, false, model.PageRequest{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if direct.Total != 2 {
+			t.Errorf("expected 2 books directly in Crime, got %d", direct.Total)
+		}
+
+		all, err := bookService.GetBooksByCategory(fiction, true, model.PageRequest{Sort: "title"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if all.Total != 3 || all.Items[0].Title != crimeBook.Title {
+			t.Errorf("expected the 3 fiction books sorted by title, got %+v", all.Items)
+		}
+		if len(all.Items[0].Categories) != 2 {
+			t.Errorf("expected the categories of %q, got %+v", crimeBook.Title, all.Items[0].Categories)
+		}
+
+		if _, err := bookService.GetBooksByCategory(9999, true, model.PageRequest{}); !errors.Is(err, service.ErrCategoryNotFound) {
+			t.Errorf("expected error %v, got %v", service.ErrCategoryNotFound, err)
+		}
+	})
+
+	t.Run("test unknown category assignment", func(t *testing.T) {
+		if err := bookService.SetBookCategories(smilla.ID, []uint{nordic, 9999}); !errors.Is(err, service.ErrCategoryNotFound) {
+			t.Errorf("expected error %v, got %v", service.ErrCategoryNotFound, err)
+		}
+		if err := bookService.SetBookCategories(9999, []uint{nordic}); !errors.Is(err, service.ErrBookNotFound) {
+			t.Errorf("expected error %v, got %v", service.ErrBookNotFound, err)
+		}
+	})
+
+	t.Run("test move category", func(t *testing.T) {
+		if err := categoryService.UpdateCategory(fiction, model.CategoryUpdate{ParentID: &nordic}); !errors.Is(err, service.ErrCategoryCycle) {
+			t.Fatalf("expected error %v, got %v", service.ErrCategoryCycle, err)
+		}
+
+		if err := categoryService.UpdateCategory(nordic, model.CategoryUpdate{ParentID: uintPtr(0)}); err != nil {
+			t.Fatalf("failed to move category: %v", err)
+		}
+
+		books, err := bookService.GetBooksByCategory(fiction, true, model.PageRequest{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if books.Total != 2 {
+			t.Errorf("expected 2 fiction books after moving Nordic Noir out, got %d", books.Total)
+		}
+	})
+
+	t.Run("test delete category", func(t *testing.T) {
+		if err := categoryService.DeleteCategory(fiction); !errors.Is(err, service.ErrCategoryHasChildren) {
+			t.Fatalf("expected error %v, got %v", service.ErrCategoryHasChildren, err)
+		}
+
+		if err := categoryService.DeleteCategory(nordic); err != nil {
+			t.Fatalf("failed to delete category: %v", err)
+		}
+
+		if err := categoryService.DeleteCategory(nordic); !errors.Is(err, service.ErrCategoryNotFound) {
+			t.Fatalf("expected error %v, got %v", service.ErrCategoryNotFound, err)
+		}
+	})
+}
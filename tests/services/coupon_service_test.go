@@ -0,0 +1,217 @@
+package services
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"errors"
+	"testing"
+	"time"
+)
+
+func createTestCoupon(t *testing.T, couponService service.CouponService, couponRequest model.CouponRequest) uint {
+	t.Helper()
+
+	coupon, err := couponService.CreateCoupon(couponRequest)
+	if err != nil {
+		t.Fatalf("failed to create coupon %q: %v", couponRequest.Code, err)
+	}
+
+	return coupon.ID
+}
+
+
+func TestCreateCoupon(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+	couponService := service.NewCouponService(testDB)
+	book := createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	testCases := []struct {
+		name			string
+		couponRequest	model.CouponRequest
+		wantErr			error
+	}{
+		{"test percent coupon", model.CouponRequest{Code: "spring-10", Kind: model.CouponPercent, PercentOff: 10}, nil},
+		{"test duplicate code", model.CouponRequest{Code: "SPRING-10", Kind: model.CouponPercent, PercentOff: 20}, service.ErrCouponExists},
+		{"test book coupon", model.CouponRequest{Code: "IDIOT", Kind: model.CouponFixed, AmountOff: decimalPtr("2.50"), Scope: model.ScopeBook, BookID: &book.ID}, nil},
+		{"test invalid code", model.CouponRequest{Code: "a b", Kind: model.CouponPercent, PercentOff: 10}, service.ErrInvalidCouponCode},
+		{"test invalid kind", model.CouponRequest{Code: "FREE", Kind: "free"}, service.ErrInvalidCouponKind},
+		{"test percent over 100", model.CouponRequest{Code: "TOO-MUCH", Kind: model.CouponPercent, PercentOff: 120}, service.ErrInvalidPercentOff},
+		{"test fixed without amount", model.CouponRequest{Code: "NOTHING", Kind: model.CouponFixed}, service.ErrInvalidAmountOff},
+		{"test negative min spend", model.CouponRequest{Code: "NEGATIVE", Kind: model.CouponPercent, PercentOff: 5, MinSpend: decimalPtr("-1")}, service.ErrInvalidMinSpend},
+		{"test category coupon without category", model.CouponRequest{Code: "CRIME", Kind: model.CouponPercent, PercentOff: 5, Scope: model.ScopeCategory}, service.ErrCouponTarget},
+		{"test unknown category", model.CouponRequest{Code: "CRIME", Kind: model.CouponPercent, PercentOff: 5, Scope: model.ScopeCategory, CategoryID: uintPtr(9999)}, service.ErrCategoryNotFound},
+		{"test zero usage limit", model.CouponRequest{Code: "NEVER", Kind: model.CouponPercent, PercentOff: 5, MaxUses: intPtr(0)}, service.ErrInvalidCouponLimit},
+		{"test window ends before it starts", model.CouponRequest{Code: "BACKWARDS", Kind: model.CouponPercent, PercentOff: 5, StartsAt: &time.Time{}, EndsAt: &time.Time{}}, service.ErrInvalidCouponWindow},
+		{"test expired coupon can be created", model.CouponRequest{Code: "EXPIRED", Kind: model.CouponPercent, PercentOff: 5, EndsAt: &yesterday}, nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := couponService.CreateCoupon(test.couponRequest); !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("test list coupons", func(t *testing.T) {
+		coupons, err := couponService.GetCoupons()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(coupons) != 3 || coupons[1].Code != "IDIOT" || coupons[1].AmountOff.String() != "2.50" || coupons[2].Code != "SPRING-10" {
+			t.Errorf("expected EXPIRED, IDIOT and SPRING-10, got %+v", coupons)
+		}
+	})
+}
+
+
+func TestCartCoupons(t *testing.T) {
+	testDB, orderService, bookService, cartService := initOrderTestServices(t)
+	couponService := service.NewCouponService(testDB)
+	categoryService := service.NewCategoryService(testDB)
+
+	fiction := createTestCategory(t, categoryService, "Fiction", nil)
+	crime := createTestCategory(t, categoryService, "Crime", &fiction)
+
+	crimeBook := createTestOrderBook(t, testDB, "Crime and Punishment", "Dostoevsky", "30.00")
+	otherBook := createTestOrderBook(t, testDB, "A Writer's Diary", "Dostoevsky", "20.00")
+	if err := bookService.SetBookCategories(crimeBook.ID, []uint{crime}); err != nil {
+		t.Fatalf("failed to set categories: %v", err)
+	}
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	createTestCoupon(t, couponService, model.CouponRequest{Code: "FICTION10", Kind: model.CouponPercent, PercentOff: 10, Scope: model.ScopeCategory, CategoryID: &fiction, Stackable: true})
+	saveID := createTestCoupon(t, couponService, model.CouponRequest{Code: "SAVE5", Kind: model.CouponFixed, AmountOff: decimalPtr("5"), MinSpend: decimalPtr("50"), Stackable: true})
+	createTestCoupon(t, couponService, model.CouponRequest{Code: "HALF", Kind: model.CouponPercent, PercentOff: 50})
+	createTestCoupon(t, couponService, model.CouponRequest{Code: "BIGSPENDER", Kind: model.CouponFixed, AmountOff: decimalPtr("20"), MinSpend: decimalPtr("100"), Stackable: true})
+	createTestCoupon(t, couponService, model.CouponRequest{Code: "LASTYEAR", Kind: model.CouponPercent, PercentOff: 5, EndsAt: &yesterday, Stackable: true})
+	createTestCoupon(t, couponService, model.CouponRequest{Code: "DIARY", Kind: model.CouponFixed, AmountOff: decimalPtr("50"), Scope: model.ScopeBook, BookID: &otherBook.ID})
+	welcomeID := createTestCoupon(t, couponService, model.CouponRequest{Code: "WELCOME", Kind: model.CouponPercent, PercentOff: 15, MaxUsesPerUser: intPtr(1)})
+
+	user := createTestOrderUser(t, testDB, "couponUser", "couponUser@gmail.com")
+	addBookToCart(t, cartService, user.ID, crimeBook.ID, 2)
+	addBookToCart(t, cartService, user.ID, otherBook.ID, 1)
+
+	testCases := []struct {
+		name	string
+		code	string
+		wantErr	error
+	}{
+		{"test apply category coupon", "fiction10", nil},
+		{"test apply twice", "FICTION10", service.ErrCouponApplied},
+		{"test apply unknown code", "NOPE", service.ErrCouponNotFound},
+		{"test coupon that does not stack", "HALF", service.ErrCouponNotStackable},
+		{"test minimum spend not reached", "BIGSPENDER", service.ErrCouponMinSpend},
+		{"test expired coupon", "LASTYEAR", service.ErrCouponNotActive},
+		{"test apply order coupon", "SAVE5", nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if err := cartService.ApplyCoupon(user.ID, test.code); !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("test cart totals", func(t *testing.T) {
+		cart, err := cartService.GetCart(principalOf(user.ID), user.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if cart.Totals == nil || cart.Totals.Subtotal.String() != "80.00" || cart.Totals.Discount.String() != "11.00" || cart.Totals.Total.String() != "69.00" {
+			t.Fatalf("expected 80.00 - 11.00 = 69.00, got %+v", cart.Totals)
+		}
+		if len(cart.Coupons) != 2 || cart.Coupons[0].Discount.String() != "6.00" || cart.Coupons[1].Discount.String() != "5.00" {
+			t.Errorf("expected 6.00 off crime books and 5.00 off the order, got %+v", cart.Coupons)
+		}
+	})
+
+	order, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil)
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if order.Subtotal.String() != "80.00" || order.Discount.String() != "11.00" || order.Total.String() != "69.00" || len(order.Coupons) != 2 {
+		t.Fatalf("expected an order of 69.00 with two coupons, got %+v", order)
+	}
+
+	err = couponService.UpdateCoupon(saveID, model.CouponRequest{Code: "SAVE5", Kind: model.CouponFixed, AmountOff: decimalPtr("8"), MinSpend: decimalPtr("50"), Stackable: true})
+	if err != nil {
+		t.Fatalf("failed to update coupon: %v", err)
+	}
+
+	t.Run("test order keeps the coupon terms", func(t *testing.T) {
+		stored, err := orderService.GetOrder(principalOf(user.ID), order.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if stored.Total.String() != "69.00" || stored.Coupons[1].Code != "SAVE5" || stored.Coupons[1].AmountOff.String() != "5.00" {
+			t.Errorf("expected SAVE5 at 5.00 and a total of 69.00, got %+v", stored)
+		}
+	})
+
+	t.Run("test book coupon never exceeds the book", func(t *testing.T) {
+		addBookToCart(t, cartService, user.ID, otherBook.ID, 1)
+		addBookToCart(t, cartService, user.ID, crimeBook.ID, 1)
+
+		if err := cartService.ApplyCoupon(user.ID, "DIARY"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		cart, err := cartService.GetCart(principalOf(user.ID), user.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if cart.Totals.Discount.String() != "20.00" || cart.Totals.Total.String() != "30.00" {
+			t.Errorf("expected 20.00 off the 20.00 book, got %+v", cart.Totals)
+		}
+	})
+
+	t.Run("test usage limit per user", func(t *testing.T) {
+		if err := cartService.RemoveCoupon(user.ID, "diary"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := cartService.ApplyCoupon(user.ID, "welcome"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		welcomeOrder, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil)
+		if err != nil || welcomeOrder.Total.String() != "42.50" {
+			t.Fatalf("expected an order of 42.50, got %+v, %v", welcomeOrder, err)
+		}
+
+		addBookToCart(t, cartService, user.ID, otherBook.ID, 1)
+		if err := cartService.ApplyCoupon(user.ID, "WELCOME"); !errors.Is(err, service.ErrCouponUserLimit) {
+			t.Fatalf("expected %v, got %v", service.ErrCouponUserLimit, err)
+		}
+
+		if err := orderService.CancelOrder(principalOf(user.ID), welcomeOrder.ID); err != nil {
+			t.Fatalf("failed to cancel order: %v", err)
+		}
+		if err := cartService.ApplyCoupon(user.ID, "WELCOME"); err != nil {
+			t.Errorf("expected the cancelled order to give the use back, got %v", err)
+		}
+	})
+
+	t.Run("test changed coupon blocks checkout", func(t *testing.T) {
+		err := couponService.UpdateCoupon(welcomeID, model.CouponRequest{Code: "WELCOME", Kind: model.CouponPercent, PercentOff: 15, MinSpend: decimalPtr("100")})
+		if err != nil {
+			t.Fatalf("failed to update coupon: %v", err)
+		}
+
+		cart, err := cartService.GetCart(principalOf(user.ID), user.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(cart.Coupons) != 1 || cart.Coupons[0].Error != service.ErrCouponMinSpend.Error() || cart.Totals.Total.String() != "20.00" {
+			t.Errorf("expected WELCOME to give no discount, got %+v, %+v", cart.Coupons, cart.Totals)
+		}
+
+		var couponErr *service.CouponError
+		if _, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil); !errors.As(err, &couponErr) || couponErr.Code != "WELCOME" || !errors.Is(err, service.ErrCouponMinSpend) {
+			t.Errorf("expected WELCOME to block checkout, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/money"
+	"BookVault-API/service"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestExchangeRates(t *testing.T) {
+	testDB, bookService := initBookTestServices(t)
+	rateService := service.NewExchangeRateService(testDB)
+	base := money.DefaultCurrency()
+
+	testCases := []struct {
+		name		string
+		rateRequest	model.ExchangeRateRequest
+		wantErr		error
+	}{
+		{"test missing quote", model.ExchangeRateRequest{Base: base, Rate: decimalPtr("1.1")}, service.ErrEmptyFields},
+		{"test invalid currency", model.ExchangeRateRequest{Quote: "dollar", Rate: decimalPtr("1.1")}, service.ErrInvalidCurrency},
+		{"test same currency", model.ExchangeRateRequest{Quote: base, Rate: decimalPtr("1.1")}, service.ErrSameCurrency},
+		{"test zero rate", model.ExchangeRateRequest{Quote: "USD", Rate: decimalPtr("0")}, service.ErrInvalidRate},
+		{"test set rate", model.ExchangeRateRequest{Quote: "usd", Rate: decimalPtr("1.085")}, nil},
+		{"test replace rate", model.ExchangeRateRequest{Base: base, Quote: "USD", Rate: decimalPtr("1.10")}, nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := rateService.SetRate(test.rateRequest); !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("test invalid import keeps every rate out", func(t *testing.T) {
+		file := "base,quote,rate\n" + base + ",GBP,0.85\n" + base + ",CHF,-1\n"
+
+		_, err := rateService.ImportRates(strings.NewReader(file))
+
+		var importErr *service.RateImportError
+		if !errors.As(err, &importErr) || importErr.Line != 3 {
+			t.Fatalf("expected an error on line 3, got %v", err)
+		}
+	})
+
+	t.Run("test import rates", func(t *testing.T) {
+		report, err := rateService.ImportRates(strings.NewReader("Base,Quote,Rate\n" + base + ",jpy,160.5\n"))
+		if err != nil || report.Imported != 1 {
+			t.Fatalf("expected 1 imported rate, got %+v, %v", report, err)
+		}
+	})
+
+	if err := testDB.Model(&model.ExchangeRate{}).Where("quote = ?", "JPY").Update("updated_at", time.Now().Add(-48*time.Hour)).Error; err != nil {
+		t.Fatalf("failed to age rate: %v", err)
+	}
+
+	t.Run("test list flags stale rates", func(t *testing.T) {
+		rates, err := rateService.GetRates()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(rates) != 2 || rates[0].Quote != "JPY" || !rates[0].Stale || rates[1].Rate != "1.1" || rates[1].Stale {
+			t.Errorf("expected a stale JPY rate and a fresh USD rate of 1.1, got %+v", rates)
+		}
+	})
+
+	createTestBook(t, bookService, testDB, "The Idiot", "Dostoevsky", "20.00")
+	if err := bookService.CreateBook(&model.BookRequest{Title: "Demons", Author: "Dostoevsky", Description: "Test Book", Price: decimalPtr("11.00"), Currency: "USD"}); err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+
+	convertTestCases := []struct {
+		name		string
+		title		string
+		currency	string
+		wantErr		error
+		wantPrice	string
+		wantStale	bool
+	}{
+		{"test no currency", "The Idiot", "", nil, "", false},
+		{"test same currency", "The Idiot", base, nil, "", false},
+		{"test stored rate", "The Idiot", "USD", nil, "22.00", false},
+		{"test stale rate", "The Idiot", "JPY", nil, "3210", true},
+		{"test inverse rate", "Demons", base, nil, "10.00", false},
+		{"test missing rate", "The Idiot", "GBP", service.ErrNoExchangeRate, "", false},
+	}
+
+	for _, test := range convertTestCases {
+		t.Run(test.name, func(t *testing.T) {
+			book, err := bookService.GetByTitle(test.title)
+			if err != nil {
+				t.Fatalf("failed to fetch book: %v", err)
+			}
+
+			err = rateService.ConvertBooks(test.currency, book)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if test.wantPrice == "" {
+				if book.Converted != nil {
+					t.Errorf("expected no conversion, got %+v", book.Converted)
+				}
+				return
+			}
+			if book.Converted == nil || book.Converted.Price.String() != test.wantPrice || book.Converted.RateStale != test.wantStale {
+				t.Errorf("expected %s (stale %v), got %+v", test.wantPrice, test.wantStale, book.Converted)
+			}
+		})
+	}
+
+	t.Run("test price bound converted to other currencies", func(t *testing.T) {
+		books, err := bookService.GetBooks(model.BookFilter{MaxPrice: pricePtr("10.50")}, model.PageRequest{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if books.Total != 1 || books.Items[0].Title != "Demons" {
+			t.Errorf("expected only Demons at 11.00 USD, got %+v", books.Items)
+		}
+	})
+
+	t.Run("test delete rate", func(t *testing.T) {
+		if err := rateService.DeleteRate("jpy", base); !errors.Is(err, service.ErrRateNotFound) {
+			t.Errorf("expected %v, got %v", service.ErrRateNotFound, err)
+		}
+		if err := rateService.DeleteRate(base, "jpy"); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
+
+
+func TestConvertCart(t *testing.T) {
+	testDB, _, _, cartService := initOrderTestServices(t)
+	rateService := service.NewExchangeRateService(testDB)
+	couponService := service.NewCouponService(testDB)
+
+	user := createTestOrderUser(t, testDB, "convertUser", "convertUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "The Possessed", "Dostoevsky", "10.00")
+	addBookToCart(t, cartService, user.ID, book.ID, 3)
+
+	createTestCoupon(t, couponService, model.CouponRequest{Code: "SAVE5", Kind: model.CouponFixed, AmountOff: decimalPtr("5")})
+	if err := cartService.ApplyCoupon(user.ID, "SAVE5"); err != nil {
+		t.Fatalf("failed to apply coupon: %v", err)
+	}
+
+	if _, err := rateService.SetRate(model.ExchangeRateRequest{Quote: "USD", Rate: decimalPtr("1.085")}); err != nil {
+		t.Fatalf("failed to set rate: %v", err)
+	}
+
+	var cart model.Cart
+	if err := testDB.Where("user_id = ?", user.ID).First(&cart).Error; err != nil {
+		t.Fatalf("failed to fetch cart: %v", err)
+	}
+
+	cartResp, err := cartService.GetCart(principalOf(user.ID), cart.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch cart: %v", err)
+	}
+
+	t.Run("test cart without rate", func(t *testing.T) {
+		if err := rateService.ConvertCart("GBP", cartResp); !errors.Is(err, service.ErrNoExchangeRate) {
+			t.Fatalf("expected %v, got %v", service.ErrNoExchangeRate, err)
+		}
+	})
+
+	t.Run("test cart in another currency", func(t *testing.T) {
+		if err := rateService.ConvertCart("USD", cartResp); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		line := cartResp.Books[0]
+		if line.ConvertedUnitPrice == nil || line.ConvertedUnitPrice.Price.String() != "10.85" {
+			t.Errorf("expected a unit price of 10.85 USD, got %+v", line.ConvertedUnitPrice)
+		}
+		if line.ConvertedLineTotal == nil || line.ConvertedLineTotal.Price.String() != "32.55" || line.ConvertedLineTotal.Currency != "USD" {
+			t.Errorf("expected a line total of 32.55 USD, got %+v", line.ConvertedLineTotal)
+		}
+
+		converted := cartResp.Totals.Converted
+		if converted == nil || converted.Currency != "USD" || converted.Rate != "1.085" || converted.Subtotal.String() != "32.55" {
+			t.Fatalf("expected a subtotal of 32.55 USD at 1.085, got %+v", converted)
+		}
+		if converted.Total.Amount != converted.Subtotal.Amount - converted.Discount.Amount || converted.Discount.Amount == 0 {
+			t.Errorf("expected the converted totals to add up, got %+v", converted)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/model"
+	"BookVault-API/money"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
 	"errors"
@@ -28,8 +30,8 @@ func createTestOrderUser(t *testing.T, testDB *gorm.DB, username, email string)
 	return user
 }
 
-func createTestOrderBook(t *testing.T, testDB *gorm.DB, title, author string, price float32) model.Book {
-	book := model.Book{Title: title, Author: author, Price: price}
+func createTestOrderBook(t *testing.T, testDB *gorm.DB, title, author, price string) model.Book {
+	book := model.Book{Title: title, Author: author, Price: priceOf(price), Stock: 100}
 	if err := testDB.Create(&book).Error; err != nil {
 		t.Fatalf("failed to create book: %v", err)
 	}
@@ -43,36 +45,61 @@ func addBookToCart(t *testing.T, cartService service.CartService, userID, bookID
 	}
 }
 
+func advanceOrder(t *testing.T, orderService service.OrderService, orderID uint, statuses ...string) {
+	t.Helper()
+
+	for _, status := range statuses {
+		if err := orderService.UpdateStatus(testAdmin, orderID, status); err != nil {
+			t.Fatalf("failed to move order to %s: %v", status, err)
+		}
+	}
+}
+
 
 func TestCreateOrder(t *testing.T) {
 	testDB, orderService, _, cartService := initOrderTestServices(t)
 
 	user := createTestOrderUser(t, testDB, "orderUser1", "orderUser1@gmail.com")
-	book := createTestOrderBook(t, testDB, "Crime and Punishment", "Dostoevsky", 30)
+	book := createTestOrderBook(t, testDB, "Crime and Punishment", "Dostoevsky", "30")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 2)
 
+	// Summed as floats these lines drift off the exact total.
+	centsUser := createTestOrderUser(t, testDB, "orderUser2", "orderUser2@gmail.com")
+	addBookToCart(t, cartService, centsUser.ID, createTestOrderBook(t, testDB, "Poor Folk", "Dostoevsky", "0.10").ID, 3)
+	addBookToCart(t, cartService, centsUser.ID, createTestOrderBook(t, testDB, "The Double", "Dostoevsky", "19.99").ID, 7)
+
+	mixedUser := createTestOrderUser(t, testDB, "orderUser3", "orderUser3@gmail.com")
+	yenBook := model.Book{Title: "Kokoro", Author: "Soseki", Price: money.New(1500, "JPY"), Stock: 100}
+	if err := testDB.Create(&yenBook).Error; err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+	addBookToCart(t, cartService, mixedUser.ID, book.ID, 1)
+	addBookToCart(t, cartService, mixedUser.ID, yenBook.ID, 1)
+
 	testCases := []struct {
 		name        string
 		userID      uint
 		address     string
 		wantErr     error
-		wantTotal   float32
+		wantTotal   string
 		wantBookQty int
 	}{
-		{"test successfully create order", user.ID, "123 Main St", nil, 60.0, 2},
-		{"test empty cart", 9999, "123 Main St", service.ErrEmptyCart, 0, 0},
+		{"test successfully create order", user.ID, "123 Main St", nil, "60.00", 2},
+		{"test total is exact", centsUser.ID, "123 Main St", nil, "140.23", 3},
+		{"test mixed currencies", mixedUser.ID, "123 Main St", service.ErrMixedCurrencies, "", 0},
+		{"test empty cart", 9999, "123 Main St", service.ErrEmptyCart, "", 0},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			resp, err := orderService.CreateOrder(test.userID, test.address)
+			resp, err := orderService.CreateOrder(principalOf(test.userID), test.userID, test.address, "", nil)
 			if !errors.Is(err, test.wantErr) {
 				t.Fatalf("expected error %v, got %v", test.wantErr, err)
 			}
 			if err == nil {
-				if resp.Total != test.wantTotal {
-					t.Errorf("expected total %.2f, got %.2f", test.wantTotal, resp.Total)
+				if resp.Total.String() != test.wantTotal || resp.Currency != money.DefaultCurrency() {
+					t.Errorf("expected total %s %s, got %s %s", test.wantTotal, money.DefaultCurrency(), resp.Total, resp.Currency)
 				}
 				if len(resp.Books) == 0 || resp.Books[0].Quantity != test.wantBookQty {
 					t.Errorf("expected book quantity %d, got %d", test.wantBookQty, resp.Books[0].Quantity)
@@ -87,30 +114,30 @@ func TestCancelOrder(t *testing.T) {
 	testDB, orderService, _, cartService := initOrderTestServices(t)
 
 	user := createTestOrderUser(t, testDB, "cancelUser", "cancelUser@gmail.com")
-	book := createTestOrderBook(t, testDB, "The Idiot", "Dostoevsky", 20)
+	book := createTestOrderBook(t, testDB, "The Idiot", "Dostoevsky", "20")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	orderResp, _ := orderService.CreateOrder(user.ID, "Addr")
-	orderService.UpdateStatus(orderResp.ID, "completed")
-
-	testCases := []struct {
-		name    string
-		orderID uint
-		wantErr error
-	}{
-		{"test cancel pending order", orderResp.ID, service.ErrOrderCancel},
-		{"test cancel non-existing order", 9999, service.ErrOrderNotFound},
-	}
-
-	for _, test := range testCases {
-		t.Run(test.name, func(t *testing.T) {
-			err := orderService.CancelOrder(test.orderID)
-			if !errors.Is(err, test.wantErr) {
-				t.Errorf("expected error %v, got %v", test.wantErr, err)
-			}
-		})
-	}
+	orderResp, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil)
+	advanceOrder(t, orderService, orderResp.ID, "approved", "shipped")
+
+	t.Run("test cancel shipped order", func(t *testing.T) {
+		err := orderService.CancelOrder(testAdmin, orderResp.ID)
+
+		var transitionErr *service.StatusTransitionError
+		if !errors.As(err, &transitionErr) {
+			t.Fatalf("expected a status transition error, got %v", err)
+		}
+		if transitionErr.From != model.OrderShipped || transitionErr.To != model.OrderCancelled {
+			t.Errorf("expected shipped -> cancelled, got %s -> %s", transitionErr.From, transitionErr.To)
+		}
+	})
+
+	t.Run("test cancel non-existing order", func(t *testing.T) {
+		if err := orderService.CancelOrder(testAdmin, 9999); !errors.Is(err, service.ErrOrderNotFound) {
+			t.Errorf("expected error %v, got %v", service.ErrOrderNotFound, err)
+		}
+	})
 }
 
 
@@ -118,11 +145,11 @@ func TestGetOrder(t *testing.T) {
 	testDB, orderService, _, cartService := initOrderTestServices(t)
 
 	user := createTestOrderUser(t, testDB, "getOrderUser", "getOrderUser@gmail.com")
-	book := createTestOrderBook(t, testDB, "Братя Карамазови", "Фьодор Достоевски", 10)
+	book := createTestOrderBook(t, testDB, "Братя Карамазови", "Фьодор Достоевски", "10")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	orderResp, _ := orderService.CreateOrder(user.ID, "Addr")
+	orderResp, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil)
 
 	testCases := []struct {
 		name      string
@@ -136,7 +163,7 @@ func TestGetOrder(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			resp, err := orderService.GetOrder(test.orderID)
+			resp, err := orderService.GetOrder(testAdmin, test.orderID)
 			if !errors.Is(err, test.wantErr) {
 				t.Errorf("expected error %v, got %v", test.wantErr, err)
 			}
@@ -152,30 +179,82 @@ func TestUpdateStatus(t *testing.T) {
 	testDB, orderService, _, cartService := initOrderTestServices(t)
 
 	user := createTestOrderUser(t, testDB, "updateUser", "updateUser@gmail.com")
-	book := createTestOrderBook(t, testDB, "Бесове", "Фьодор Михайлович Достоевски", 12)
+	book := createTestOrderBook(t, testDB, "Бесове", "Фьодор Михайлович Достоевски", "12")
 
 	addBookToCart(t, cartService, user.ID, book.ID, 1)
 
-	orderResp, _ := orderService.CreateOrder(user.ID, "Addr")
+	orderResp, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil)
 
 	testCases := []struct {
-		name    string
-		orderID uint
-		status  string
-		wantErr error
+		name			string
+		orderID			uint
+		status			string
+		wantErr			error
+		wantTransition	bool
 	}{
-		{"test update status", orderResp.ID, "shipped", nil},
-		{"test update non-existing order", 9999, "shipped", service.ErrOrderNotFound},
+		{"test approve order", orderResp.ID, "approved", nil, false},
+		{"test ship order ignoring case", orderResp.ID, "Shipped", nil, false},
+		{"test move back to pending", orderResp.ID, "pending", nil, true},
+		{"test unknown status", orderResp.ID, "completed", service.ErrInvalidStatus, false},
+		{"test update non-existing order", 9999, "shipped", service.ErrOrderNotFound, false},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			err := orderService.UpdateStatus(test.orderID, test.status)
+			err := orderService.UpdateStatus(testAdmin, test.orderID, test.status)
+
+			var transitionErr *service.StatusTransitionError
+			if test.wantTransition {
+				if !errors.As(err, &transitionErr) {
+					t.Errorf("expected a status transition error, got %v", err)
+				}
+				return
+			}
 			if !errors.Is(err, test.wantErr) {
 				t.Errorf("expected error %v, got %v", test.wantErr, err)
 			}
 		})
 	}
+
+	t.Run("test history records every change", func(t *testing.T) {
+		order, err := orderService.GetOrder(testAdmin, orderResp.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		want := []model.OrderStatus{model.OrderPending, model.OrderApproved, model.OrderShipped}
+		if len(order.History) != len(want) {
+			t.Fatalf("expected %d history entries, got %d", len(want), len(order.History))
+		}
+		for i, change := range order.History {
+			if change.To != want[i] {
+				t.Errorf("expected entry %d to be %s, got %s", i, want[i], change.To)
+			}
+		}
+		if order.History[0].ChangedBy != user.ID {
+			t.Errorf("expected order to be created by %d, got %d", user.ID, order.History[0].ChangedBy)
+		}
+		if order.History[2].From != model.OrderApproved || order.History[2].ChangedBy != testAdmin.UserID {
+			t.Errorf("expected approved -> shipped by %d, got %+v", testAdmin.UserID, order.History[2])
+		}
+	})
+
+	t.Run("test history records the admin who placed an order for the user", func(t *testing.T) {
+		addBookToCart(t, cartService, user.ID, book.ID, 1)
+
+		placed, err := orderService.CreateOrder(testAdmin, user.ID, "Addr", "", nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		order, err := orderService.GetOrder(testAdmin, placed.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(order.History) != 1 || order.History[0].ChangedBy != testAdmin.UserID {
+			t.Errorf("expected the order to be created by %d, got %+v", testAdmin.UserID, order.History)
+		}
+	})
 }
 
 
@@ -185,15 +264,15 @@ func TestGetUserOrders(t *testing.T) {
 	user1 := createTestOrderUser(t, testDB, "user1", "user1@gmail.com")
 	user2 := createTestOrderUser(t, testDB, "user2", "user2@gmail.com")
 
-	bookA := createTestOrderBook(t, testDB, "Book A", "Author A", 10)
-	bookB := createTestOrderBook(t, testDB, "Book B", "Author B", 20)
+	bookA := createTestOrderBook(t, testDB, "Book A", "Author A", "10")
+	bookB := createTestOrderBook(t, testDB, "Book B", "Author B", "20")
 
 	addBookToCart(t, cartService, user1.ID, bookA.ID, 1)
 	addBookToCart(t, cartService, user1.ID, bookB.ID, 2)
-	_, _ = orderService.CreateOrder(user1.ID, "Addr1")
+	_, _ = orderService.CreateOrder(principalOf(user1.ID), user1.ID, "Addr1", "", nil)
 
 	addBookToCart(t, cartService, user2.ID, bookB.ID, 3)
-	_, _ = orderService.CreateOrder(user2.ID, "Addr2")
+	_, _ = orderService.CreateOrder(principalOf(user2.ID), user2.ID, "Addr2", "", nil)
 
 	testCases := []struct {
 		name        string
@@ -209,10 +288,15 @@ func TestGetUserOrders(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			orders, err := orderService.GetUserOrders(test.userID)
+			page, err := orderService.GetUserOrders(test.userID, model.PageRequest{})
 			if !errors.Is(err, test.wantErr) {
-				t.Errorf("expected error %v, got %v", test.wantErr, err)
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
 			}
+			if err != nil {
+				return
+			}
+
+			orders := page.Items
 			if len(orders) != test.wantOrders {
 				t.Errorf("expected %d orders, got %d", test.wantOrders, len(orders))
 			}
@@ -228,16 +312,15 @@ func TestGetOrdersByStatus(t *testing.T) {
 	testDB, orderService, _, cartService := initOrderTestServices(t)
 
 	user := createTestOrderUser(t, testDB, "statusUser", "statusUser@gmail.com")
-	bookA := createTestOrderBook(t, testDB, "Book A", "Author A", 10)
-	bookB := createTestOrderBook(t, testDB, "Book B", "Author B", 20)
+	bookA := createTestOrderBook(t, testDB, "Book A", "Author A", "10")
+	bookB := createTestOrderBook(t, testDB, "Book B", "Author B", "20")
 
 	addBookToCart(t, cartService, user.ID, bookA.ID, 1)
-	order1, _ := orderService.CreateOrder(user.ID, "Addr1")
-	orderService.UpdateStatus(order1.ID, "pending")
+	orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr1", "", nil)
 
 	addBookToCart(t, cartService, user.ID, bookB.ID, 2)
-	order2, _ := orderService.CreateOrder(user.ID, "Addr2")
-	orderService.UpdateStatus(order2.ID, "shipped")
+	order2, _ := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr2", "", nil)
+	advanceOrder(t, orderService, order2.ID, "approved", "shipped")
 
 	testCases := []struct {
 		name        string
@@ -248,15 +331,21 @@ func TestGetOrdersByStatus(t *testing.T) {
 	}{
 		{"test get pending orders", "pending", nil, 1, "Book A"},
 		{"test get shipped orders", "shipped", nil, 1, "Book B"},
-		{"test get non-existing status", "cancelled", nil, 0, ""},
+		{"test get status without orders", "cancelled", nil, 0, ""},
+		{"test get unknown status", "completed", service.ErrInvalidStatus, 0, ""},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			orders, err := orderService.GetOrdersByStatus(test.status)
+			page, err := orderService.GetOrdersByStatus(testAdmin, test.status, model.PageRequest{})
 			if !errors.Is(err, test.wantErr) {
-				t.Errorf("expected error %v, got %v", test.wantErr, err)
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
 			}
+			if err != nil {
+				return
+			}
+
+			orders := page.Items
 			if len(orders) != test.wantCount {
 				t.Errorf("expected %d orders, got %d", test.wantCount, len(orders))
 			}
@@ -265,4 +354,270 @@ func TestGetOrdersByStatus(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestOrderOwnership(t *testing.T) {
+	testDB, orderService, _, cartService := initOrderTestServices(t)
+
+	owner := createTestOrderUser(t, testDB, "ownerUser", "ownerUser@gmail.com")
+	otherUser := createTestOrderUser(t, testDB, "otherUser", "otherUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "White Nights", "Dostoevsky", "8")
+
+	addBookToCart(t, cartService, owner.ID, book.ID, 1)
+
+	orderResp, err := orderService.CreateOrder(principalOf(owner.ID), owner.ID, "Addr", "", nil)
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		principal auth.Principal
+		wantErr   error
+	}{
+		{"test other user is forbidden", principalOf(otherUser.ID), service.ErrForbidden},
+		{"test owner is allowed", principalOf(owner.ID), nil},
+		{"test admin is allowed", testAdmin, nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := orderService.GetOrder(test.principal, orderResp.ID)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	if err := orderService.CancelOrder(principalOf(otherUser.ID), orderResp.ID); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected error %v, got %v", service.ErrForbidden, err)
+	}
+
+	var order model.Order
+	if err := testDB.First(&order, orderResp.ID).Error; err != nil {
+		t.Fatalf("failed to fetch order: %v", err)
+	}
+	if order.Status == "cancelled" {
+		t.Errorf("expected order to stay %s after forbidden cancel", orderResp.Status)
+	}
+}
+
+
+func TestOrderStock(t *testing.T) {
+	testDB, orderService, _, cartService := initOrderTestServices(t)
+
+	user := createTestOrderUser(t, testDB, "stockUser", "stockUser@gmail.com")
+	book := model.Book{Title: "Netochka Nezvanova", Author: "Dostoevsky", Price: priceOf("9"), Stock: 2}
+	if err := testDB.Create(&book).Error; err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+
+	assertStock := func(t *testing.T, want int) {
+		t.Helper()
+
+		var stored model.Book
+		if err := testDB.First(&stored, book.ID).Error; err != nil {
+			t.Fatalf("failed to fetch book: %v", err)
+		}
+		if stored.Stock != want {
+			t.Errorf("expected stock %d, got %d", want, stored.Stock)
+		}
+	}
+
+	addBookToCart(t, cartService, user.ID, book.ID, 3)
+
+	t.Run("test order more copies than in stock", func(t *testing.T) {
+		if _, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil); !errors.Is(err, service.ErrInsufficientStock) {
+			t.Fatalf("expected error %v, got %v", service.ErrInsufficientStock, err)
+		}
+		assertStock(t, 2)
+	})
+
+	if err := cartService.UpdateQuantity(user.ID, book.ID, 2); err != nil {
+		t.Fatalf("failed to update cart quantity: %v", err)
+	}
+
+	var orderID uint
+
+	t.Run("test order takes copies out of stock", func(t *testing.T) {
+		orderResp, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		orderID = orderResp.ID
+		assertStock(t, 0)
+	})
+
+	t.Run("test cancel puts copies back in stock", func(t *testing.T) {
+		if err := orderService.CancelOrder(principalOf(user.ID), orderID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		assertStock(t, 2)
+	})
+}
+
+
+func TestCreateOrderRollsBack(t *testing.T) {
+	testDB, orderService, _, cartService := initOrderTestServices(t)
+
+	user := createTestOrderUser(t, testDB, "rollbackUser", "rollbackUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "The Gambler", "Dostoevsky", "11")
+
+	addBookToCart(t, cartService, user.ID, book.ID, 2)
+
+	errInjected := errors.New("injected failure")
+
+	// Fail when the cart is cleared, after stock was reserved and the order was inserted.
+	err := testDB.Callback().Delete().Before("gorm:delete").Register("test:fail_cart_clear", func(tx *gorm.DB) {
+		if tx.Statement.Table == "cart_books" {
+			tx.AddError(errInjected)
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	if _, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil); !errors.Is(err, errInjected) {
+		t.Fatalf("expected error %v, got %v", errInjected, err)
+	}
+
+	var orders, orderBooks, cartBooks int64
+	testDB.Model(&model.Order{}).Where("user_id = ?", user.ID).Count(&orders)
+	testDB.Model(&model.OrderBook{}).Count(&orderBooks)
+	testDB.Model(&model.CartBook{}).Where("book_id = ?", book.ID).Count(&cartBooks)
+
+	if orders != 0 || orderBooks != 0 {
+		t.Errorf("expected no order to be persisted, got %d orders and %d order books", orders, orderBooks)
+	}
+	if cartBooks != 1 {
+		t.Errorf("expected cart to keep its book, got %d cart books", cartBooks)
+	}
+
+	var stored model.Book
+	if err := testDB.First(&stored, book.ID).Error; err != nil {
+		t.Fatalf("failed to fetch book: %v", err)
+	}
+	if stored.Stock != book.Stock {
+		t.Errorf("expected stock %d, got %d", book.Stock, stored.Stock)
+	}
+}
+
+
+func TestOrderExchangeRate(t *testing.T) {
+	testDB, orderService, _, cartService := initOrderTestServices(t)
+	rateService := service.NewExchangeRateService(testDB)
+
+	setRate := func(quote, rate string) {
+		if _, err := rateService.SetRate(model.ExchangeRateRequest{Quote: quote, Rate: decimalPtr(rate)}); err != nil {
+			t.Fatalf("failed to set rate: %v", err)
+		}
+	}
+	setRate("USD", "1.1")
+
+	user := createTestOrderUser(t, testDB, "rateUser", "rateUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "White Nights", "Dostoevsky", "20")
+	addBookToCart(t, cartService, user.ID, book.ID, 2)
+
+	t.Run("test missing rate keeps the cart", func(t *testing.T) {
+		if _, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "GBP", nil); !errors.Is(err, service.ErrNoExchangeRate) {
+			t.Errorf("expected %v, got %v", service.ErrNoExchangeRate, err)
+		}
+	})
+
+	order, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "USD", nil)
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+	if order.Converted == nil || order.Converted.Price.String() != "44.00" || order.Books[0].Converted.Price.String() != "22.00" {
+		t.Fatalf("expected a total of 44.00 USD, got %+v", order.Converted)
+	}
+
+	setRate("USD", "1.2")
+	setRate("JPY", "160")
+
+	t.Run("test order keeps its rate", func(t *testing.T) {
+		stored, err := orderService.GetOrder(principalOf(user.ID), order.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := rateService.ConvertOrders("USD", stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if stored.Converted == nil || stored.Converted.Price.String() != "44.00" || stored.Converted.Rate != "1.1" {
+			t.Errorf("expected 44.00 USD at 1.1, got %+v", stored.Converted)
+		}
+	})
+
+	t.Run("test other currency uses the current rate", func(t *testing.T) {
+		stored, err := orderService.GetOrder(principalOf(user.ID), order.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := rateService.ConvertOrders("JPY", stored); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if stored.Converted == nil || stored.Converted.Price.String() != "6400" {
+			t.Errorf("expected 6400 JPY, got %+v", stored.Converted)
+		}
+	})
+}
+
+
+func TestCreateOrderExpectedTotal(t *testing.T) {
+	testDB, orderService, bookService, cartService := initOrderTestServices(t)
+
+	user := createTestOrderUser(t, testDB, "totalUser", "totalUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "The Adolescent", "Dostoevsky", "10")
+	addBookToCart(t, cartService, user.ID, book.ID, 2)
+
+	if err := bookService.UpdateBook(book.ID, &model.BookRequest{Price: decimalPtr("11")}); err != nil {
+		t.Fatalf("failed to update price: %v", err)
+	}
+
+	testCases := []struct {
+		name			string
+		expectedTotal	*money.Decimal
+		wantErr			error
+	}{
+		{"test stale total", decimalPtr("20.00"), service.ErrTotalChanged},
+		{"test current total", decimalPtr("22"), nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			order, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", test.expectedTotal)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected %v, got %v", test.wantErr, err)
+			}
+			if err == nil && order.Total.String() != "22.00" {
+				t.Errorf("expected a total of 22.00, got %s", order.Total)
+			}
+		})
+	}
+}
+
+
+func TestCreateOrderQuantityLimit(t *testing.T) {
+	testDB, orderService, bookService, cartService := initOrderTestServices(t)
+
+	user := createTestOrderUser(t, testDB, "limitUser", "limitUser@gmail.com")
+	book := createTestOrderBook(t, testDB, "Poor Folk", "Dostoevsky", "10")
+	addBookToCart(t, cartService, user.ID, book.ID, 3)
+
+	if err := bookService.UpdateBook(book.ID, &model.BookRequest{MaxQuantity: intPtr(2)}); err != nil {
+		t.Fatalf("failed to set max quantity: %v", err)
+	}
+
+	var limitErr *service.QuantityLimitError
+	if _, err := orderService.CreateOrder(principalOf(user.ID), user.ID, "Addr", "", nil); !errors.As(err, &limitErr) {
+		t.Fatalf("expected a quantity limit error, got %v", err)
+	}
+
+	var stock int
+	if err := testDB.Model(&model.Book{}).Where("id = ?", book.ID).Pluck("stock", &stock).Error; err != nil {
+		t.Fatalf("failed to fetch stock: %v", err)
+	}
+	if stock != 100 {
+		t.Errorf("expected the stock to stay at 100, got %d", stock)
+	}
+}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"BookVault-API/auth"
 	"BookVault-API/model"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
 	"errors"
+	"fmt"
+	"math"
 	"testing"
 	"gorm.io/gorm"
 )
@@ -39,14 +42,14 @@ func createTestUserForReview(t *testing.T, testDB *gorm.DB, userService service.
 	return user
 }
 
-func createTestBookForReview(t *testing.T, testDB *gorm.DB, bookService service.BookService, title, author string, price float32) model.Book {
+func createTestBookForReview(t *testing.T, testDB *gorm.DB, bookService service.BookService, title, author, price string) model.Book {
 	t.Helper()
 
 	err := bookService.CreateBook(&model.BookRequest{
 		Title:       title,
 		Author:      author,
 		Description: "Test book",
-		Price:       &price,
+		Price:       decimalPtr(price),
 	})
 	if err != nil {
 		t.Fatalf("failed to create book: %v", err)
@@ -64,7 +67,7 @@ func TestAddReview(t *testing.T) {
 	testDB, reviewService, bookService, userService := initReviewTestServices(t)
 
 	user := createTestUserForReview(t, testDB, userService, "reviewuser", "reviewuser@gmail.com")
-	book := createTestBookForReview(t, testDB, bookService, "Test Book", "Author", 12.50)
+	book := createTestBookForReview(t, testDB, bookService, "Test Book", "Author", "12.50")
 
 	testCases := []struct {
 		name    			string
@@ -74,9 +77,12 @@ func TestAddReview(t *testing.T) {
 		wantErr 			error
 	}{
 		{"test empty text", user.ID, book.ID, model.ReviewRequest{Text: ""}, service.ErrEmptyReview},           
-		{"test book not found", user.ID, 9999, model.ReviewRequest{Text: "Nice"}, service.ErrBookNotFound},
-		{"test user not found", 9999, book.ID, model.ReviewRequest{Text: "Nice"}, service.ErrUserNotFound},
-		{"test success", user.ID, book.ID, model.ReviewRequest{Text: "Loved it!"}, nil},
+		{"test missing rating", user.ID, book.ID, model.ReviewRequest{Text: "Nice"}, service.ErrInvalidRating},
+		{"test rating above five", user.ID, book.ID, model.ReviewRequest{Text: "Nice", Rating: 6}, service.ErrInvalidRating},
+		{"test book not found", user.ID, 9999, model.ReviewRequest{Text: "Nice", Rating: 4}, service.ErrBookNotFound},
+		{"test user not found", 9999, book.ID, model.ReviewRequest{Text: "Nice", Rating: 4}, service.ErrUserNotFound},
+		{"test success", user.ID, book.ID, model.ReviewRequest{Text: "Loved it!", Rating: 4}, nil},
+		{"test second review", user.ID, book.ID, model.ReviewRequest{Text: "Loved it again!", Rating: 5}, service.ErrReviewExists},
 	}
 
 	for _, test := range testCases {
@@ -109,27 +115,32 @@ func TestGetReviewsByBook(t *testing.T) {
 	testDB, reviewService, bookService, userService := initReviewTestServices(t)
 
 	user := createTestUserForReview(t, testDB, userService, "reviewuser", "reviewuser@gmail.com")
-	book := createTestBookForReview(t, testDB, bookService, "Test Book", "Author", 12.50) 
+	book := createTestBookForReview(t, testDB, bookService, "Test Book", "Author", "12.50") 
 	
-	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Great book!"})
+	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Great book!", Rating: 4})
 	if err != nil {
 		t.Fatalf("failed to add review on book %d: %v", book.ID, err)
 	}
 
+	verified, unverified := true, false
+
 	testCases := []struct{
 		name		string
 		bookID		uint
+		filter		model.ReviewFilter
 		wantCount	int
 		wantErr		error
 		wantText	[]string
 	}{
-		{"test valid book with reviews", book.ID, 1, nil, []string{"Great book!"}},
-		{"test book with no reviews", 9999, 0, nil, []string{}},
+		{"test valid book with reviews", book.ID, model.ReviewFilter{}, 1, nil, []string{"Great book!"}},
+		{"test book with no reviews", 9999, model.ReviewFilter{}, 0, nil, []string{}},
+		{"test verified reviews only", book.ID, model.ReviewFilter{Verified: &verified}, 0, nil, []string{}},
+		{"test unverified reviews only", book.ID, model.ReviewFilter{Verified: &unverified}, 1, nil, []string{"Great book!"}},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			reviews, err := reviewService.GetReviewsByBook(test.bookID)
+			page, err := reviewService.GetReviewsByBook(test.bookID, test.filter, model.PageRequest{})
 			if test.wantErr != nil {
 				if !errors.Is(err, test.wantErr) {
 					t.Fatalf("expected error %v, got %v", test.wantErr, err)
@@ -141,6 +152,7 @@ func TestGetReviewsByBook(t *testing.T) {
 				t.Fatalf("expected no error, got %v", err)
 			}
 
+			reviews := page.Items
 			if len(reviews) != test.wantCount {
 				t.Errorf("expected %d reviews, got %d", test.wantCount, len(reviews))
 			}
@@ -167,9 +179,9 @@ func TestGetReviewsByUser(t *testing.T) {
 	testDB, reviewService, bookService, userService := initReviewTestServices(t)
 
 	user := createTestUserForReview(t, testDB, userService, "reviewuser3", "reviewuser3@gmail.com")
-	book := createTestBookForReview(t, testDB, bookService, "Cousin Bette", "Balzac", 25.0)
+	book := createTestBookForReview(t, testDB, bookService, "Cousin Bette", "Balzac", "25.0")
 
-	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Amazing book!"})
+	err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Amazing book!", Rating: 4})
 	if err != nil {
 		t.Fatalf("failed to add review on book: %v", err)
 	}
@@ -188,7 +200,7 @@ func TestGetReviewsByUser(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			reviews, err := reviewService.GetReviewsByUser(test.userID)
+			reviews, err := reviewService.GetReviewsByUser(testAdmin, test.userID)
 			if test.wantErr != nil {
 				if !errors.Is(err, test.wantErr) {
 					t.Fatalf("expected error %v, got %v", test.wantErr, err)
@@ -237,9 +249,9 @@ func TestUpdateReview(t *testing.T) {
 	testDB, reviewService, bookService, userService := initReviewTestServices(t)
 
     user := createTestUserForReview(t, testDB, userService,  "updateUser", "updateUser@gmail.com")
-    book := createTestBookForReview(t, testDB, bookService, "Update Test Book", "Test Author", 30.00)
+    book := createTestBookForReview(t, testDB, bookService, "Update Test Book", "Test Author", "30.00")
 
-    err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Original review"})
+    err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Original review", Rating: 4})
     if err != nil {
         t.Fatalf("failed to add review on book: %v", err)
     }
@@ -250,9 +262,13 @@ func TestUpdateReview(t *testing.T) {
 		bookID			uint
 		reviewRequest	model.ReviewRequest
 		wantErr			error
+		wantText		string
+		wantRating		int
 	}{
-		{"test valid update", user.ID, book.ID, model.ReviewRequest{Text: "Update review text"}, nil},
-		{"test review not found", 9999, book.ID, model.ReviewRequest{Text: "Should not work"}, service.ErrReviewNotFound},
+		{"test valid update", user.ID, book.ID, model.ReviewRequest{Text: "Update review text"}, nil, "Update review text", 4},
+		{"test rating-only update keeps the text", user.ID, book.ID, model.ReviewRequest{Rating: 2}, nil, "Update review text", 2},
+		{"test empty update", user.ID, book.ID, model.ReviewRequest{}, service.ErrEmptyReview, "", 0},
+		{"test review not found", 9999, book.ID, model.ReviewRequest{Text: "Should not work"}, service.ErrReviewNotFound, "", 0},
 	}
 
 	for _, test := range testCases {
@@ -273,8 +289,11 @@ func TestUpdateReview(t *testing.T) {
 					t.Fatalf("failed to fetch review from db: %v", dbErr)
 				}
 
-				if review.Text != test.reviewRequest.Text {
-					t.Errorf("expected review text %q, got %q", test.reviewRequest.Text, review.Text)
+				if review.Text != test.wantText {
+					t.Errorf("expected review text %q, got %q", test.wantText, review.Text)
+				}
+				if review.Rating != test.wantRating {
+					t.Errorf("expected rating %d, got %d", test.wantRating, review.Rating)
 				}
 			}
 		})
@@ -286,9 +305,9 @@ func TestDeleteReviewByID(t *testing.T) {
 	testDB, reviewService, bookService, userService := initReviewTestServices(t)
 
     user := createTestUserForReview(t, testDB, userService,  "DeleteUser", "deleteUser@gmail.com")
-    book := createTestBookForReview(t, testDB, bookService, "Delete Test Book", "Test Author", 30.00)
+    book := createTestBookForReview(t, testDB, bookService, "Delete Test Book", "Test Author", "30.00")
 
-    err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Original review"})
+    err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Original review", Rating: 4})
     if err != nil {
         t.Fatalf("failed to add review on book: %v", err)
     }
@@ -309,7 +328,7 @@ func TestDeleteReviewByID(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			err := reviewService.DeleteReviewByID(test.reviewID)
+			err := reviewService.DeleteReviewByID(testAdmin, test.reviewID)
 
 			if (err == nil) != (test.wantErr == nil) {
 				t.Fatalf("expected error %v, got %v", test.wantErr, err)
@@ -327,4 +346,570 @@ func TestDeleteReviewByID(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestDeleteReviewByIDOwnership(t *testing.T) {
+	testDB, reviewService, bookService, userService := initReviewTestServices(t)
+
+	author := createTestUserForReview(t, testDB, userService, "ReviewAuthor", "reviewAuthor@gmail.com")
+	otherUser := createTestUserForReview(t, testDB, userService, "OtherReader", "otherReader@gmail.com")
+	book := createTestBookForReview(t, testDB, bookService, "Ownership Test Book", "Test Author", "30.00")
+
+	if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: "Mine", Rating: 4}); err != nil {
+		t.Fatalf("failed to add review on book: %v", err)
+	}
+
+	var review model.Review
+	if err := testDB.Where("user_id = ? AND book_id = ?", author.ID, book.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+
+	testCases := []struct{
+		name		string
+		principal	auth.Principal
+		wantErr		error
+	}{
+		{"test other user is forbidden", principalOf(otherUser.ID), service.ErrForbidden},
+		{"test author is allowed", principalOf(author.ID), nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			err := reviewService.DeleteReviewByID(test.principal, review.ID)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+
+func TestSetReview(t *testing.T) {
+	testDB, reviewService, bookService, userService := initReviewTestServices(t)
+
+	user := createTestUserForReview(t, testDB, userService, "setReviewUser", "setReviewUser@gmail.com")
+	book := createTestBookForReview(t, testDB, bookService, "The Idiot", "Dostoevsky", "14")
+
+	testCases := []struct {
+		name			string
+		userID			uint
+		bookID			uint
+		reviewRequest	model.ReviewRequest
+		wantCreated		bool
+		wantErr			error
+	}{
+		{"test empty text", user.ID, book.ID, model.ReviewRequest{Rating: 3}, false, service.ErrEmptyReview},
+		{"test missing rating", user.ID, book.ID, model.ReviewRequest{Text: "Nice"}, false, service.ErrInvalidRating},
+		{"test book not found", user.ID, 9999, model.ReviewRequest{Text: "Nice", Rating: 3}, false, service.ErrBookNotFound},
+		{"test user not found", 9999, book.ID, model.ReviewRequest{Text: "Nice", Rating: 3}, false, service.ErrUserNotFound},
+		{"test creates review", user.ID, book.ID, model.ReviewRequest{Text: "Nice", Rating: 3}, true, nil},
+		{"test replaces review", user.ID, book.ID, model.ReviewRequest{Text: "Even better the second time", Rating: 5}, false, nil},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			created, err := reviewService.SetReview(test.userID, test.bookID, test.reviewRequest)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected error %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected success, got %v", err)
+			}
+			if created != test.wantCreated {
+				t.Errorf("expected created %v, got %v", test.wantCreated, created)
+			}
+
+			var reviews []model.Review
+			if err := testDB.Where("user_id = ? AND book_id = ?", test.userID, test.bookID).Find(&reviews).Error; err != nil {
+				t.Fatalf("failed to fetch reviews: %v", err)
+			}
+			if len(reviews) != 1 || reviews[0].Text != test.reviewRequest.Text || reviews[0].Rating != test.reviewRequest.Rating {
+				t.Fatalf("expected a single review %+v, got %+v", test.reviewRequest, reviews)
+			}
+		})
+	}
+
+	resp, err := bookService.GetByTitle(book.Title)
+	if err != nil {
+		t.Fatalf("failed to fetch book: %v", err)
+	}
+	if resp.Rating.Count != 1 || resp.Rating.Average != 5 {
+		t.Errorf("expected a single 5 star rating, got %+v", resp.Rating)
+	}
+}
+
+
+func TestVerifiedReview(t *testing.T) {
+	testDB, reviewService, _, userService := initReviewTestServices(t)
+	orderService := service.NewOrderService(testDB)
+	cartService := service.NewCartService(testDB)
+	settingsService := service.NewSettingsService(testDB)
+
+	buyer := createTestUserForReview(t, testDB, userService, "verifiedBuyer", "verifiedBuyer@gmail.com")
+	stranger := createTestUserForReview(t, testDB, userService, "verifiedStranger", "verifiedStranger@gmail.com")
+	book := createTestOrderBook(t, testDB, "The Double", "Dostoevsky", "10")
+
+	addBookToCart(t, cartService, buyer.ID, book.ID, 1)
+	order, err := orderService.CreateOrder(principalOf(buyer.ID), buyer.ID, "Address", "", nil)
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+
+	t.Run("test review before delivery is unverified", func(t *testing.T) {
+		if err := reviewService.AddReview(buyer.ID, book.ID, model.ReviewRequest{Text: "Waiting for it", Rating: 3}); err != nil {
+			t.Fatalf("failed to add review: %v", err)
+		}
+
+		reviews, err := reviewService.GetReviewsByUser(principalOf(buyer.ID), buyer.ID)
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if len(reviews) != 1 || reviews[0].Verified {
+			t.Errorf("expected one unverified review, got %+v", reviews)
+		}
+	})
+
+	t.Run("test review after delivery is verified", func(t *testing.T) {
+		advanceOrder(t, orderService, order.ID, "approved", "shipped", "delivered")
+
+		if err := reviewService.UpdateReview(buyer.ID, book.ID, model.ReviewRequest{Text: "Arrived and read it"}); err != nil {
+			t.Fatalf("failed to update review: %v", err)
+		}
+
+		verified := true
+		reviews, err := reviewService.GetReviewsByBook(book.ID, model.ReviewFilter{Verified: &verified}, model.PageRequest{})
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if len(reviews.Items) != 1 || !reviews.Items[0].Verified {
+			t.Errorf("expected one verified review, got %+v", reviews.Items)
+		}
+	})
+
+	t.Run("test unverified review rejected when disallowed", func(t *testing.T) {
+		allow := false
+		if _, err := settingsService.UpdateReviewSettings(model.ReviewSettingsRequest{AllowUnverified: &allow}); err != nil {
+			t.Fatalf("failed to update settings: %v", err)
+		}
+
+		err := reviewService.AddReview(stranger.ID, book.ID, model.ReviewRequest{Text: "Never read it", Rating: 1})
+		if !errors.Is(err, service.ErrUnverifiedReview) {
+			t.Fatalf("expected error %v, got %v", service.ErrUnverifiedReview, err)
+		}
+
+		if _, err := reviewService.SetReview(stranger.ID, book.ID, model.ReviewRequest{Text: "Never read it", Rating: 1}); !errors.Is(err, service.ErrUnverifiedReview) {
+			t.Fatalf("expected error %v, got %v", service.ErrUnverifiedReview, err)
+		}
+	})
+}
+
+
+func TestReviewModeration(t *testing.T) {
+	testDB, reviewService, bookService, userService := initReviewTestServices(t)
+	settingsService := service.NewSettingsService(testDB)
+
+	none, threshold := model.AutoApproveNone, 2
+	if _, err := settingsService.UpdateReviewSettings(model.ReviewSettingsRequest{AutoApprove: &none, ReportThreshold: &threshold}); err != nil {
+		t.Fatalf("failed to update settings: %v", err)
+	}
+
+	author := createTestUserForReview(t, testDB, userService, "moderatedAuthor", "moderatedAuthor@gmail.com")
+	reporter := createTestUserForReview(t, testDB, userService, "moderationReporter", "moderationReporter@gmail.com")
+	otherReporter := createTestUserForReview(t, testDB, userService, "moderationReporter2", "moderationReporter2@gmail.com")
+	book := createTestBookForReview(t, testDB, bookService, "White Nights", "Dostoevsky", "7")
+
+	if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: "Short and lovely", Rating: 5}); err != nil {
+		t.Fatalf("failed to add review: %v", err)
+	}
+
+	var review model.Review
+	if err := testDB.Where("user_id = ? AND book_id = ?", author.ID, book.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+
+	assertVisible := func(t *testing.T, wantVisible bool, wantQueued int) {
+		t.Helper()
+
+		reviews, err := reviewService.GetReviewsByBook(book.ID, model.ReviewFilter{}, model.PageRequest{})
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if (len(reviews.Items) == 1) != wantVisible {
+			t.Errorf("expected review visible %v, got %+v", wantVisible, reviews.Items)
+		}
+
+		resp, err := bookService.GetByTitle(book.Title)
+		if err != nil {
+			t.Fatalf("failed to fetch book: %v", err)
+		}
+		if (resp.Rating.Count == 1) != wantVisible {
+			t.Errorf("expected review rated %v, got %+v", wantVisible, resp.Rating)
+		}
+
+		queue, err := reviewService.GetModerationQueue(model.PageRequest{})
+		if err != nil {
+			t.Fatalf("failed to fetch moderation queue: %v", err)
+		}
+		if len(queue.Items) != wantQueued {
+			t.Errorf("expected %d queued reviews, got %+v", wantQueued, queue.Items)
+		}
+	}
+
+	t.Run("test new review waits for moderation", func(t *testing.T) {
+		assertVisible(t, false, 1)
+	})
+
+	t.Run("test reject without reason", func(t *testing.T) {
+		err := reviewService.RejectReview(testAdmin, review.ID, model.ModerationRequest{Reason: "  "})
+		if !errors.Is(err, service.ErrRejectReasonRequired) {
+			t.Fatalf("expected error %v, got %v", service.ErrRejectReasonRequired, err)
+		}
+	})
+
+	t.Run("test approve", func(t *testing.T) {
+		if err := reviewService.ApproveReview(testAdmin, review.ID, model.ModerationRequest{}); err != nil {
+			t.Fatalf("failed to approve review: %v", err)
+		}
+		assertVisible(t, true, 0)
+
+		if err := reviewService.ApproveReview(testAdmin, review.ID, model.ModerationRequest{}); !errors.Is(err, service.ErrReviewAlreadyModerated) {
+			t.Fatalf("expected error %v, got %v", service.ErrReviewAlreadyModerated, err)
+		}
+	})
+
+	t.Run("test reports send the review back to the queue", func(t *testing.T) {
+		if err := reviewService.ReportReview(principalOf(author.ID), review.ID, model.ReportRequest{}); !errors.Is(err, service.ErrReportOwnReview) {
+			t.Fatalf("expected error %v, got %v", service.ErrReportOwnReview, err)
+		}
+
+		if err := reviewService.ReportReview(principalOf(reporter.ID), review.ID, model.ReportRequest{Reason: "Spoilers"}); err != nil {
+			t.Fatalf("failed to report review: %v", err)
+		}
+		if err := reviewService.ReportReview(principalOf(reporter.ID), review.ID, model.ReportRequest{Reason: "Spoilers"}); !errors.Is(err, service.ErrReviewAlreadyReported) {
+			t.Fatalf("expected error %v, got %v", service.ErrReviewAlreadyReported, err)
+		}
+		assertVisible(t, true, 0)
+
+		if err := reviewService.ReportReview(principalOf(otherReporter.ID), review.ID, model.ReportRequest{Reason: "Offensive"}); err != nil {
+			t.Fatalf("failed to report review: %v", err)
+		}
+		assertVisible(t, false, 1)
+
+		queue, err := reviewService.GetModerationQueue(model.PageRequest{})
+		if err != nil {
+			t.Fatalf("failed to fetch moderation queue: %v", err)
+		}
+		if queue.Items[0].Reports != 2 {
+			t.Errorf("expected 2 reports, got %d", queue.Items[0].Reports)
+		}
+	})
+
+	t.Run("test reject", func(t *testing.T) {
+		if err := reviewService.RejectReview(testAdmin, review.ID, model.ModerationRequest{Reason: "Spoilers"}); err != nil {
+			t.Fatalf("failed to reject review: %v", err)
+		}
+		assertVisible(t, false, 0)
+
+		others, err := reviewService.GetReviewsByUser(principalOf(reporter.ID), author.ID)
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if len(others) != 0 {
+			t.Errorf("expected rejected review to be hidden from other users, got %+v", others)
+		}
+
+		own, err := reviewService.GetReviewsByUser(principalOf(author.ID), author.ID)
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if len(own) != 1 || own[0].Status != model.ReviewRejected {
+			t.Errorf("expected the author to see the rejected review, got %+v", own)
+		}
+	})
+
+	var history []model.ReviewModeration
+	if err := testDB.Where("review_id = ?", review.ID).Order("id").Find(&history).Error; err != nil {
+		t.Fatalf("failed to fetch moderation history: %v", err)
+	}
+
+	want := []model.ReviewStatus{model.ReviewApproved, model.ReviewPending, model.ReviewRejected}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d moderation entries, got %+v", len(want), history)
+	}
+	for i, entry := range history {
+		if entry.ToStatus != want[i] {
+			t.Errorf("expected entry %d to move to %s, got %s", i, want[i], entry.ToStatus)
+		}
+	}
+	if history[1].ModeratedByID != nil {
+		t.Errorf("expected the report threshold entry to have no moderator, got %d", *history[1].ModeratedByID)
+	}
+}
+
+
+func TestEditModeratedReview(t *testing.T) {
+	testDB, reviewService, bookService, userService := initReviewTestServices(t)
+	settingsService := service.NewSettingsService(testDB)
+
+	threshold := 1
+	if _, err := settingsService.UpdateReviewSettings(model.ReviewSettingsRequest{ReportThreshold: &threshold}); err != nil {
+		t.Fatalf("failed to update settings: %v", err)
+	}
+
+	author := createTestUserForReview(t, testDB, userService, "editedAuthor", "editedAuthor@gmail.com")
+	reporter := createTestUserForReview(t, testDB, userService, "editedReporter", "editedReporter@gmail.com")
+	book := createTestBookForReview(t, testDB, bookService, "The Landlady", "Dostoevsky", "6")
+
+	if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: "Strange and haunting", Rating: 4}); err != nil {
+		t.Fatalf("failed to add review: %v", err)
+	}
+
+	var review model.Review
+	if err := testDB.Where("user_id = ? AND book_id = ?", author.ID, book.ID).First(&review).Error; err != nil {
+		t.Fatalf("failed to fetch review: %v", err)
+	}
+
+	assertStatus := func(t *testing.T, want model.ReviewStatus) {
+		t.Helper()
+
+		var current model.Review
+		if err := testDB.First(&current, review.ID).Error; err != nil {
+			t.Fatalf("failed to fetch review: %v", err)
+		}
+		if current.Status != want {
+			t.Errorf("expected status %s, got %s", want, current.Status)
+		}
+	}
+
+	t.Run("test edit keeps an approved review approved", func(t *testing.T) {
+		if err := reviewService.UpdateReview(author.ID, book.ID, model.ReviewRequest{Text: "Strange and haunting!"}); err != nil {
+			t.Fatalf("failed to update review: %v", err)
+		}
+		assertStatus(t, model.ReviewApproved)
+	})
+
+	t.Run("test edit after reaching the report threshold", func(t *testing.T) {
+		if err := reviewService.ReportReview(principalOf(reporter.ID), review.ID, model.ReportRequest{Reason: "Spoilers"}); err != nil {
+			t.Fatalf("failed to report review: %v", err)
+		}
+		assertStatus(t, model.ReviewPending)
+
+		if err := reviewService.UpdateReview(author.ID, book.ID, model.ReviewRequest{Text: "Strange and haunting."}); err != nil {
+			t.Fatalf("failed to update review: %v", err)
+		}
+		assertStatus(t, model.ReviewPending)
+
+		if _, err := reviewService.SetReview(author.ID, book.ID, model.ReviewRequest{Text: "Strange and haunting", Rating: 5}); err != nil {
+			t.Fatalf("failed to replace review: %v", err)
+		}
+		assertStatus(t, model.ReviewPending)
+	})
+
+	t.Run("test edit after a rejection", func(t *testing.T) {
+		if err := reviewService.RejectReview(testAdmin, review.ID, model.ModerationRequest{Reason: "Spoilers"}); err != nil {
+			t.Fatalf("failed to reject review: %v", err)
+		}
+
+		if err := reviewService.UpdateReview(author.ID, book.ID, model.ReviewRequest{Text: "No spoilers now"}); err != nil {
+			t.Fatalf("failed to update review: %v", err)
+		}
+		assertStatus(t, model.ReviewRejected)
+
+		if _, err := reviewService.SetReview(author.ID, book.ID, model.ReviewRequest{Text: "No spoilers at all", Rating: 5}); err != nil {
+			t.Fatalf("failed to replace review: %v", err)
+		}
+		assertStatus(t, model.ReviewRejected)
+
+		reviews, err := reviewService.GetReviewsByBook(book.ID, model.ReviewFilter{}, model.PageRequest{})
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if len(reviews.Items) != 0 {
+			t.Errorf("expected the rejected review to stay hidden, got %+v", reviews.Items)
+		}
+	})
+}
+
+
+func TestReviewVotes(t *testing.T) {
+	testDB, reviewService, bookService, userService := initReviewTestServices(t)
+
+	book := createTestBookForReview(t, testDB, bookService, "The Meek One", "Dostoevsky", "6")
+
+	var reviewIDs []uint
+	for i := 0; i < 3; i++ {
+		author := createTestUserForReview(t, testDB, userService, fmt.Sprintf("voteAuthor%d", i), fmt.Sprintf("voteAuthor%d@gmail.com", i))
+		if err := reviewService.AddReview(author.ID, book.ID, model.ReviewRequest{Text: fmt.Sprintf("Review %d", i), Rating: 4}); err != nil {
+			t.Fatalf("failed to add review: %v", err)
+		}
+
+		var review model.Review
+		if err := testDB.Where("user_id = ?", author.ID).First(&review).Error; err != nil {
+			t.Fatalf("failed to fetch review: %v", err)
+		}
+		reviewIDs = append(reviewIDs, review.ID)
+	}
+
+	var voters []model.User
+	for i := 0; i < 3; i++ {
+		voters = append(voters, createTestUserForReview(t, testDB, userService, fmt.Sprintf("voter%d", i), fmt.Sprintf("voter%d@gmail.com", i)))
+	}
+
+	vote := func(t *testing.T, voter model.User, reviewID uint, helpful bool) {
+		t.Helper()
+		if err := reviewService.VoteReview(principalOf(voter.ID), reviewID, helpful); err != nil {
+			t.Fatalf("failed to vote: %v", err)
+		}
+	}
+
+	t.Run("test vote on own review", func(t *testing.T) {
+		var review model.Review
+		if err := testDB.First(&review, reviewIDs[0]).Error; err != nil {
+			t.Fatalf("failed to fetch review: %v", err)
+		}
+
+		err := reviewService.VoteReview(principalOf(review.UserID), review.ID, true)
+		if !errors.Is(err, service.ErrVoteOwnReview) {
+			t.Fatalf("expected error %v, got %v", service.ErrVoteOwnReview, err)
+		}
+	})
+
+	t.Run("test vote on missing review", func(t *testing.T) {
+		if err := reviewService.VoteReview(principalOf(voters[0].ID), 9999, true); !errors.Is(err, service.ErrReviewNotFound) {
+			t.Fatalf("expected error %v, got %v", service.ErrReviewNotFound, err)
+		}
+	})
+
+	t.Run("test most helpful first", func(t *testing.T) {
+		// Review 1 was found helpful by one voter, review 2 by all three and review 0 splits its votes.
+		vote(t, voters[0], reviewIDs[1], true)
+		for _, voter := range voters {
+			vote(t, voter, reviewIDs[2], true)
+		}
+		vote(t, voters[0], reviewIDs[0], true)
+		vote(t, voters[1], reviewIDs[0], false)
+
+		// Changing a vote replaces it instead of adding a second one.
+		vote(t, voters[1], reviewIDs[0], true)
+		vote(t, voters[1], reviewIDs[0], false)
+
+		reviews, err := reviewService.GetReviewsByBook(book.ID, model.ReviewFilter{}, model.PageRequest{Sort: "helpful"})
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+
+		want := []uint{reviewIDs[2], reviewIDs[1], reviewIDs[0]}
+		for i, review := range reviews.Items {
+			if review.ID != want[i] {
+				t.Fatalf("expected order %v, got %+v", want, reviews.Items)
+			}
+		}
+
+		descending, err := reviewService.GetReviewsByBook(book.ID, model.ReviewFilter{}, model.PageRequest{Sort: "helpful", Desc: true})
+		if err != nil {
+			t.Fatalf("failed to fetch reviews: %v", err)
+		}
+		if len(descending.Items) != 3 || descending.Items[0].ID != reviewIDs[2] {
+			t.Errorf("expected review %d first with -helpful, got %+v", reviewIDs[2], descending.Items)
+		}
+
+		if first := reviews.Items[0]; first.HelpfulVotes != 3 || first.UnhelpfulVotes != 0 {
+			t.Errorf("expected 3 helpful votes, got %+v", first)
+		}
+		if last := reviews.Items[2]; last.HelpfulVotes != 1 || last.UnhelpfulVotes != 1 {
+			t.Errorf("expected 1 helpful and 1 unhelpful vote, got %+v", last)
+		}
+	})
+
+	t.Run("test retract vote", func(t *testing.T) {
+		if err := reviewService.RetractVote(principalOf(voters[1].ID), reviewIDs[0]); err != nil {
+			t.Fatalf("failed to retract vote: %v", err)
+		}
+		if err := reviewService.RetractVote(principalOf(voters[1].ID), reviewIDs[0]); !errors.Is(err, service.ErrVoteNotFound) {
+			t.Fatalf("expected error %v, got %v", service.ErrVoteNotFound, err)
+		}
+
+		var review model.Review
+		if err := testDB.First(&review, reviewIDs[0]).Error; err != nil {
+			t.Fatalf("failed to fetch review: %v", err)
+		}
+		if review.HelpfulVotes != 1 || review.UnhelpfulVotes != 0 {
+			t.Errorf("expected only the helpful vote to remain, got %d/%d", review.HelpfulVotes, review.UnhelpfulVotes)
+		}
+	})
+}
+
+
+func TestBookRating(t *testing.T) {
+	testDB, reviewService, bookService, userService := initReviewTestServices(t)
+
+	book := createTestBookForReview(t, testDB, bookService, "The Brothers Karamazov", "Dostoevsky", "18")
+	otherBook := createTestBookForReview(t, testDB, bookService, "Notes from Underground", "Dostoevsky", "9")
+
+	var users []model.User
+	for i, rating := range []int{5, 4, 4} {
+		user := createTestUserForReview(t, testDB, userService, fmt.Sprintf("ratingUser%d", i), fmt.Sprintf("ratingUser%d@gmail.com", i))
+		if err := reviewService.AddReview(user.ID, book.ID, model.ReviewRequest{Text: "Review", Rating: rating}); err != nil {
+			t.Fatalf("failed to add review: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := reviewService.AddReview(users[0].ID, otherBook.ID, model.ReviewRequest{Text: "Review", Rating: 2}); err != nil {
+		t.Fatalf("failed to add review: %v", err)
+	}
+
+	assertRating := func(t *testing.T, wantAverage float64, wantCount int, wantHistogram [5]int) {
+		t.Helper()
+
+		resp, err := bookService.GetByTitle(book.Title)
+		if err != nil {
+			t.Fatalf("failed to fetch book: %v", err)
+		}
+		if math.Abs(resp.Rating.Average-wantAverage) > 1e-9 || resp.Rating.Count != wantCount || resp.Rating.Histogram != wantHistogram {
+			t.Errorf("expected average %.2f from %d ratings %v, got %+v", wantAverage, wantCount, wantHistogram, resp.Rating)
+		}
+	}
+
+	t.Run("test ratings are aggregated", func(t *testing.T) {
+		assertRating(t, 13.0/3, 3, [5]int{0, 0, 0, 2, 1})
+	})
+
+	t.Run("test updated rating is recomputed", func(t *testing.T) {
+		if err := reviewService.UpdateReview(users[1].ID, book.ID, model.ReviewRequest{Text: "Changed my mind", Rating: 1}); err != nil {
+			t.Fatalf("failed to update review: %v", err)
+		}
+		assertRating(t, 10.0/3, 3, [5]int{1, 0, 0, 1, 1})
+	})
+
+	t.Run("test text update keeps the rating", func(t *testing.T) {
+		if err := reviewService.UpdateReview(users[1].ID, book.ID, model.ReviewRequest{Text: "Changed it again"}); err != nil {
+			t.Fatalf("failed to update review: %v", err)
+		}
+		assertRating(t, 10.0/3, 3, [5]int{1, 0, 0, 1, 1})
+	})
+
+	t.Run("test deleted review is left out", func(t *testing.T) {
+		var review model.Review
+		if err := testDB.Where("user_id = ? AND book_id = ?", users[0].ID, book.ID).First(&review).Error; err != nil {
+			t.Fatalf("failed to fetch review: %v", err)
+		}
+		if err := reviewService.DeleteReviewByID(testAdmin, review.ID); err != nil {
+			t.Fatalf("failed to delete review: %v", err)
+		}
+		assertRating(t, 2.5, 2, [5]int{1, 0, 0, 1, 0})
+	})
+
+	t.Run("test books sort by rating", func(t *testing.T) {
+		books, err := bookService.GetBooks(model.BookFilter{}, model.PageRequest{Sort: "rating", Desc: true})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(books.Items) != 2 || books.Items[0].ID != book.ID || books.Items[1].ID != otherBook.ID {
+			t.Errorf("expected %q before %q, got %+v", book.Title, otherBook.Title, books.Items)
+		}
+	})
+}
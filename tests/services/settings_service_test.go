@@ -0,0 +1,83 @@
+package services
+
+import (
+	"BookVault-API/model"
+	"BookVault-API/service"
+	"BookVault-API/tests/db"
+	"errors"
+	"testing"
+)
+
+
+func TestReviewSettings(t *testing.T) {
+	settingsService := service.NewSettingsService(db.SetupTestDB(t))
+
+	allow, disallow := true, false
+
+	testCases := []struct {
+		name				string
+		settingsRequest		model.ReviewSettingsRequest
+		wantAllowUnverified	bool
+	}{
+		{"test defaults", model.ReviewSettingsRequest{}, true},
+		{"test disallow unverified reviews", model.ReviewSettingsRequest{AllowUnverified: &disallow}, false},
+		{"test omitted option is kept", model.ReviewSettingsRequest{}, false},
+		{"test allow unverified reviews", model.ReviewSettingsRequest{AllowUnverified: &allow}, true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := settingsService.UpdateReviewSettings(test.settingsRequest); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			settings, err := settingsService.GetReviewSettings()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if settings.AllowUnverified != test.wantAllowUnverified {
+				t.Errorf("expected allow_unverified %v, got %v", test.wantAllowUnverified, settings.AllowUnverified)
+			}
+		})
+	}
+}
+
+
+func TestModerationSettings(t *testing.T) {
+	settingsService := service.NewSettingsService(db.SetupTestDB(t))
+
+	verified, unknown := model.AutoApproveVerified, model.AutoApprovePolicy("sometimes")
+	threshold, zero := 5, 0
+
+	testCases := []struct {
+		name				string
+		settingsRequest		model.ReviewSettingsRequest
+		wantErr				error
+		wantAutoApprove		model.AutoApprovePolicy
+		wantThreshold		int
+	}{
+		{"test defaults", model.ReviewSettingsRequest{}, nil, model.AutoApproveAll, 3},
+		{"test unknown policy", model.ReviewSettingsRequest{AutoApprove: &unknown}, service.ErrInvalidAutoApprove, "", 0},
+		{"test zero threshold", model.ReviewSettingsRequest{ReportThreshold: &zero}, service.ErrInvalidReportThreshold, "", 0},
+		{"test update", model.ReviewSettingsRequest{AutoApprove: &verified, ReportThreshold: &threshold}, nil, model.AutoApproveVerified, 5},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			settings, err := settingsService.UpdateReviewSettings(test.settingsRequest)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected error %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if settings.AutoApprove != test.wantAutoApprove || settings.ReportThreshold != test.wantThreshold {
+				t.Errorf("expected %s and threshold %d, got %+v", test.wantAutoApprove, test.wantThreshold, settings)
+			}
+		})
+	}
+}
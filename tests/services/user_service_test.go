@@ -1,6 +1,8 @@
 package services
 
 import (
+	"BookVault-API/auth"
+	"BookVault-API/jwt"
 	"BookVault-API/model"
 	"BookVault-API/service"
 	"BookVault-API/tests/db"
@@ -96,8 +98,8 @@ func TestLogin(t *testing.T) {
 			if err != nil {
 				t.Errorf("expected success, got %v", err)
 			}
-			if test.valid && token == "" {
-				t.Errorf("expected non-empty token")
+			if test.valid && (token == nil || token.Token == "" || token.RefreshToken == "") {
+				t.Errorf("expected non-empty access and refresh tokens")
 			}
 		})
 	}
@@ -176,4 +178,127 @@ func TestGetUserByID(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func loginTestUser(t *testing.T, userService service.UserService, username string) *model.TokenResponse {
+	t.Helper()
+
+	token, err := userService.Login(&model.LoginRequest{Username: username, Password: "1234"})
+	if err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	return token
+}
+
+
+func TestRefresh(t *testing.T) {
+	_, userService := initUserTestServices(t)
+
+	user := createTestUser(t, "refreshUser", "refreshUser@gmail.com")
+	login := loginTestUser(t, userService, user.Username)
+
+	rotated, err := userService.Refresh(login.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected refresh to succeed, got %v", err)
+	}
+	if rotated.RefreshToken == login.RefreshToken {
+		t.Errorf("expected a new refresh token after rotation")
+	}
+
+	testCases := []struct{
+		testName		string
+		refreshToken	string
+		wantErr			error
+	}{
+		{"test empty token", "", service.ErrEmptyFields},
+		{"test unknown token", "not-a-real-token", service.ErrInvalidRefreshToken},
+		{"test reuse of rotated token", login.RefreshToken, service.ErrRefreshTokenReused},
+		{"test family revoked after reuse", rotated.RefreshToken, service.ErrRefreshTokenReused},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			_, err := userService.Refresh(test.refreshToken)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+
+func TestLogout(t *testing.T) {
+	_, userService := initUserTestServices(t)
+
+	jwt.SetRevocationChecker(userService.IsTokenRevoked)
+	t.Cleanup(func() { jwt.SetRevocationChecker(nil) })
+
+	user := createTestUser(t, "logoutUser", "logoutUser@gmail.com")
+	login := loginTestUser(t, userService, user.Username)
+
+	claims, err := jwt.ParseToken(login.Token)
+	if err != nil {
+		t.Fatalf("expected valid access token, got %v", err)
+	}
+
+	expiresAt, _ := claims.GetExpirationTime()
+	principal := auth.Principal{UserID: user.ID, Username: user.Username, Role: user.Role, TokenID: claims["jti"].(string), ExpiresAt: expiresAt.Time}
+
+	if err := userService.Logout(principal, login.RefreshToken); err != nil {
+		t.Fatalf("expected logout to succeed, got %v", err)
+	}
+
+	if _, err := jwt.ParseToken(login.Token); !errors.Is(err, jwt.ErrTokenRevoked) {
+		t.Errorf("expected %v, got %v", jwt.ErrTokenRevoked, err)
+	}
+
+	if _, err := userService.Refresh(login.RefreshToken); !errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Errorf("expected %v, got %v", service.ErrRefreshTokenReused, err)
+	}
+}
+
+
+func TestRefreshDeletedUser(t *testing.T) {
+	testDB, userService := initUserTestServices(t)
+
+	user := createTestUser(t, "deletedRefreshUser", "deletedRefreshUser@gmail.com")
+	login := loginTestUser(t, userService, user.Username)
+
+	if err := testDB.Delete(&model.User{}, user.ID).Error; err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+
+	if _, err := userService.Refresh(login.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected %v, got %v", service.ErrInvalidRefreshToken, err)
+	}
+}
+
+
+func TestDeleteUser(t *testing.T) {
+	testDB, userService := initUserTestServices(t)
+
+	user := createTestUser(t, "deleteUser", "deleteUser@gmail.com")
+	login := loginTestUser(t, userService, user.Username)
+	loginTestUser(t, userService, user.Username)
+
+	if err := userService.DeleteUser(user.ID); err != nil {
+		t.Fatalf("expected delete to succeed, got %v", err)
+	}
+
+	var active int64
+	if err := testDB.Model(&model.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&active).Error; err != nil {
+		t.Fatalf("failed to count refresh tokens: %v", err)
+	}
+	if active != 0 {
+		t.Errorf("expected every refresh token family to be revoked, %d still active", active)
+	}
+
+	if _, err := userService.Refresh(login.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("expected %v, got %v", service.ErrInvalidRefreshToken, err)
+	}
+
+	if err := userService.DeleteUser(user.ID); !errors.Is(err, service.ErrUserNotFound) {
+		t.Errorf("expected %v, got %v", service.ErrUserNotFound, err)
+	}
+}